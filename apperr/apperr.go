@@ -0,0 +1,68 @@
+// Classify the errors reported to clients, so each API can answer them the way it should,
+// e.g. with a 404 for something that does not exist. Errors that are not classified are
+// failures of the server and their messages should not be shown.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of error, checked with errors.Is.
+var (
+	// Something requested does not exist
+	ErrNotFound = errors.New("not found")
+	// The current user may not do what was asked
+	ErrForbidden = errors.New("forbidden")
+	// What was asked clashes with the data as it is, e.g. deleting something still in use
+	ErrConflict = errors.New("conflict")
+	// The request itself is wrong, e.g. a field out of range
+	ErrInvalid = errors.New("invalid")
+)
+
+// An error of one kind with its own message.
+type kindError struct {
+	kind    error
+	message string
+}
+
+func (e *kindError) Error() string {
+	return e.message
+}
+
+func (e *kindError) Unwrap() error {
+	return e.kind
+}
+
+// Create an error of a kind, with the message formatted as fmt.Sprintf does.
+//
+// Parameters:
+//   - kind: One of the kinds above
+//   - format: Format of the message
+//   - args: Values for the format
+//
+// Returns:
+//   - The error
+func New(kind error, format string, args ...interface{}) error {
+	return &kindError{kind: kind, message: fmt.Sprintf(format, args...)}
+}
+
+// Create an error for something that does not exist.
+func NotFound(format string, args ...interface{}) error {
+	return New(ErrNotFound, format, args...)
+}
+
+// Create an error for something the current user may not do.
+func Forbidden(format string, args ...interface{}) error {
+	return New(ErrForbidden, format, args...)
+}
+
+// Create an error for a request that clashes with the data as it is.
+func Conflict(format string, args ...interface{}) error {
+	return New(ErrConflict, format, args...)
+}
+
+// Create an error for a request that is wrong in itself.
+func Invalid(format string, args ...interface{}) error {
+	return New(ErrInvalid, format, args...)
+}
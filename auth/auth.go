@@ -0,0 +1,53 @@
+// Track the authenticated user for a request.
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+type contextKey struct{}
+
+// Returned when a request that needs a user has none.
+var ErrUnauthenticated = errors.New("authentication required")
+
+// Attach an authenticated user to a context.
+//
+// Parameters:
+//   - ctx: Context to extend
+//   - user: Authenticated user for the request
+//
+// Returns:
+//   - Copy of ctx carrying the user
+func WithUser(ctx context.Context, user *model.User) context.Context {
+	return context.WithValue(ctx, contextKey{}, user)
+}
+
+// Get the authenticated user from a context.
+//
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - Authenticated user, or nil for anonymous requests
+func ForContext(ctx context.Context) *model.User {
+	user, _ := ctx.Value(contextKey{}).(*model.User)
+	return user
+}
+
+// Get the authenticated user from a context, failing for anonymous requests.
+//
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - Authenticated user
+func RequireUser(ctx context.Context) (*model.User, error) {
+	user := ForContext(ctx)
+	if user == nil {
+		return nil, ErrUnauthenticated
+	}
+	return user, nil
+}
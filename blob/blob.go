@@ -0,0 +1,43 @@
+// Store binary objects, such as uploaded images, behind a pluggable interface.
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+)
+
+// Returned when no object is stored under a key.
+var ErrNotFound = errors.New("blob not found")
+
+// Keys are slash separated paths of simple names, e.g. "images/ab12/thumbnail".
+var validKey = regexp.MustCompile(`^[A-Za-z0-9_-]+(/[A-Za-z0-9_-][A-Za-z0-9_.-]*)*$`)
+
+// Check whether a key is safe to store an object under.
+//
+// Parameters:
+//   - key: Key to check
+//
+// Returns:
+//   - Whether the key is valid
+func ValidKey(key string) bool {
+	return validKey.MatchString(key)
+}
+
+// Details of a stored object.
+type Info struct {
+	ContentType string
+	Size        int64
+}
+
+// Storage backend for binary objects.
+type Store interface {
+	// Store an object, replacing any object already under the key.
+	Put(ctx context.Context, key string, contentType string, r io.Reader) error
+	// Open a stored object; the caller must close the reader.
+	// Returns ErrNotFound when nothing is stored under the key.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Info, error)
+	// Remove an object. Removing a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
@@ -0,0 +1,143 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Suffix of the sidecar file holding an object's content type.
+const contentTypeSuffix = ".type"
+
+// Store keeping objects as files under a directory on local disk.
+type LocalStore struct {
+	root string
+}
+
+// Create a store rooted at a directory, creating the directory if needed.
+//
+// Parameters:
+//   - root: Directory to keep objects in
+//
+// Returns:
+//   - The store
+func NewLocalStore(root string) (*LocalStore, error) {
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob directory; error: %v", err)
+	}
+	return &LocalStore{root: root}, nil
+}
+
+// Resolve the file path of a key.
+func (s *LocalStore) path(key string) (string, error) {
+	if !ValidKey(key) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Store an object, writing to a temporary file first so readers never see partial objects.
+//
+// Parameters:
+//   - ctx: Request context
+//   - key: Key to store the object under
+//   - contentType: MIME type of the object
+//   - r: Contents of the object
+//
+// Returns:
+//   - Error if the object could not be stored
+func (s *LocalStore) Put(ctx context.Context, key string, contentType string, r io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create blob directory; error: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("failed to create blob file; error: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write blob; error: %v", err)
+	}
+
+	err = os.WriteFile(path+contentTypeSuffix, []byte(contentType), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write blob content type; error: %v", err)
+	}
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return fmt.Errorf("failed to store blob; error: %v", err)
+	}
+	return nil
+}
+
+// Open a stored object.
+//
+// Parameters:
+//   - ctx: Request context
+//   - key: Key the object is stored under
+//
+// Returns:
+//   - Tuple of the object's contents and its details
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, *Info, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open blob; error: %v", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to read blob details; error: %v", err)
+	}
+
+	info := Info{ContentType: "application/octet-stream", Size: stat.Size()}
+	contentType, err := os.ReadFile(path + contentTypeSuffix)
+	if err == nil {
+		info.ContentType = string(contentType)
+	}
+	return file, &info, nil
+}
+
+// Remove a stored object.
+//
+// Parameters:
+//   - ctx: Request context
+//   - key: Key the object is stored under
+//
+// Returns:
+//   - Error if the object could not be removed
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{path, path + contentTypeSuffix} {
+		err := os.Remove(name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove blob; error: %v", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,610 @@
+// Command line subcommands, run in place of the server
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/fdc"
+	"github.com/zldobbs/ambrosia-server/graph"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/importer"
+)
+
+// A subcommand, e.g. `./ambrosia-server import-fdc <path>`
+type command struct {
+	// Arguments and description shown in the usage message
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]command{
+	"import-fdc": {
+		usage: "import-fdc <path>\n\tImport reference foods from a FoodData Central CSV directory or JSON file",
+		run:   importFDCCommand,
+	},
+	"import-markdown": {
+		usage: "import-markdown -user <name> [-household <id>] <path>...\n\tImport recipes from Markdown files, or directories of them",
+		run:   importRecipesCommand("import-markdown", markdownFiles),
+	},
+	"export-markdown": {
+		usage: "export-markdown [-user <name>] [-out <dir>] [<recipe id>...]\n\tExport recipes as Markdown, to stdout or one file per recipe in a directory",
+		run:   exportRecipesCommand("export-markdown", markdownFiles),
+	},
+	"import-cooklang": {
+		usage: "import-cooklang -user <name> [-household <id>] <path>...\n\tImport recipes from Cooklang .cook files, or directories of them",
+		run:   importRecipesCommand("import-cooklang", cooklangFiles),
+	},
+	"import-archive": {
+		usage: "import-archive -user <name> [-household <id>] <path>...\n\tImport every recipe in MealMaster files or Paprika exports",
+		run:   importArchiveCommand,
+	},
+	"export-pdf": {
+		usage: "export-pdf -out <file> [-user <name>] [-title <title>] [<recipe id>...]\n\tPrint recipes to a PDF, with a contents page when there is more than one",
+		run:   exportBookCommand("export-pdf", exporter.Cookbook),
+	},
+	"export-epub": {
+		usage: "export-epub -out <file> [-user <name>] [-title <title>] [<recipe id>...]\n\tWrite recipes to an EPUB book with a contents page and an ingredient index",
+		run:   exportBookCommand("export-epub", exporter.EPUB),
+	},
+	"import-csv": {
+		usage: "import-csv -user <name> [-household <id>] [-dry-run] <ingredients|recipe-lines> <file>\n\tAdd and update ingredients or recipe ingredient lines by name from a CSV file",
+		run:   importCSVCommand,
+	},
+	"export-csv": {
+		usage: "export-csv -user <name> [-household <id>] [-out <file>] <ingredients|recipe-lines>\n\tExport ingredients or recipe ingredient lines as CSV, to stdout or a file",
+		run:   exportCSVCommand,
+	},
+	"export-cooklang": {
+		usage: "export-cooklang [-user <name>] [-out <dir>] [<recipe id>...]\n\tExport recipes as Cooklang, to stdout or one file per recipe in a directory",
+		run:   exportRecipesCommand("export-cooklang", cooklangFiles),
+	},
+}
+
+// Print the available subcommands.
+func printUsage() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(os.Stderr, "Usage: ambrosia-server [command]")
+	fmt.Fprintln(os.Stderr, "Runs the server when no command is given. Commands:")
+	for _, name := range names {
+		fmt.Fprintln(os.Stderr, "  "+commands[name].usage)
+	}
+}
+
+// Run a subcommand and exit.
+//
+// Parameters:
+//   - name: Name of the subcommand
+//   - args: Remaining command line arguments
+func runCommand(name string, args []string) {
+	cmd, ok := commands[name]
+	if !ok {
+		printUsage()
+		os.Exit(2)
+	}
+	if err := cmd.run(args); err != nil {
+		log.Fatalf("%s failed: %v", name, err)
+	}
+}
+
+// Import reference foods from a FoodData Central download.
+//
+// Parameters:
+//   - args: Command line arguments; expects the path to the download
+//
+// Returns:
+//   - Error if the import failed
+func importFDCCommand(args []string) error {
+	flags := flag.NewFlagSet("import-fdc", flag.ExitOnError)
+	batchSize := flags.Int("batch", 1000, "number of foods to write at a time")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected the path to a FoodData Central download")
+	}
+
+	pool := db.InitDB()
+	defer pool.Close()
+	ctx := context.Background()
+
+	imported := 0
+	batch := []*model.Food{}
+	flush := func() error {
+		if err := db.UpsertFoods(pool, ctx, batch); err != nil {
+			return err
+		}
+		imported += len(batch)
+		batch = batch[:0]
+		log.Printf("Imported %d foods", imported)
+		return nil
+	}
+
+	err := fdc.Read(flags.Arg(0), func(food *model.Food) error {
+		batch = append(batch, food)
+		if len(batch) >= *batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(batch) > 0 {
+		return flush()
+	}
+	return nil
+}
+
+// A recipe file format handled by the import and export commands.
+type recipeFiles struct {
+	// File extension, including the dot
+	extension string
+	read      func(text string) (*importer.Recipe, error)
+	write     func(doc *exporter.Document) ([]byte, error)
+}
+
+var (
+	markdownFiles = recipeFiles{".md", importer.FromMarkdown, exporter.Markdown}
+	cooklangFiles = recipeFiles{".cook", importer.FromCooklang, exporter.Cooklang}
+)
+
+// Build a command importing recipe files as a user.
+//
+// Parameters:
+//   - name: Name of the command
+//   - format: Format of the files
+//
+// Returns:
+//   - Command taking the user to import as and the files or directories to import;
+//     files that fail are reported and skipped
+func importRecipesCommand(name string, format recipeFiles) func(args []string) error {
+	return func(args []string) error {
+		return importRecipes(name, format, args)
+	}
+}
+
+// Import recipe files as a user.
+//
+// Parameters:
+//   - name: Name of the command
+//   - format: Format of the files
+//   - args: Command line arguments
+//
+// Returns:
+//   - Error if the import could not run
+func importRecipes(name string, format recipeFiles, args []string) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	userName := flags.String("user", "", "name of the user to import recipes as")
+	householdID := flags.String("household", "", "household to place the recipes in")
+	flags.Parse(args)
+	if *userName == "" || flags.NArg() == 0 {
+		return fmt.Errorf("expected -user and at least one path")
+	}
+
+	paths := []string{}
+	for _, root := range flags.Args() {
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			// Files named directly are imported whatever their extension
+			if !entry.IsDir() && (path == root || strings.EqualFold(filepath.Ext(path), format.extension)) {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	pool := db.InitDB()
+	defer pool.Close()
+	user, err := db.GetUserByName(pool, *userName, context.Background())
+	if err != nil {
+		return err
+	}
+	ctx := auth.WithUser(context.Background(), user)
+	resolver := graph.NewResolver(pool, nil, os.Getenv("AMBROSIA_PUBLIC_URL"))
+	var household *string
+	if *householdID != "" {
+		household = householdID
+	}
+
+	failed := 0
+	for _, path := range paths {
+		result, err := importRecipeFile(resolver, ctx, household, format, path)
+		if err != nil {
+			failed++
+			log.Printf("%s: failed: %v", path, err)
+			continue
+		}
+		log.Printf("%s: imported recipe %s %q", path, result.Recipe.RecipeID, result.Recipe.Name)
+		for _, warning := range result.Warnings {
+			log.Printf("%s: warning: %s", path, warning)
+		}
+	}
+	log.Printf("Imported %d of %d files", len(paths)-failed, len(paths))
+	return nil
+}
+
+// Import a single recipe file.
+//
+// Parameters:
+//   - resolver: Resolver to save the recipe through
+//   - ctx: Context carrying the importing user
+//   - householdID: Household to place the recipe in, if any
+//   - format: Format of the file
+//   - path: File to import
+//
+// Returns:
+//   - The saved recipe with what was created along the way
+func importRecipeFile(resolver *graph.Resolver, ctx context.Context, householdID *string, format recipeFiles, path string) (*model.RecipeImport, error) {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := format.read(string(text))
+	if err != nil {
+		return nil, err
+	}
+	return resolver.SaveImport(ctx, householdID, imported)
+}
+
+// Build a command exporting recipes to files, either chosen by ID or all of a user's recipes.
+//
+// Parameters:
+//   - name: Name of the command
+//   - format: Format to write
+//
+// Returns:
+//   - Command taking recipe IDs or a user, and optionally a directory to write to
+func exportRecipesCommand(name string, format recipeFiles) func(args []string) error {
+	return func(args []string) error {
+		return exportRecipes(name, format, args)
+	}
+}
+
+// Export recipes to files.
+//
+// Parameters:
+//   - name: Name of the command
+//   - format: Format to write
+//   - args: Command line arguments
+//
+// Returns:
+//   - Error if the export failed
+func exportRecipes(name string, format recipeFiles, args []string) error {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	userName := flags.String("user", "", "export all recipes created by this user")
+	out := flags.String("out", "", "directory to write one file per recipe to, named by recipe ID")
+	flags.Parse(args)
+
+	pool := db.InitDB()
+	defer pool.Close()
+	ctx := context.Background()
+	resolver := graph.NewResolver(pool, nil, os.Getenv("AMBROSIA_PUBLIC_URL"))
+
+	recipes := []*model.Recipe{}
+	if *userName != "" {
+		user, err := db.GetUserByName(pool, *userName, ctx)
+		if err != nil {
+			return err
+		}
+		owned, err := db.GetRecipes(pool, ctx, map[string]interface{}{"r.user_id": user.UserID})
+		if err != nil {
+			return err
+		}
+		recipes = append(recipes, owned...)
+	}
+	for _, recipeID := range flags.Args() {
+		recipe, err := db.GetRecipeById(pool, recipeID, ctx)
+		if err != nil {
+			return err
+		}
+		recipes = append(recipes, recipe)
+	}
+	if len(recipes) == 0 {
+		return fmt.Errorf("expected recipe IDs or -user")
+	}
+	if *out == "" && len(recipes) > 1 {
+		return fmt.Errorf("use -out to export more than one recipe")
+	}
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			return err
+		}
+	}
+
+	for _, recipe := range recipes {
+		doc, err := resolver.ExportDocument(ctx, recipe)
+		if err != nil {
+			return err
+		}
+		data, err := format.write(doc)
+		if err != nil {
+			return err
+		}
+		if *out == "" {
+			_, err = os.Stdout.Write(data)
+			return err
+		}
+		path := filepath.Join(*out, recipe.RecipeID+format.extension)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+		log.Printf("Wrote %s", path)
+	}
+	return nil
+}
+
+// Import every recipe in MealMaster files or Paprika exports as a user, reporting how
+// each recipe went.
+//
+// Parameters:
+//   - args: Command line arguments; expects the user to import as and the files to import
+//
+// Returns:
+//   - Error if the import could not run; recipes that fail are reported and skipped
+func importArchiveCommand(args []string) error {
+	flags := flag.NewFlagSet("import-archive", flag.ExitOnError)
+	userName := flags.String("user", "", "name of the user to import recipes as")
+	householdID := flags.String("household", "", "household to place the recipes in")
+	flags.Parse(args)
+	if *userName == "" || flags.NArg() == 0 {
+		return fmt.Errorf("expected -user and at least one file")
+	}
+
+	pool := db.InitDB()
+	defer pool.Close()
+	user, err := db.GetUserByName(pool, *userName, context.Background())
+	if err != nil {
+		return err
+	}
+	store, err := openImageStore()
+	if err != nil {
+		return err
+	}
+	ctx := auth.WithUser(context.Background(), user)
+	resolver := graph.NewResolver(pool, store, os.Getenv("AMBROSIA_PUBLIC_URL"))
+	var household *string
+	if *householdID != "" {
+		household = householdID
+	}
+
+	imported, failed := 0, 0
+	for _, path := range flags.Args() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		entries, err := importer.FromArchive(data)
+		if err != nil {
+			log.Printf("%s: failed: %v", path, err)
+			continue
+		}
+		result, err := resolver.ImportArchive(ctx, household, entries)
+		if err != nil {
+			return err
+		}
+		for _, outcome := range result.Results {
+			if outcome.Error != nil {
+				log.Printf("%s: %q failed: %s", path, outcome.Name, *outcome.Error)
+				continue
+			}
+			log.Printf("%s: imported %q as recipe %s", path, outcome.Name, outcome.Saved.Recipe.RecipeID)
+			for _, warning := range outcome.Saved.Warnings {
+				log.Printf("%s: %q warning: %s", path, outcome.Name, warning)
+			}
+		}
+		imported += result.Imported
+		failed += result.Failed
+	}
+	log.Printf("Imported %d recipes, %d failed", imported, failed)
+	return nil
+}
+
+// Import a CSV file of ingredients or recipe ingredient lines as a user, logging what
+// happened to each row.
+//
+// Parameters:
+//   - args: Command line arguments; expects the user to import as, the table and the file
+//
+// Returns:
+//   - Error if the import could not run; rows that fail are reported and skipped
+func importCSVCommand(args []string) error {
+	flags := flag.NewFlagSet("import-csv", flag.ExitOnError)
+	userName := flags.String("user", "", "name of the user to import as")
+	householdID := flags.String("household", "", "household whose ingredients or recipes to update")
+	dryRun := flags.Bool("dry-run", false, "report what would be done without saving anything")
+	flags.Parse(args)
+	if *userName == "" || flags.NArg() != 2 {
+		return fmt.Errorf("expected -user, a table and a file")
+	}
+	table, ok := graph.CSVTables[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown table %q; expected ingredients or recipe-lines", flags.Arg(0))
+	}
+	file, err := os.Open(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pool := db.InitDB()
+	defer pool.Close()
+	user, err := db.GetUserByName(pool, *userName, context.Background())
+	if err != nil {
+		return err
+	}
+	ctx := auth.WithUser(context.Background(), user)
+	resolver := graph.NewResolver(pool, nil, os.Getenv("AMBROSIA_PUBLIC_URL"))
+	var household *string
+	if *householdID != "" {
+		household = householdID
+	}
+
+	result, err := table.Import(resolver, ctx, household, file, *dryRun)
+	if err != nil {
+		return err
+	}
+	for _, warning := range result.Warnings {
+		log.Printf("warning: %s", warning)
+	}
+	for _, row := range result.Rows {
+		if row.Error != "" {
+			log.Printf("line %d: %q failed: %s", row.Line, row.Name, row.Error)
+			continue
+		}
+		log.Printf("line %d: %s %q", row.Line, row.Action, row.Name)
+		for _, note := range row.Notes {
+			log.Printf("line %d: %s", row.Line, note)
+		}
+	}
+	summary := fmt.Sprintf("Created %d, updated %d, %d failed", result.Created, result.Updated, result.Failed)
+	if result.DryRun {
+		summary += " (dry run, nothing was saved)"
+	}
+	log.Print(summary)
+	return nil
+}
+
+// Export ingredients or recipe ingredient lines as CSV.
+//
+// Parameters:
+//   - args: Command line arguments; expects the user to export for and the table
+//
+// Returns:
+//   - Error if the export failed
+func exportCSVCommand(args []string) error {
+	flags := flag.NewFlagSet("export-csv", flag.ExitOnError)
+	userName := flags.String("user", "", "name of the user to export for")
+	householdID := flags.String("household", "", "household whose ingredients or recipes to export")
+	out := flags.String("out", "", "file to write to instead of stdout")
+	flags.Parse(args)
+	if *userName == "" || flags.NArg() != 1 {
+		return fmt.Errorf("expected -user and a table")
+	}
+	table, ok := graph.CSVTables[flags.Arg(0)]
+	if !ok {
+		return fmt.Errorf("unknown table %q; expected ingredients or recipe-lines", flags.Arg(0))
+	}
+
+	pool := db.InitDB()
+	defer pool.Close()
+	user, err := db.GetUserByName(pool, *userName, context.Background())
+	if err != nil {
+		return err
+	}
+	ctx := auth.WithUser(context.Background(), user)
+	resolver := graph.NewResolver(pool, nil, os.Getenv("AMBROSIA_PUBLIC_URL"))
+	var household *string
+	if *householdID != "" {
+		household = householdID
+	}
+
+	if *out == "" {
+		return table.Export(resolver, ctx, household, os.Stdout)
+	}
+	file, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := table.Export(resolver, ctx, household, file); err != nil {
+		file.Close()
+		return err
+	}
+	log.Printf("Wrote %s", *out)
+	return file.Close()
+}
+
+// Build a command writing recipes, either chosen by ID or all of a user's recipes, to one book.
+//
+// Parameters:
+//   - name: Name of the command
+//   - write: Function writing the book
+//
+// Returns:
+//   - Command reading the file to write and recipe IDs or a user from its arguments
+func exportBookCommand(name string, write func(title string, docs []*exporter.Document) ([]byte, error)) func([]string) error {
+	return func(args []string) error {
+		flags := flag.NewFlagSet(name, flag.ExitOnError)
+		userName := flags.String("user", "", "include all recipes created by this user")
+		title := flags.String("title", "Cookbook", "title of the book")
+		out := flags.String("out", "", "file to write")
+		flags.Parse(args)
+		if *out == "" {
+			return fmt.Errorf("expected -out")
+		}
+
+		pool := db.InitDB()
+		defer pool.Close()
+		ctx := context.Background()
+		store, err := openImageStore()
+		if err != nil {
+			return err
+		}
+		resolver := graph.NewResolver(pool, store, os.Getenv("AMBROSIA_PUBLIC_URL"))
+
+		recipes := []*model.Recipe{}
+		if *userName != "" {
+			user, err := db.GetUserByName(pool, *userName, ctx)
+			if err != nil {
+				return err
+			}
+			owned, err := db.GetRecipes(pool, ctx, map[string]interface{}{"r.user_id": user.UserID})
+			if err != nil {
+				return err
+			}
+			sort.Slice(owned, func(i, j int) bool {
+				return strings.ToLower(owned[i].Name) < strings.ToLower(owned[j].Name)
+			})
+			recipes = append(recipes, owned...)
+		}
+		for _, recipeID := range flags.Args() {
+			recipe, err := db.GetRecipeById(pool, recipeID, ctx)
+			if err != nil {
+				return err
+			}
+			recipes = append(recipes, recipe)
+		}
+		if len(recipes) == 0 {
+			return fmt.Errorf("expected recipe IDs or -user")
+		}
+
+		docs := []*exporter.Document{}
+		for _, recipe := range recipes {
+			doc, err := resolver.ExportDocument(ctx, recipe)
+			if err != nil {
+				return err
+			}
+			if err := resolver.LoadExportPhoto(ctx, doc); err != nil {
+				log.Printf("%s: image left out: %v", recipe.RecipeID, err)
+			}
+			docs = append(docs, doc)
+		}
+		data, err := write(*title, docs)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*out, data, 0o644); err != nil {
+			return err
+		}
+		log.Printf("Wrote %d recipes to %s", len(docs), *out)
+		return nil
+	}
+}
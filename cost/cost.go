@@ -0,0 +1,82 @@
+// Estimate the cost of recipes from recorded ingredient prices.
+package cost
+
+import (
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/nutrition"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Express an amount of an ingredient in another unit.
+// Units of the same dimension convert directly, others go through the
+// ingredient's weight, e.g. cups of flour priced by the pound.
+//
+// Parameters:
+//   - quantity: Amount of the ingredient
+//   - unit: Unit the amount is written in
+//   - to: Unit to express the amount in
+//   - ingredient: Ingredient measured, for its density and item weight
+//
+// Returns:
+//   - Amount in the to unit, and whether the conversion was possible
+func convert(quantity float64, unit string, to string, ingredient *model.Ingredient) (float64, bool) {
+	if converted, ok := units.ConvertNamed(quantity, unit, to); ok {
+		return converted, true
+	}
+	grams, ok := nutrition.Grams(quantity, unit, ingredient.GramsPerMl, ingredient.GramsEach)
+	if !ok {
+		return 0, false
+	}
+	gramsPerUnit, ok := nutrition.Grams(1, to, ingredient.GramsPerMl, ingredient.GramsEach)
+	if !ok || gramsPerUnit == 0 {
+		return 0, false
+	}
+	return grams / gramsPerUnit, true
+}
+
+// Total the cost of a recipe's ingredient lines.
+//
+// Parameters:
+//   - lines: Ingredient lines of the recipe
+//   - prices: Current price of each ingredient, keyed by ingredient ID
+//   - servings: Number of servings the recipe makes, if known
+//
+// Returns:
+//   - Cost of the whole recipe, flagging lines that could not be counted
+func ForLines(lines []*model.RecipeIngredient, prices map[string]*model.IngredientPrice, servings *int) *model.RecipeCost {
+	result := &model.RecipeCost{
+		UnconvertedLines: []*model.RecipeIngredient{},
+		MissingPrices:    []*model.Ingredient{},
+	}
+	for _, line := range lines {
+		price, ok := prices[line.Ingredient.IngredientID]
+		if !ok {
+			result.MissingPrices = append(result.MissingPrices, line.Ingredient)
+			continue
+		}
+		if line.Quantity == nil {
+			result.UnconvertedLines = append(result.UnconvertedLines, line)
+			continue
+		}
+		unit, pricedUnit := "", ""
+		if line.Unit != nil {
+			unit = *line.Unit
+		}
+		if price.Unit != nil {
+			pricedUnit = *price.Unit
+		}
+		amount, ok := convert(*line.Quantity, unit, pricedUnit, line.Ingredient)
+		if !ok {
+			result.UnconvertedLines = append(result.UnconvertedLines, line)
+			continue
+		}
+		result.Total += amount / price.Quantity * price.Price
+	}
+
+	if servings != nil && *servings > 0 {
+		perServing := units.Round(result.Total / float64(*servings))
+		result.PerServing = &perServing
+	}
+	result.Total = units.Round(result.Total)
+	return result
+}
@@ -0,0 +1,425 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Get every meal plan entry a user created, personal or for a household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user
+//
+// Returns:
+//   - Array of MealPlanEntries encoded as the defined model object
+func GetMealPlanEntriesByUser(pool *pgxpool.Pool, ctx context.Context, user_id string) ([]*model.MealPlanEntry, error) {
+	return getMealPlanEntries(pool, ctx, "mpe.user_id = $1", []interface{}{user_id})
+}
+
+// Get every shopping list a user created, personal or for a household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user
+//
+// Returns:
+//   - Array of ShoppingLists encoded as the defined model object
+func GetShoppingListsByUser(pool *pgxpool.Pool, ctx context.Context, user_id string) ([]*model.ShoppingList, error) {
+	return getShoppingLists(pool, ctx, "sl.user_id = $1", []interface{}{user_id})
+}
+
+// Get every pantry item a user added, personal or for a household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user
+//
+// Returns:
+//   - Array of PantryItems encoded as the defined model object
+func GetPantryItemsByUser(pool *pgxpool.Pool, ctx context.Context, user_id string) ([]*model.PantryItem, error) {
+	return getPantryItems(pool, ctx, "pi.user_id = $1", []interface{}{user_id})
+}
+
+// Get every price a user recorded, personal or for a household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user
+//
+// Returns:
+//   - Array of IngredientPrices encoded as the defined model object
+func GetIngredientPricesByUser(pool *pgxpool.Pool, ctx context.Context, user_id string) ([]*model.IngredientPrice, error) {
+	return getIngredientPrices(pool, ctx, "ip.user_id = $1", []interface{}{user_id})
+}
+
+// Get every image a user uploaded.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user
+//
+// Returns:
+//   - Array of Images encoded as the defined model object
+func GetImagesByUser(pool *pgxpool.Pool, ctx context.Context, user_id string) ([]*model.Image, error) {
+	rows, err := pool.Query(
+		ctx,
+		`SELECT image_id, content_type, width, height FROM image WHERE user_id = $1 ORDER BY created_at`,
+		user_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get images; error: %v", err)
+	}
+	images := []*model.Image{}
+	for rows.Next() {
+		var id, contentType string
+		var width, height int
+		err := rows.Scan(&id, &contentType, &width, &height)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse images into struct; error: %v", err)
+		}
+		images = append(images, scanImage(&id, &contentType, &width, &height))
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+	return images, nil
+}
+
+// Count everything a user created, personal or for a household, as their data export holds it.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user
+//
+// Returns:
+//   - DataExport with its counts filled in and no URL
+func CountUserData(pool *pgxpool.Pool, ctx context.Context, user_id string) (*model.DataExport, error) {
+	var counts model.DataExport
+	err := pool.QueryRow(
+		ctx,
+		`
+		SELECT
+			(SELECT COUNT(*) FROM recipe WHERE user_id = $1),
+			(SELECT COUNT(*) FROM ingredient WHERE user_id = $1),
+			(SELECT COUNT(*) FROM meal_plan_entry WHERE user_id = $1),
+			(SELECT COUNT(*) FROM shopping_list WHERE user_id = $1),
+			(SELECT COUNT(*) FROM pantry_item WHERE user_id = $1),
+			(SELECT COUNT(*) FROM ingredient_price WHERE user_id = $1),
+			(SELECT COUNT(*) FROM image WHERE user_id = $1)
+		`,
+		user_id,
+	).Scan(
+		&counts.Recipes,
+		&counts.Ingredients,
+		&counts.MealPlanEntries,
+		&counts.ShoppingLists,
+		&counts.PantryItems,
+		&counts.Prices,
+		&counts.Images,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count account data; error: %v", err)
+	}
+	return &counts, nil
+}
+
+// Delete a user's account. Deleting the user_account row cascades to everything the user
+// created, so data other users depend on is first handed over rather than lost with it:
+//   - Rows the user created for a household that has other members are reassigned to one of them,
+//     and a household the user was the only owner of gets a new owner
+//   - Households with no other members are deleted
+//   - Each other user whose recipes, pantry, prices, shopping lists or substitutions use one of
+//     the user's ingredients gets their own copy of it, with its nutrition and dietary labels
+//
+// Other users' meal plan entries of the user's recipes are removed with the recipes.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user to delete
+//
+// Returns:
+//   - Tuple of what was done with shared data and the IDs of images no longer referenced,
+//     whose files should be removed
+func DeleteUser(pool *pgxpool.Pool, ctx context.Context, user_id string) (*model.AccountDeletion, []string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	result := model.AccountDeletion{UserID: user_id}
+	if err := handOverHouseholds(tx, ctx, user_id, &result); err != nil {
+		return nil, nil, err
+	}
+	if err := copySharedIngredients(tx, ctx, user_id, &result); err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.QueryRow(
+		ctx,
+		`
+		SELECT COUNT(*) FROM meal_plan_entry mpe
+		JOIN recipe r ON mpe.recipe_id = r.recipe_id
+		WHERE r.user_id = $1 AND mpe.user_id <> $1
+		`,
+		user_id,
+	).Scan(&result.MealPlanEntriesRemoved)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to count meal plan entries; error: %v", err)
+	}
+
+	// Images stay while a recipe that was handed over still shows them
+	rows, err := tx.Query(
+		ctx,
+		`
+		SELECT image_id FROM image WHERE user_id = $1
+		EXCEPT
+		SELECT image_id FROM recipe WHERE user_id <> $1 AND image_id IS NOT NULL
+		EXCEPT
+		SELECT rs.image_id FROM recipe_step rs JOIN recipe r ON rs.recipe_id = r.recipe_id
+		WHERE r.user_id <> $1 AND rs.image_id IS NOT NULL
+		`,
+		user_id,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get account images; error: %v", err)
+	}
+	image_ids := []string{}
+	for rows.Next() {
+		var image_id string
+		err := rows.Scan(&image_id)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse account images; error: %v", err)
+		}
+		image_ids = append(image_ids, image_id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM user_account WHERE user_id = $1`, user_id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to delete account; error: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, nil, apperr.NotFound("found no user with provided ID")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete account; error: %v", err)
+	}
+	return &result, image_ids, nil
+}
+
+// Tables of household data, each with user_id and household_id columns.
+var householdTables = []string{"recipe", "ingredient", "meal_plan_entry", "shopping_list", "pantry_item", "ingredient_price"}
+
+// Hand the households a user belongs to over to their other members before the user is
+// deleted, or delete those the user is the last member of.
+//
+// Parameters:
+//   - tx: Transaction deleting the user
+//   - ctx: pgx connection context
+//   - user_id: ID of the user being deleted
+//   - result: Counts to add to
+//
+// Returns:
+//   - Error if the households could not be handed over
+func handOverHouseholds(tx pgx.Tx, ctx context.Context, user_id string, result *model.AccountDeletion) error {
+	rows, err := tx.Query(ctx, `SELECT household_id FROM household_member WHERE user_id = $1`, user_id)
+	if err != nil {
+		return fmt.Errorf("failed to get households; error: %v", err)
+	}
+	household_ids := []string{}
+	for rows.Next() {
+		var household_id string
+		err := rows.Scan(&household_id)
+		if err != nil {
+			return fmt.Errorf("failed to parse households; error: %v", err)
+		}
+		household_ids = append(household_ids, household_id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	for _, household_id := range household_ids {
+		// The member with the highest role, and of those the earliest to join, takes over
+		var heir string
+		var role model.HouseholdRole
+		err := tx.QueryRow(
+			ctx,
+			`
+			SELECT user_id, role FROM household_member
+			WHERE household_id = $1 AND user_id <> $2
+			ORDER BY CASE role WHEN 'OWNER' THEN 0 WHEN 'EDITOR' THEN 1 ELSE 2 END, user_id
+			LIMIT 1
+			`,
+			household_id,
+			user_id,
+		).Scan(&heir, &role)
+		if err == pgx.ErrNoRows {
+			_, err = tx.Exec(ctx, `DELETE FROM household WHERE household_id = $1`, household_id)
+			if err != nil {
+				return fmt.Errorf("failed to delete household; error: %v", err)
+			}
+			result.HouseholdsDeleted++
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to find household member; error: %v", err)
+		}
+
+		if role != model.HouseholdRoleOwner {
+			_, err = tx.Exec(
+				ctx,
+				`UPDATE household_member SET role = $3 WHERE household_id = $1 AND user_id = $2`,
+				household_id,
+				heir,
+				model.HouseholdRoleOwner,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to transfer household; error: %v", err)
+			}
+			result.HouseholdsTransferred++
+		}
+
+		for _, table := range householdTables {
+			tag, err := tx.Exec(
+				ctx,
+				`UPDATE `+table+` SET user_id = $3 WHERE household_id = $1 AND user_id = $2`,
+				household_id,
+				user_id,
+				heir,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to reassign household %s; error: %v", table, err)
+			}
+			result.Reassigned += int(tag.RowsAffected())
+		}
+	}
+	return nil
+}
+
+// Give each other user that relies on one of a user's ingredients their own copy of it,
+// so their recipes, pantry, prices, shopping lists and substitutions keep it once the user is deleted.
+//
+// Parameters:
+//   - tx: Transaction deleting the user
+//   - ctx: pgx connection context
+//   - user_id: ID of the user being deleted
+//   - result: Counts to add to
+//
+// Returns:
+//   - Error if the ingredients could not be copied
+func copySharedIngredients(tx pgx.Tx, ctx context.Context, user_id string, result *model.AccountDeletion) error {
+	rows, err := tx.Query(
+		ctx,
+		`
+		SELECT DISTINCT i.ingredient_id, uses.user_id
+		FROM ingredient i
+		JOIN (
+			SELECT ri.ingredient_id, r.user_id FROM recipe_ingredient ri JOIN recipe r ON ri.recipe_id = r.recipe_id
+			UNION SELECT ingredient_id, user_id FROM pantry_item
+			UNION SELECT ingredient_id, user_id FROM ingredient_price
+			UNION SELECT sli.ingredient_id, sl.user_id FROM shopping_list_item sli
+				JOIN shopping_list sl ON sli.shopping_list_id = sl.shopping_list_id
+			UNION SELECT ingredient_id, user_id FROM ingredient_substitution
+			UNION SELECT isp.ingredient_id, s.user_id FROM ingredient_substitution_part isp
+				JOIN ingredient_substitution s ON isp.substitution_id = s.substitution_id
+		) uses ON i.ingredient_id = uses.ingredient_id
+		WHERE i.user_id = $1 AND uses.user_id <> $1
+		`,
+		user_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to get shared ingredients; error: %v", err)
+	}
+	type use struct {
+		ingredient_id string
+		user_id       string
+	}
+	uses := []use{}
+	for rows.Next() {
+		var u use
+		err := rows.Scan(&u.ingredient_id, &u.user_id)
+		if err != nil {
+			return fmt.Errorf("failed to parse shared ingredients; error: %v", err)
+		}
+		uses = append(uses, u)
+	}
+	err = rows.Err()
+	if err != nil {
+		return fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	for _, u := range uses {
+		// The copy is personal, since the other user may not belong to the ingredient's household
+		var copy_id string
+		err := tx.QueryRow(
+			ctx,
+			`
+			INSERT INTO ingredient (user_id, name, description, category, grams_per_ml, grams_each, fdc_id)
+			SELECT $2, name, description, category, grams_per_ml, grams_each, fdc_id
+			FROM ingredient WHERE ingredient_id = $1
+			RETURNING ingredient_id
+			`,
+			u.ingredient_id,
+			u.user_id,
+		).Scan(&copy_id)
+		if err != nil {
+			return fmt.Errorf("failed to copy ingredient; error: %v", err)
+		}
+		// Rows are copied whole, swapping in the new ingredient's ID
+		for _, table := range []string{"ingredient_nutrition", "ingredient_allergen", "ingredient_diet"} {
+			_, err := tx.Exec(
+				ctx,
+				`
+				INSERT INTO `+table+`
+				SELECT (jsonb_populate_record(NULL::`+table+`, to_jsonb(t) || jsonb_build_object('ingredient_id', $2::INT))).*
+				FROM `+table+` t WHERE ingredient_id = $1
+				`,
+				u.ingredient_id,
+				copy_id,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to copy %s; error: %v", table, err)
+			}
+		}
+
+		for _, query := range []string{
+			`UPDATE recipe_ingredient SET ingredient_id = $3 WHERE ingredient_id = $1
+				AND recipe_id IN (SELECT recipe_id FROM recipe WHERE user_id = $2)`,
+			`UPDATE pantry_item SET ingredient_id = $3 WHERE ingredient_id = $1 AND user_id = $2`,
+			`UPDATE ingredient_price SET ingredient_id = $3 WHERE ingredient_id = $1 AND user_id = $2`,
+			`UPDATE shopping_list_item SET ingredient_id = $3 WHERE ingredient_id = $1
+				AND shopping_list_id IN (SELECT shopping_list_id FROM shopping_list WHERE user_id = $2)`,
+			`UPDATE ingredient_substitution SET ingredient_id = $3 WHERE ingredient_id = $1 AND user_id = $2`,
+			`UPDATE ingredient_substitution_part SET ingredient_id = $3 WHERE ingredient_id = $1
+				AND substitution_id IN (SELECT substitution_id FROM ingredient_substitution WHERE user_id = $2)`,
+		} {
+			_, err := tx.Exec(ctx, query, u.ingredient_id, u.user_id, copy_id)
+			if err != nil {
+				return fmt.Errorf("failed to move ingredient uses to its copy; error: %v", err)
+			}
+		}
+		result.IngredientsCopied++
+	}
+	return nil
+}
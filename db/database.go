@@ -5,9 +5,14 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/dietary"
 	"github.com/zldobbs/ambrosia-server/graph/model"
 )
 
@@ -82,6 +87,46 @@ func BuildWhereQuery(where map[string]interface{}) (string, []interface{}) {
 	return " WHERE " + strings.Join(conditions, " AND "), args
 }
 
+// Build the owning household of a row from its nullable joined columns.
+//
+// Parameters:
+//   - id: Scanned household ID, nil when the row has no household
+//   - name: Scanned household name
+//
+// Returns:
+//   - Household encoded as the defined model object, or nil
+func scanHousehold(id *string, name *string) *model.Household {
+	if id == nil {
+		return nil
+	}
+	household := model.Household{HouseholdID: *id}
+	if name != nil {
+		household.Name = *name
+	}
+	return &household
+}
+
+// Add up the parts of a recipe's time that are known.
+//
+// Parameters:
+//   - parts: Durations to add, nil when unknown
+//
+// Returns:
+//   - Total duration, nil when none of the parts are known
+func totalTime(parts ...*time.Duration) *time.Duration {
+	var total *time.Duration
+	for _, part := range parts {
+		if part == nil {
+			continue
+		}
+		if total == nil {
+			total = new(time.Duration)
+		}
+		*total += *part
+	}
+	return total
+}
+
 // Get a collection of ingredients from the database.
 // TODO: Limit responses here, use pagination
 //
@@ -93,12 +138,70 @@ func BuildWhereQuery(where map[string]interface{}) (string, []interface{}) {
 // Returns:
 //   - Array of Ingredients encoded as the defined model object
 func GetIngredients(pool *pgxpool.Pool, ctx context.Context, where map[string]interface{}) ([]*model.Ingredient, error) {
+	whereQuery, whereArgs := BuildWhereQuery(where)
+	return getIngredients(pool, ctx, whereQuery, whereArgs)
+}
+
+// Get one page of the ingredients a user may read, ordered by ID so pages do not overlap.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the current user, nil when anonymous
+//   - limit: Ingredients per page
+//   - offset: Ingredients to skip
+//
+// Returns:
+//   - Ingredients on the page, and how many the user may read in all
+func GetReadableIngredients(pool *pgxpool.Pool, ctx context.Context, user_id *string, limit int, offset int) ([]*model.Ingredient, int, error) {
+	scope, args := readableScope("i", user_id, 1)
+	var total int
+	err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM ingredient i WHERE `+scope, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count ingredients; error: %v", err)
+	}
+	page := fmt.Sprintf(" WHERE %s ORDER BY i.ingredient_id LIMIT $%d OFFSET $%d", scope, len(args)+1, len(args)+2)
+	ingredients, err := getIngredients(pool, ctx, page, append(args, limit, offset))
+	if err != nil {
+		return nil, 0, err
+	}
+	return ingredients, total, nil
+}
+
+// Get a collection of ingredients by their IDs. Unknown IDs are skipped.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_ids: IDs of the ingredients to retrieve
+//
+// Returns:
+//   - Array of Ingredients encoded as the defined model object
+func GetIngredientsByIds(pool *pgxpool.Pool, ctx context.Context, ingredient_ids []string) ([]*model.Ingredient, error) {
+	if len(ingredient_ids) == 0 {
+		return nil, nil
+	}
+	return getIngredients(pool, ctx, "WHERE i.ingredient_id::TEXT = ANY($1::TEXT[])", []interface{}{ingredient_ids})
+}
+
+// Run the ingredient query with a prepared "WHERE" clause.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - whereQuery: "WHERE" clause appended to the query, may be empty
+//   - whereArgs: arguments referenced by whereQuery
+//
+// Returns:
+//   - Array of Ingredients encoded as the defined model object
+func getIngredients(pool *pgxpool.Pool, ctx context.Context, whereQuery string, whereArgs []interface{}) ([]*model.Ingredient, error) {
 	query := `
-		SELECT i.ingredient_id, i.name, i.description, iu.user_id, iu.name
+		SELECT i.ingredient_id, i.name, i.description, i.category, i.grams_per_ml, i.grams_each, iu.user_id, iu.name, ih.household_id, ih.name,
+			` + ingredientLabelColumns + `
 		FROM ingredient i
 		JOIN user_account iu ON i.user_id = iu.user_id
+		LEFT JOIN household ih ON i.household_id = ih.household_id
 	`
-	whereQuery, whereArgs := BuildWhereQuery(where)
 
 	rows, err := pool.Query(
 		ctx,
@@ -113,19 +216,31 @@ func GetIngredients(pool *pgxpool.Pool, ctx context.Context, where map[string]in
 	for rows.Next() {
 		var user model.User
 		var ingredient model.Ingredient
+		var householdID, householdName *string
+		var allergens, diets []string
 
 		err := rows.Scan(
 			&ingredient.IngredientID,
 			&ingredient.Name,
 			&ingredient.Description,
+			&ingredient.Category,
+			&ingredient.GramsPerMl,
+			&ingredient.GramsEach,
 			&user.UserID,
 			&user.Name,
+			&householdID,
+			&householdName,
+			&allergens,
+			&diets,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse ingredients into struct; error: %v", err)
 		}
+		ingredient.Allergens = toAllergens(allergens)
+		ingredient.Diets = toDiets(diets)
 
 		ingredient.User = &user
+		ingredient.Household = scanHousehold(householdID, householdName)
 		ingredients = append(ingredients, &ingredient)
 	}
 	err = rows.Err()
@@ -152,7 +267,7 @@ func GetIngredientById(pool *pgxpool.Pool, ingredient_id string, ctx context.Con
 		return nil, err
 	}
 	if len(ingredients) == 0 {
-		return nil, fmt.Errorf("found no ingredients with provided id")
+		return nil, apperr.NotFound("found no ingredients with provided id")
 	}
 	if len(ingredients) > 1 {
 		return nil, fmt.Errorf("found multiple ingredients with provided id")
@@ -171,12 +286,78 @@ func GetIngredientById(pool *pgxpool.Pool, ingredient_id string, ctx context.Con
 // Returns:
 //   - Array of Recipes encoded as the defined model object
 func GetRecipes(pool *pgxpool.Pool, ctx context.Context, where map[string]interface{}) ([]*model.Recipe, error) {
+	whereQuery, whereArgs := BuildWhereQuery(where)
+	return getRecipes(pool, ctx, whereQuery, whereArgs)
+}
+
+// Build the condition selecting rows the user may read: those with no household, and
+// those of households the user is a member of. Matches the resolvers' canRead.
+//
+// Parameters:
+//   - alias: Alias of the table whose household_id is checked, e.g. "r"
+//   - user_id: ID of the current user, nil when anonymous
+//   - argPosition: Position of the query argument to use
+//
+// Returns:
+//   - Tuple of SQL condition with corresponding args in order
+func readableScope(alias string, user_id *string, argPosition int) (string, []interface{}) {
+	if user_id == nil {
+		return alias + ".household_id IS NULL", nil
+	}
+	return fmt.Sprintf(
+		"(%s.household_id IS NULL OR %s.household_id IN (SELECT hm.household_id FROM household_member hm WHERE hm.user_id = $%d))",
+		alias, alias, argPosition,
+	), []interface{}{*user_id}
+}
+
+// Get one page of the recipes a user may read, ordered by ID so pages do not overlap.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the current user, nil when anonymous
+//   - limit: Recipes per page
+//   - offset: Recipes to skip
+//
+// Returns:
+//   - Recipes on the page, and how many the user may read in all
+func GetReadableRecipes(pool *pgxpool.Pool, ctx context.Context, user_id *string, limit int, offset int) ([]*model.Recipe, int, error) {
+	scope, args := readableScope("r", user_id, 1)
+	var total int
+	err := pool.QueryRow(ctx, `SELECT COUNT(*) FROM recipe r WHERE `+scope, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count recipes; error: %v", err)
+	}
+	page := fmt.Sprintf(" WHERE %s ORDER BY r.recipe_id LIMIT $%d OFFSET $%d", scope, len(args)+1, len(args)+2)
+	recipes, err := getRecipes(pool, ctx, page, append(args, limit, offset))
+	if err != nil {
+		return nil, 0, err
+	}
+	return recipes, total, nil
+}
+
+// Run the recipe query with a prepared "WHERE" clause, then load the ingredient lines of
+// every recipe found in one more query.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - whereQuery: "WHERE" clause appended to the query, and any ordering; may be empty
+//   - whereArgs: arguments referenced by whereQuery
+//
+// Returns:
+//   - Array of Recipes encoded as the defined model object
+func getRecipes(pool *pgxpool.Pool, ctx context.Context, whereQuery string, whereArgs []interface{}) ([]*model.Recipe, error) {
 	query := `
-		SELECT r.recipe_id, r.name, r.description, ru.user_id, ru.name
+		SELECT r.recipe_id, r.name, r.description, r.servings, r.prep_time, r.cook_time, r.rest_time, r.source_url, r.rating,
+			ARRAY(SELECT rc.category FROM recipe_category rc WHERE rc.recipe_id = r.recipe_id ORDER BY rc.category),
+			ru.user_id, ru.name, rh.household_id, rh.name,
+			rim.image_id, rim.content_type, rim.width, rim.height
 		FROM recipe r
 		JOIN user_account ru ON r.user_id = ru.user_id
+		LEFT JOIN household rh ON r.household_id = rh.household_id
+		LEFT JOIN image rim ON r.image_id = rim.image_id
 	`
-	whereQuery, whereArgs := BuildWhereQuery(where)
 
 	rows, err := pool.Query(
 		ctx,
@@ -186,70 +367,147 @@ func GetRecipes(pool *pgxpool.Pool, ctx context.Context, where map[string]interf
 	if err != nil {
 		return nil, fmt.Errorf("failed to get recipes froms server; error: %v", err)
 	}
+	defer rows.Close()
 
 	var recipes []*model.Recipe
 	for rows.Next() {
 		var recipe model.Recipe
 		var recipeUser model.User
+		var householdID, householdName *string
+		var imageID, imageContentType *string
+		var imageWidth, imageHeight *int
 		err := rows.Scan(
 			&recipe.RecipeID,
 			&recipe.Name,
 			&recipe.Description,
+			&recipe.Servings,
+			&recipe.PrepTime,
+			&recipe.CookTime,
+			&recipe.RestTime,
+			&recipe.SourceURL,
+			&recipe.Rating,
+			&recipe.Categories,
 			&recipeUser.UserID,
 			&recipeUser.Name,
+			&householdID,
+			&householdName,
+			&imageID,
+			&imageContentType,
+			&imageWidth,
+			&imageHeight,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("could not load recipe: %v", err)
 		}
 		recipe.User = &recipeUser
+		recipe.Household = scanHousehold(householdID, householdName)
+		recipe.TotalTime = totalTime(recipe.PrepTime, recipe.CookTime, recipe.RestTime)
+		recipe.Image = scanImage(imageID, imageContentType, imageWidth, imageHeight)
+		recipe.IngredientLines = []*model.RecipeIngredient{}
+		recipes = append(recipes, &recipe)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
 
-		// Get all ingredients associated with this recipe
-		rows, err := pool.Query(
-			ctx,
-			`
-			SELECT i.ingredient_id, i.name, i.description, iu.user_id, iu.name
-			FROM recipe_ingredient ri
-			JOIN ingredient i ON ri.ingredient_id = i.ingredient_id
-			JOIN user_account iu ON i.user_id = iu.user_id
-			WHERE ri.recipe_id = $1
-			`,
-			recipe.RecipeID,
-		)
+	if err := loadRecipeIngredients(pool, ctx, recipes); err != nil {
+		return nil, err
+	}
+	return recipes, nil
+}
+
+// Fill in the ingredient lines, ingredients and dietary labels of recipes.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - recipes: Recipes to fill in
+//
+// Returns:
+//   - Error if the lines could not be loaded
+func loadRecipeIngredients(pool *pgxpool.Pool, ctx context.Context, recipes []*model.Recipe) error {
+	if len(recipes) == 0 {
+		return nil
+	}
+	byID := map[string]*model.Recipe{}
+	recipe_ids := []int{}
+	for _, recipe := range recipes {
+		byID[recipe.RecipeID] = recipe
+		// Compared as integers so the recipe_ingredient key is used
+		recipe_id, err := strconv.Atoi(recipe.RecipeID)
 		if err != nil {
-			return nil, fmt.Errorf("could not retrieve ingredients for recipe: %v", err)
+			return fmt.Errorf("failed to read recipe id %q; error: %v", recipe.RecipeID, err)
 		}
+		recipe_ids = append(recipe_ids, recipe_id)
+	}
 
-		var ingredients []*model.Ingredient
-		for rows.Next() {
-			var ingredient model.Ingredient
-			var ingredientUser model.User
-			err = rows.Scan(
-				&ingredient.IngredientID,
-				&ingredient.Name,
-				&ingredient.Description,
-				&ingredientUser.UserID,
-				&ingredientUser.Name,
-			)
-			if err != nil {
-				return nil, fmt.Errorf("could not scan out row: %v", err)
-			}
-			ingredient.User = &ingredientUser
-			ingredients = append(ingredients, &ingredient)
-		}
-		err = rows.Err()
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT ri.recipe_id, i.ingredient_id, i.name, i.description, i.category, i.grams_per_ml, i.grams_each, iu.user_id, iu.name, ih.household_id, ih.name,
+			`+ingredientLabelColumns+`, ri.quantity, ri.unit, ri.preparation, ri.notes
+		FROM recipe_ingredient ri
+		JOIN ingredient i ON ri.ingredient_id = i.ingredient_id
+		JOIN user_account iu ON i.user_id = iu.user_id
+		LEFT JOIN household ih ON i.household_id = ih.household_id
+		WHERE ri.recipe_id = ANY($1::INT[])
+		`,
+		recipe_ids,
+	)
+	if err != nil {
+		return fmt.Errorf("could not retrieve ingredients for recipe: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var recipe_id string
+		var ingredient model.Ingredient
+		var ingredientUser model.User
+		var ingredientHouseholdID, ingredientHouseholdName *string
+		var allergens, diets []string
+		var line model.RecipeIngredient
+		err = rows.Scan(
+			&recipe_id,
+			&ingredient.IngredientID,
+			&ingredient.Name,
+			&ingredient.Description,
+			&ingredient.Category,
+			&ingredient.GramsPerMl,
+			&ingredient.GramsEach,
+			&ingredientUser.UserID,
+			&ingredientUser.Name,
+			&ingredientHouseholdID,
+			&ingredientHouseholdName,
+			&allergens,
+			&diets,
+			&line.Quantity,
+			&line.Unit,
+			&line.Preparation,
+			&line.Notes,
+		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+			return fmt.Errorf("could not scan out row: %v", err)
 		}
-
-		recipe.Ingredients = ingredients
-		recipes = append(recipes, &recipe)
+		ingredient.User = &ingredientUser
+		ingredient.Household = scanHousehold(ingredientHouseholdID, ingredientHouseholdName)
+		ingredient.Allergens = toAllergens(allergens)
+		ingredient.Diets = toDiets(diets)
+		line.Ingredient = &ingredient
+		recipe := byID[recipe_id]
+		recipe.Ingredients = append(recipe.Ingredients, &ingredient)
+		recipe.IngredientLines = append(recipe.IngredientLines, &line)
 	}
 	err = rows.Err()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+		return fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
 	}
 
-	return recipes, nil
+	for _, recipe := range recipes {
+		recipe.Allergens = dietary.Allergens(recipe.Ingredients)
+		recipe.DietaryLabels = dietary.Labels(recipe.Ingredients)
+	}
+	return nil
 }
 
 // Get a recipe from the database.
@@ -268,10 +526,474 @@ func GetRecipeById(pool *pgxpool.Pool, recipe_id string, ctx context.Context) (*
 		return nil, err
 	}
 	if len(recipes) == 0 {
-		return nil, fmt.Errorf("found no recipes with provided id")
+		return nil, apperr.NotFound("found no recipes with provided id")
 	}
 	if len(recipes) > 1 {
 		return nil, fmt.Errorf("found multiple recipes with provided id")
 	}
 	return recipes[0], nil
 }
+
+// Create a new ingredient.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - input: Details of the ingredient
+//
+// Returns:
+//   - ID of the newly created ingredient
+func CreateIngredient(pool *pgxpool.Pool, ctx context.Context, input model.NewIngredient) (string, error) {
+	ingredient_id, err := insertIngredient(pool, ctx, input)
+	if err != nil {
+		return "", err
+	}
+
+	// Tag allergens and diets
+	if input.Allergens != nil || input.Diets != nil {
+		err = SetIngredientLabels(pool, ctx, ingredient_id, input.Allergens, input.Diets)
+		if err != nil {
+			return "", err
+		}
+	}
+	return ingredient_id, nil
+}
+
+// Runs a query on the pool, or in an open transaction.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// Insert an ingredient's row, without its allergens and diets.
+//
+// Parameters:
+//   - db: Pool or open transaction to insert with
+//   - ctx: pgx connection context
+//   - input: Details of the ingredient
+//
+// Returns:
+//   - ID of the newly created ingredient
+func insertIngredient(db rowQuerier, ctx context.Context, input model.NewIngredient) (string, error) {
+	var ingredient_id string
+	err := db.QueryRow(
+		ctx,
+		`
+		INSERT INTO ingredient (name, description, user_id, household_id, category, grams_per_ml, grams_each)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ingredient_id::TEXT
+		`,
+		input.Name,
+		input.Description,
+		input.UserID,
+		input.HouseholdID,
+		input.Category,
+		input.GramsPerMl,
+		input.GramsEach,
+	).Scan(&ingredient_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create ingredient from %v, error: %v", input, err)
+	}
+	return ingredient_id, nil
+}
+
+// An ingredient to create along with a recipe, for a line that matched no stored ingredient.
+type PendingIngredient struct {
+	Input model.NewIngredient
+	// Recipe line the ingredient is for; its IngredientID is set once the ingredient exists
+	Line *model.ExistingIngredientID
+}
+
+// Create the ingredients a recipe's lines are waiting on.
+//
+// Parameters:
+//   - tx: Open transaction the recipe is being written in
+//   - ctx: pgx connection context
+//   - pending: Ingredients to create
+//
+// Returns:
+//   - Error if an ingredient could not be created
+func insertPendingIngredients(tx pgx.Tx, ctx context.Context, pending []*PendingIngredient) error {
+	for _, ingredient := range pending {
+		ingredient_id, err := insertIngredient(tx, ctx, ingredient.Input)
+		if err != nil {
+			return err
+		}
+		ingredient.Line.IngredientID = ingredient_id
+	}
+	return nil
+}
+
+// Update the fields of an ingredient that are provided, replacing its allergens and diets when those are given.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_id: ID of the ingredient
+//   - input: Fields to change
+//
+// Returns:
+//   - Error if the ingredient could not be updated
+func UpdateIngredient(pool *pgxpool.Pool, ctx context.Context, ingredient_id string, input model.UpdateIngredient) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		UPDATE ingredient SET
+			name = COALESCE($1, name),
+			description = COALESCE($2, description),
+			category = COALESCE($3, category),
+			grams_per_ml = COALESCE($4, grams_per_ml),
+			grams_each = COALESCE($5, grams_each)
+		WHERE ingredient_id = $6
+		`,
+		input.Name,
+		input.Description,
+		input.Category,
+		input.GramsPerMl,
+		input.GramsEach,
+		ingredient_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update ingredient: %v", err)
+	}
+	if input.Allergens != nil || input.Diets != nil {
+		return SetIngredientLabels(pool, ctx, ingredient_id, input.Allergens, input.Diets)
+	}
+	return nil
+}
+
+// Delete an ingredient, unless a recipe still uses it. Its nutrition, labels, prices,
+// substitutions and pantry items go with it.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_id: ID of the ingredient
+//
+// Returns:
+//   - Error if the ingredient does not exist, is in use or could not be deleted
+func DeleteIngredient(pool *pgxpool.Pool, ctx context.Context, ingredient_id string) error {
+	// The ingredient is looked up in the same statement, so a missing one is told apart from one in use
+	var used bool
+	err := pool.QueryRow(
+		ctx,
+		`
+		WITH target AS (
+			SELECT i.ingredient_id,
+				EXISTS (SELECT 1 FROM recipe_ingredient ri WHERE ri.ingredient_id = i.ingredient_id) AS used
+			FROM ingredient i
+			WHERE i.ingredient_id = $1
+		), deleted AS (
+			DELETE FROM ingredient
+			WHERE ingredient_id IN (SELECT ingredient_id FROM target WHERE NOT used)
+		)
+		SELECT used FROM target
+		`,
+		ingredient_id,
+	).Scan(&used)
+	if err == pgx.ErrNoRows {
+		return apperr.NotFound("found no ingredients with provided id")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to delete ingredient; error: %v", err)
+	}
+	if used {
+		return apperr.Conflict("ingredient is used by a recipe")
+	}
+	return nil
+}
+
+// Create a new recipe along with its ingredient lines and steps, and any ingredients its
+// lines need, so nothing is left behind if part of it fails.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - input: Details of the recipe
+//   - pending: Ingredients to create for lines of the recipe, if any
+//
+// Returns:
+//   - ID of the newly created recipe
+func CreateRecipe(pool *pgxpool.Pool, ctx context.Context, input model.NewRecipe, pending []*PendingIngredient) (string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertPendingIngredients(tx, ctx, pending); err != nil {
+		return "", err
+	}
+
+	// First create the recipe
+	var recipe_id string
+	err = tx.QueryRow(
+		ctx,
+		`
+		INSERT INTO recipe (name, description, user_id, household_id, servings, prep_time, cook_time, rest_time, source_url, rating)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING recipe_id::TEXT
+		`,
+		input.Name,
+		input.Description,
+		input.UserID,
+		input.HouseholdID,
+		input.Servings,
+		input.PrepTime,
+		input.CookTime,
+		input.RestTime,
+		input.SourceURL,
+		input.Rating,
+	).Scan(&recipe_id)
+	if err != nil {
+		return "", fmt.Errorf("could not grab the newly created recipe id: %v", err)
+	}
+
+	// Next add each ingredient as a relationship
+	err = insertRecipeIngredients(tx, ctx, recipe_id, input.Ingredients)
+	if err != nil {
+		return "", err
+	}
+
+	// Then add the steps in order
+	err = insertRecipeSteps(tx, ctx, recipe_id, input.Steps)
+	if err != nil {
+		return "", err
+	}
+
+	err = insertRecipeCategories(tx, ctx, recipe_id, input.Categories)
+	if err != nil {
+		return "", err
+	}
+
+	return recipe_id, tx.Commit(ctx)
+}
+
+// File a recipe under categories, skipping any it is already under.
+//
+// Parameters:
+//   - tx: Open transaction
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//   - categories: Categories to add
+//
+// Returns:
+//   - Error if a category could not be added
+func insertRecipeCategories(tx pgx.Tx, ctx context.Context, recipe_id string, categories []string) error {
+	for _, category := range categories {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO recipe_category (recipe_id, category) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			recipe_id,
+			category,
+		)
+		if err != nil {
+			return fmt.Errorf("could not add recipe category: %v", err)
+		}
+	}
+	return nil
+}
+
+// Link ingredients to a recipe.
+//
+// Parameters:
+//   - tx: Open transaction
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//   - ingredients: Ingredient lines to add
+//
+// Returns:
+//   - Error if a line could not be added
+func insertRecipeIngredients(tx pgx.Tx, ctx context.Context, recipe_id string, ingredients []*model.ExistingIngredientID) error {
+	for _, existing_ingredient_id := range ingredients {
+		_, err := tx.Exec(
+			ctx,
+			`
+			INSERT INTO recipe_ingredient (recipe_id, ingredient_id, quantity, unit, preparation, notes)
+			VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+			`,
+			recipe_id,
+			existing_ingredient_id.IngredientID,
+			existing_ingredient_id.Quantity,
+			existing_ingredient_id.Unit,
+			existing_ingredient_id.Preparation,
+			existing_ingredient_id.Notes,
+		)
+		if err != nil {
+			return fmt.Errorf("could not add recipe ingredient: %v", err)
+		}
+	}
+	return nil
+}
+
+// Add an ingredient line to a recipe, or replace the amount and notes of the line
+// already using that ingredient.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//   - line: Ingredient line to add
+//
+// Returns:
+//   - Error if the line could not be saved
+func UpsertRecipeIngredient(pool *pgxpool.Pool, ctx context.Context, recipe_id string, line *model.ExistingIngredientID) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		INSERT INTO recipe_ingredient (recipe_id, ingredient_id, quantity, unit, preparation, notes)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''))
+		ON CONFLICT (recipe_id, ingredient_id) DO UPDATE SET
+			quantity = EXCLUDED.quantity,
+			unit = EXCLUDED.unit,
+			preparation = EXCLUDED.preparation,
+			notes = EXCLUDED.notes
+		`,
+		recipe_id,
+		line.IngredientID,
+		line.Quantity,
+		line.Unit,
+		line.Preparation,
+		line.Notes,
+	)
+	if err != nil {
+		return fmt.Errorf("could not save recipe ingredient: %v", err)
+	}
+	return nil
+}
+
+// Add steps to a recipe, numbering them from 1 in the given order.
+//
+// Parameters:
+//   - tx: Open transaction
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//   - steps: Steps to add
+//
+// Returns:
+//   - Error if a step could not be added
+func insertRecipeSteps(tx pgx.Tx, ctx context.Context, recipe_id string, steps []*model.NewRecipeStep) error {
+	for i, step := range steps {
+		_, err := tx.Exec(
+			ctx,
+			`INSERT INTO recipe_step (recipe_id, position, text, duration) VALUES ($1, $2, $3, $4)`,
+			recipe_id,
+			i+1,
+			step.Text,
+			step.Duration,
+		)
+		if err != nil {
+			return fmt.Errorf("could not add recipe step: %v", err)
+		}
+	}
+	return nil
+}
+
+// Update the fields of a recipe that are provided, replacing its ingredient
+// lines and steps when those are given. Ingredients the new lines need are created
+// in the same transaction.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//   - input: Fields to change
+//   - pending: Ingredients to create for the new lines, if any
+//
+// Returns:
+//   - IDs of images attached to replaced steps, which are no longer referenced
+func UpdateRecipe(pool *pgxpool.Pool, ctx context.Context, recipe_id string, input model.UpdateRecipe, pending []*PendingIngredient) ([]string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not start transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := insertPendingIngredients(tx, ctx, pending); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`
+		UPDATE recipe SET
+			name = COALESCE($1, name),
+			description = COALESCE($2, description),
+			household_id = COALESCE($3, household_id),
+			servings = COALESCE($4, servings),
+			prep_time = COALESCE($5, prep_time),
+			cook_time = COALESCE($6, cook_time),
+			rest_time = COALESCE($7, rest_time),
+			rating = COALESCE($8, rating)
+		WHERE recipe_id = $9
+		`,
+		input.Name,
+		input.Description,
+		input.HouseholdID,
+		input.Servings,
+		input.PrepTime,
+		input.CookTime,
+		input.RestTime,
+		input.Rating,
+		recipe_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not update recipe: %v", err)
+	}
+
+	// Replace the ingredient list when one is provided
+	if input.Ingredients != nil {
+		_, err = tx.Exec(ctx, `DELETE FROM recipe_ingredient WHERE recipe_id = $1`, recipe_id)
+		if err != nil {
+			return nil, fmt.Errorf("could not clear recipe ingredients: %v", err)
+		}
+		err = insertRecipeIngredients(tx, ctx, recipe_id, input.Ingredients)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace the categories when they are provided
+	if input.Categories != nil {
+		_, err = tx.Exec(ctx, `DELETE FROM recipe_category WHERE recipe_id = $1`, recipe_id)
+		if err != nil {
+			return nil, fmt.Errorf("could not clear recipe categories: %v", err)
+		}
+		err = insertRecipeCategories(tx, ctx, recipe_id, input.Categories)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Replace the steps when they are provided, collecting the images of the old ones
+	replaced_image_ids := []string{}
+	if input.Steps != nil {
+		rows, err := tx.Query(ctx, `DELETE FROM recipe_step WHERE recipe_id = $1 RETURNING image_id`, recipe_id)
+		if err != nil {
+			return nil, fmt.Errorf("could not clear recipe steps: %v", err)
+		}
+		for rows.Next() {
+			var image_id *string
+			err := rows.Scan(&image_id)
+			if err != nil {
+				return nil, fmt.Errorf("could not clear recipe steps: %v", err)
+			}
+			if image_id != nil {
+				replaced_image_ids = append(replaced_image_ids, *image_id)
+			}
+		}
+		err = rows.Err()
+		if err != nil {
+			return nil, fmt.Errorf("could not clear recipe steps: %v", err)
+		}
+		err = insertRecipeSteps(tx, ctx, recipe_id, input.Steps)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not commit recipe update: %v", err)
+	}
+	return replaced_image_ids, nil
+}
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Columns selecting the allergens and diets of an ingredient aliased as "i".
+const ingredientLabelColumns = `
+	ARRAY(SELECT ia.allergen FROM ingredient_allergen ia WHERE ia.ingredient_id = i.ingredient_id),
+	ARRAY(SELECT idt.diet FROM ingredient_diet idt WHERE idt.ingredient_id = i.ingredient_id)`
+
+// Convert scanned allergen names to the defined model enum.
+func toAllergens(values []string) []model.Allergen {
+	allergens := make([]model.Allergen, 0, len(values))
+	for _, value := range values {
+		allergens = append(allergens, model.Allergen(value))
+	}
+	return allergens
+}
+
+// Convert scanned diet names to the defined model enum.
+func toDiets(values []string) []model.Diet {
+	diets := make([]model.Diet, 0, len(values))
+	for _, value := range values {
+		diets = append(diets, model.Diet(value))
+	}
+	return diets
+}
+
+// Replace the allergens and diets an ingredient is tagged with.
+// Either list may be nil to leave those tags unchanged.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_id: ID of the ingredient
+//   - allergens: Allergens the ingredient contains
+//   - diets: Diets the ingredient is suitable for
+//
+// Returns:
+//   - Error if the tags could not be stored
+func SetIngredientLabels(pool *pgxpool.Pool, ctx context.Context, ingredient_id string, allergens []model.Allergen, diets []model.Diet) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if allergens != nil {
+		_, err = tx.Exec(ctx, `DELETE FROM ingredient_allergen WHERE ingredient_id = $1`, ingredient_id)
+		if err != nil {
+			return fmt.Errorf("failed to clear ingredient allergens; error: %v", err)
+		}
+		for _, allergen := range allergens {
+			_, err = tx.Exec(
+				ctx,
+				`INSERT INTO ingredient_allergen (ingredient_id, allergen) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+				ingredient_id,
+				allergen,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to tag ingredient allergen; error: %v", err)
+			}
+		}
+	}
+
+	if diets != nil {
+		_, err = tx.Exec(ctx, `DELETE FROM ingredient_diet WHERE ingredient_id = $1`, ingredient_id)
+		if err != nil {
+			return fmt.Errorf("failed to clear ingredient diets; error: %v", err)
+		}
+		for _, diet := range diets {
+			_, err = tx.Exec(
+				ctx,
+				`INSERT INTO ingredient_diet (ingredient_id, diet) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+				ingredient_id,
+				diet,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to tag ingredient diet; error: %v", err)
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/nutrition"
+)
+
+// Number of foods written per batch when importing.
+const foodBatchSize = 500
+
+// Columns selected for a food, with its nutrients in the order of nutrition.Fields.
+var foodColumns = "f.fdc_id, f.description, f.data_type, f.category, f." + strings.Join(nutritionColumns, ", f.")
+
+// Scan a food selected with foodColumns.
+//
+// Parameters:
+//   - row: Row to scan
+//
+// Returns:
+//   - Food encoded as the defined model object
+func scanFood(row pgx.Row) (*model.Food, error) {
+	food := model.Food{Nutrition: &model.Nutrition{}}
+	dest := []interface{}{&food.FdcID, &food.Description, &food.DataType, &food.Category}
+	for _, field := range nutrition.Fields(food.Nutrition) {
+		dest = append(dest, field)
+	}
+	err := row.Scan(dest...)
+	if err != nil {
+		return nil, err
+	}
+	return &food, nil
+}
+
+// Get foods matching a query.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - query: Query selecting foodColumns
+//   - args: Arguments referenced by the query
+//
+// Returns:
+//   - Array of Foods encoded as the defined model object
+func getFoods(pool *pgxpool.Pool, ctx context.Context, query string, args ...interface{}) ([]*model.Food, error) {
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get foods from server; error: %v", err)
+	}
+	defer rows.Close()
+
+	foods := []*model.Food{}
+	for rows.Next() {
+		food, err := scanFood(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse foods into struct; error: %v", err)
+		}
+		foods = append(foods, food)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return foods, nil
+}
+
+// Search reference foods by description.
+// Foods sharing more words with the query rank higher, so that ingredient
+// names like "raw chicken breast" still find "Chicken, breast, meat only, raw".
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - query: Words to search for
+//   - limit: Maximum number of foods to return
+//
+// Returns:
+//   - Array of Foods encoded as the defined model object, best match first
+func SearchFoods(pool *pgxpool.Pool, ctx context.Context, query string, limit int) ([]*model.Food, error) {
+	return getFoods(
+		pool,
+		ctx,
+		`
+		WITH q AS (
+			SELECT NULLIF(REPLACE(plainto_tsquery('english', $1)::TEXT, '&', '|'), '')::TSQUERY AS terms
+		)
+		SELECT `+foodColumns+`
+		FROM food f, q
+		WHERE to_tsvector('english', f.description) @@ q.terms
+		ORDER BY ts_rank(to_tsvector('english', f.description), q.terms) DESC, LENGTH(f.description), f.fdc_id
+		LIMIT $2
+		`,
+		query,
+		limit,
+	)
+}
+
+// Get a reference food from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - fdc_id: FoodData Central ID of the food
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - Food encoded as the defined model object
+func GetFoodById(pool *pgxpool.Pool, fdc_id string, ctx context.Context) (*model.Food, error) {
+	foods, err := getFoods(pool, ctx, `SELECT `+foodColumns+` FROM food f WHERE f.fdc_id = $1`, fdc_id)
+	if err != nil {
+		return nil, err
+	}
+	if len(foods) == 0 {
+		return nil, apperr.NotFound("found no foods with provided id")
+	}
+	return foods[0], nil
+}
+
+// Get the reference food an ingredient is linked to.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_id: ID of the ingredient
+//
+// Returns:
+//   - Food encoded as the defined model object, or nil if the ingredient is not linked
+func GetFoodForIngredient(pool *pgxpool.Pool, ctx context.Context, ingredient_id string) (*model.Food, error) {
+	foods, err := getFoods(
+		pool,
+		ctx,
+		`SELECT `+foodColumns+` FROM food f JOIN ingredient i ON i.fdc_id = f.fdc_id WHERE i.ingredient_id = $1`,
+		ingredient_id,
+	)
+	if err != nil || len(foods) == 0 {
+		return nil, err
+	}
+	return foods[0], nil
+}
+
+// Insert or update a set of reference foods.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - foods: Foods to store
+//
+// Returns:
+//   - Error if the foods could not be stored
+func UpsertFoods(pool *pgxpool.Pool, ctx context.Context, foods []*model.Food) error {
+	placeholders := []string{"$1", "$2", "$3", "$4"}
+	updates := []string{"description = EXCLUDED.description", "data_type = EXCLUDED.data_type", "category = EXCLUDED.category"}
+	for i, column := range nutritionColumns {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+5))
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	query := `INSERT INTO food (fdc_id, description, data_type, category, ` + strings.Join(nutritionColumns, ", ") + `)
+		VALUES (` + strings.Join(placeholders, ", ") + `)
+		ON CONFLICT (fdc_id) DO UPDATE SET ` + strings.Join(updates, ", ")
+
+	for start := 0; start < len(foods); start += foodBatchSize {
+		end := min(start+foodBatchSize, len(foods))
+		batch := &pgx.Batch{}
+		for _, food := range foods[start:end] {
+			args := []interface{}{food.FdcID, food.Description, food.DataType, food.Category}
+			for _, field := range nutrition.Fields(food.Nutrition) {
+				args = append(args, *field)
+			}
+			batch.Queue(query, args...)
+		}
+		err := pool.SendBatch(ctx, batch).Close()
+		if err != nil {
+			return fmt.Errorf("failed to store foods; error: %v", err)
+		}
+	}
+	return nil
+}
+
+// Link an ingredient to a reference food, copying the food's nutrition data.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_id: ID of the ingredient
+//   - fdc_id: FoodData Central ID of the food
+//
+// Returns:
+//   - Error if the ingredient could not be linked
+func LinkIngredientToFood(pool *pgxpool.Pool, ctx context.Context, ingredient_id string, fdc_id string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE ingredient SET fdc_id = $1 WHERE ingredient_id = $2`, fdc_id, ingredient_id)
+	if err != nil {
+		return fmt.Errorf("failed to link ingredient to food; error: %v", err)
+	}
+
+	updates := []string{}
+	for _, column := range nutritionColumns {
+		updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", column, column))
+	}
+	columns := strings.Join(nutritionColumns, ", ")
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO ingredient_nutrition (ingredient_id, `+columns+`)
+		SELECT $1, `+columns+` FROM food WHERE fdc_id = $2
+		ON CONFLICT (ingredient_id) DO UPDATE SET `+strings.Join(updates, ", "),
+		ingredient_id,
+		fdc_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy food nutrition; error: %v", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,507 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Get the households a user belongs to.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the member
+//
+// Returns:
+//   - Array of Households encoded as the defined model object
+func GetHouseholdsForUser(pool *pgxpool.Pool, ctx context.Context, user_id string) ([]*model.Household, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT h.household_id, h.name
+		FROM household h
+		JOIN household_member hm ON h.household_id = hm.household_id
+		WHERE hm.user_id = $1
+		ORDER BY h.name
+		`,
+		user_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get households from server; error: %v", err)
+	}
+
+	households := []*model.Household{}
+	for rows.Next() {
+		var household model.Household
+		err := rows.Scan(&household.HouseholdID, &household.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse households into struct; error: %v", err)
+		}
+		households = append(households, &household)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return households, nil
+}
+
+// Get a household from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - household_id: ID of household to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - Household encoded as the defined model object
+func GetHouseholdById(pool *pgxpool.Pool, household_id string, ctx context.Context) (*model.Household, error) {
+	var household model.Household
+	err := pool.QueryRow(
+		ctx,
+		`SELECT household_id, name FROM household WHERE household_id = $1`,
+		household_id,
+	).Scan(&household.HouseholdID, &household.Name)
+	if err == pgx.ErrNoRows {
+		return nil, apperr.NotFound("found no households with provided id")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household; error: %v", err)
+	}
+	return &household, nil
+}
+
+// Get the members of a household along with their roles.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - household_id: ID of the household
+//
+// Returns:
+//   - Array of HouseholdMembers encoded as the defined model object
+func GetHouseholdMembers(pool *pgxpool.Pool, ctx context.Context, household_id string) ([]*model.HouseholdMember, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT u.user_id, u.name, hm.role
+		FROM household_member hm
+		JOIN user_account u ON hm.user_id = u.user_id
+		WHERE hm.household_id = $1
+		ORDER BY u.name
+		`,
+		household_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household members from server; error: %v", err)
+	}
+
+	members := []*model.HouseholdMember{}
+	for rows.Next() {
+		var user model.User
+		var member model.HouseholdMember
+		err := rows.Scan(&user.UserID, &user.Name, &member.Role)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse household members into struct; error: %v", err)
+		}
+		member.User = &user
+		members = append(members, &member)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return members, nil
+}
+
+// Get every household role held by a user.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the member
+//
+// Returns:
+//   - Map of household IDs to the user's role within that household
+func GetHouseholdRoles(pool *pgxpool.Pool, ctx context.Context, user_id string) (map[string]model.HouseholdRole, error) {
+	rows, err := pool.Query(
+		ctx,
+		`SELECT household_id, role FROM household_member WHERE user_id = $1`,
+		user_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household roles from server; error: %v", err)
+	}
+
+	roles := map[string]model.HouseholdRole{}
+	for rows.Next() {
+		var household_id string
+		var role model.HouseholdRole
+		err := rows.Scan(&household_id, &role)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse household roles; error: %v", err)
+		}
+		roles[household_id] = role
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return roles, nil
+}
+
+// Get the role a user holds in a single household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - household_id: ID of the household
+//   - user_id: ID of the member
+//
+// Returns:
+//   - Role of the user, or nil if they are not a member
+func GetHouseholdRole(pool *pgxpool.Pool, ctx context.Context, household_id string, user_id string) (*model.HouseholdRole, error) {
+	var role model.HouseholdRole
+	err := pool.QueryRow(
+		ctx,
+		`SELECT role FROM household_member WHERE household_id = $1 AND user_id = $2`,
+		household_id,
+		user_id,
+	).Scan(&role)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household role; error: %v", err)
+	}
+	return &role, nil
+}
+
+// Create a new household with the given user as its owner.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - name: Name of the household
+//   - owner_id: ID of the user creating the household
+//
+// Returns:
+//   - ID of the newly created household
+func CreateHousehold(pool *pgxpool.Pool, ctx context.Context, name string, owner_id string) (string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var household_id string
+	err = tx.QueryRow(
+		ctx,
+		`INSERT INTO household (name) VALUES ($1) RETURNING household_id::TEXT`,
+		name,
+	).Scan(&household_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create household; error: %v", err)
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		`INSERT INTO household_member (household_id, user_id, role) VALUES ($1, $2, $3)`,
+		household_id,
+		owner_id,
+		model.HouseholdRoleOwner,
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to add household owner; error: %v", err)
+	}
+
+	return household_id, tx.Commit(ctx)
+}
+
+// Get a collection of household invitations from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - where: map of filters to apply as "WHERE" clauses in the query
+//
+// Returns:
+//   - Array of HouseholdInvitations encoded as the defined model object
+func GetHouseholdInvitations(pool *pgxpool.Pool, ctx context.Context, where map[string]interface{}) ([]*model.HouseholdInvitation, error) {
+	query := `
+		SELECT hi.invitation_id, hi.role, hi.status,
+			h.household_id, h.name,
+			u.user_id, u.name,
+			ib.user_id, ib.name
+		FROM household_invitation hi
+		JOIN household h ON hi.household_id = h.household_id
+		JOIN user_account u ON hi.user_id = u.user_id
+		JOIN user_account ib ON hi.invited_by = ib.user_id
+	`
+	whereQuery, whereArgs := BuildWhereQuery(where)
+
+	rows, err := pool.Query(
+		ctx,
+		query+whereQuery+" ORDER BY hi.created_at DESC",
+		whereArgs...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get household invitations from server; error: %v", err)
+	}
+
+	invitations := []*model.HouseholdInvitation{}
+	for rows.Next() {
+		var invitation model.HouseholdInvitation
+		var household model.Household
+		var user model.User
+		var invitedBy model.User
+		err := rows.Scan(
+			&invitation.InvitationID,
+			&invitation.Role,
+			&invitation.Status,
+			&household.HouseholdID,
+			&household.Name,
+			&user.UserID,
+			&user.Name,
+			&invitedBy.UserID,
+			&invitedBy.Name,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse household invitations into struct; error: %v", err)
+		}
+		invitation.Household = &household
+		invitation.User = &user
+		invitation.InvitedBy = &invitedBy
+		invitations = append(invitations, &invitation)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return invitations, nil
+}
+
+// Get a household invitation from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - invitation_id: ID of invitation to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - HouseholdInvitation encoded as the defined model object
+func GetHouseholdInvitationById(pool *pgxpool.Pool, invitation_id string, ctx context.Context) (*model.HouseholdInvitation, error) {
+	where := map[string]interface{}{"hi.invitation_id": invitation_id}
+	invitations, err := GetHouseholdInvitations(pool, ctx, where)
+	if err != nil {
+		return nil, err
+	}
+	if len(invitations) == 0 {
+		return nil, apperr.NotFound("found no household invitations with provided id")
+	}
+	return invitations[0], nil
+}
+
+// Invite a user to join a household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - household_id: ID of the household
+//   - user_id: ID of the user being invited
+//   - invited_by: ID of the member sending the invitation
+//   - role: Role the user will hold once they accept
+//
+// Returns:
+//   - ID of the newly created invitation
+func CreateHouseholdInvitation(pool *pgxpool.Pool, ctx context.Context, household_id string, user_id string, invited_by string, role model.HouseholdRole) (string, error) {
+	var invitation_id string
+	err := pool.QueryRow(
+		ctx,
+		`
+		INSERT INTO household_invitation (household_id, user_id, invited_by, role)
+		VALUES ($1, $2, $3, $4)
+		RETURNING invitation_id::TEXT
+		`,
+		household_id,
+		user_id,
+		invited_by,
+		role,
+	).Scan(&invitation_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create household invitation; error: %v", err)
+	}
+	return invitation_id, nil
+}
+
+// Accept or decline a pending household invitation.
+// Accepting adds the invited user to the household with the invited role.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - invitation_id: ID of the invitation
+//   - accept: Whether the invitation was accepted
+//
+// Returns:
+//   - Error if the invitation could not be updated
+func RespondToHouseholdInvitation(pool *pgxpool.Pool, ctx context.Context, invitation_id string, accept bool) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	status := model.InvitationStatusDeclined
+	if accept {
+		status = model.InvitationStatusAccepted
+	}
+
+	var household_id, user_id string
+	var role model.HouseholdRole
+	err = tx.QueryRow(
+		ctx,
+		`
+		UPDATE household_invitation SET status = $1
+		WHERE invitation_id = $2 AND status = $3
+		RETURNING household_id::TEXT, user_id::TEXT, role
+		`,
+		status,
+		invitation_id,
+		model.InvitationStatusPending,
+	).Scan(&household_id, &user_id, &role)
+	if err == pgx.ErrNoRows {
+		return apperr.NotFound("found no pending household invitation with provided id")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update household invitation; error: %v", err)
+	}
+
+	if accept {
+		_, err = tx.Exec(
+			ctx,
+			`
+			INSERT INTO household_member (household_id, user_id, role)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (household_id, user_id) DO UPDATE SET role = EXCLUDED.role
+			`,
+			household_id,
+			user_id,
+			role,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to add household member; error: %v", err)
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Ensure a household still has at least one owner after a membership change.
+//
+// Parameters:
+//   - tx: Open transaction the change was made in
+//   - ctx: pgx connection context
+//   - household_id: ID of the household
+//
+// Returns:
+//   - Error if the household would be left without an owner
+func ensureHouseholdOwner(tx pgx.Tx, ctx context.Context, household_id string) error {
+	var owners int
+	err := tx.QueryRow(
+		ctx,
+		`SELECT COUNT(*) FROM household_member WHERE household_id = $1 AND role = $2`,
+		household_id,
+		model.HouseholdRoleOwner,
+	).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("failed to count household owners; error: %v", err)
+	}
+	if owners == 0 {
+		return apperr.Conflict("a household must keep at least one owner")
+	}
+	return nil
+}
+
+// Change the role of an existing household member.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - household_id: ID of the household
+//   - user_id: ID of the member
+//   - role: New role for the member
+//
+// Returns:
+//   - Error if the role could not be changed
+func SetHouseholdMemberRole(pool *pgxpool.Pool, ctx context.Context, household_id string, user_id string, role model.HouseholdRole) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		`UPDATE household_member SET role = $1 WHERE household_id = $2 AND user_id = $3`,
+		role,
+		household_id,
+		user_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update household member; error: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("user is not a member of this household")
+	}
+	if err := ensureHouseholdOwner(tx, ctx, household_id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Remove a member from a household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - household_id: ID of the household
+//   - user_id: ID of the member to remove
+//
+// Returns:
+//   - Error if the member could not be removed
+func RemoveHouseholdMember(pool *pgxpool.Pool, ctx context.Context, household_id string, user_id string) error {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(
+		ctx,
+		`DELETE FROM household_member WHERE household_id = $1 AND user_id = $2`,
+		household_id,
+		user_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove household member; error: %v", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return apperr.NotFound("user is not a member of this household")
+	}
+	if err := ensureHouseholdOwner(tx, ctx, household_id); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
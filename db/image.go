@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/images"
+)
+
+// Build an image from nullable columns of a LEFT JOIN.
+//
+// Parameters:
+//   - id: Scanned image ID, nil when there is no image
+//   - contentType: Scanned content type
+//   - width: Scanned width in pixels
+//   - height: Scanned height in pixels
+//
+// Returns:
+//   - The image, nil if id is nil
+func scanImage(id *string, contentType *string, width *int, height *int) *model.Image {
+	if id == nil || contentType == nil || width == nil || height == nil {
+		return nil
+	}
+	return &model.Image{
+		ImageID:      *id,
+		URL:          images.URL(*id),
+		ThumbnailURL: images.ThumbnailURL(*id),
+		ContentType:  *contentType,
+		Width:        *width,
+		Height:       *height,
+	}
+}
+
+// Record an uploaded image whose files are already in the blob store.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user uploading the image
+//   - image: Details of the image
+//   - byte_size: Size of the original upload in bytes
+//
+// Returns:
+//   - Error if the image could not be recorded
+func CreateImage(pool *pgxpool.Pool, ctx context.Context, user_id string, image *model.Image, byte_size int) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		INSERT INTO image (image_id, user_id, content_type, width, height, byte_size)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		image.ImageID,
+		user_id,
+		image.ContentType,
+		image.Width,
+		image.Height,
+		byte_size,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record image; error: %v", err)
+	}
+	return nil
+}
+
+// Remove the record of an image.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - image_id: ID of the image
+//
+// Returns:
+//   - Error if the image could not be removed
+func DeleteImage(pool *pgxpool.Pool, ctx context.Context, image_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM image WHERE image_id = $1`, image_id)
+	if err != nil {
+		return fmt.Errorf("failed to remove image; error: %v", err)
+	}
+	return nil
+}
+
+// Set or clear a recipe's cover image.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//   - image_id: ID of the new image, nil to clear it
+//
+// Returns:
+//   - ID of the image that was replaced, if any
+func SetRecipeImage(pool *pgxpool.Pool, ctx context.Context, recipe_id string, image_id *string) (*string, error) {
+	return replaceImage(
+		pool,
+		ctx,
+		`SELECT image_id FROM recipe WHERE recipe_id = $1 FOR UPDATE`,
+		`UPDATE recipe SET image_id = $2 WHERE recipe_id = $1`,
+		[]interface{}{recipe_id},
+		image_id,
+	)
+}
+
+// Set or clear the image of a recipe step.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//   - position: Position of the step
+//   - image_id: ID of the new image, nil to clear it
+//
+// Returns:
+//   - ID of the image that was replaced, if any
+func SetRecipeStepImage(pool *pgxpool.Pool, ctx context.Context, recipe_id string, position int, image_id *string) (*string, error) {
+	return replaceImage(
+		pool,
+		ctx,
+		`SELECT image_id FROM recipe_step WHERE recipe_id = $1 AND position = $2 FOR UPDATE`,
+		`UPDATE recipe_step SET image_id = $3 WHERE recipe_id = $1 AND position = $2`,
+		[]interface{}{recipe_id, position},
+		image_id,
+	)
+}
+
+// Swap the image referenced by a row, returning the previous one.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - selectQuery: Query locking the row and selecting its image ID
+//   - updateQuery: Query setting the image ID, given as the argument after args
+//   - args: Arguments identifying the row
+//   - image_id: ID of the new image, nil to clear it
+//
+// Returns:
+//   - ID of the image that was replaced, if any
+func replaceImage(pool *pgxpool.Pool, ctx context.Context, selectQuery string, updateQuery string, args []interface{}, image_id *string) (*string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var previous *string
+	err = tx.QueryRow(ctx, selectQuery, args...).Scan(&previous)
+	if err == pgx.ErrNoRows {
+		return nil, apperr.NotFound("found nothing to attach the image to")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current image; error: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, updateQuery, append(args, image_id)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set image; error: %v", err)
+	}
+	return previous, tx.Commit(ctx)
+}
+
+// Get the IDs of every image attached to a recipe or its steps.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//
+// Returns:
+//   - Array of image IDs
+func GetRecipeImageIds(pool *pgxpool.Pool, ctx context.Context, recipe_id string) ([]string, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT image_id FROM recipe WHERE recipe_id = $1 AND image_id IS NOT NULL
+		UNION
+		SELECT image_id FROM recipe_step WHERE recipe_id = $1 AND image_id IS NOT NULL
+		`,
+		recipe_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipe images; error: %v", err)
+	}
+	image_ids := []string{}
+	for rows.Next() {
+		var image_id string
+		err := rows.Scan(&image_id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recipe images; error: %v", err)
+		}
+		image_ids = append(image_ids, image_id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+	return image_ids, nil
+}
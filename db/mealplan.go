@@ -0,0 +1,268 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Build the condition selecting a single meal plan.
+// A plan belongs either to a household or, when no household is given,
+// to a user's personal calendar.
+//
+// Parameters:
+//   - user_id: ID of the user owning a personal plan
+//   - household_id: ID of the household owning the plan, if any
+//   - argPosition: Position of the first query argument to use
+//
+// Returns:
+//   - Tuple of SQL condition with corresponding args in order
+func mealPlanScope(user_id string, household_id *string, argPosition int) (string, []interface{}) {
+	if household_id != nil {
+		return fmt.Sprintf("mpe.household_id = $%d", argPosition), []interface{}{*household_id}
+	}
+	return fmt.Sprintf("mpe.household_id IS NULL AND mpe.user_id = $%d", argPosition), []interface{}{user_id}
+}
+
+// Get meal plan entries matching a condition, loading the scheduled recipes.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - condition: SQL condition to filter entries with
+//   - args: Arguments referenced by the condition
+//
+// Returns:
+//   - Array of MealPlanEntries encoded as the defined model object
+func getMealPlanEntries(pool *pgxpool.Pool, ctx context.Context, condition string, args []interface{}) ([]*model.MealPlanEntry, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT mpe.meal_plan_entry_id, mpe.plan_date, mpe.slot, mpe.servings, mpe.notes, mpe.recipe_id::TEXT,
+			u.user_id, u.name, h.household_id, h.name
+		FROM meal_plan_entry mpe
+		JOIN user_account u ON mpe.user_id = u.user_id
+		LEFT JOIN household h ON mpe.household_id = h.household_id
+		WHERE `+condition+`
+		ORDER BY mpe.plan_date, CASE mpe.slot
+			WHEN 'BREAKFAST' THEN 1 WHEN 'LUNCH' THEN 2 WHEN 'DINNER' THEN 3 ELSE 4 END,
+			mpe.meal_plan_entry_id
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get meal plan from server; error: %v", err)
+	}
+
+	entries := []*model.MealPlanEntry{}
+	recipeIDs := []string{}
+	for rows.Next() {
+		var entry model.MealPlanEntry
+		var user model.User
+		var recipeID string
+		var householdID, householdName *string
+		err := rows.Scan(
+			&entry.MealPlanEntryID,
+			&entry.Date,
+			&entry.Slot,
+			&entry.Servings,
+			&entry.Notes,
+			&recipeID,
+			&user.UserID,
+			&user.Name,
+			&householdID,
+			&householdName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse meal plan entries into struct; error: %v", err)
+		}
+		entry.User = &user
+		entry.Household = scanHousehold(householdID, householdName)
+		entries = append(entries, &entry)
+		recipeIDs = append(recipeIDs, recipeID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	// Load each distinct recipe once
+	recipes := map[string]*model.Recipe{}
+	for i, recipeID := range recipeIDs {
+		recipe, ok := recipes[recipeID]
+		if !ok {
+			recipe, err = GetRecipeById(pool, recipeID, ctx)
+			if err != nil {
+				return nil, err
+			}
+			recipes[recipeID] = recipe
+		}
+		entries[i].Recipe = recipe
+	}
+
+	return entries, nil
+}
+
+// Get the entries of a meal plan within a date range.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning a personal plan
+//   - household_id: ID of the household owning the plan, if any
+//   - from: First date to include
+//   - to: Last date to include
+//
+// Returns:
+//   - Array of MealPlanEntries encoded as the defined model object
+func GetMealPlanEntries(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string, from time.Time, to time.Time) ([]*model.MealPlanEntry, error) {
+	scope, args := mealPlanScope(user_id, household_id, 3)
+	condition := "mpe.plan_date >= $1 AND mpe.plan_date <= $2 AND " + scope
+	return getMealPlanEntries(pool, ctx, condition, append([]interface{}{from, to}, args...))
+}
+
+// Get a meal plan entry from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - meal_plan_entry_id: ID of the entry to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - MealPlanEntry encoded as the defined model object
+func GetMealPlanEntryById(pool *pgxpool.Pool, meal_plan_entry_id string, ctx context.Context) (*model.MealPlanEntry, error) {
+	entries, err := getMealPlanEntries(pool, ctx, "mpe.meal_plan_entry_id = $1", []interface{}{meal_plan_entry_id})
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, apperr.NotFound("found no meal plan entries with provided id")
+	}
+	return entries[0], nil
+}
+
+// Schedule a recipe onto a meal plan.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user adding the entry
+//   - input: Details of the entry to add
+//
+// Returns:
+//   - ID of the newly created entry
+func CreateMealPlanEntry(pool *pgxpool.Pool, ctx context.Context, user_id string, input model.NewMealPlanEntry) (string, error) {
+	notes := ""
+	if input.Notes != nil {
+		notes = *input.Notes
+	}
+
+	var meal_plan_entry_id string
+	err := pool.QueryRow(
+		ctx,
+		`
+		INSERT INTO meal_plan_entry (user_id, household_id, recipe_id, plan_date, slot, servings, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING meal_plan_entry_id::TEXT
+		`,
+		user_id,
+		input.HouseholdID,
+		input.RecipeID,
+		input.Date,
+		input.Slot,
+		input.Servings,
+		notes,
+	).Scan(&meal_plan_entry_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create meal plan entry; error: %v", err)
+	}
+	return meal_plan_entry_id, nil
+}
+
+// Move a meal plan entry to another date and slot.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - meal_plan_entry_id: ID of the entry to move
+//   - date: New date of the entry
+//   - slot: New meal slot of the entry
+//
+// Returns:
+//   - Error if the entry could not be moved
+func MoveMealPlanEntry(pool *pgxpool.Pool, ctx context.Context, meal_plan_entry_id string, date time.Time, slot model.MealSlot) error {
+	_, err := pool.Exec(
+		ctx,
+		`UPDATE meal_plan_entry SET plan_date = $1, slot = $2 WHERE meal_plan_entry_id = $3`,
+		date,
+		slot,
+		meal_plan_entry_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to move meal plan entry; error: %v", err)
+	}
+	return nil
+}
+
+// Remove an entry from a meal plan.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - meal_plan_entry_id: ID of the entry to remove
+//
+// Returns:
+//   - Error if the entry could not be removed
+func DeleteMealPlanEntry(pool *pgxpool.Pool, ctx context.Context, meal_plan_entry_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM meal_plan_entry WHERE meal_plan_entry_id = $1`, meal_plan_entry_id)
+	if err != nil {
+		return fmt.Errorf("failed to remove meal plan entry; error: %v", err)
+	}
+	return nil
+}
+
+// Copy a week of a meal plan onto another week.
+// Entries keep their weekday, slot, servings and notes. Entries the other week already has,
+// the same recipe in the same slot on the same day, are skipped, so copying is idempotent
+// and copying a week onto itself changes nothing.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user copying the plan
+//   - household_id: ID of the household owning the plan, if any
+//   - fromWeekStart: First day of the week to copy
+//   - toWeekStart: First day of the week to copy onto
+//
+// Returns:
+//   - Error if the week could not be copied
+func CopyMealPlanWeek(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string, fromWeekStart time.Time, toWeekStart time.Time) error {
+	offset := int(toWeekStart.Sub(fromWeekStart).Hours() / 24)
+	scope, args := mealPlanScope(user_id, household_id, 4)
+
+	_, err := pool.Exec(
+		ctx,
+		`
+		INSERT INTO meal_plan_entry (user_id, household_id, recipe_id, plan_date, slot, servings, notes)
+		SELECT $1, mpe.household_id, mpe.recipe_id, mpe.plan_date + $2::INT, mpe.slot, mpe.servings, mpe.notes
+		FROM meal_plan_entry mpe
+		WHERE mpe.plan_date >= $3 AND mpe.plan_date < $3::DATE + 7 AND `+scope+`
+			AND NOT EXISTS (
+				SELECT 1 FROM meal_plan_entry copied
+				WHERE copied.plan_date = mpe.plan_date + $2::INT
+					AND copied.slot = mpe.slot
+					AND copied.recipe_id IS NOT DISTINCT FROM mpe.recipe_id
+					AND copied.household_id IS NOT DISTINCT FROM mpe.household_id
+					AND (mpe.household_id IS NOT NULL OR copied.user_id = $1)
+			)`,
+		append([]interface{}{user_id, offset, fromWeekStart}, args...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to copy meal plan week; error: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/nutrition"
+)
+
+// Columns of ingredient_nutrition, in the order of nutrition.Fields.
+var nutritionColumns = []string{
+	"calories",
+	"protein_g",
+	"fat_g",
+	"saturated_fat_g",
+	"carbohydrates_g",
+	"sugar_g",
+	"fiber_g",
+	"sodium_mg",
+	"cholesterol_mg",
+	"potassium_mg",
+	"calcium_mg",
+	"iron_mg",
+	"vitamin_a_ug",
+	"vitamin_c_mg",
+	"vitamin_d_ug",
+}
+
+// Get the nutrients per 100 g of a set of ingredients.
+// Ingredients without nutrition data are left out of the result.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_ids: IDs of the ingredients
+//
+// Returns:
+//   - Map of ingredient IDs to their Nutrition encoded as the defined model object
+func GetIngredientNutrition(pool *pgxpool.Pool, ctx context.Context, ingredient_ids []string) (map[string]*model.Nutrition, error) {
+	rows, err := pool.Query(
+		ctx,
+		`SELECT ingredient_id::TEXT, `+strings.Join(nutritionColumns, ", ")+`
+		FROM ingredient_nutrition
+		WHERE ingredient_id::TEXT = ANY($1::TEXT[])`,
+		ingredient_ids,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient nutrition from server; error: %v", err)
+	}
+
+	facts := map[string]*model.Nutrition{}
+	for rows.Next() {
+		var ingredient_id string
+		var n model.Nutrition
+		dest := []interface{}{&ingredient_id}
+		for _, field := range nutrition.Fields(&n) {
+			dest = append(dest, field)
+		}
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ingredient nutrition into struct; error: %v", err)
+		}
+		facts[ingredient_id] = &n
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return facts, nil
+}
+
+// Set the nutrients per 100 g of an ingredient.
+// Nutrients left out of the input keep their current value, or zero if there is none.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_id: ID of the ingredient
+//   - input: Nutrients to set
+//
+// Returns:
+//   - Error if the nutrition could not be stored
+func SetIngredientNutrition(pool *pgxpool.Pool, ctx context.Context, ingredient_id string, input model.NutritionInput) error {
+	values := []interface{}{
+		input.Calories,
+		input.ProteinG,
+		input.FatG,
+		input.SaturatedFatG,
+		input.CarbohydratesG,
+		input.SugarG,
+		input.FiberG,
+		input.SodiumMg,
+		input.CholesterolMg,
+		input.PotassiumMg,
+		input.CalciumMg,
+		input.IronMg,
+		input.VitaminAUg,
+		input.VitaminCMg,
+		input.VitaminDUg,
+	}
+
+	placeholders := []string{}
+	updates := []string{}
+	for i, column := range nutritionColumns {
+		placeholders = append(placeholders, fmt.Sprintf("COALESCE($%d::NUMERIC, 0)", i+2))
+		updates = append(updates, fmt.Sprintf("%s = COALESCE($%d, ingredient_nutrition.%s)", column, i+2, column))
+	}
+
+	_, err := pool.Exec(
+		ctx,
+		`INSERT INTO ingredient_nutrition (ingredient_id, `+strings.Join(nutritionColumns, ", ")+`)
+		VALUES ($1, `+strings.Join(placeholders, ", ")+`)
+		ON CONFLICT (ingredient_id) DO UPDATE SET `+strings.Join(updates, ", "),
+		append([]interface{}{ingredient_id}, values...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set ingredient nutrition; error: %v", err)
+	}
+	return nil
+}
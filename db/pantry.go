@@ -0,0 +1,392 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Build the condition selecting the pantry of a user or household.
+//
+// Parameters:
+//   - user_id: ID of the user owning a personal pantry
+//   - household_id: ID of the household owning the pantry, if any
+//   - argPosition: Position of the first query argument to use
+//
+// Returns:
+//   - Tuple of SQL condition with corresponding args in order
+func pantryScope(user_id string, household_id *string, argPosition int) (string, []interface{}) {
+	if household_id != nil {
+		return fmt.Sprintf("pi.household_id = $%d", argPosition), []interface{}{*household_id}
+	}
+	return fmt.Sprintf("pi.household_id IS NULL AND pi.user_id = $%d", argPosition), []interface{}{user_id}
+}
+
+// Get pantry items matching a condition.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - condition: SQL condition to filter items with
+//   - args: Arguments referenced by the condition
+//
+// Returns:
+//   - Array of PantryItems encoded as the defined model object
+func getPantryItems(pool *pgxpool.Pool, ctx context.Context, condition string, args []interface{}) ([]*model.PantryItem, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT pi.pantry_item_id, pi.quantity, pi.unit, pi.purchased_on, pi.expires_on,
+			i.ingredient_id, i.name, i.description, i.category, iu.user_id, iu.name,
+			u.user_id, u.name, h.household_id, h.name
+		FROM pantry_item pi
+		JOIN ingredient i ON pi.ingredient_id = i.ingredient_id
+		JOIN user_account iu ON i.user_id = iu.user_id
+		JOIN user_account u ON pi.user_id = u.user_id
+		LEFT JOIN household h ON pi.household_id = h.household_id
+		WHERE `+condition+`
+		ORDER BY pi.expires_on NULLS LAST, i.name, pi.pantry_item_id
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pantry from server; error: %v", err)
+	}
+
+	items := []*model.PantryItem{}
+	for rows.Next() {
+		var item model.PantryItem
+		var ingredient model.Ingredient
+		var ingredientUser, user model.User
+		var householdID, householdName *string
+		err := rows.Scan(
+			&item.PantryItemID,
+			&item.Quantity,
+			&item.Unit,
+			&item.PurchasedOn,
+			&item.ExpiresOn,
+			&ingredient.IngredientID,
+			&ingredient.Name,
+			&ingredient.Description,
+			&ingredient.Category,
+			&ingredientUser.UserID,
+			&ingredientUser.Name,
+			&user.UserID,
+			&user.Name,
+			&householdID,
+			&householdName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pantry items into struct; error: %v", err)
+		}
+		ingredient.User = &ingredientUser
+		item.Ingredient = &ingredient
+		item.User = &user
+		item.Household = scanHousehold(householdID, householdName)
+		items = append(items, &item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return items, nil
+}
+
+// Get the pantry of a user or household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning a personal pantry
+//   - household_id: ID of the household owning the pantry, if any
+//
+// Returns:
+//   - Array of PantryItems encoded as the defined model object
+func GetPantryItems(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string) ([]*model.PantryItem, error) {
+	scope, args := pantryScope(user_id, household_id, 1)
+	return getPantryItems(pool, ctx, scope, args)
+}
+
+// Get the pantry items of a user or household that expire within a number of days.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning a personal pantry
+//   - household_id: ID of the household owning the pantry, if any
+//   - days: Number of days from today to look ahead
+//
+// Returns:
+//   - Array of PantryItems encoded as the defined model object, soonest first
+func GetExpiringPantryItems(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string, days int) ([]*model.PantryItem, error) {
+	scope, args := pantryScope(user_id, household_id, 2)
+	condition := "pi.expires_on IS NOT NULL AND pi.expires_on <= CURRENT_DATE + $1::INT AND " + scope
+	return getPantryItems(pool, ctx, condition, append([]interface{}{days}, args...))
+}
+
+// Get a pantry item from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - pantry_item_id: ID of the item to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - PantryItem encoded as the defined model object
+func GetPantryItemById(pool *pgxpool.Pool, pantry_item_id string, ctx context.Context) (*model.PantryItem, error) {
+	items, err := getPantryItems(pool, ctx, "pi.pantry_item_id = $1", []interface{}{pantry_item_id})
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, apperr.NotFound("found no pantry items with provided id")
+	}
+	return items[0], nil
+}
+
+// Add an item to a pantry.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user adding the item
+//   - input: Details of the item
+//
+// Returns:
+//   - ID of the newly created item
+func CreatePantryItem(pool *pgxpool.Pool, ctx context.Context, user_id string, input model.NewPantryItem) (string, error) {
+	var pantry_item_id string
+	err := pool.QueryRow(
+		ctx,
+		`
+		INSERT INTO pantry_item (user_id, household_id, ingredient_id, quantity, unit, purchased_on, expires_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING pantry_item_id::TEXT
+		`,
+		user_id,
+		input.HouseholdID,
+		input.IngredientID,
+		input.Quantity,
+		input.Unit,
+		input.PurchasedOn,
+		input.ExpiresOn,
+	).Scan(&pantry_item_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create pantry item; error: %v", err)
+	}
+	return pantry_item_id, nil
+}
+
+// Update the fields of a pantry item that are provided.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - pantry_item_id: ID of the item
+//   - input: Fields to change
+//
+// Returns:
+//   - Error if the item could not be updated
+func UpdatePantryItem(pool *pgxpool.Pool, ctx context.Context, pantry_item_id string, input model.UpdatePantryItem) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		UPDATE pantry_item SET
+			quantity = COALESCE($1, quantity),
+			unit = COALESCE($2, unit),
+			purchased_on = COALESCE($3, purchased_on),
+			expires_on = COALESCE($4, expires_on)
+		WHERE pantry_item_id = $5
+		`,
+		input.Quantity,
+		input.Unit,
+		input.PurchasedOn,
+		input.ExpiresOn,
+		pantry_item_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update pantry item; error: %v", err)
+	}
+	return nil
+}
+
+// Remove an item from a pantry.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - pantry_item_id: ID of the item
+//
+// Returns:
+//   - Error if the item could not be removed
+func DeletePantryItem(pool *pgxpool.Pool, ctx context.Context, pantry_item_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM pantry_item WHERE pantry_item_id = $1`, pantry_item_id)
+	if err != nil {
+		return fmt.Errorf("failed to remove pantry item; error: %v", err)
+	}
+	return nil
+}
+
+// Subtract what is on hand in a pantry from the ingredients needed for a shopping list.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning a personal pantry
+//   - household_id: ID of the household owning the pantry, if any
+//   - needs: Ingredients needed for the shopping list
+//
+// Returns:
+//   - Error if the pantry could not be read
+func subtractPantry(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string, needs []*shoppingNeed) error {
+	items, err := GetPantryItems(pool, ctx, user_id, household_id)
+	if err != nil {
+		return err
+	}
+
+	byIngredient := map[string]*shoppingNeed{}
+	for _, need := range needs {
+		byIngredient[need.ingredientID] = need
+	}
+	for _, item := range items {
+		need, ok := byIngredient[item.Ingredient.IngredientID]
+		if !ok || item.Quantity == nil {
+			continue
+		}
+		need.total.Subtract(*item.Quantity, deref(item.Unit))
+	}
+	return nil
+}
+
+// Deduct the ingredients of a cooked recipe from a pantry.
+// Items expiring soonest are used first, and items that are used up are removed.
+// Ingredients with no matching pantry item, or in units that cannot be converted, are skipped.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning a personal pantry
+//   - household_id: ID of the household owning the pantry, if any
+//   - recipe: Recipe that was cooked
+//   - servings: Servings cooked, if different to the recipe
+//
+// Returns:
+//   - Error if the pantry could not be updated
+func DeductRecipeFromPantry(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string, recipe *model.Recipe, servings *int) error {
+	scale := 1.0
+	if servings != nil && recipe.Servings != nil && *recipe.Servings > 0 {
+		scale = float64(*servings) / float64(*recipe.Servings)
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	byIngredient, err := lockPantryItems(tx, ctx, user_id, household_id)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range recipe.IngredientLines {
+		if line.Quantity == nil {
+			continue
+		}
+		needed := *line.Quantity * scale
+		for _, item := range byIngredient[line.Ingredient.IngredientID] {
+			if needed <= 0 {
+				break
+			}
+			// Work in the pantry item's unit
+			neededInItemUnit, ok := units.ConvertNamed(needed, deref(line.Unit), deref(item.Unit))
+			if !ok || *item.Quantity <= 0 {
+				continue
+			}
+			used := math.Min(neededInItemUnit, *item.Quantity)
+			remaining := *item.Quantity - used
+			*item.Quantity = remaining
+			needed -= needed * used / neededInItemUnit
+
+			if err := updatePantryQuantity(tx, ctx, item.PantryItemID, remaining); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Lock the measured items of a pantry until a transaction ends, so deductions made at the
+// same time, or edits to the pantry, wait rather than being overwritten.
+//
+// Parameters:
+//   - tx: Open transaction to lock the items in
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning a personal pantry
+//   - household_id: ID of the household owning the pantry, if any
+//
+// Returns:
+//   - Map of ingredient ID to its pantry items, soonest expiring first
+func lockPantryItems(tx pgx.Tx, ctx context.Context, user_id string, household_id *string) (map[string][]*model.PantryItem, error) {
+	scope, args := pantryScope(user_id, household_id, 1)
+	rows, err := tx.Query(
+		ctx,
+		`
+		SELECT pi.pantry_item_id, pi.ingredient_id, pi.quantity, pi.unit
+		FROM pantry_item pi
+		WHERE pi.quantity IS NOT NULL AND `+scope+`
+		ORDER BY pi.expires_on NULLS LAST, pi.pantry_item_id
+		FOR UPDATE
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock pantry items; error: %v", err)
+	}
+	defer rows.Close()
+
+	byIngredient := map[string][]*model.PantryItem{}
+	for rows.Next() {
+		var item model.PantryItem
+		var ingredientID string
+		if err := rows.Scan(&item.PantryItemID, &ingredientID, &item.Quantity, &item.Unit); err != nil {
+			return nil, fmt.Errorf("failed to parse pantry items into struct; error: %v", err)
+		}
+		byIngredient[ingredientID] = append(byIngredient[ingredientID], &item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+	return byIngredient, nil
+}
+
+// Set the remaining quantity of a pantry item, removing it once used up.
+//
+// Parameters:
+//   - tx: Open transaction to make the change in
+//   - ctx: pgx connection context
+//   - pantry_item_id: ID of the item
+//   - remaining: Quantity left
+//
+// Returns:
+//   - Error if the item could not be updated
+func updatePantryQuantity(tx pgx.Tx, ctx context.Context, pantry_item_id string, remaining float64) error {
+	var err error
+	if units.Round(remaining) <= 0 {
+		_, err = tx.Exec(ctx, `DELETE FROM pantry_item WHERE pantry_item_id = $1`, pantry_item_id)
+	} else {
+		_, err = tx.Exec(ctx, `UPDATE pantry_item SET quantity = $1 WHERE pantry_item_id = $2`, remaining, pantry_item_id)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to deduct from pantry item; error: %v", err)
+	}
+	return nil
+}
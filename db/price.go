@@ -0,0 +1,228 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Build the condition selecting the prices recorded by a user or household.
+//
+// Parameters:
+//   - user_id: ID of the user owning personal prices
+//   - household_id: ID of the household owning the prices, if any
+//   - argPosition: Position of the first query argument to use
+//
+// Returns:
+//   - Tuple of SQL condition with corresponding args in order
+func priceScope(user_id string, household_id *string, argPosition int) (string, []interface{}) {
+	if household_id != nil {
+		return fmt.Sprintf("ip.household_id = $%d", argPosition), []interface{}{*household_id}
+	}
+	return fmt.Sprintf("ip.household_id IS NULL AND ip.user_id = $%d", argPosition), []interface{}{user_id}
+}
+
+// Get ingredient prices matching a condition, newest first.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - condition: SQL condition to filter prices with
+//   - args: Arguments referenced by the condition
+//
+// Returns:
+//   - Array of IngredientPrices encoded as the defined model object
+func getIngredientPrices(pool *pgxpool.Pool, ctx context.Context, condition string, args []interface{}) ([]*model.IngredientPrice, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT ip.price_id, ip.store, ip.price::FLOAT8, ip.quantity::FLOAT8, ip.unit, ip.priced_on,
+			i.ingredient_id, i.name, i.description, i.category, iu.user_id, iu.name,
+			u.user_id, u.name, h.household_id, h.name
+		FROM ingredient_price ip
+		JOIN ingredient i ON ip.ingredient_id = i.ingredient_id
+		JOIN user_account iu ON i.user_id = iu.user_id
+		JOIN user_account u ON ip.user_id = u.user_id
+		LEFT JOIN household h ON ip.household_id = h.household_id
+		WHERE `+condition+`
+		ORDER BY ip.priced_on DESC, ip.price_id DESC
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ingredient prices from server; error: %v", err)
+	}
+
+	prices := []*model.IngredientPrice{}
+	for rows.Next() {
+		var price model.IngredientPrice
+		var ingredient model.Ingredient
+		var ingredientUser, user model.User
+		var householdID, householdName *string
+		err := rows.Scan(
+			&price.PriceID,
+			&price.Store,
+			&price.Price,
+			&price.Quantity,
+			&price.Unit,
+			&price.PricedOn,
+			&ingredient.IngredientID,
+			&ingredient.Name,
+			&ingredient.Description,
+			&ingredient.Category,
+			&ingredientUser.UserID,
+			&ingredientUser.Name,
+			&user.UserID,
+			&user.Name,
+			&householdID,
+			&householdName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ingredient prices into struct; error: %v", err)
+		}
+		ingredient.User = &ingredientUser
+		price.Ingredient = &ingredient
+		price.User = &user
+		price.Household = scanHousehold(householdID, householdName)
+		prices = append(prices, &price)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return prices, nil
+}
+
+// Get the price history of an ingredient for a user or household, newest first.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning personal prices
+//   - household_id: ID of the household owning the prices, if any
+//   - ingredient_id: ID of the ingredient
+//
+// Returns:
+//   - Array of IngredientPrices encoded as the defined model object
+func GetIngredientPrices(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string, ingredient_id string) ([]*model.IngredientPrice, error) {
+	scope, args := priceScope(user_id, household_id, 1)
+	return getIngredientPrices(pool, ctx, scope+" AND ip.ingredient_id = $2", append(args, ingredient_id))
+}
+
+// Get the latest price of each of a set of ingredients for a user or household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning personal prices
+//   - household_id: ID of the household owning the prices, if any
+//   - ingredient_ids: IDs of the ingredients
+//
+// Returns:
+//   - Map of ingredient ID to its latest price; ingredients never priced are left out
+func GetCurrentIngredientPrices(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string, ingredient_ids []string) (map[string]*model.IngredientPrice, error) {
+	current := map[string]*model.IngredientPrice{}
+	if len(ingredient_ids) == 0 {
+		return current, nil
+	}
+
+	scope, args := priceScope(user_id, household_id, 1)
+	prices, err := getIngredientPrices(
+		pool,
+		ctx,
+		`ip.price_id IN (
+			SELECT DISTINCT ON (ip.ingredient_id) ip.price_id
+			FROM ingredient_price ip
+			WHERE `+scope+` AND ip.ingredient_id::TEXT = ANY($2::TEXT[])
+			ORDER BY ip.ingredient_id, ip.priced_on DESC, ip.price_id DESC
+		)`,
+		append(args, ingredient_ids),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		current[price.Ingredient.IngredientID] = price
+	}
+	return current, nil
+}
+
+// Get an ingredient price from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - price_id: ID of the price to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - IngredientPrice encoded as the defined model object
+func GetIngredientPriceById(pool *pgxpool.Pool, price_id string, ctx context.Context) (*model.IngredientPrice, error) {
+	prices, err := getIngredientPrices(pool, ctx, "ip.price_id = $1", []interface{}{price_id})
+	if err != nil {
+		return nil, err
+	}
+	if len(prices) == 0 {
+		return nil, apperr.NotFound("found no ingredient prices with provided id")
+	}
+	return prices[0], nil
+}
+
+// Record a price paid for an ingredient.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user recording the price
+//   - input: Details of the price
+//
+// Returns:
+//   - ID of the newly recorded price
+func CreateIngredientPrice(pool *pgxpool.Pool, ctx context.Context, user_id string, input model.NewIngredientPrice) (string, error) {
+	quantity := 1.0
+	if input.Quantity != nil {
+		quantity = *input.Quantity
+	}
+
+	var price_id string
+	err := pool.QueryRow(
+		ctx,
+		`
+		INSERT INTO ingredient_price (ingredient_id, user_id, household_id, store, price, quantity, unit, priced_on)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, CURRENT_DATE))
+		RETURNING price_id::TEXT
+		`,
+		input.IngredientID,
+		user_id,
+		input.HouseholdID,
+		input.Store,
+		input.Price,
+		quantity,
+		input.Unit,
+		input.PricedOn,
+	).Scan(&price_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to record ingredient price; error: %v", err)
+	}
+	return price_id, nil
+}
+
+// Remove a recorded ingredient price.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - price_id: ID of the price
+//
+// Returns:
+//   - Error if the price could not be removed
+func DeleteIngredientPrice(pool *pgxpool.Pool, ctx context.Context, price_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM ingredient_price WHERE price_id = $1`, price_id)
+	if err != nil {
+		return fmt.Errorf("failed to remove ingredient price; error: %v", err)
+	}
+	return nil
+}
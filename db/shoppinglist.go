@@ -0,0 +1,614 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Category used for items whose ingredient has none.
+const defaultShoppingCategory = "Other"
+
+// Build the condition selecting the shopping lists of a user or household.
+//
+// Parameters:
+//   - user_id: ID of the user owning personal lists
+//   - household_id: ID of the household owning the lists, if any
+//   - argPosition: Position of the first query argument to use
+//
+// Returns:
+//   - Tuple of SQL condition with corresponding args in order
+func shoppingListScope(user_id string, household_id *string, argPosition int) (string, []interface{}) {
+	if household_id != nil {
+		return fmt.Sprintf("sl.household_id = $%d", argPosition), []interface{}{*household_id}
+	}
+	return fmt.Sprintf("sl.household_id IS NULL AND sl.user_id = $%d", argPosition), []interface{}{user_id}
+}
+
+// Get shopping lists matching a condition, loading their items.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - condition: SQL condition to filter lists with
+//   - args: Arguments referenced by the condition
+//
+// Returns:
+//   - Array of ShoppingLists encoded as the defined model object
+func getShoppingLists(pool *pgxpool.Pool, ctx context.Context, condition string, args []interface{}) ([]*model.ShoppingList, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT sl.shopping_list_id, sl.name, sl.created_at, u.user_id, u.name, h.household_id, h.name
+		FROM shopping_list sl
+		JOIN user_account u ON sl.user_id = u.user_id
+		LEFT JOIN household h ON sl.household_id = h.household_id
+		WHERE `+condition+`
+		ORDER BY sl.created_at DESC
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get shopping lists from server; error: %v", err)
+	}
+
+	lists := []*model.ShoppingList{}
+	for rows.Next() {
+		var list model.ShoppingList
+		var user model.User
+		var householdID, householdName *string
+		err := rows.Scan(
+			&list.ShoppingListID,
+			&list.Name,
+			&list.CreatedAt,
+			&user.UserID,
+			&user.Name,
+			&householdID,
+			&householdName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse shopping lists into struct; error: %v", err)
+		}
+		list.User = &user
+		list.Household = scanHousehold(householdID, householdName)
+		lists = append(lists, &list)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	for _, list := range lists {
+		list.Items, err = getShoppingListItems(pool, ctx, list.ShoppingListID)
+		if err != nil {
+			return nil, err
+		}
+		list.Categories = groupShoppingListItems(list.Items)
+	}
+
+	return lists, nil
+}
+
+// Get the items of a shopping list, ordered by category and name.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - shopping_list_id: ID of the list
+//
+// Returns:
+//   - Array of ShoppingListItems encoded as the defined model object
+func getShoppingListItems(pool *pgxpool.Pool, ctx context.Context, shopping_list_id string) ([]*model.ShoppingListItem, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT sli.shopping_list_item_id, sli.name, sli.quantity, sli.unit, sli.category, sli.checked,
+			i.ingredient_id, i.name, i.description, i.category, iu.user_id, iu.name
+		FROM shopping_list_item sli
+		LEFT JOIN ingredient i ON sli.ingredient_id = i.ingredient_id
+		LEFT JOIN user_account iu ON i.user_id = iu.user_id
+		WHERE sli.shopping_list_id = $1
+		ORDER BY sli.category, sli.name, sli.shopping_list_item_id
+		`,
+		shopping_list_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve items for shopping list: %v", err)
+	}
+
+	items := []*model.ShoppingListItem{}
+	for rows.Next() {
+		var item model.ShoppingListItem
+		var category *string
+		var ingredientID, ingredientName, ingredientDescription, userID, userName *string
+		var ingredientCategory *string
+		err := rows.Scan(
+			&item.ShoppingListItemID,
+			&item.Name,
+			&item.Quantity,
+			&item.Unit,
+			&category,
+			&item.Checked,
+			&ingredientID,
+			&ingredientName,
+			&ingredientDescription,
+			&ingredientCategory,
+			&userID,
+			&userName,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not scan out row: %v", err)
+		}
+		item.Category = defaultShoppingCategory
+		if category != nil && *category != "" {
+			item.Category = *category
+		}
+		if ingredientID != nil {
+			item.Ingredient = &model.Ingredient{
+				IngredientID: *ingredientID,
+				Name:         deref(ingredientName),
+				Description:  deref(ingredientDescription),
+				Category:     ingredientCategory,
+				User:         &model.User{UserID: deref(userID), Name: deref(userName)},
+			}
+		}
+		items = append(items, &item)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return items, nil
+}
+
+// Group shopping list items by category, keeping the catch-all category last.
+//
+// Parameters:
+//   - items: Items ordered by category
+//
+// Returns:
+//   - Array of categories with their items
+func groupShoppingListItems(items []*model.ShoppingListItem) []*model.ShoppingListCategory {
+	categories := []*model.ShoppingListCategory{}
+	var other *model.ShoppingListCategory
+	byName := map[string]*model.ShoppingListCategory{}
+	for _, item := range items {
+		category, ok := byName[item.Category]
+		if !ok {
+			category = &model.ShoppingListCategory{Category: item.Category}
+			byName[item.Category] = category
+			if item.Category == defaultShoppingCategory {
+				other = category
+			} else {
+				categories = append(categories, category)
+			}
+		}
+		category.Items = append(category.Items, item)
+	}
+	if other != nil {
+		categories = append(categories, other)
+	}
+	return categories
+}
+
+// Dereference an optional string, defaulting to empty.
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// Get the shopping lists of a user or household.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning personal lists
+//   - household_id: ID of the household owning the lists, if any
+//
+// Returns:
+//   - Array of ShoppingLists encoded as the defined model object
+func GetShoppingLists(pool *pgxpool.Pool, ctx context.Context, user_id string, household_id *string) ([]*model.ShoppingList, error) {
+	scope, args := shoppingListScope(user_id, household_id, 1)
+	return getShoppingLists(pool, ctx, scope, args)
+}
+
+// Get a shopping list from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - shopping_list_id: ID of the list to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - ShoppingList encoded as the defined model object
+func GetShoppingListById(pool *pgxpool.Pool, shopping_list_id string, ctx context.Context) (*model.ShoppingList, error) {
+	lists, err := getShoppingLists(pool, ctx, "sl.shopping_list_id = $1", []interface{}{shopping_list_id})
+	if err != nil {
+		return nil, err
+	}
+	if len(lists) == 0 {
+		return nil, apperr.NotFound("found no shopping lists with provided id")
+	}
+	return lists[0], nil
+}
+
+// Get the shopping list an item belongs to.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - shopping_list_item_id: ID of the item
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - ShoppingList encoded as the defined model object
+func GetShoppingListByItemId(pool *pgxpool.Pool, shopping_list_item_id string, ctx context.Context) (*model.ShoppingList, error) {
+	var shopping_list_id string
+	err := pool.QueryRow(
+		ctx,
+		`SELECT shopping_list_id::TEXT FROM shopping_list_item WHERE shopping_list_item_id = $1`,
+		shopping_list_item_id,
+	).Scan(&shopping_list_id)
+	if err != nil {
+		return nil, apperr.NotFound("found no shopping list items with provided id")
+	}
+	return GetShoppingListById(pool, shopping_list_id, ctx)
+}
+
+// A recipe to shop for, scaled to the servings being cooked.
+type shoppingSource struct {
+	recipeID string
+	scale    float64
+}
+
+// Amounts of one ingredient needed across every source.
+type shoppingNeed struct {
+	ingredientID string
+	name         string
+	category     string
+	total        *units.Total
+	measured     bool
+}
+
+// Collect the recipes scheduled on a meal plan, scaled by servings overrides.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user owning a personal plan
+//   - input: Shopping list request with the meal plan range
+//
+// Returns:
+//   - Array of recipes to shop for
+func mealPlanShoppingSources(pool *pgxpool.Pool, ctx context.Context, user_id string, input model.GenerateShoppingList) ([]shoppingSource, error) {
+	if input.MealPlanFrom == nil || input.MealPlanTo == nil {
+		return nil, nil
+	}
+
+	scope, args := mealPlanScope(user_id, input.HouseholdID, 3)
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT mpe.recipe_id::TEXT, mpe.servings, r.servings
+		FROM meal_plan_entry mpe
+		JOIN recipe r ON mpe.recipe_id = r.recipe_id
+		WHERE mpe.plan_date >= $1 AND mpe.plan_date <= $2 AND `+scope,
+		append([]interface{}{*input.MealPlanFrom, *input.MealPlanTo}, args...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get meal plan recipes; error: %v", err)
+	}
+
+	sources := []shoppingSource{}
+	for rows.Next() {
+		var source shoppingSource
+		var entryServings, recipeServings *int
+		err := rows.Scan(&source.recipeID, &entryServings, &recipeServings)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse meal plan recipes; error: %v", err)
+		}
+		source.scale = 1
+		if entryServings != nil && recipeServings != nil && *recipeServings > 0 {
+			source.scale = float64(*entryServings) / float64(*recipeServings)
+		}
+		sources = append(sources, source)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return sources, nil
+}
+
+// Total up the ingredients needed for a set of recipes.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - sources: Recipes to shop for
+//
+// Returns:
+//   - Needed ingredients, ordered by category and name
+func totalShoppingNeeds(pool *pgxpool.Pool, ctx context.Context, sources []shoppingSource) ([]*shoppingNeed, error) {
+	needs := map[string]*shoppingNeed{}
+	for _, source := range sources {
+		rows, err := pool.Query(
+			ctx,
+			`
+			SELECT i.ingredient_id::TEXT, i.name, COALESCE(i.category, ''), ri.quantity, COALESCE(ri.unit, '')
+			FROM recipe_ingredient ri
+			JOIN ingredient i ON ri.ingredient_id = i.ingredient_id
+			WHERE ri.recipe_id = $1
+			`,
+			source.recipeID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not retrieve ingredients for recipe: %v", err)
+		}
+
+		for rows.Next() {
+			var ingredientID, name, category, unit string
+			var quantity *float64
+			err := rows.Scan(&ingredientID, &name, &category, &quantity, &unit)
+			if err != nil {
+				return nil, fmt.Errorf("could not scan out row: %v", err)
+			}
+
+			need, ok := needs[ingredientID]
+			if !ok {
+				if category == "" {
+					category = defaultShoppingCategory
+				}
+				need = &shoppingNeed{
+					ingredientID: ingredientID,
+					name:         name,
+					category:     category,
+					total:        units.NewTotal(),
+				}
+				needs[ingredientID] = need
+			}
+			if quantity != nil {
+				need.total.Add(*quantity*source.scale, unit)
+				need.measured = true
+			}
+		}
+		err = rows.Err()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+		}
+	}
+
+	sorted := make([]*shoppingNeed, 0, len(needs))
+	for _, need := range needs {
+		sorted = append(sorted, need)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].category != sorted[j].category {
+			return sorted[i].category < sorted[j].category
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted, nil
+}
+
+// Generate and store a shopping list from recipes and/or a meal plan date range.
+// The same ingredient is combined across recipes, converting units where possible,
+// and what is already in the pantry is left out unless requested otherwise.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user generating the list
+//   - input: Recipes and meal plan range to shop for
+//
+// Returns:
+//   - ID of the newly created shopping list
+func GenerateShoppingList(pool *pgxpool.Pool, ctx context.Context, user_id string, input model.GenerateShoppingList) (string, error) {
+	sources := []shoppingSource{}
+	for _, recipeID := range input.RecipeIds {
+		sources = append(sources, shoppingSource{recipeID: recipeID, scale: 1})
+	}
+	planned, err := mealPlanShoppingSources(pool, ctx, user_id, input)
+	if err != nil {
+		return "", err
+	}
+	sources = append(sources, planned...)
+
+	needs, err := totalShoppingNeeds(pool, ctx, sources)
+	if err != nil {
+		return "", err
+	}
+	if input.SubtractPantry == nil || *input.SubtractPantry {
+		err = subtractPantry(pool, ctx, user_id, input.HouseholdID, needs)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var shopping_list_id string
+	err = tx.QueryRow(
+		ctx,
+		`
+		INSERT INTO shopping_list (user_id, household_id, name)
+		VALUES ($1, $2, $3)
+		RETURNING shopping_list_id::TEXT
+		`,
+		user_id,
+		input.HouseholdID,
+		input.Name,
+	).Scan(&shopping_list_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create shopping list; error: %v", err)
+	}
+
+	for _, need := range needs {
+		amounts := need.total.Amounts()
+		if need.measured && len(amounts) == 0 {
+			// Everything needed is already in the pantry
+			continue
+		}
+		if !need.measured {
+			// Still list ingredients the recipes do not give an amount for
+			amounts = []units.Amount{{}}
+		}
+		for _, amount := range amounts {
+			var quantity *float64
+			var unit *string
+			if need.measured {
+				quantity = &amount.Amount
+				if amount.Unit != "" {
+					unit = &amount.Unit
+				}
+			}
+			_, err = tx.Exec(
+				ctx,
+				`
+				INSERT INTO shopping_list_item (shopping_list_id, ingredient_id, name, quantity, unit, category)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				`,
+				shopping_list_id,
+				need.ingredientID,
+				need.name,
+				quantity,
+				unit,
+				need.category,
+			)
+			if err != nil {
+				return "", fmt.Errorf("failed to add shopping list item; error: %v", err)
+			}
+		}
+	}
+
+	return shopping_list_id, tx.Commit(ctx)
+}
+
+// Rename a shopping list.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - shopping_list_id: ID of the list
+//   - name: New name of the list
+//
+// Returns:
+//   - Error if the list could not be renamed
+func RenameShoppingList(pool *pgxpool.Pool, ctx context.Context, shopping_list_id string, name string) error {
+	_, err := pool.Exec(ctx, `UPDATE shopping_list SET name = $1 WHERE shopping_list_id = $2`, name, shopping_list_id)
+	if err != nil {
+		return fmt.Errorf("failed to rename shopping list; error: %v", err)
+	}
+	return nil
+}
+
+// Delete a shopping list and its items.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - shopping_list_id: ID of the list
+//
+// Returns:
+//   - Error if the list could not be deleted
+func DeleteShoppingList(pool *pgxpool.Pool, ctx context.Context, shopping_list_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM shopping_list WHERE shopping_list_id = $1`, shopping_list_id)
+	if err != nil {
+		return fmt.Errorf("failed to delete shopping list; error: %v", err)
+	}
+	return nil
+}
+
+// Add an item to a shopping list by hand.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - shopping_list_id: ID of the list
+//   - input: Details of the item
+//
+// Returns:
+//   - Error if the item could not be added
+func CreateShoppingListItem(pool *pgxpool.Pool, ctx context.Context, shopping_list_id string, input model.NewShoppingListItem) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		INSERT INTO shopping_list_item (shopping_list_id, ingredient_id, name, quantity, unit, category)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		`,
+		shopping_list_id,
+		input.IngredientID,
+		input.Name,
+		input.Quantity,
+		input.Unit,
+		input.Category,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to add shopping list item; error: %v", err)
+	}
+	return nil
+}
+
+// Update the fields of a shopping list item that are provided.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - shopping_list_item_id: ID of the item
+//   - input: Fields to change
+//
+// Returns:
+//   - Error if the item could not be updated
+func UpdateShoppingListItem(pool *pgxpool.Pool, ctx context.Context, shopping_list_item_id string, input model.UpdateShoppingListItem) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		UPDATE shopping_list_item SET
+			name = COALESCE($1, name),
+			quantity = COALESCE($2, quantity),
+			unit = COALESCE($3, unit),
+			category = COALESCE($4, category),
+			checked = COALESCE($5, checked)
+		WHERE shopping_list_item_id = $6
+		`,
+		input.Name,
+		input.Quantity,
+		input.Unit,
+		input.Category,
+		input.Checked,
+		shopping_list_item_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update shopping list item; error: %v", err)
+	}
+	return nil
+}
+
+// Remove an item from a shopping list.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - shopping_list_item_id: ID of the item
+//
+// Returns:
+//   - Error if the item could not be removed
+func DeleteShoppingListItem(pool *pgxpool.Pool, ctx context.Context, shopping_list_item_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM shopping_list_item WHERE shopping_list_item_id = $1`, shopping_list_item_id)
+	if err != nil {
+		return fmt.Errorf("failed to remove shopping list item; error: %v", err)
+	}
+	return nil
+}
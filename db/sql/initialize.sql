@@ -6,6 +6,31 @@ CREATE DATABASE ambrosia;
 
 -- Create tables for use within app
 
+-- Reference foods imported from USDA FoodData Central, nutrients per 100 g
+CREATE TABLE food (
+    fdc_id INT PRIMARY KEY,
+    description TEXT NOT NULL,
+    data_type VARCHAR(64),
+    category VARCHAR(255),
+    calories NUMERIC NOT NULL DEFAULT 0,
+    protein_g NUMERIC NOT NULL DEFAULT 0,
+    fat_g NUMERIC NOT NULL DEFAULT 0,
+    saturated_fat_g NUMERIC NOT NULL DEFAULT 0,
+    carbohydrates_g NUMERIC NOT NULL DEFAULT 0,
+    sugar_g NUMERIC NOT NULL DEFAULT 0,
+    fiber_g NUMERIC NOT NULL DEFAULT 0,
+    sodium_mg NUMERIC NOT NULL DEFAULT 0,
+    cholesterol_mg NUMERIC NOT NULL DEFAULT 0,
+    potassium_mg NUMERIC NOT NULL DEFAULT 0,
+    calcium_mg NUMERIC NOT NULL DEFAULT 0,
+    iron_mg NUMERIC NOT NULL DEFAULT 0,
+    vitamin_a_ug NUMERIC NOT NULL DEFAULT 0,
+    vitamin_c_mg NUMERIC NOT NULL DEFAULT 0,
+    vitamin_d_ug NUMERIC NOT NULL DEFAULT 0
+);
+
+CREATE INDEX food_description_search ON food USING GIN (to_tsvector('english', description));
+
 -- TODO: Need better authentication for users
 CREATE TABLE user_account (
     user_id SERIAL PRIMARY KEY,
@@ -13,22 +38,376 @@ CREATE TABLE user_account (
     password TEXT
 );
 
+-- Households let several users share ownership of recipes, ingredients, etc.
+CREATE TABLE household (
+    household_id SERIAL PRIMARY KEY,
+    name VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE household_member (
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('OWNER', 'EDITOR', 'VIEWER')),
+    CONSTRAINT household_member_id PRIMARY KEY (household_id, user_id)
+);
+
+CREATE TABLE household_invitation (
+    invitation_id SERIAL PRIMARY KEY,
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    invited_by INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL CHECK (role IN ('OWNER', 'EDITOR', 'VIEWER')),
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'ACCEPTED', 'DECLINED')),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- NOTE: household_id is optional; when set, members of the household share ownership.
+-- Uploaded images; the files themselves live in the blob store
+CREATE TABLE image (
+    image_id VARCHAR(32) PRIMARY KEY,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE SET NULL,
+    content_type VARCHAR(32) NOT NULL,
+    width INT NOT NULL,
+    height INT NOT NULL,
+    byte_size INT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 CREATE TABLE recipe (
     recipe_id SERIAL PRIMARY KEY,
     user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE SET NULL,
     name VARCHAR(255),
-    description VARCHAR(255)
+    description VARCHAR(255),
+    servings INT,
+    prep_time INTERVAL,
+    cook_time INTERVAL,
+    rest_time INTERVAL,
+    image_id VARCHAR(32) REFERENCES image (image_id) ON UPDATE CASCADE ON DELETE SET NULL,
+    source_url TEXT,
+    -- Out of 5, e.g. carried over from a Paprika import
+    rating INT CHECK (rating BETWEEN 1 AND 5)
+);
+
+-- Categories a recipe is filed under, e.g. "Breakfast"
+CREATE TABLE recipe_category (
+    recipe_id INT REFERENCES recipe (recipe_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    category VARCHAR(64) NOT NULL,
+    CONSTRAINT recipe_category_id PRIMARY KEY (recipe_id, category)
+);
+
+CREATE TABLE recipe_step (
+    recipe_id INT REFERENCES recipe (recipe_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    position INT NOT NULL,
+    text TEXT NOT NULL,
+    duration INTERVAL,
+    image_id VARCHAR(32) REFERENCES image (image_id) ON UPDATE CASCADE ON DELETE SET NULL,
+    CONSTRAINT recipe_step_id PRIMARY KEY (recipe_id, position)
 );
 
 CREATE TABLE ingredient (
     ingredient_id SERIAL PRIMARY KEY,
     user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE SET NULL,
     name VARCHAR(255),
-    description VARCHAR(255)
+    description VARCHAR(255),
+    category VARCHAR(64),
+    -- Used to convert volumes and counts of the ingredient to mass
+    grams_per_ml NUMERIC,
+    grams_each NUMERIC,
+    -- Reference food the nutrition data was taken from
+    fdc_id INT REFERENCES food (fdc_id) ON UPDATE CASCADE ON DELETE SET NULL
+);
+
+-- Nutrients per 100 g of an ingredient
+CREATE TABLE ingredient_nutrition (
+    ingredient_id INT PRIMARY KEY REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    calories NUMERIC NOT NULL DEFAULT 0,
+    protein_g NUMERIC NOT NULL DEFAULT 0,
+    fat_g NUMERIC NOT NULL DEFAULT 0,
+    saturated_fat_g NUMERIC NOT NULL DEFAULT 0,
+    carbohydrates_g NUMERIC NOT NULL DEFAULT 0,
+    sugar_g NUMERIC NOT NULL DEFAULT 0,
+    fiber_g NUMERIC NOT NULL DEFAULT 0,
+    sodium_mg NUMERIC NOT NULL DEFAULT 0,
+    cholesterol_mg NUMERIC NOT NULL DEFAULT 0,
+    potassium_mg NUMERIC NOT NULL DEFAULT 0,
+    calcium_mg NUMERIC NOT NULL DEFAULT 0,
+    iron_mg NUMERIC NOT NULL DEFAULT 0,
+    vitamin_a_ug NUMERIC NOT NULL DEFAULT 0,
+    vitamin_c_mg NUMERIC NOT NULL DEFAULT 0,
+    vitamin_d_ug NUMERIC NOT NULL DEFAULT 0
+);
+
+-- Allergens an ingredient contains, from the 14 major allergens
+CREATE TABLE ingredient_allergen (
+    ingredient_id INT REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    allergen VARCHAR(16) NOT NULL CHECK (allergen IN (
+        'CELERY', 'GLUTEN', 'CRUSTACEAN', 'EGG', 'FISH', 'LUPIN', 'MILK',
+        'MOLLUSC', 'MUSTARD', 'TREE_NUT', 'PEANUT', 'SESAME', 'SOY', 'SULPHITE'
+    )),
+    CONSTRAINT ingredient_allergen_id PRIMARY KEY (ingredient_id, allergen)
+);
+
+-- Diets an ingredient is suitable for
+CREATE TABLE ingredient_diet (
+    ingredient_id INT REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    diet VARCHAR(16) NOT NULL CHECK (diet IN (
+        'VEGAN', 'VEGETARIAN', 'GLUTEN_FREE', 'DAIRY_FREE', 'HALAL', 'KOSHER'
+    )),
+    CONSTRAINT ingredient_diet_id PRIMARY KEY (ingredient_id, diet)
+);
+
+-- Ingredients that can stand in for another, e.g. buttermilk -> milk + lemon juice
+CREATE TABLE ingredient_substitution (
+    substitution_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE SET NULL,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+-- Replacement ingredients of a substitution, with the amount used per unit of the original
+CREATE TABLE ingredient_substitution_part (
+    substitution_id INT REFERENCES ingredient_substitution (substitution_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ingredient_id INT REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ratio NUMERIC NOT NULL DEFAULT 1 CHECK (ratio > 0),
+    CONSTRAINT ingredient_substitution_part_id PRIMARY KEY (substitution_id, ingredient_id)
 );
 
 CREATE TABLE recipe_ingredient (
     recipe_id INT REFERENCES recipe (recipe_id) ON UPDATE CASCADE ON DELETE CASCADE,
     ingredient_id INT REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    quantity NUMERIC,
+    unit VARCHAR(32),
+    -- How the ingredient is prepared and anything else said about it, e.g. "finely chopped" and "about 2"
+    preparation TEXT,
+    notes TEXT,
     CONSTRAINT recipe_ingredient_id PRIMARY KEY (recipe_id, ingredient_id)
 );
+
+-- Recipes scheduled onto a calendar, owned by a user or a household
+CREATE TABLE meal_plan_entry (
+    meal_plan_entry_id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    recipe_id INT REFERENCES recipe (recipe_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    plan_date DATE NOT NULL,
+    slot VARCHAR(16) NOT NULL CHECK (slot IN ('BREAKFAST', 'LUNCH', 'DINNER', 'SNACK')),
+    servings INT,
+    notes TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX meal_plan_entry_plan_date ON meal_plan_entry (plan_date);
+
+-- Shopping lists, owned by a user or a household
+CREATE TABLE shopping_list (
+    shopping_list_id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    name VARCHAR(255),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE shopping_list_item (
+    shopping_list_item_id SERIAL PRIMARY KEY,
+    shopping_list_id INT REFERENCES shopping_list (shopping_list_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ingredient_id INT REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE SET NULL,
+    name VARCHAR(255),
+    quantity NUMERIC,
+    unit VARCHAR(32),
+    category VARCHAR(64),
+    checked BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- Ingredients on hand, owned by a user or a household
+CREATE TABLE pantry_item (
+    pantry_item_id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ingredient_id INT NOT NULL REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    quantity NUMERIC,
+    unit VARCHAR(32),
+    purchased_on DATE,
+    expires_on DATE
+);
+
+CREATE INDEX pantry_item_expires_on ON pantry_item (expires_on);
+
+-- Prices paid for an amount of an ingredient, owned by a user or a household
+CREATE TABLE ingredient_price (
+    price_id SERIAL PRIMARY KEY,
+    ingredient_id INT NOT NULL REFERENCES ingredient (ingredient_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    user_id INT REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    household_id INT REFERENCES household (household_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    store VARCHAR(255),
+    price NUMERIC NOT NULL CHECK (price >= 0),
+    quantity NUMERIC NOT NULL DEFAULT 1 CHECK (quantity > 0),
+    unit VARCHAR(32),
+    priced_on DATE NOT NULL DEFAULT CURRENT_DATE
+);
+
+CREATE INDEX ingredient_price_priced_on ON ingredient_price (ingredient_id, priced_on DESC);
+
+-- Changes are announced on the ambrosia_events channel as "topic:id", or "topic:id:user_id"
+-- when the trigger names an owner column, so every server instance can update its GraphQL
+-- subscriptions without loading rows they do not want; notifications are only sent on commit
+CREATE FUNCTION notify_change() RETURNS TRIGGER AS $$
+BEGIN
+    -- TG_ARGV holds the topic, the column identifying what changed and optionally the owner column
+    IF TG_OP <> 'INSERT' THEN
+        PERFORM pg_notify('ambrosia_events', TG_ARGV[0] || ':' || (to_jsonb(OLD) ->> TG_ARGV[1])
+            || CASE WHEN TG_NARGS > 2 THEN ':' || COALESCE(to_jsonb(OLD) ->> TG_ARGV[2], '') ELSE '' END);
+    END IF;
+    IF TG_OP <> 'DELETE' THEN
+        PERFORM pg_notify('ambrosia_events', TG_ARGV[0] || ':' || (to_jsonb(NEW) ->> TG_ARGV[1])
+            || CASE WHEN TG_NARGS > 2 THEN ':' || COALESCE(to_jsonb(NEW) ->> TG_ARGV[2], '') ELSE '' END);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER recipe_created AFTER INSERT ON recipe
+    FOR EACH ROW EXECUTE FUNCTION notify_change('recipe_created', 'recipe_id', 'user_id');
+CREATE TRIGGER recipe_updated AFTER UPDATE OR DELETE ON recipe
+    FOR EACH ROW EXECUTE FUNCTION notify_change('recipe_updated', 'recipe_id');
+CREATE TRIGGER recipe_step_updated AFTER INSERT OR UPDATE OR DELETE ON recipe_step
+    FOR EACH ROW EXECUTE FUNCTION notify_change('recipe_updated', 'recipe_id');
+CREATE TRIGGER recipe_ingredient_updated AFTER INSERT OR UPDATE OR DELETE ON recipe_ingredient
+    FOR EACH ROW EXECUTE FUNCTION notify_change('recipe_updated', 'recipe_id');
+CREATE TRIGGER shopping_list_changed AFTER UPDATE OR DELETE ON shopping_list
+    FOR EACH ROW EXECUTE FUNCTION notify_change('shopping_list_changed', 'shopping_list_id');
+CREATE TRIGGER shopping_list_item_changed AFTER INSERT OR UPDATE OR DELETE ON shopping_list_item
+    FOR EACH ROW EXECUTE FUNCTION notify_change('shopping_list_changed', 'shopping_list_id');
+
+-- URLs users register to be sent events about their recipes and meal plans, and their households'
+CREATE TABLE webhook (
+    webhook_id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES user_account (user_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    -- Key request bodies are signed with
+    secret VARCHAR(64) NOT NULL,
+    events VARCHAR(32)[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Outbox of webhook events, written by triggers in the same transaction as the change,
+-- so events are not lost if the server stops before sending them
+CREATE TABLE webhook_event (
+    event_id BIGSERIAL PRIMARY KEY,
+    event VARCHAR(32) NOT NULL,
+    subject_id INT NOT NULL,
+    -- Owners of the subject; the user's and the household members' webhooks are sent the event
+    user_id INT,
+    household_id INT,
+    payload JSONB NOT NULL,
+    transaction_id BIGINT NOT NULL DEFAULT txid_current(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Set once a delivery is queued for each matching webhook
+    queued_at TIMESTAMPTZ
+);
+
+-- A change touching several rows of a recipe in one transaction is a single event
+CREATE UNIQUE INDEX webhook_event_once ON webhook_event (event, subject_id, transaction_id);
+CREATE INDEX webhook_event_unqueued ON webhook_event (event_id) WHERE queued_at IS NULL;
+
+CREATE TABLE webhook_delivery (
+    delivery_id BIGSERIAL PRIMARY KEY,
+    webhook_id INT NOT NULL REFERENCES webhook (webhook_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    event_id BIGINT NOT NULL REFERENCES webhook_event (event_id) ON UPDATE CASCADE ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'PENDING' CHECK (status IN ('PENDING', 'SUCCEEDED', 'FAILED')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX webhook_delivery_due ON webhook_delivery (next_attempt_at) WHERE status = 'PENDING';
+CREATE INDEX webhook_delivery_log ON webhook_delivery (webhook_id, created_at DESC);
+
+-- Record a webhook event: event, subject ID, user ID, household ID and payload.
+-- Updates in the transaction that creates or deletes a recipe are part of that event.
+CREATE FUNCTION record_webhook_event(TEXT, INT, INT, INT, JSONB) RETURNS VOID AS $$
+    INSERT INTO webhook_event (event, subject_id, user_id, household_id, payload)
+    SELECT $1, $2, $3, $4, $5
+    WHERE $1 <> 'RECIPE_UPDATED' OR NOT EXISTS (
+        SELECT 1 FROM webhook_event
+        WHERE subject_id = $2 AND transaction_id = txid_current() AND event IN ('RECIPE_CREATED', 'RECIPE_DELETED')
+    )
+    ON CONFLICT DO NOTHING;
+$$ LANGUAGE SQL;
+
+CREATE FUNCTION recipe_webhook_event(event TEXT, subject recipe) RETURNS VOID AS $$
+    SELECT record_webhook_event(event, subject.recipe_id, subject.user_id, subject.household_id, jsonb_build_object(
+        'recipeId', subject.recipe_id::TEXT,
+        'name', subject.name,
+        'userId', subject.user_id::TEXT,
+        'householdId', subject.household_id::TEXT
+    ));
+$$ LANGUAGE SQL;
+
+CREATE FUNCTION record_recipe_event() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM recipe_webhook_event('RECIPE_CREATED', NEW);
+    ELSIF TG_OP = 'UPDATE' THEN
+        PERFORM recipe_webhook_event('RECIPE_UPDATED', NEW);
+    ELSE
+        PERFORM recipe_webhook_event('RECIPE_DELETED', OLD);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Changes to a recipe's steps and ingredient lines update the recipe
+CREATE FUNCTION record_recipe_part_event() RETURNS TRIGGER AS $$
+DECLARE
+    subject recipe;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        SELECT * INTO subject FROM recipe WHERE recipe_id = OLD.recipe_id;
+    ELSE
+        SELECT * INTO subject FROM recipe WHERE recipe_id = NEW.recipe_id;
+    END IF;
+    -- Parts removed along with their recipe are covered by its deletion
+    IF FOUND THEN
+        PERFORM recipe_webhook_event('RECIPE_UPDATED', subject);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE FUNCTION record_meal_plan_event() RETURNS TRIGGER AS $$
+DECLARE
+    entry meal_plan_entry;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        entry := OLD;
+    ELSE
+        entry := NEW;
+    END IF;
+    PERFORM record_webhook_event('MEAL_PLAN_CHANGED', entry.meal_plan_entry_id, entry.user_id, entry.household_id, jsonb_build_object(
+        'change', CASE TG_OP WHEN 'INSERT' THEN 'ADDED' WHEN 'UPDATE' THEN 'UPDATED' ELSE 'REMOVED' END,
+        'mealPlanEntryId', entry.meal_plan_entry_id::TEXT,
+        'date', entry.plan_date,
+        'slot', entry.slot,
+        'recipeId', entry.recipe_id::TEXT,
+        'servings', entry.servings,
+        'userId', entry.user_id::TEXT,
+        'householdId', entry.household_id::TEXT
+    ));
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER recipe_webhook AFTER INSERT OR UPDATE OR DELETE ON recipe
+    FOR EACH ROW EXECUTE FUNCTION record_recipe_event();
+CREATE TRIGGER recipe_step_webhook AFTER INSERT OR UPDATE OR DELETE ON recipe_step
+    FOR EACH ROW EXECUTE FUNCTION record_recipe_part_event();
+CREATE TRIGGER recipe_ingredient_webhook AFTER INSERT OR UPDATE OR DELETE ON recipe_ingredient
+    FOR EACH ROW EXECUTE FUNCTION record_recipe_part_event();
+CREATE TRIGGER meal_plan_entry_webhook AFTER INSERT OR UPDATE OR DELETE ON meal_plan_entry
+    FOR EACH ROW EXECUTE FUNCTION record_meal_plan_event();
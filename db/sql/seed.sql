@@ -7,21 +7,91 @@ INSERT INTO user_account (name, password) VALUES
     ('Jeff Lebowski', 'thedude123'),
     ('Jim', 'password');
 
+-- Create a household shared by both users
+INSERT INTO household (name) VALUES
+    ('The Lebowski Kitchen');
+
+INSERT INTO household_member (household_id, user_id, role) VALUES
+    (1, 1, 'OWNER'),
+    (1, 2, 'EDITOR');
+
 -- Create some ingredients
-INSERT INTO ingredient (name, description, user_id) VALUES
-    ('salt', 'common spice; table salt', 1),
-    ('black pepper', 'common spice; ground black pepper', 1),
-    ('raw chicken breast', 'raw, unprepared chicken breast', 2);
+INSERT INTO ingredient (name, description, user_id, category, grams_per_ml, grams_each) VALUES
+    ('salt', 'common spice; table salt', 1, 'Spices', 1.22, NULL),
+    ('black pepper', 'common spice; ground black pepper', 1, 'Spices', 0.46, NULL),
+    ('raw chicken breast', 'raw, unprepared chicken breast', 2, 'Meat', NULL, 174),
+    ('butter', 'unsalted butter', 1, 'Dairy', 0.96, NULL),
+    ('margarine', 'plant-based margarine', 1, 'Dairy', 0.96, NULL),
+    ('buttermilk', 'cultured buttermilk', 1, 'Dairy', 1.03, NULL),
+    ('milk', 'whole milk', 1, 'Dairy', 1.03, NULL),
+    ('lemon juice', 'freshly squeezed lemon juice', 1, 'Produce', 1.03, NULL);
+
+-- Tag ingredients with the diets they suit
+INSERT INTO ingredient_diet (ingredient_id, diet) VALUES
+    (1, 'VEGAN'), (1, 'VEGETARIAN'), (1, 'GLUTEN_FREE'), (1, 'DAIRY_FREE'), (1, 'HALAL'), (1, 'KOSHER'),
+    (2, 'VEGAN'), (2, 'VEGETARIAN'), (2, 'GLUTEN_FREE'), (2, 'DAIRY_FREE'), (2, 'HALAL'), (2, 'KOSHER'),
+    (3, 'GLUTEN_FREE'), (3, 'DAIRY_FREE'),
+    (4, 'VEGETARIAN'), (4, 'GLUTEN_FREE'), (4, 'HALAL'), (4, 'KOSHER'),
+    (5, 'VEGAN'), (5, 'VEGETARIAN'), (5, 'GLUTEN_FREE'), (5, 'DAIRY_FREE'), (5, 'HALAL'), (5, 'KOSHER'),
+    (6, 'VEGETARIAN'), (6, 'GLUTEN_FREE'), (6, 'HALAL'), (6, 'KOSHER'),
+    (7, 'VEGETARIAN'), (7, 'GLUTEN_FREE'), (7, 'HALAL'), (7, 'KOSHER'),
+    (8, 'VEGAN'), (8, 'VEGETARIAN'), (8, 'GLUTEN_FREE'), (8, 'DAIRY_FREE'), (8, 'HALAL'), (8, 'KOSHER');
+
+-- Tag ingredients with the allergens they contain
+INSERT INTO ingredient_allergen (ingredient_id, allergen) VALUES
+    (4, 'MILK'), (6, 'MILK'), (7, 'MILK');
+
+-- Suggest a few common substitutions
+INSERT INTO ingredient_substitution (ingredient_id, user_id, notes) VALUES
+    (4, 1, 'Works for baking and sauteing; expect a softer crumb in pastry'),
+    (6, 1, 'Let the milk stand for 5 minutes after adding the lemon juice');
+
+INSERT INTO ingredient_substitution_part (substitution_id, ingredient_id, ratio) VALUES
+    (1, 5, 1),
+    (2, 7, 0.9375),
+    (2, 8, 0.0625);
+
+-- Nutrients per 100 g
+INSERT INTO ingredient_nutrition (ingredient_id, calories, protein_g, fat_g, saturated_fat_g, carbohydrates_g, sugar_g, fiber_g, sodium_mg, cholesterol_mg, potassium_mg, calcium_mg, iron_mg, vitamin_a_ug, vitamin_c_mg, vitamin_d_ug) VALUES
+    (1, 0, 0, 0, 0, 0, 0, 0, 38758, 0, 8, 24, 0.33, 0, 0, 0),
+    (2, 251, 10.4, 3.3, 1.4, 64, 0.6, 25.3, 20, 0, 1329, 443, 9.71, 27, 0, 0),
+    (3, 120, 22.5, 2.6, 0.6, 0, 0, 0, 45, 73, 334, 5, 0.37, 9, 0, 0.1);
 
 -- Create a recipe or two
-INSERT INTO recipe (name, description, user_id) VALUES
-    ('grilled chicken breast', 'Grill up some tasty chicken!', 1),
-    ('oven baked chicken breast', 'Prepare this easy chicken dish in the oven', 2);
+INSERT INTO recipe (name, description, user_id, household_id, servings, prep_time, cook_time, rest_time) VALUES
+    ('grilled chicken breast', 'Grill up some tasty chicken!', 1, NULL, 2, '10 minutes', '15 minutes', '5 minutes'),
+    ('oven baked chicken breast', 'Prepare this easy chicken dish in the oven', 2, 1, 4, '10 minutes', '25 minutes', NULL);
+
+-- Describe how to make them
+INSERT INTO recipe_step (recipe_id, position, text, duration) VALUES
+    (1, 1, 'Season the chicken with salt and pepper.', NULL),
+    (1, 2, 'Grill over medium-high heat, turning once.', '15 minutes'),
+    (1, 3, 'Rest before slicing.', '5 minutes'),
+    (2, 1, 'Heat the oven to 220C and season the chicken.', NULL),
+    (2, 2, 'Bake until cooked through.', '25 minutes');
 
 -- Link the ingredients to recipes
-INSERT INTO recipe_ingredient (recipe_id, ingredient_id) VALUES
-    (1, 1),
-    (1, 2),
-    (1, 3),
-    (2, 1),
-    (2, 2);
+INSERT INTO recipe_ingredient (recipe_id, ingredient_id, quantity, unit) VALUES
+    (1, 1, 1, 'tsp'),
+    (1, 2, 0.5, 'tsp'),
+    (1, 3, 1, 'lb'),
+    (2, 1, 2, 'tsp'),
+    (2, 2, 1, 'tsp');
+
+-- Plan a couple of meals
+INSERT INTO meal_plan_entry (user_id, household_id, recipe_id, plan_date, slot, servings, notes) VALUES
+    (1, NULL, 1, CURRENT_DATE, 'DINNER', NULL, 'Fire up the grill early'),
+    (2, 1, 2, CURRENT_DATE + 1, 'DINNER', 6, 'Guests coming over');
+
+-- Stock the pantry
+INSERT INTO pantry_item (user_id, household_id, ingredient_id, quantity, unit, purchased_on, expires_on) VALUES
+    (1, NULL, 1, 500, 'g', CURRENT_DATE - 30, NULL),
+    (2, 1, 3, 2, 'lb', CURRENT_DATE, CURRENT_DATE + 3);
+
+-- Record what a few ingredients cost
+INSERT INTO ingredient_price (ingredient_id, user_id, household_id, store, price, quantity, unit, priced_on) VALUES
+    (1, 1, NULL, 'Ralphs', 1.29, 26, 'oz', CURRENT_DATE - 60),
+    (2, 1, NULL, 'Ralphs', 4.99, 3, 'oz', CURRENT_DATE - 60),
+    (3, 1, NULL, 'Ralphs', 4.49, 1, 'lb', CURRENT_DATE - 14),
+    (3, 1, NULL, 'Ralphs', 4.79, 1, 'lb', CURRENT_DATE - 2),
+    (3, 2, 1, 'Costco', 18.99, 6, 'lb', CURRENT_DATE - 7);
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Get the steps of a recipe, in order.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - recipe_id: ID of the recipe
+//
+// Returns:
+//   - Array of RecipeSteps encoded as the defined model object
+func GetRecipeSteps(pool *pgxpool.Pool, ctx context.Context, recipe_id string) ([]*model.RecipeStep, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT rs.position, rs.text, rs.duration, im.image_id, im.content_type, im.width, im.height
+		FROM recipe_step rs
+		LEFT JOIN image im ON rs.image_id = im.image_id
+		WHERE rs.recipe_id = $1
+		ORDER BY rs.position
+		`,
+		recipe_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recipe steps from server; error: %v", err)
+	}
+
+	steps := []*model.RecipeStep{}
+	for rows.Next() {
+		var step model.RecipeStep
+		var imageID, imageContentType *string
+		var imageWidth, imageHeight *int
+		err := rows.Scan(&step.Position, &step.Text, &step.Duration, &imageID, &imageContentType, &imageWidth, &imageHeight)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recipe steps into struct; error: %v", err)
+		}
+		step.Image = scanImage(imageID, imageContentType, imageWidth, imageHeight)
+		steps = append(steps, &step)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return steps, nil
+}
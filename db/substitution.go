@@ -0,0 +1,239 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Get substitutions from the database, with their ingredients loaded.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - whereQuery: "WHERE" clause appended to the query, may be empty
+//   - whereArgs: arguments referenced by whereQuery
+//
+// Returns:
+//   - Array of Substitutions encoded as the defined model object
+func getSubstitutions(pool *pgxpool.Pool, ctx context.Context, whereQuery string, whereArgs []interface{}) ([]*model.Substitution, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT s.substitution_id::TEXT, s.ingredient_id::TEXT, s.notes, u.user_id, u.name
+		FROM ingredient_substitution s
+		LEFT JOIN user_account u ON s.user_id = u.user_id
+		`+whereQuery+`
+		ORDER BY s.substitution_id
+		`,
+		whereArgs...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get substitutions from server; error: %v", err)
+	}
+
+	var substitutions []*model.Substitution
+	byId := map[string]*model.Substitution{}
+	ingredient_ids := []string{}
+	for rows.Next() {
+		var substitution model.Substitution
+		var ingredient_id string
+		var userID, userName *string
+
+		err := rows.Scan(&substitution.SubstitutionID, &ingredient_id, &substitution.Notes, &userID, &userName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse substitutions into struct; error: %v", err)
+		}
+		if userID != nil {
+			substitution.User = &model.User{UserID: *userID, Name: *userName}
+		}
+		substitution.Ingredient = &model.Ingredient{IngredientID: ingredient_id}
+		substitution.Replacements = []*model.SubstitutionPart{}
+
+		substitutions = append(substitutions, &substitution)
+		byId[substitution.SubstitutionID] = &substitution
+		ingredient_ids = append(ingredient_ids, ingredient_id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+	if len(substitutions) == 0 {
+		return substitutions, nil
+	}
+
+	substitution_ids := make([]string, 0, len(substitutions))
+	for _, substitution := range substitutions {
+		substitution_ids = append(substitution_ids, substitution.SubstitutionID)
+	}
+	rows, err = pool.Query(
+		ctx,
+		`
+		SELECT substitution_id::TEXT, ingredient_id::TEXT, ratio::FLOAT8
+		FROM ingredient_substitution_part
+		WHERE substitution_id::TEXT = ANY($1::TEXT[])
+		ORDER BY substitution_id, ratio DESC
+		`,
+		substitution_ids,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get substitution parts from server; error: %v", err)
+	}
+	for rows.Next() {
+		var substitution_id, ingredient_id string
+		var part model.SubstitutionPart
+
+		err := rows.Scan(&substitution_id, &ingredient_id, &part.Ratio)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse substitution parts into struct; error: %v", err)
+		}
+		part.Ingredient = &model.Ingredient{IngredientID: ingredient_id}
+		byId[substitution_id].Replacements = append(byId[substitution_id].Replacements, &part)
+		ingredient_ids = append(ingredient_ids, ingredient_id)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	ingredients, err := GetIngredientsByIds(pool, ctx, ingredient_ids)
+	if err != nil {
+		return nil, err
+	}
+	ingredientsById := map[string]*model.Ingredient{}
+	for _, ingredient := range ingredients {
+		ingredientsById[ingredient.IngredientID] = ingredient
+	}
+	for _, substitution := range substitutions {
+		substitution.Ingredient = ingredientsById[substitution.Ingredient.IngredientID]
+		for _, part := range substitution.Replacements {
+			part.Ingredient = ingredientsById[part.Ingredient.IngredientID]
+		}
+	}
+
+	return substitutions, nil
+}
+
+// Get the substitutions known for a set of ingredients.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - ingredient_ids: IDs of the ingredients being replaced
+//
+// Returns:
+//   - Map of ingredient ID to the substitutions for that ingredient
+func GetSubstitutions(pool *pgxpool.Pool, ctx context.Context, ingredient_ids []string) (map[string][]*model.Substitution, error) {
+	substitutions := map[string][]*model.Substitution{}
+	if len(ingredient_ids) == 0 {
+		return substitutions, nil
+	}
+
+	found, err := getSubstitutions(pool, ctx, "WHERE s.ingredient_id::TEXT = ANY($1::TEXT[])", []interface{}{ingredient_ids})
+	if err != nil {
+		return nil, err
+	}
+	for _, substitution := range found {
+		ingredient_id := substitution.Ingredient.IngredientID
+		substitutions[ingredient_id] = append(substitutions[ingredient_id], substitution)
+	}
+	return substitutions, nil
+}
+
+// Get a substitution from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - substitution_id: ID of the substitution to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - Substitution encoded as the defined model object
+func GetSubstitutionById(pool *pgxpool.Pool, substitution_id string, ctx context.Context) (*model.Substitution, error) {
+	substitutions, err := getSubstitutions(pool, ctx, "WHERE s.substitution_id::TEXT = $1", []interface{}{substitution_id})
+	if err != nil {
+		return nil, err
+	}
+	if len(substitutions) == 0 {
+		return nil, apperr.NotFound("found no substitutions with provided id")
+	}
+	return substitutions[0], nil
+}
+
+// Create a substitution for an ingredient.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user suggesting the substitution
+//   - input: Ingredient being replaced and its replacements
+//
+// Returns:
+//   - ID of the newly created substitution
+func CreateSubstitution(pool *pgxpool.Pool, ctx context.Context, user_id string, input model.NewSubstitution) (string, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to start transaction; error: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	notes := ""
+	if input.Notes != nil {
+		notes = *input.Notes
+	}
+
+	var substitution_id string
+	err = tx.QueryRow(
+		ctx,
+		`
+		INSERT INTO ingredient_substitution (ingredient_id, user_id, notes)
+		VALUES ($1, $2, $3)
+		RETURNING substitution_id::TEXT
+		`,
+		input.IngredientID,
+		user_id,
+		notes,
+	).Scan(&substitution_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create substitution; error: %v", err)
+	}
+
+	for _, part := range input.Replacements {
+		ratio := 1.0
+		if part.Ratio != nil {
+			ratio = *part.Ratio
+		}
+		_, err = tx.Exec(
+			ctx,
+			`INSERT INTO ingredient_substitution_part (substitution_id, ingredient_id, ratio) VALUES ($1, $2, $3)`,
+			substitution_id,
+			part.IngredientID,
+			ratio,
+		)
+		if err != nil {
+			return "", fmt.Errorf("failed to add substitution replacement; error: %v", err)
+		}
+	}
+
+	return substitution_id, tx.Commit(ctx)
+}
+
+// Remove a substitution.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - substitution_id: ID of the substitution
+//
+// Returns:
+//   - Error if the substitution could not be removed
+func DeleteSubstitution(pool *pgxpool.Pool, ctx context.Context, substitution_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM ingredient_substitution WHERE substitution_id = $1`, substitution_id)
+	if err != nil {
+		return fmt.Errorf("failed to remove substitution; error: %v", err)
+	}
+	return nil
+}
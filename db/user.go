@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Get a user from the database by their name.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - name: Unique name of the user
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - User encoded as the defined model object
+func GetUserByName(pool *pgxpool.Pool, name string, ctx context.Context) (*model.User, error) {
+	var user model.User
+	err := pool.QueryRow(
+		ctx,
+		`SELECT user_id, name FROM user_account WHERE name = $1`,
+		name,
+	).Scan(&user.UserID, &user.Name)
+	if err == pgx.ErrNoRows {
+		return nil, apperr.NotFound("found no user with provided name")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user; error: %v", err)
+	}
+	return &user, nil
+}
+
+// Check a user's credentials against the database.
+// TODO: Passwords are stored as plain text, see initialize.sql
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - name: Name the user is logging in as
+//   - password: Password provided by the user
+//
+// Returns:
+//   - User encoded as the defined model object, or nil if the credentials do not match
+func GetUserByCredentials(pool *pgxpool.Pool, ctx context.Context, name string, password string) (*model.User, error) {
+	var user model.User
+	var storedPassword string
+	err := pool.QueryRow(
+		ctx,
+		`SELECT user_id, name, COALESCE(password, '') FROM user_account WHERE name = $1`,
+		name,
+	).Scan(&user.UserID, &user.Name, &storedPassword)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up user credentials; error: %v", err)
+	}
+	if storedPassword == "" || subtle.ConstantTimeCompare([]byte(storedPassword), []byte(password)) != 1 {
+		return nil, nil
+	}
+	return &user, nil
+}
@@ -0,0 +1,482 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// A delivery claimed for sending, with what is needed to send it.
+type WebhookAttempt struct {
+	DeliveryID string
+	// Attempts made so far, including this one
+	Attempts  int
+	URL       string
+	Secret    string
+	EventID   string
+	Event     model.WebhookEvent
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// Get webhooks matching a condition.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - condition: SQL condition to filter webhooks with
+//   - args: Arguments referenced by the condition
+//
+// Returns:
+//   - Array of Webhooks encoded as the defined model object
+func getWebhooks(pool *pgxpool.Pool, ctx context.Context, condition string, args []interface{}) ([]*model.Webhook, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT webhook_id, url, events, active, created_at
+		FROM webhook
+		WHERE `+condition+`
+		ORDER BY created_at, webhook_id
+		`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks from server; error: %v", err)
+	}
+
+	webhooks := []*model.Webhook{}
+	for rows.Next() {
+		var webhook model.Webhook
+		var events []string
+		err := rows.Scan(&webhook.WebhookID, &webhook.URL, &events, &webhook.Active, &webhook.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhooks into struct; error: %v", err)
+		}
+		webhook.Events = []model.WebhookEvent{}
+		for _, event := range events {
+			webhook.Events = append(webhook.Events, model.WebhookEvent(event))
+		}
+		webhooks = append(webhooks, &webhook)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return webhooks, nil
+}
+
+// Get the webhooks a user registered.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user
+//
+// Returns:
+//   - Array of Webhooks encoded as the defined model object
+func GetWebhooks(pool *pgxpool.Pool, ctx context.Context, user_id string) ([]*model.Webhook, error) {
+	return getWebhooks(pool, ctx, "user_id = $1", []interface{}{user_id})
+}
+
+// Get a webhook from the database.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - webhook_id: ID of the webhook to retrieve
+//   - ctx: pgx connection context
+//
+// Returns:
+//   - Webhook encoded as the defined model object
+//   - ID of the user who registered it
+func GetWebhookById(pool *pgxpool.Pool, webhook_id string, ctx context.Context) (*model.Webhook, string, error) {
+	var user_id string
+	err := pool.QueryRow(ctx, `SELECT user_id::TEXT FROM webhook WHERE webhook_id = $1`, webhook_id).Scan(&user_id)
+	if err == pgx.ErrNoRows {
+		return nil, "", apperr.NotFound("found no webhooks with provided id")
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get webhook; error: %v", err)
+	}
+	webhooks, err := getWebhooks(pool, ctx, "webhook_id = $1", []interface{}{webhook_id})
+	if err != nil {
+		return nil, "", err
+	}
+	if len(webhooks) == 0 {
+		return nil, "", apperr.NotFound("found no webhooks with provided id")
+	}
+	return webhooks[0], user_id, nil
+}
+
+// Register a webhook.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - user_id: ID of the user registering the webhook
+//   - input: URL and events of the webhook
+//   - secret: Key to sign requests to the webhook with
+//
+// Returns:
+//   - ID of the newly created webhook
+func CreateWebhook(pool *pgxpool.Pool, ctx context.Context, user_id string, input model.NewWebhook, secret string) (string, error) {
+	var webhook_id string
+	err := pool.QueryRow(
+		ctx,
+		`INSERT INTO webhook (user_id, url, secret, events) VALUES ($1, $2, $3, $4) RETURNING webhook_id::TEXT`,
+		user_id,
+		input.URL,
+		secret,
+		input.Events,
+	).Scan(&webhook_id)
+	if err != nil {
+		return "", fmt.Errorf("failed to create webhook; error: %v", err)
+	}
+	return webhook_id, nil
+}
+
+// Update the fields of a webhook that are provided.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - webhook_id: ID of the webhook
+//   - input: Fields to change
+//
+// Returns:
+//   - Error if the webhook could not be updated
+func UpdateWebhook(pool *pgxpool.Pool, ctx context.Context, webhook_id string, input model.UpdateWebhook) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		UPDATE webhook SET
+			url = COALESCE($1, url),
+			events = COALESCE($2, events),
+			active = COALESCE($3, active)
+		WHERE webhook_id = $4
+		`,
+		input.URL,
+		input.Events,
+		input.Active,
+		webhook_id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook; error: %v", err)
+	}
+	return nil
+}
+
+// Delete a webhook along with its deliveries.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - webhook_id: ID of the webhook
+//
+// Returns:
+//   - Error if the webhook could not be deleted
+func DeleteWebhook(pool *pgxpool.Pool, ctx context.Context, webhook_id string) error {
+	_, err := pool.Exec(ctx, `DELETE FROM webhook WHERE webhook_id = $1`, webhook_id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook; error: %v", err)
+	}
+	return nil
+}
+
+// Get webhook deliveries matching a condition, most recent first.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - condition: SQL condition to filter deliveries with
+//   - args: Arguments referenced by the condition
+//   - limit: Maximum number of deliveries to return
+//
+// Returns:
+//   - Array of WebhookDeliveries encoded as the defined model object
+func getWebhookDeliveries(pool *pgxpool.Pool, ctx context.Context, condition string, args []interface{}, limit int) ([]*model.WebhookDelivery, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		SELECT d.delivery_id, e.event, e.payload::TEXT, d.status, d.attempts,
+			CASE WHEN d.status = 'PENDING' THEN d.next_attempt_at END,
+			d.last_status_code, d.last_error, d.created_at, d.delivered_at
+		FROM webhook_delivery d
+		JOIN webhook_event e ON d.event_id = e.event_id
+		WHERE `+condition+`
+		ORDER BY d.created_at DESC, d.delivery_id DESC
+		LIMIT `+fmt.Sprintf("$%d", len(args)+1),
+		append(args, limit)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries from server; error: %v", err)
+	}
+
+	deliveries := []*model.WebhookDelivery{}
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+		err := rows.Scan(
+			&delivery.DeliveryID,
+			&delivery.Event,
+			&delivery.Payload,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.NextAttemptAt,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&delivery.CreatedAt,
+			&delivery.DeliveredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook deliveries into struct; error: %v", err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// Get the most recent deliveries to a webhook.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - webhook_id: ID of the webhook
+//   - limit: Maximum number of deliveries to return
+//
+// Returns:
+//   - Array of WebhookDeliveries encoded as the defined model object
+func GetWebhookDeliveries(pool *pgxpool.Pool, ctx context.Context, webhook_id string, limit int) ([]*model.WebhookDelivery, error) {
+	return getWebhookDeliveries(pool, ctx, "d.webhook_id = $1", []interface{}{webhook_id}, limit)
+}
+
+// Queue a delivery to be sent again straight away, however it went before.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - delivery_id: ID of the delivery
+//
+// Returns:
+//   - WebhookDelivery encoded as the defined model object
+func RetryWebhookDelivery(pool *pgxpool.Pool, ctx context.Context, delivery_id string) (*model.WebhookDelivery, error) {
+	_, err := pool.Exec(
+		ctx,
+		`
+		UPDATE webhook_delivery SET status = 'PENDING', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE delivery_id = $1
+		`,
+		delivery_id,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry webhook delivery; error: %v", err)
+	}
+	deliveries, err := getWebhookDeliveries(pool, ctx, "d.delivery_id = $1", []interface{}{delivery_id}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, apperr.NotFound("found no webhook deliveries with provided id")
+	}
+	return deliveries[0], nil
+}
+
+// Get the ID of the webhook a delivery is sent to.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - delivery_id: ID of the delivery
+//
+// Returns:
+//   - ID of the webhook
+func GetWebhookIdForDelivery(pool *pgxpool.Pool, ctx context.Context, delivery_id string) (string, error) {
+	var webhook_id string
+	err := pool.QueryRow(ctx, `SELECT webhook_id::TEXT FROM webhook_delivery WHERE delivery_id = $1`, delivery_id).Scan(&webhook_id)
+	if err == pgx.ErrNoRows {
+		return "", apperr.NotFound("found no webhook deliveries with provided id")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get webhook delivery; error: %v", err)
+	}
+	return webhook_id, nil
+}
+
+// Queue a delivery of events waiting in the outbox to every active webhook that is subscribed
+// to them and belongs to the event's user or a member of its household. Events are claimed with
+// SKIP LOCKED, so several servers can queue at once without sending anything twice.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - limit: Maximum number of events to queue
+//
+// Returns:
+//   - Number of events queued
+func QueueWebhookEvents(pool *pgxpool.Pool, ctx context.Context, limit int) (int, error) {
+	tag, err := pool.Exec(
+		ctx,
+		`
+		WITH waiting AS (
+			SELECT event_id, event, user_id, household_id FROM webhook_event
+			WHERE queued_at IS NULL
+			ORDER BY event_id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), queued AS (
+			INSERT INTO webhook_delivery (webhook_id, event_id)
+			SELECT w.webhook_id, e.event_id
+			FROM waiting e
+			JOIN webhook w ON w.active AND e.event = ANY (w.events)
+			WHERE w.user_id = e.user_id
+				OR w.user_id IN (SELECT user_id FROM household_member WHERE household_id = e.household_id)
+		)
+		UPDATE webhook_event SET queued_at = NOW() WHERE event_id IN (SELECT event_id FROM waiting)
+		`,
+		limit,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to queue webhook events; error: %v", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
+
+// Claim deliveries that are due, counting an attempt for each. A claim lasts for the lease:
+// if the server stops before recording how an attempt went, the delivery is due again after it.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - limit: Maximum number of deliveries to claim
+//   - lease: How long the claim lasts
+//
+// Returns:
+//   - Array of claimed deliveries
+func ClaimWebhookDeliveries(pool *pgxpool.Pool, ctx context.Context, limit int, lease time.Duration) ([]*WebhookAttempt, error) {
+	rows, err := pool.Query(
+		ctx,
+		`
+		UPDATE webhook_delivery d SET attempts = d.attempts + 1, next_attempt_at = NOW() + $2::INTERVAL
+		FROM webhook w, webhook_event e
+		WHERE d.delivery_id IN (
+			SELECT delivery_id FROM webhook_delivery
+			JOIN webhook USING (webhook_id)
+			WHERE status = 'PENDING' AND next_attempt_at <= NOW() AND active
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF webhook_delivery SKIP LOCKED
+		) AND w.webhook_id = d.webhook_id AND e.event_id = d.event_id
+		RETURNING d.delivery_id, d.attempts, w.url, w.secret, e.event_id, e.event, e.payload, e.created_at
+		`,
+		limit,
+		lease,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries; error: %v", err)
+	}
+
+	attempts := []*WebhookAttempt{}
+	for rows.Next() {
+		var attempt WebhookAttempt
+		err := rows.Scan(
+			&attempt.DeliveryID,
+			&attempt.Attempts,
+			&attempt.URL,
+			&attempt.Secret,
+			&attempt.EventID,
+			&attempt.Event,
+			&attempt.Payload,
+			&attempt.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse webhook deliveries into struct; error: %v", err)
+		}
+		attempts = append(attempts, &attempt)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse through returned SQL rows; error: %v", err)
+	}
+
+	return attempts, nil
+}
+
+// Record how an attempt to send a delivery went.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - delivery_id: ID of the delivery
+//   - status: Status of the delivery after the attempt
+//   - status_code: HTTP status the receiver answered with, if it answered
+//   - attempt_error: Why the attempt failed, if it did
+//   - retry_in: How long to wait before trying again, while still pending
+//
+// Returns:
+//   - Error if the attempt could not be recorded
+func RecordWebhookAttempt(
+	pool *pgxpool.Pool,
+	ctx context.Context,
+	delivery_id string,
+	status model.WebhookDeliveryStatus,
+	status_code *int,
+	attempt_error *string,
+	retry_in time.Duration,
+) error {
+	_, err := pool.Exec(
+		ctx,
+		`
+		UPDATE webhook_delivery SET
+			status = $2::VARCHAR,
+			last_status_code = $3,
+			last_error = $4,
+			next_attempt_at = NOW() + $5::INTERVAL,
+			delivered_at = CASE WHEN $2::VARCHAR = 'SUCCEEDED' THEN NOW() END
+		WHERE delivery_id = $1
+		`,
+		delivery_id,
+		status,
+		status_code,
+		attempt_error,
+		retry_in,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt; error: %v", err)
+	}
+	return nil
+}
+
+// Delete queued events older than a cutoff, along with their deliveries.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - ctx: pgx connection context
+//   - before: Cutoff to delete events created before
+//
+// Returns:
+//   - Number of events deleted
+func PruneWebhookEvents(pool *pgxpool.Pool, ctx context.Context, before time.Time) (int, error) {
+	tag, err := pool.Exec(
+		ctx,
+		`
+		DELETE FROM webhook_event e
+		WHERE queued_at IS NOT NULL AND created_at < $1
+			AND NOT EXISTS (SELECT 1 FROM webhook_delivery WHERE event_id = e.event_id AND status = 'PENDING')
+		`,
+		before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune webhook events; error: %v", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,78 @@
+// Derive the allergens and dietary labels of recipes from their ingredients.
+package dietary
+
+import (
+	"slices"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Collect the allergens found in any of a set of ingredients.
+//
+// Parameters:
+//   - ingredients: Ingredients to check
+//
+// Returns:
+//   - Array of allergens, in schema order
+func Allergens(ingredients []*model.Ingredient) []model.Allergen {
+	found := map[model.Allergen]bool{}
+	for _, ingredient := range ingredients {
+		for _, allergen := range ingredient.Allergens {
+			found[allergen] = true
+		}
+	}
+
+	allergens := []model.Allergen{}
+	for _, allergen := range model.AllAllergen {
+		if found[allergen] {
+			allergens = append(allergens, allergen)
+		}
+	}
+	return allergens
+}
+
+// Collect the diets every one of a set of ingredients is suitable for.
+// A recipe with no ingredients is given no labels, since nothing is known about it.
+//
+// Parameters:
+//   - ingredients: Ingredients to check
+//
+// Returns:
+//   - Array of diets, in schema order
+func Labels(ingredients []*model.Ingredient) []model.Diet {
+	labels := []model.Diet{}
+	if len(ingredients) == 0 {
+		return labels
+	}
+	for _, diet := range model.AllDiet {
+		suitable := true
+		for _, ingredient := range ingredients {
+			if !slices.Contains(ingredient.Diets, diet) {
+				suitable = false
+				break
+			}
+		}
+		if suitable {
+			labels = append(labels, diet)
+		}
+	}
+	return labels
+}
+
+// Check whether a recipe passes a dietary filter.
+//
+// Parameters:
+//   - recipe: Recipe with its allergens and labels derived
+//   - excludeAllergens: Allergens the recipe must not contain
+//   - diet: Diet the recipe must be suitable for, if any
+//
+// Returns:
+//   - Whether the recipe passes
+func Matches(recipe *model.Recipe, excludeAllergens []model.Allergen, diet *model.Diet) bool {
+	for _, allergen := range excludeAllergens {
+		if slices.Contains(recipe.Allergens, allergen) {
+			return false
+		}
+	}
+	return diet == nil || slices.Contains(recipe.DietaryLabels, *diet)
+}
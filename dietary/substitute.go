@@ -0,0 +1,100 @@
+package dietary
+
+import (
+	"slices"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Check whether an ingredient passes a dietary filter.
+//
+// Parameters:
+//   - ingredient: Ingredient with its allergens and diets loaded
+//   - excludeAllergens: Allergens the ingredient must not contain
+//   - diet: Diet the ingredient must be suitable for, if any
+//
+// Returns:
+//   - Whether the ingredient passes
+func Suits(ingredient *model.Ingredient, excludeAllergens []model.Allergen, diet *model.Diet) bool {
+	for _, allergen := range excludeAllergens {
+		if slices.Contains(ingredient.Allergens, allergen) {
+			return false
+		}
+	}
+	return diet == nil || slices.Contains(ingredient.Diets, *diet)
+}
+
+// Swap substitutes into a recipe's ingredient lines so it passes a dietary filter.
+// Each unsuitable line is replaced by the first of its substitutions whose replacements
+// all pass, scaling the quantity by each replacement's ratio and keeping the unit.
+// Lines with no suitable substitution are kept as they are and reported as unresolved.
+//
+// Parameters:
+//   - lines: Recipe ingredient lines
+//   - substitutions: Known substitutions keyed by the ID of the ingredient they replace
+//   - excludeAllergens: Allergens the recipe must not contain
+//   - diet: Diet the recipe must be suitable for, if any
+//
+// Returns:
+//   - Plan holding the modified lines and what was changed
+func Substitute(lines []*model.RecipeIngredient, substitutions map[string][]*model.Substitution, excludeAllergens []model.Allergen, diet *model.Diet) *model.SubstitutionPlan {
+	plan := model.SubstitutionPlan{
+		Lines:      []*model.RecipeIngredient{},
+		Applied:    []*model.AppliedSubstitution{},
+		Unresolved: []*model.RecipeIngredient{},
+	}
+
+	for _, line := range lines {
+		if Suits(line.Ingredient, excludeAllergens, diet) {
+			plan.Lines = append(plan.Lines, line)
+			continue
+		}
+
+		substitution := suitableSubstitution(substitutions[line.Ingredient.IngredientID], excludeAllergens, diet)
+		if substitution == nil {
+			plan.Lines = append(plan.Lines, line)
+			plan.Unresolved = append(plan.Unresolved, line)
+			continue
+		}
+
+		for _, part := range substitution.Replacements {
+			replacement := model.RecipeIngredient{Ingredient: part.Ingredient, Unit: line.Unit}
+			if line.Quantity != nil {
+				quantity := *line.Quantity * part.Ratio
+				replacement.Quantity = &quantity
+			}
+			plan.Lines = append(plan.Lines, &replacement)
+		}
+		plan.Applied = append(plan.Applied, &model.AppliedSubstitution{Original: line, Substitution: substitution})
+	}
+
+	return &plan
+}
+
+// Pick the first substitution whose replacements all pass a dietary filter.
+//
+// Parameters:
+//   - substitutions: Candidate substitutions
+//   - excludeAllergens: Allergens the replacements must not contain
+//   - diet: Diet the replacements must be suitable for, if any
+//
+// Returns:
+//   - The substitution, nil if none pass
+func suitableSubstitution(substitutions []*model.Substitution, excludeAllergens []model.Allergen, diet *model.Diet) *model.Substitution {
+	for _, substitution := range substitutions {
+		if len(substitution.Replacements) == 0 {
+			continue
+		}
+		suitable := true
+		for _, part := range substitution.Replacements {
+			if !Suits(part.Ingredient, excludeAllergens, diet) {
+				suitable = false
+				break
+			}
+		}
+		if suitable {
+			return substitution
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Deliver changes announced by the database to subscribers on every server instance.
+package events
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Channel the database announces changes on, from the triggers in initialize.sql.
+const Channel = "ambrosia_events"
+
+// Kind of change announced.
+type Topic string
+
+const (
+	// A recipe was created; the ID is the recipe's and the user is its creator
+	RecipeCreated Topic = "recipe_created"
+	// A recipe, its steps or its ingredient lines changed, or it was deleted
+	RecipeUpdated Topic = "recipe_updated"
+	// A shopping list or its items changed, or it was deleted
+	ShoppingListChanged Topic = "shopping_list_changed"
+)
+
+// A change to one row, e.g. recipe 7 being updated.
+type Event struct {
+	Topic Topic
+	ID    string
+	// User the row belongs to, for topics that announce it; empty otherwise
+	UserID string
+}
+
+// Events waiting to be read by a subscriber before more are dropped.
+const subscriberBuffer = 64
+
+// Longest wait before listening again after losing the database connection.
+const maxReconnectDelay = 30 * time.Second
+
+type subscriber struct {
+	id     string
+	events chan Event
+}
+
+// Broker fans changes out to subscribers on this server instance. Changes are announced by the
+// database with NOTIFY, so each instance hears about writes made through any of them.
+type Broker struct {
+	pool        *pgxpool.Pool
+	mu          sync.Mutex
+	subscribers map[Topic]map[*subscriber]struct{}
+}
+
+// Create a broker; it delivers nothing until Listen is running.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//
+// Returns:
+//   - The broker
+func NewBroker(pool *pgxpool.Pool) *Broker {
+	return &Broker{pool: pool, subscribers: map[Topic]map[*subscriber]struct{}{}}
+}
+
+// Receive changes on a topic until the context ends, when the channel is closed.
+// Subscribers that fall behind miss changes rather than hold up the others.
+//
+// Parameters:
+//   - ctx: Context of the subscription
+//   - topic: Kind of change to receive
+//   - id: ID of the row to receive changes to, or empty for every row
+//
+// Returns:
+//   - Channel of changes
+func (b *Broker) Subscribe(ctx context.Context, topic Topic, id string) <-chan Event {
+	sub := &subscriber{id: id, events: make(chan Event, subscriberBuffer)}
+	b.mu.Lock()
+	if b.subscribers[topic] == nil {
+		b.subscribers[topic] = map[*subscriber]struct{}{}
+	}
+	b.subscribers[topic][sub] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		delete(b.subscribers[topic], sub)
+		b.mu.Unlock()
+		close(sub.events)
+	}()
+	return sub.events
+}
+
+// Pass a change to its topic's subscribers.
+//
+// Parameters:
+//   - event: Change to pass on
+func (b *Broker) publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subscribers[event.Topic] {
+		if sub.id != "" && sub.id != event.ID {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			log.Printf("Dropped %s event for a slow subscriber", event.Topic)
+		}
+	}
+}
+
+// Listen for changes announced by the database and deliver them until the context ends,
+// reconnecting with a growing delay whenever the connection is lost.
+//
+// Parameters:
+//   - ctx: Context to stop listening with
+func (b *Broker) Listen(ctx context.Context) {
+	delay := time.Second
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := b.listen(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		// A connection that held up for a while starts the delay over
+		if time.Since(started) > maxReconnectDelay {
+			delay = time.Second
+		}
+		log.Printf("Lost database notifications, listening again in %s: %v", delay, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// Hold a connection listening for changes, delivering them until it fails.
+//
+// Parameters:
+//   - ctx: Context to stop listening with
+//
+// Returns:
+//   - Error the connection failed with
+func (b *Broker) listen(ctx context.Context) error {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// The connection is left listening, so it should not go back to the pool
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		topic, id, ok := strings.Cut(notification.Payload, ":")
+		if !ok {
+			log.Printf("Ignoring malformed notification %q", notification.Payload)
+			continue
+		}
+		id, userID, _ := strings.Cut(id, ":")
+		b.publish(Event{Topic: Topic(topic), ID: id, UserID: userID})
+	}
+}
@@ -0,0 +1,167 @@
+package exporter
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+)
+
+// Write a recipe in Cooklang (https://cooklang.org), in the form read by importer.FromCooklang.
+// Each ingredient is marked where a step first mentions it by name; ingredients no step
+// mentions are listed in a paragraph of their own before the steps. Preparation and notes
+// follow the ingredient in parentheses, separated by a semicolon, e.g. "@salt{}(crushed; to taste)".
+// Step durations are written as a timer at the end of the step.
+//
+// Parameters:
+//   - doc: Recipe to write
+//
+// Returns:
+//   - Cooklang document
+func Cooklang(doc *Document) ([]byte, error) {
+	recipe := doc.Recipe
+	var b strings.Builder
+
+	b.WriteString("---\n")
+	writeFrontMatter(&b, "title", yamlScalar(recipe.Name))
+	if description := strings.TrimSpace(recipe.Description); description != "" && !strings.Contains(description, "\n") {
+		writeFrontMatter(&b, "description", yamlScalar(description))
+	}
+	if recipe.Servings != nil {
+		writeFrontMatter(&b, "servings", strconv.Itoa(*recipe.Servings))
+	}
+	for _, field := range []struct {
+		key      string
+		duration *time.Duration
+	}{
+		{"prep time", recipe.PrepTime},
+		{"cook time", recipe.CookTime},
+		{"rest time", recipe.RestTime},
+	} {
+		if field.duration != nil {
+			writeFrontMatter(&b, field.key, model.FormatDuration(*field.duration))
+		}
+	}
+	if recipe.SourceURL != nil {
+		writeFrontMatter(&b, "source", yamlScalar(*recipe.SourceURL))
+	}
+	if recipe.Image != nil {
+		writeFrontMatter(&b, "image", yamlScalar(doc.URL(recipe.Image.URL)))
+	}
+	b.WriteString("---\n")
+
+	// Descriptions of several lines are kept as notes
+	if description := strings.TrimSpace(recipe.Description); strings.Contains(description, "\n") {
+		b.WriteString("\n")
+		for _, line := range strings.Split(description, "\n") {
+			b.WriteString(strings.TrimSpace("> "+line) + "\n")
+		}
+	}
+
+	steps := make([]string, len(doc.Steps))
+	for i, step := range doc.Steps {
+		steps[i] = strings.Join(strings.Fields(step.Text), " ")
+	}
+	unmentioned := []string{}
+	for _, line := range recipe.IngredientLines {
+		if !markIngredient(steps, line) {
+			unmentioned = append(unmentioned, cooklangIngredient(line.Ingredient.Name, line))
+		}
+	}
+	if len(unmentioned) > 0 {
+		b.WriteString("\n" + strings.Join(unmentioned, ", ") + "\n")
+	}
+
+	for i, step := range doc.Steps {
+		text := steps[i]
+		if step.Duration != nil {
+			text += " " + cooklangTimer(*step.Duration)
+		}
+		b.WriteString("\n" + text + "\n")
+	}
+
+	return []byte(b.String()), nil
+}
+
+// Mark the first mention of an ingredient in the steps with its amount,
+// skipping text that is already marked up. Mentions must match the name's case,
+// since the name is read back as it appears in the step.
+//
+// Parameters:
+//   - steps: Step texts, updated in place
+//   - line: Ingredient line to mark
+//
+// Returns:
+//   - Whether the ingredient was found in a step
+func markIngredient(steps []string, line *model.RecipeIngredient) bool {
+	name := strings.TrimSpace(line.Ingredient.Name)
+	if name == "" || strings.ContainsAny(name, "{}@#~") {
+		return false
+	}
+	mention := regexp.MustCompile(`(^|[^\p{L}\p{N}_@#~{%])(` + regexp.QuoteMeta(name) + `)($|[^\p{L}\p{N}_{}])`)
+	for i, text := range steps {
+		match := mention.FindStringSubmatchIndex(text)
+		if match == nil {
+			continue
+		}
+		steps[i] = text[:match[4]] + cooklangIngredient(text[match[4]:match[5]], line) + text[match[5]:]
+		return true
+	}
+	return false
+}
+
+// Write an ingredient in Cooklang, e.g. "@flour{2%cup}(sifted)".
+//
+// Parameters:
+//   - name: Name to write, as it appears in the step
+//   - line: Ingredient line the amount is taken from
+//
+// Returns:
+//   - The marked up ingredient
+func cooklangIngredient(name string, line *model.RecipeIngredient) string {
+	amount := ""
+	if line.Quantity != nil {
+		// Cooklang has no mixed numbers, so only plain fractions such as 1/2 are kept
+		amount = FormatQuantity(*line.Quantity)
+		if parsed, ok := ingredientline.ParseQuantity(amount); !ok || strings.Contains(amount, " ") || math.Abs(parsed-*line.Quantity) > 1e-6 {
+			amount = strconv.FormatFloat(*line.Quantity, 'f', -1, 64)
+		}
+		if line.Unit != nil && *line.Unit != "" {
+			amount += "%" + *line.Unit
+		}
+	}
+	details := ""
+	if line.Preparation != nil {
+		details = *line.Preparation
+	}
+	if line.Notes != nil && *line.Notes != "" {
+		details += "; " + *line.Notes
+	}
+	if details != "" {
+		details = "(" + details + ")"
+	}
+	return "@" + name + "{" + amount + "}" + details
+}
+
+// Write a duration as a Cooklang timer in the largest unit that keeps it whole,
+// e.g. "~{90%minutes}".
+//
+// Parameters:
+//   - d: Duration of the timer
+//
+// Returns:
+//   - The timer
+func cooklangTimer(d time.Duration) string {
+	switch {
+	case d%time.Hour == 0:
+		return "~{" + strconv.FormatInt(int64(d/time.Hour), 10) + "%hours}"
+	case d%time.Minute == 0:
+		return "~{" + strconv.FormatInt(int64(d/time.Minute), 10) + "%minutes}"
+	default:
+		return "~{" + strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "%seconds}"
+	}
+}
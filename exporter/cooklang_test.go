@@ -0,0 +1,80 @@
+package exporter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/importer"
+)
+
+func TestCooklangRoundTrip(t *testing.T) {
+	lines := []*model.RecipeIngredient{
+		{Ingredient: &model.Ingredient{Name: "crushed tomatoes"}, Quantity: ptr(400.0), Unit: ptr("g")},
+		{Ingredient: &model.Ingredient{Name: "onion"}, Quantity: ptr(1.0), Preparation: ptr("finely chopped")},
+		{Ingredient: &model.Ingredient{Name: "Basil"}, Quantity: ptr(0.5), Unit: ptr("cup"), Preparation: ptr("torn"), Notes: ptr("or parsley")},
+		{Ingredient: &model.Ingredient{Name: "salt"}, Notes: ptr("to taste")},
+		{Ingredient: &model.Ingredient{Name: "olive oil"}, Quantity: ptr(2.0), Unit: ptr("tbsp")},
+	}
+	steps := []*model.RecipeStep{
+		{Text: "Soften the onion in the olive oil."},
+		{Text: "Add the crushed tomatoes and simmer.", Duration: ptr(20 * time.Minute)},
+		{Text: "Stir in the basil and season.", Duration: ptr(90 * time.Second)},
+	}
+	doc := &exporter.Document{
+		Recipe: &model.Recipe{
+			Name:            "Tomato Sauce",
+			Description:     "A simple sauce.",
+			Servings:        ptr(4),
+			CookTime:        ptr(30 * time.Minute),
+			IngredientLines: lines,
+		},
+		Steps: steps,
+	}
+	text, err := exporter.Cooklang(doc)
+	if err != nil {
+		t.Fatalf("Cooklang() error: %v", err)
+	}
+	recipe, err := importer.FromCooklang(string(text))
+	if err != nil {
+		t.Fatalf("FromCooklang() error: %v", err)
+	}
+
+	if recipe.Name != doc.Recipe.Name || recipe.Description != doc.Recipe.Description ||
+		recipe.Servings == nil || *recipe.Servings != 4 || recipe.CookTime == nil || *recipe.CookTime != 30*time.Minute {
+		t.Errorf("read back %q, %q, %v, %v\n%s", recipe.Name, recipe.Description, recipe.Servings, recipe.CookTime, text)
+	}
+
+	// Ingredients come back in the order they are marked: unmentioned ones first, then by step
+	if len(recipe.Ingredients) != len(lines) {
+		t.Fatalf("read back %d ingredients; want %d\n%s", len(recipe.Ingredients), len(lines), text)
+	}
+	read := map[string]bool{}
+	for _, got := range recipe.Ingredients {
+		for _, want := range lines {
+			if got.Name == want.Ingredient.Name &&
+				sameQuantity(got.Quantity, want.Quantity) &&
+				deref(got.Unit) == deref(want.Unit) &&
+				got.Preparation == deref(want.Preparation) &&
+				got.Notes == deref(want.Notes) {
+				read[got.Name] = true
+			}
+		}
+	}
+	for _, want := range lines {
+		if !read[want.Ingredient.Name] {
+			t.Errorf("ingredient %q did not read back unchanged\n%s", want.Ingredient.Name, text)
+		}
+	}
+
+	if len(recipe.Steps) != len(steps) {
+		t.Fatalf("read back %d steps; want %d\n%s", len(recipe.Steps), len(steps), text)
+	}
+	for i, want := range steps {
+		got := recipe.Steps[i]
+		if got.Text != want.Text || (got.Duration == nil) != (want.Duration == nil) || got.Duration != nil && *got.Duration != *want.Duration {
+			t.Errorf("step %d read back as %q, %v; want %q, %v", i, got.Text, got.Duration, want.Text, want.Duration)
+		}
+	}
+}
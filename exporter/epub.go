@@ -0,0 +1,362 @@
+package exporter
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zldobbs/ambrosia-server/nutrition"
+)
+
+// Write several recipes as an EPUB 3 book: a chapter per recipe with its photo, ingredients,
+// steps and nutrition, a table of contents, and an index of ingredients linking to the
+// recipes that use them. The book's author is the recipes' creator when they share one.
+//
+// Parameters:
+//   - title: Title of the book
+//   - docs: Recipes to write, in order
+//
+// Returns:
+//   - EPUB document
+func EPUB(title string, docs []*Document) ([]byte, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("cookbook has no recipes")
+	}
+	author := "Ambrosia"
+	if user := docs[0].Recipe.User; user != nil {
+		author = user.Name
+		for _, doc := range docs {
+			if doc.Recipe.User == nil || doc.Recipe.User.UserID != user.UserID {
+				author = "Ambrosia"
+				break
+			}
+		}
+	}
+
+	var out bytes.Buffer
+	book := zip.NewWriter(&out)
+	// Readers identify the format by an uncompressed mimetype file at the start of the archive
+	files := []epubFile{
+		{"mimetype", []byte("application/epub+zip"), true},
+		{"META-INF/container.xml", []byte(epubContainer), false},
+		{"OEBPS/style.css", []byte(epubStyle), false},
+		{"OEBPS/nav.xhtml", epubNav(title, docs), false},
+		{"OEBPS/index.xhtml", epubIndex(docs), false},
+		{"OEBPS/content.opf", epubPackage(title, author, docs), false},
+	}
+	for i, doc := range docs {
+		files = append(files, epubFile{"OEBPS/" + epubChapter(i), epubRecipe(doc, i), false})
+		if doc.Photo != nil {
+			// Photos are already compressed
+			files = append(files, epubFile{"OEBPS/" + epubPhoto(i), doc.Photo, true})
+		}
+	}
+
+	for _, file := range files {
+		method := zip.Deflate
+		if file.stored {
+			method = zip.Store
+		}
+		w, err := book.CreateHeader(&zip.FileHeader{Name: file.name, Method: method, Modified: time.Now()})
+		if err != nil {
+			return nil, fmt.Errorf("failed to write EPUB; error: %v", err)
+		}
+		if _, err := w.Write(file.data); err != nil {
+			return nil, fmt.Errorf("failed to write EPUB; error: %v", err)
+		}
+	}
+	if err := book.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write EPUB; error: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// A file in an EPUB archive, stored uncompressed when compressing it would not help.
+type epubFile struct {
+	name   string
+	data   []byte
+	stored bool
+}
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+const epubStyle = `body { font-family: serif; line-height: 1.4; }
+h1 { margin-bottom: 0.2em; }
+.summary { color: #555; font-size: 0.9em; margin-top: 0; }
+.photo { text-align: center; margin: 1em 0; }
+.photo img { max-width: 100%; max-height: 60vh; }
+.amount { font-weight: bold; }
+ol.steps li { margin-bottom: 0.6em; }
+table.nutrition { border: 1px solid #000; border-collapse: collapse; margin-top: 1em; }
+table.nutrition th, table.nutrition td { padding: 0.2em 0.6em; text-align: left; }
+table.nutrition td { text-align: right; }
+.note { font-size: 0.8em; font-style: italic; }
+.index dt { font-weight: bold; margin-top: 0.8em; }
+.index dd { margin: 0.2em 0 0 1em; }
+`
+
+// File name of a recipe's chapter.
+func epubChapter(index int) string {
+	return "recipe-" + strconv.Itoa(index+1) + ".xhtml"
+}
+
+// File name of a recipe's photo.
+func epubPhoto(index int) string {
+	return "images/recipe-" + strconv.Itoa(index+1) + ".jpg"
+}
+
+// Escape text for XHTML.
+func xhtml(text string) string {
+	return html.EscapeString(text)
+}
+
+// Wrap the body of a page in an XHTML document.
+//
+// Parameters:
+//   - title: Title of the page
+//   - body: Markup of the page body
+//
+// Returns:
+//   - The page
+func epubPage(title string, body string) []byte {
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+  <meta charset="UTF-8"/>
+  <title>` + xhtml(title) + `</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+` + body + `</body>
+</html>
+`)
+}
+
+// Write the package document listing the book's files and reading order.
+//
+// Parameters:
+//   - title: Title of the book
+//   - author: Creator of the book
+//   - docs: Recipes in the book
+//
+// Returns:
+//   - The package document
+func epubPackage(title string, author string, docs []*Document) []byte {
+	// The same recipes make the same book, so the identifier is derived from them
+	hash := sha1.New()
+	hash.Write([]byte(title))
+	for _, doc := range docs {
+		hash.Write([]byte("\x00" + doc.Recipe.RecipeID))
+	}
+	sum := hash.Sum(nil)
+	identifier := fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+
+	var manifest, spine strings.Builder
+	manifest.WriteString(`    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+    <item id="index" href="index.xhtml" media-type="application/xhtml+xml"/>
+`)
+	spine.WriteString("    <itemref idref=\"nav\"/>\n")
+	for i, doc := range docs {
+		fmt.Fprintf(&manifest, "    <item id=\"recipe-%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, epubChapter(i))
+		if doc.Photo != nil {
+			fmt.Fprintf(&manifest, "    <item id=\"photo-%d\" href=\"%s\" media-type=\"image/jpeg\"/>\n", i+1, epubPhoto(i))
+		}
+		fmt.Fprintf(&spine, "    <itemref idref=\"recipe-%d\"/>\n", i+1)
+	}
+	spine.WriteString("    <itemref idref=\"index\"/>\n")
+
+	return []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="en">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">` + identifier + `</dc:identifier>
+    <dc:title>` + xhtml(title) + `</dc:title>
+    <dc:creator>` + xhtml(author) + `</dc:creator>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">` + time.Now().UTC().Format("2006-01-02T15:04:05Z") + `</meta>
+  </metadata>
+  <manifest>
+` + manifest.String() + `  </manifest>
+  <spine>
+` + spine.String() + `  </spine>
+</package>
+`)
+}
+
+// Write the table of contents.
+//
+// Parameters:
+//   - title: Title of the book
+//   - docs: Recipes in the book
+//
+// Returns:
+//   - The navigation document
+func epubNav(title string, docs []*Document) []byte {
+	var b strings.Builder
+	b.WriteString("<h1>" + xhtml(title) + "</h1>\n<nav epub:type=\"toc\" id=\"toc\">\n  <h2>Contents</h2>\n  <ol>\n")
+	for i, doc := range docs {
+		fmt.Fprintf(&b, "    <li><a href=\"%s\">%s</a></li>\n", epubChapter(i), xhtml(doc.Recipe.Name))
+	}
+	b.WriteString("    <li><a href=\"index.xhtml\">Ingredient Index</a></li>\n  </ol>\n</nav>\n")
+	return epubPage(title, b.String())
+}
+
+// Write a recipe's chapter.
+//
+// Parameters:
+//   - doc: Recipe to write
+//   - index: Position of the recipe in the book
+//
+// Returns:
+//   - The chapter
+func epubRecipe(doc *Document, index int) []byte {
+	recipe := doc.Recipe
+	var b strings.Builder
+	b.WriteString("<h1>" + xhtml(recipe.Name) + "</h1>\n")
+	if summary := recipeSummary(recipe); summary != "" {
+		b.WriteString("<p class=\"summary\">" + xhtml(summary) + "</p>\n")
+	}
+	for _, paragraph := range strings.Split(strings.TrimSpace(recipe.Description), "\n\n") {
+		if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+			b.WriteString("<p>" + xhtml(paragraph) + "</p>\n")
+		}
+	}
+	if doc.Photo != nil {
+		fmt.Fprintf(&b, "<div class=\"photo\"><img src=\"%s\" alt=\"%s\"/></div>\n", epubPhoto(index), xhtml(recipe.Name))
+	}
+
+	if len(recipe.IngredientLines) > 0 {
+		b.WriteString("<h2>Ingredients</h2>\n<ul class=\"ingredients\">\n")
+		for i, line := range recipe.IngredientLines {
+			amount := ""
+			if line.Quantity != nil {
+				amount = FormatQuantity(*line.Quantity)
+			}
+			if line.Unit != nil && *line.Unit != "" {
+				amount = strings.TrimSpace(amount + " " + *line.Unit)
+			}
+			if amount != "" {
+				amount = "<span class=\"amount\">" + xhtml(amount) + "</span> "
+			}
+			fmt.Fprintf(&b, "  <li id=\"ingredient-%d\">%s%s</li>\n", i+1, amount, xhtml(line.Ingredient.Name+lineDetails(line)))
+		}
+		b.WriteString("</ul>\n")
+	}
+
+	if len(doc.Steps) > 0 {
+		b.WriteString("<h2>Steps</h2>\n<ol class=\"steps\">\n")
+		for _, step := range doc.Steps {
+			text := xhtml(strings.TrimSpace(step.Text))
+			if step.Duration != nil {
+				text += " <em>(" + readableDuration(*step.Duration) + ")</em>"
+			}
+			b.WriteString("  <li>" + text + "</li>\n")
+		}
+		b.WriteString("</ol>\n")
+	}
+
+	if doc.Nutrition != nil && doc.Nutrition.Nutrients != nil {
+		facts := doc.Nutrition
+		caption := "Nutrition (whole recipe)"
+		if recipe.Servings != nil && *recipe.Servings > 0 {
+			facts = nutrition.PerServing(doc.Nutrition, *recipe.Servings)
+			caption = "Nutrition per serving"
+		}
+		n := facts.Nutrients
+		b.WriteString("<table class=\"nutrition\">\n  <caption>" + caption + "</caption>\n")
+		for _, row := range []struct {
+			label string
+			value string
+		}{
+			{"Calories", strconv.FormatFloat(n.Calories, 'f', 0, 64)},
+			{"Protein", nutrientAmount(n.ProteinG, "g")},
+			{"Fat", nutrientAmount(n.FatG, "g")},
+			{"Saturated fat", nutrientAmount(n.SaturatedFatG, "g")},
+			{"Carbohydrates", nutrientAmount(n.CarbohydratesG, "g")},
+			{"Sugar", nutrientAmount(n.SugarG, "g")},
+			{"Fiber", nutrientAmount(n.FiberG, "g")},
+			{"Sodium", nutrientAmount(n.SodiumMg, "mg")},
+		} {
+			fmt.Fprintf(&b, "  <tr><th>%s</th><td>%s</td></tr>\n", row.label, row.value)
+		}
+		b.WriteString("</table>\n")
+		if missing := len(doc.Nutrition.MissingNutrition) + len(doc.Nutrition.UnconvertedLines); missing > 0 {
+			fmt.Fprintf(&b, "<p class=\"note\">Estimate; leaves out %d ingredient line(s) without nutrition data or a convertible amount.</p>\n", missing)
+		}
+	}
+
+	if recipe.SourceURL != nil {
+		fmt.Fprintf(&b, "<p class=\"note\">Source: <a href=\"%s\">%s</a></p>\n", xhtml(*recipe.SourceURL), xhtml(*recipe.SourceURL))
+	}
+	return epubPage(recipe.Name, b.String())
+}
+
+// Write the index of ingredients, grouped by first letter, each linking to the lines
+// of the recipes that use it.
+//
+// Parameters:
+//   - docs: Recipes in the book
+//
+// Returns:
+//   - The index page
+func epubIndex(docs []*Document) []byte {
+	type use struct {
+		recipe string
+		href   string
+	}
+	uses := map[string][]use{}
+	names := map[string]string{}
+	for i, doc := range docs {
+		for j, line := range doc.Recipe.IngredientLines {
+			name := strings.TrimSpace(line.Ingredient.Name)
+			if name == "" {
+				continue
+			}
+			key := strings.ToLower(name)
+			if _, ok := names[key]; !ok {
+				names[key] = name
+			}
+			uses[key] = append(uses[key], use{doc.Recipe.Name, fmt.Sprintf("%s#ingredient-%d", epubChapter(i), j+1)})
+		}
+	}
+	keys := make([]string, 0, len(names))
+	for key := range names {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("<h1>Ingredient Index</h1>\n<dl class=\"index\">\n")
+	letter := ""
+	for _, key := range keys {
+		first := strings.ToUpper(string([]rune(key)[0]))
+		if !unicode.IsLetter([]rune(first)[0]) {
+			first = "#"
+		}
+		if first != letter {
+			letter = first
+			b.WriteString("  <dt>" + xhtml(letter) + "</dt>\n")
+		}
+		links := []string{}
+		for _, u := range uses[key] {
+			links = append(links, fmt.Sprintf("<a href=\"%s\">%s</a>", u.href, xhtml(u.recipe)))
+		}
+		b.WriteString("  <dd>" + xhtml(names[key]) + ": " + strings.Join(links, ", ") + "</dd>\n")
+	}
+	b.WriteString("</dl>\n")
+	return epubPage("Ingredient Index", b.String())
+}
@@ -0,0 +1,99 @@
+// Write recipes out in formats other tools understand.
+package exporter
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Everything known about a recipe that exporters may write out.
+type Document struct {
+	Recipe *model.Recipe
+	Steps  []*model.RecipeStep
+	// Nutrition of the whole recipe, nil if it is not to be included
+	Nutrition *model.RecipeNutrition
+	// Absolute URL of the server, used to make links absolute; may be empty
+	BaseURL string
+	// Recipe image as JPEG, for formats that embed it rather than link to it; nil if not loaded
+	Photo []byte
+}
+
+// Make a server path absolute using the document's base URL.
+//
+// Parameters:
+//   - path: Path on this server, e.g. "/images/ab12"
+//
+// Returns:
+//   - Absolute URL, or the path unchanged when there is no base URL
+func (d *Document) URL(path string) string {
+	return strings.TrimSuffix(d.BaseURL, "/") + path
+}
+
+// Fractions written out in place of decimals.
+var fractions = []struct {
+	value float64
+	text  string
+}{
+	{1.0 / 8, "1/8"}, {1.0 / 4, "1/4"}, {1.0 / 3, "1/3"}, {3.0 / 8, "3/8"}, {1.0 / 2, "1/2"},
+	{5.0 / 8, "5/8"}, {2.0 / 3, "2/3"}, {3.0 / 4, "3/4"}, {7.0 / 8, "7/8"},
+}
+
+// Write a quantity the way a recipe would, e.g. 1.5 as "1 1/2".
+//
+// Parameters:
+//   - quantity: Amount to write
+//
+// Returns:
+//   - Quantity as text
+func FormatQuantity(quantity float64) string {
+	whole, fraction := math.Modf(quantity)
+	for _, f := range fractions {
+		if math.Abs(fraction-f.value) < 0.01 {
+			if whole == 0 {
+				return f.text
+			}
+			return strconv.FormatFloat(whole, 'f', -1, 64) + " " + f.text
+		}
+	}
+	return strconv.FormatFloat(math.Round(quantity*100)/100, 'f', -1, 64)
+}
+
+// Write an ingredient line as text, e.g. "1 1/2 cup onions, chopped (about 2)".
+//
+// Parameters:
+//   - line: Ingredient line
+//
+// Returns:
+//   - Line as text
+func FormatLine(line *model.RecipeIngredient) string {
+	parts := []string{}
+	if line.Quantity != nil {
+		parts = append(parts, FormatQuantity(*line.Quantity))
+	}
+	if line.Unit != nil && *line.Unit != "" {
+		parts = append(parts, *line.Unit)
+	}
+	parts = append(parts, line.Ingredient.Name)
+	return strings.Join(parts, " ") + lineDetails(line)
+}
+
+// Write the preparation and notes that follow an ingredient's name, e.g. ", chopped (about 2)".
+//
+// Parameters:
+//   - line: Ingredient line
+//
+// Returns:
+//   - Preparation and notes as text, empty if there are none
+func lineDetails(line *model.RecipeIngredient) string {
+	details := ""
+	if line.Preparation != nil && *line.Preparation != "" {
+		details += ", " + *line.Preparation
+	}
+	if line.Notes != nil && *line.Notes != "" {
+		details += " (" + *line.Notes + ")"
+	}
+	return details
+}
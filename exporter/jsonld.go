@@ -0,0 +1,155 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/nutrition"
+)
+
+type jsonLDPerson struct {
+	Type string `json:"@type"`
+	Name string `json:"name"`
+}
+
+type jsonLDStep struct {
+	Type         string `json:"@type"`
+	Position     int    `json:"position"`
+	Text         string `json:"text"`
+	TimeRequired string `json:"timeRequired,omitempty"`
+	Image        string `json:"image,omitempty"`
+}
+
+type jsonLDNutrition struct {
+	Type                string `json:"@type"`
+	ServingSize         string `json:"servingSize,omitempty"`
+	Calories            string `json:"calories"`
+	ProteinContent      string `json:"proteinContent"`
+	FatContent          string `json:"fatContent"`
+	SaturatedFatContent string `json:"saturatedFatContent"`
+	CarbohydrateContent string `json:"carbohydrateContent"`
+	SugarContent        string `json:"sugarContent"`
+	FiberContent        string `json:"fiberContent"`
+	SodiumContent       string `json:"sodiumContent"`
+	CholesterolContent  string `json:"cholesterolContent"`
+}
+
+type jsonLDRecipe struct {
+	Context            string           `json:"@context"`
+	Type               string           `json:"@type"`
+	ID                 string           `json:"@id,omitempty"`
+	Name               string           `json:"name"`
+	Description        string           `json:"description,omitempty"`
+	Author             *jsonLDPerson    `json:"author,omitempty"`
+	Image              []string         `json:"image,omitempty"`
+	RecipeYield        string           `json:"recipeYield,omitempty"`
+	PrepTime           string           `json:"prepTime,omitempty"`
+	CookTime           string           `json:"cookTime,omitempty"`
+	TotalTime          string           `json:"totalTime,omitempty"`
+	RecipeIngredient   []string         `json:"recipeIngredient"`
+	RecipeInstructions []jsonLDStep     `json:"recipeInstructions"`
+	SuitableForDiet    []string         `json:"suitableForDiet,omitempty"`
+	Nutrition          *jsonLDNutrition `json:"nutrition,omitempty"`
+	IsBasedOn          string           `json:"isBasedOn,omitempty"`
+}
+
+// schema.org RestrictedDiet values for the diets that have one; there is none for dairy free.
+var schemaDiets = map[model.Diet]string{
+	model.DietVegan:      "https://schema.org/VeganDiet",
+	model.DietVegetarian: "https://schema.org/VegetarianDiet",
+	model.DietGlutenFree: "https://schema.org/GlutenFreeDiet",
+	model.DietHalal:      "https://schema.org/HalalDiet",
+	model.DietKosher:     "https://schema.org/KosherDiet",
+}
+
+// Write a recipe as a schema.org Recipe in JSON-LD.
+// Nutrition is given per serving when the recipe says how many it makes.
+//
+// Parameters:
+//   - doc: Recipe to write
+//
+// Returns:
+//   - JSON-LD document
+func JSONLD(doc *Document) ([]byte, error) {
+	recipe := doc.Recipe
+	out := jsonLDRecipe{
+		Context:            "https://schema.org",
+		Type:               "Recipe",
+		Name:               recipe.Name,
+		Description:        recipe.Description,
+		RecipeIngredient:   []string{},
+		RecipeInstructions: []jsonLDStep{},
+	}
+	if doc.BaseURL != "" {
+		out.ID = doc.URL("/recipes/" + recipe.RecipeID + ".jsonld")
+	}
+	if recipe.User != nil {
+		out.Author = &jsonLDPerson{Type: "Person", Name: recipe.User.Name}
+	}
+	if recipe.Image != nil {
+		out.Image = []string{doc.URL(recipe.Image.URL)}
+	}
+	if recipe.Servings != nil {
+		out.RecipeYield = fmt.Sprintf("%d servings", *recipe.Servings)
+	}
+	if recipe.PrepTime != nil {
+		out.PrepTime = model.FormatDuration(*recipe.PrepTime)
+	}
+	if recipe.CookTime != nil {
+		out.CookTime = model.FormatDuration(*recipe.CookTime)
+	}
+	if recipe.TotalTime != nil {
+		out.TotalTime = model.FormatDuration(*recipe.TotalTime)
+	}
+	if recipe.SourceURL != nil {
+		out.IsBasedOn = *recipe.SourceURL
+	}
+	for _, line := range recipe.IngredientLines {
+		out.RecipeIngredient = append(out.RecipeIngredient, FormatLine(line))
+	}
+	for _, step := range doc.Steps {
+		item := jsonLDStep{Type: "HowToStep", Position: step.Position, Text: step.Text}
+		if step.Duration != nil {
+			item.TimeRequired = model.FormatDuration(*step.Duration)
+		}
+		if step.Image != nil {
+			item.Image = doc.URL(step.Image.URL)
+		}
+		out.RecipeInstructions = append(out.RecipeInstructions, item)
+	}
+	for _, diet := range recipe.DietaryLabels {
+		if value := schemaDiets[diet]; value != "" {
+			out.SuitableForDiet = append(out.SuitableForDiet, value)
+		}
+	}
+	if doc.Nutrition != nil {
+		out.Nutrition = jsonLDNutritionFor(doc.Nutrition, recipe.Servings)
+	}
+
+	return json.MarshalIndent(out, "", "  ")
+}
+
+// Describe a recipe's nutrition as schema.org NutritionInformation.
+func jsonLDNutritionFor(total *model.RecipeNutrition, servings *int) *jsonLDNutrition {
+	facts := total
+	servingSize := ""
+	if servings != nil && *servings > 0 {
+		facts = nutrition.PerServing(total, *servings)
+		servingSize = "1 serving"
+	}
+	n := facts.Nutrients
+	return &jsonLDNutrition{
+		Type:                "NutritionInformation",
+		ServingSize:         servingSize,
+		Calories:            fmt.Sprintf("%g calories", n.Calories),
+		ProteinContent:      fmt.Sprintf("%g g", n.ProteinG),
+		FatContent:          fmt.Sprintf("%g g", n.FatG),
+		SaturatedFatContent: fmt.Sprintf("%g g", n.SaturatedFatG),
+		CarbohydrateContent: fmt.Sprintf("%g g", n.CarbohydratesG),
+		SugarContent:        fmt.Sprintf("%g g", n.SugarG),
+		FiberContent:        fmt.Sprintf("%g g", n.FiberG),
+		SodiumContent:       fmt.Sprintf("%g mg", n.SodiumMg),
+		CholesterolContent:  fmt.Sprintf("%g mg", n.CholesterolMg),
+	}
+}
@@ -0,0 +1,221 @@
+package exporter
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Lines within a step that importer.FromMarkdown would read as a new step or an image.
+var stepLineMarker = regexp.MustCompile(`^(?:\d+[.)]\s|[-*+]\s|!\[|\d*\\[[:punct:]])`)
+
+// Write a recipe as Markdown with YAML front matter, in the format read by importer.FromMarkdown:
+//
+//	---
+//	title: Buttermilk Pancakes
+//	servings: 4
+//	prep_time: PT10M
+//	cook_time: PT20M
+//	---
+//
+//	# Buttermilk Pancakes
+//
+//	Description lines.
+//
+//	## Ingredients
+//
+//	- 2 cup flour
+//	- 1 [handful] blueberries
+//
+//	## Steps
+//
+//	1. Whisk everything together.
+//	2. Cook on a hot griddle. *(PT5M)*
+//
+// Units Ambrosia does not recognize are written in brackets, and names that would otherwise be
+// split on import in braces, e.g. "1 {whole chicken}", so they read back unchanged.
+//
+// Parameters:
+//   - doc: Recipe to write
+//
+// Returns:
+//   - Markdown document
+func Markdown(doc *Document) ([]byte, error) {
+	recipe := doc.Recipe
+	var b strings.Builder
+
+	b.WriteString("---\n")
+	writeFrontMatter(&b, "title", yamlScalar(recipe.Name))
+	if recipe.Servings != nil {
+		writeFrontMatter(&b, "servings", strconv.Itoa(*recipe.Servings))
+	}
+	for _, field := range []struct {
+		key      string
+		duration *time.Duration
+	}{
+		{"prep_time", recipe.PrepTime},
+		{"cook_time", recipe.CookTime},
+		{"rest_time", recipe.RestTime},
+	} {
+		if field.duration != nil {
+			writeFrontMatter(&b, field.key, model.FormatDuration(*field.duration))
+		}
+	}
+	if recipe.SourceURL != nil {
+		writeFrontMatter(&b, "source", yamlScalar(*recipe.SourceURL))
+	}
+	if recipe.Image != nil {
+		writeFrontMatter(&b, "image", yamlScalar(doc.URL(recipe.Image.URL)))
+	}
+	b.WriteString("---\n\n")
+
+	b.WriteString("# " + recipe.Name + "\n\n")
+	if description := strings.TrimSpace(recipe.Description); description != "" {
+		b.WriteString(description + "\n\n")
+	}
+
+	b.WriteString("## Ingredients\n\n")
+	for _, line := range recipe.IngredientLines {
+		b.WriteString("- " + markdownLine(line) + "\n")
+	}
+
+	b.WriteString("\n## Steps\n\n")
+	for i, step := range doc.Steps {
+		marker := fmt.Sprintf("%d. ", i+1)
+		indent := strings.Repeat(" ", len(marker))
+		lines := strings.Split(strings.TrimSpace(step.Text), "\n")
+		for j := 1; j < len(lines); j++ {
+			if strings.TrimSpace(lines[j]) != "" {
+				lines[j] = indent + escapeStepLine(lines[j])
+			}
+		}
+		text := strings.Join(lines, "\n")
+		if step.Duration != nil {
+			text += " *(" + model.FormatDuration(*step.Duration) + ")*"
+		}
+		b.WriteString(marker + text + "\n")
+		if step.Image != nil {
+			b.WriteString(indent + fmt.Sprintf("![Step %d](%s)", i+1, doc.URL(step.Image.URL)) + "\n")
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// Write a front matter entry.
+func writeFrontMatter(b *strings.Builder, key string, value string) {
+	b.WriteString(key + ": " + value + "\n")
+}
+
+// Write text as a YAML scalar, quoting it when it would otherwise be read as something else.
+//
+// Parameters:
+//   - text: Text to write
+//
+// Returns:
+//   - The text, double quoted if needed
+func yamlScalar(text string) string {
+	plain := text != "" &&
+		text == strings.TrimSpace(text) &&
+		!strings.ContainsAny(text[:1], "-?:,[]{}#&*!|>'\"%@`") &&
+		!strings.Contains(text, ": ") &&
+		!strings.Contains(text, " #") &&
+		!strings.ContainsAny(text, "\n\t")
+	if plain {
+		switch strings.ToLower(text) {
+		case "true", "false", "yes", "no", "on", "off", "null", "~":
+			plain = false
+		}
+	}
+	if _, err := strconv.ParseFloat(text, 64); err == nil {
+		plain = false
+	}
+	if plain {
+		return text
+	}
+	return strconv.Quote(text)
+}
+
+// Write an ingredient line for the Markdown format. Quantities are written as fractions
+// only when that reads back as the same amount, and units are bracketed unless they
+// read back as the same unit. When the name, preparation or notes would be split
+// differently on import, e.g. "whole chicken" or "salt, kosher", they are braced.
+//
+// Parameters:
+//   - line: Ingredient line
+//
+// Returns:
+//   - Line as text, e.g. "1 1/2 cup flour, sifted"
+func markdownLine(line *model.RecipeIngredient) string {
+	parts := []string{}
+	if line.Quantity != nil {
+		quantity := FormatQuantity(*line.Quantity)
+		if parsed, ok := ingredientline.ParseQuantity(quantity); !ok || math.Abs(parsed-*line.Quantity) > 1e-6 {
+			quantity = strconv.FormatFloat(*line.Quantity, 'f', -1, 64)
+		}
+		parts = append(parts, quantity)
+	}
+	unit := ""
+	if line.Unit != nil && *line.Unit != "" {
+		unit = *line.Unit
+		if known, ok := units.Lookup(unit); !ok || known.Name != unit || line.Quantity == nil {
+			parts = append(parts, "["+unit+"]")
+		} else {
+			parts = append(parts, unit)
+		}
+	}
+	name := line.Ingredient.Name
+	preparation, notes := "", ""
+	if line.Preparation != nil {
+		preparation = *line.Preparation
+	}
+	if line.Notes != nil {
+		notes = *line.Notes
+	}
+
+	plain := strings.Join(append(parts, name), " ") + lineDetails(line)
+	parsed := ingredientline.ParseMarked(plain)
+	parsedUnit := ""
+	if parsed.Unit != nil {
+		parsedUnit = *parsed.Unit
+	}
+	if parsed.Name == name && parsed.Preparation == preparation && parsed.Notes == notes && parsedUnit == unit &&
+		(parsed.Quantity == nil) == (line.Quantity == nil) && parsed.MaxQuantity == nil ||
+		strings.ContainsAny(name+preparation+notes, "{}") {
+		return plain
+	}
+
+	braced := strings.Join(append(parts, "{"+name+"}"), " ")
+	if preparation != "" {
+		braced += ", {" + preparation + "}"
+	}
+	if notes != "" {
+		braced += " ({" + notes + "})"
+	}
+	return braced
+}
+
+// Escape a continuation line of a step that would otherwise be read as the start of another
+// step or as a step image, e.g. "2. Stir" becomes "2\. Stir". Lines already starting with an
+// escape are escaped again so they read back unchanged.
+//
+// Parameters:
+//   - line: Line of a step's text after its first
+//
+// Returns:
+//   - Line as Markdown
+func escapeStepLine(line string) string {
+	trimmed := strings.TrimSpace(line)
+	if !stepLineMarker.MatchString(trimmed) {
+		return line
+	}
+	digits := len(trimmed) - len(strings.TrimLeft(trimmed, "0123456789"))
+	return trimmed[:digits] + "\\" + trimmed[digits:]
+}
@@ -0,0 +1,102 @@
+package exporter_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/importer"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// Ingredient lines that must read back exactly as they were written.
+var roundTripLines = []*model.RecipeIngredient{
+	{Ingredient: &model.Ingredient{Name: "flour"}, Quantity: ptr(1.5), Unit: ptr("cup"), Preparation: ptr("sifted")},
+	{Ingredient: &model.Ingredient{Name: "crushed tomatoes"}, Quantity: ptr(400.0), Unit: ptr("g")},
+	{Ingredient: &model.Ingredient{Name: "whole chicken"}, Quantity: ptr(1.0)},
+	{Ingredient: &model.Ingredient{Name: "salt, kosher"}, Notes: ptr("to taste")},
+	{Ingredient: &model.Ingredient{Name: "blueberries"}, Quantity: ptr(1.0), Unit: ptr("handful")},
+	{Ingredient: &model.Ingredient{Name: "cups"}, Quantity: ptr(2.0)},
+	{Ingredient: &model.Ingredient{Name: "7 up"}},
+	{Ingredient: &model.Ingredient{Name: "butter (salted)"}, Quantity: ptr(0.3), Unit: ptr("cups"), Preparation: ptr("melted, cooled"), Notes: ptr("about 2 (or more)")},
+	{Ingredient: &model.Ingredient{Name: "egg"}, Quantity: ptr(2.0), Preparation: ptr("beaten")},
+}
+
+// Steps that must read back exactly as they were written.
+var roundTripSteps = []*model.RecipeStep{
+	{Text: "Whisk everything together."},
+	{Text: "Cook on a hot griddle.", Duration: ptr(5 * time.Minute)},
+	{Text: "Make the sauce:\n1. Melt the butter.\n2. Whisk in the flour."},
+	{Text: "Serve with\n- syrup\n* jam\n+ cream\n![not an image](here)\n\\ a backslash\n3\\. an escape"},
+	{Text: "Rest.\n\nThen slice."},
+}
+
+func TestMarkdownRoundTrip(t *testing.T) {
+	doc := &exporter.Document{
+		Recipe: &model.Recipe{
+			Name:            "Round Trip",
+			Description:     "Everything should come back.",
+			Servings:        ptr(4),
+			PrepTime:        ptr(10 * time.Minute),
+			IngredientLines: roundTripLines,
+		},
+		Steps: roundTripSteps,
+	}
+	text, err := exporter.Markdown(doc)
+	if err != nil {
+		t.Fatalf("Markdown() error: %v", err)
+	}
+	recipe, err := importer.FromMarkdown(string(text))
+	if err != nil {
+		t.Fatalf("FromMarkdown() error: %v", err)
+	}
+
+	if recipe.Name != doc.Recipe.Name || recipe.Description != doc.Recipe.Description {
+		t.Errorf("read back %q, %q; want %q, %q", recipe.Name, recipe.Description, doc.Recipe.Name, doc.Recipe.Description)
+	}
+	if len(recipe.Ingredients) != len(roundTripLines) {
+		t.Fatalf("read back %d ingredients; want %d\n%s", len(recipe.Ingredients), len(roundTripLines), text)
+	}
+	for i, want := range roundTripLines {
+		got := recipe.Ingredients[i]
+		if got.Name != want.Ingredient.Name ||
+			!sameQuantity(got.Quantity, want.Quantity) ||
+			deref(got.Unit) != deref(want.Unit) ||
+			got.Preparation != deref(want.Preparation) ||
+			got.Notes != deref(want.Notes) {
+			t.Errorf("ingredient %d read back as %q %q %q %q %v from %q", i, got.Name, deref(got.Unit), got.Preparation, got.Notes, got.Quantity, got.Text)
+		}
+	}
+	if len(recipe.Steps) != len(roundTripSteps) {
+		t.Fatalf("read back %d steps; want %d\n%s", len(recipe.Steps), len(roundTripSteps), text)
+	}
+	for i, want := range roundTripSteps {
+		got := recipe.Steps[i]
+		if got.Text != want.Text || (got.Duration == nil) != (want.Duration == nil) || got.Duration != nil && *got.Duration != *want.Duration {
+			t.Errorf("step %d read back as %q, %v; want %q, %v", i, got.Text, got.Duration, want.Text, want.Duration)
+		}
+	}
+	if !strings.Contains(string(text), "- 1 {whole chicken}\n") {
+		t.Errorf("expected the chicken's name to be braced:\n%s", text)
+	}
+}
+
+func sameQuantity(a *float64, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return math.Abs(*a-*b) < 1e-6
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
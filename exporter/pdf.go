@@ -0,0 +1,391 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pdf/fpdf"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/nutrition"
+)
+
+// Page layout of printed recipes, in millimetres.
+const (
+	pdfMargin = 18.0
+	// Space kept clear at the foot of each page for the footer
+	pdfFooter      = 8.0
+	pdfLineHeight  = 5.5
+	pdfPhotoHeight = 75.0
+	// Width of the amount column of the ingredient table
+	pdfAmountWidth = 38.0
+	// Indent of step text past its number
+	pdfStepIndent = 9.0
+)
+
+// Write a recipe as a printable PDF: its title and details, photo, ingredient table,
+// numbered steps and a nutrition box. The photo is included when the document has one loaded.
+//
+// Parameters:
+//   - doc: Recipe to write
+//
+// Returns:
+//   - PDF document
+func PDF(doc *Document) ([]byte, error) {
+	return Cookbook(doc.Recipe.Name, []*Document{doc})
+}
+
+// Write several recipes as one printable PDF, each starting on a new page laid out as by PDF.
+// Books of more than one recipe open with a contents page linking to each recipe.
+//
+// Parameters:
+//   - title: Title of the book
+//   - docs: Recipes to write, in order
+//
+// Returns:
+//   - PDF document
+func Cookbook(title string, docs []*Document) ([]byte, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("cookbook has no recipes")
+	}
+
+	// The contents page lists the page each recipe starts on, so books are laid out once to find them
+	book, starts := layoutCookbook(title, docs, nil)
+	if len(docs) > 1 {
+		book, _ = layoutCookbook(title, docs, starts)
+	}
+
+	var out bytes.Buffer
+	if err := book.Output(&out); err != nil {
+		return nil, fmt.Errorf("failed to write PDF; error: %v", err)
+	}
+	return out.Bytes(), nil
+}
+
+// A PDF being laid out, with the translation of text into the encoding of its fonts.
+type pdfBook struct {
+	*fpdf.Fpdf
+	text func(string) string
+}
+
+// Lay out a book of recipes.
+//
+// Parameters:
+//   - title: Title of the book
+//   - docs: Recipes to write
+//   - starts: Page each recipe starts on, to list on the contents page; nil if not yet known
+//
+// Returns:
+//   - The laid out book, and the page each recipe started on
+func layoutCookbook(title string, docs []*Document, starts []int) (*pdfBook, []int) {
+	pdf := fpdf.New("P", "mm", "Letter", "")
+	pdf.SetMargins(pdfMargin, pdfMargin, pdfMargin)
+	pdf.SetAutoPageBreak(true, pdfMargin+pdfFooter)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("Ambrosia", true)
+	// The core fonts only cover Windows-1252, which most recipes fit in
+	book := &pdfBook{pdf, pdf.UnicodeTranslatorFromDescriptor("")}
+
+	footer := ""
+	pdf.SetFooterFunc(func() {
+		width := book.contentWidth()
+		pdf.SetY(-pdfMargin)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.SetTextColor(120, 120, 120)
+		pdf.CellFormat(width, 4, strconv.Itoa(pdf.PageNo()), "", 0, "R", false, 0, "")
+		pdf.SetX(pdfMargin)
+		pdf.CellFormat(width-15, 4, book.fit(footer, width-15), "", 0, "L", false, 0, "")
+	})
+
+	links := make([]int, len(docs))
+	if len(docs) > 1 {
+		pdf.AddPage()
+		pdf.SetFont("Helvetica", "B", 24)
+		pdf.MultiCell(0, 11, book.text(title), "", "L", false)
+		pdf.Ln(6)
+		pdf.SetFont("Helvetica", "", 12)
+		for i, doc := range docs {
+			links[i] = pdf.AddLink()
+			page := ""
+			if starts != nil {
+				page = strconv.Itoa(starts[i])
+			}
+			width := book.contentWidth() - 15
+			pdf.CellFormat(width, 8, book.fit(doc.Recipe.Name, width), "", 0, "L", false, links[i], "")
+			pdf.CellFormat(15, 8, page, "", 1, "R", false, links[i], "")
+		}
+	}
+
+	found := make([]int, len(docs))
+	for i, doc := range docs {
+		pdf.AddPage()
+		found[i] = pdf.PageNo()
+		// Footers are drawn as the next page is added, so this labels the recipe's own pages
+		footer = doc.Recipe.Name
+		if doc.Recipe.SourceURL != nil {
+			footer += "  ·  " + *doc.Recipe.SourceURL
+		}
+		if len(docs) > 1 {
+			pdf.SetLink(links[i], 0, -1)
+			pdf.Bookmark(book.text(doc.Recipe.Name), 0, 0)
+		}
+		book.writeRecipe(doc, i)
+	}
+	return book, found
+}
+
+// Lay out a recipe from the top of the current page.
+//
+// Parameters:
+//   - doc: Recipe to write
+//   - index: Position of the recipe in the book, naming its photo
+func (book *pdfBook) writeRecipe(doc *Document, index int) {
+	recipe := doc.Recipe
+	width := book.contentWidth()
+
+	book.SetTextColor(0, 0, 0)
+	book.SetFont("Helvetica", "B", 22)
+	book.MultiCell(0, 9, book.text(recipe.Name), "", "L", false)
+	if summary := recipeSummary(recipe); summary != "" {
+		book.SetFont("Helvetica", "", 10)
+		book.SetTextColor(90, 90, 90)
+		book.MultiCell(0, 5, book.text(summary), "", "L", false)
+		book.SetTextColor(0, 0, 0)
+	}
+	book.Ln(3)
+	if description := strings.TrimSpace(recipe.Description); description != "" {
+		book.SetFont("Helvetica", "", 11)
+		book.MultiCell(0, pdfLineHeight, book.text(description), "", "L", false)
+		book.Ln(3)
+	}
+
+	if doc.Photo != nil {
+		name := "photo-" + strconv.Itoa(index)
+		options := fpdf.ImageOptions{ImageType: "JPG"}
+		info := book.RegisterImageOptionsReader(name, options, bytes.NewReader(doc.Photo))
+		if info != nil && info.Width() > 0 && info.Height() > 0 {
+			h := min(pdfPhotoHeight, width*info.Height()/info.Width())
+			w := h * info.Width() / info.Height()
+			book.ensureSpace(h + 4)
+			y := book.GetY()
+			book.ImageOptions(name, pdfMargin+(width-w)/2, y, w, h, false, options, 0, "")
+			book.SetY(y + h + 4)
+		}
+	}
+
+	if len(recipe.IngredientLines) > 0 {
+		book.heading("Ingredients")
+		for _, line := range recipe.IngredientLines {
+			amount := ""
+			if line.Quantity != nil {
+				amount = FormatQuantity(*line.Quantity)
+			}
+			if line.Unit != nil && *line.Unit != "" {
+				amount = strings.TrimSpace(amount + " " + *line.Unit)
+			}
+			name := book.text(line.Ingredient.Name + lineDetails(line))
+
+			book.SetFont("Helvetica", "", 11)
+			rows := book.SplitText(name, width-pdfAmountWidth)
+			h := float64(max(1, len(rows)))*pdfLineHeight + 1.5
+			book.ensureSpace(h)
+			y := book.GetY()
+			book.SetFont("Helvetica", "B", 11)
+			book.CellFormat(pdfAmountWidth, pdfLineHeight, book.fit(amount, pdfAmountWidth), "", 0, "L", false, 0, "")
+			book.SetFont("Helvetica", "", 11)
+			book.SetXY(pdfMargin+pdfAmountWidth, y)
+			book.MultiCell(width-pdfAmountWidth, pdfLineHeight, name, "", "L", false)
+			book.SetDrawColor(220, 220, 220)
+			book.Line(pdfMargin, y+h-0.5, pdfMargin+width, y+h-0.5)
+			book.SetY(y + h)
+		}
+	}
+
+	if len(doc.Steps) > 0 {
+		book.heading("Steps")
+		for i, step := range doc.Steps {
+			text := strings.TrimSpace(step.Text)
+			if step.Duration != nil {
+				text += " (" + readableDuration(*step.Duration) + ")"
+			}
+			text = book.text(text)
+
+			book.SetFont("Helvetica", "", 11)
+			rows := len(book.SplitText(text, width-pdfStepIndent))
+			// Long steps may run onto the next page, but not from their last few lines
+			book.ensureSpace(float64(min(max(1, rows), 3)) * pdfLineHeight)
+			y := book.GetY()
+			book.SetFont("Helvetica", "B", 11)
+			book.CellFormat(pdfStepIndent, pdfLineHeight, strconv.Itoa(i+1)+".", "", 0, "L", false, 0, "")
+			book.SetFont("Helvetica", "", 11)
+			book.SetXY(pdfMargin+pdfStepIndent, y)
+			book.MultiCell(width-pdfStepIndent, pdfLineHeight, text, "", "L", false)
+			book.Ln(2)
+		}
+	}
+
+	if doc.Nutrition != nil && doc.Nutrition.Nutrients != nil {
+		book.writeNutrition(doc.Nutrition, recipe.Servings)
+	}
+}
+
+// Draw a box of a recipe's main nutrients, per serving when the recipe says how many it makes.
+//
+// Parameters:
+//   - total: Nutrition of the whole recipe
+//   - servings: Servings the recipe makes, if known
+func (book *pdfBook) writeNutrition(total *model.RecipeNutrition, servings *int) {
+	facts := total
+	title := "Nutrition (whole recipe)"
+	if servings != nil && *servings > 0 {
+		facts = nutrition.PerServing(total, *servings)
+		title = "Nutrition per serving"
+	}
+	n := facts.Nutrients
+	rows := []struct {
+		label string
+		value string
+	}{
+		{"Calories", strconv.FormatFloat(n.Calories, 'f', 0, 64)},
+		{"Protein", nutrientAmount(n.ProteinG, "g")},
+		{"Fat", nutrientAmount(n.FatG, "g")},
+		{"Saturated fat", nutrientAmount(n.SaturatedFatG, "g")},
+		{"Carbohydrates", nutrientAmount(n.CarbohydratesG, "g")},
+		{"Sugar", nutrientAmount(n.SugarG, "g")},
+		{"Fiber", nutrientAmount(n.FiberG, "g")},
+		{"Sodium", nutrientAmount(n.SodiumMg, "mg")},
+	}
+	note := ""
+	if missing := len(total.MissingNutrition) + len(total.UnconvertedLines); missing > 0 {
+		note = fmt.Sprintf("Estimate; leaves out %d ingredient line(s) without nutrition data or a convertible amount.", missing)
+	}
+
+	width := book.contentWidth()
+	height := 10 + float64(len(rows)+1)/2*pdfLineHeight + 3
+	if note != "" {
+		height += 5
+	}
+	book.Ln(4)
+	book.ensureSpace(height)
+	y := book.GetY()
+	book.SetDrawColor(0, 0, 0)
+	book.SetLineWidth(0.4)
+	book.Rect(pdfMargin, y, width, height, "D")
+	book.SetLineWidth(0.2)
+
+	book.SetXY(pdfMargin+3, y+2)
+	book.SetFont("Helvetica", "B", 12)
+	book.CellFormat(width-6, 6, title, "", 0, "L", false, 0, "")
+	column := (width - 6) / 2
+	for i, row := range rows {
+		book.SetXY(pdfMargin+3+float64(i%2)*column, y+10+float64(i/2)*pdfLineHeight)
+		book.SetFont("Helvetica", "", 10)
+		book.CellFormat(column-30, pdfLineHeight, row.label, "", 0, "L", false, 0, "")
+		book.SetFont("Helvetica", "B", 10)
+		book.CellFormat(24, pdfLineHeight, row.value, "", 0, "R", false, 0, "")
+	}
+	if note != "" {
+		book.SetXY(pdfMargin+3, y+height-7)
+		book.SetFont("Helvetica", "I", 8)
+		book.SetTextColor(90, 90, 90)
+		book.CellFormat(width-6, 4, book.fit(note, width-6), "", 0, "L", false, 0, "")
+		book.SetTextColor(0, 0, 0)
+	}
+	book.SetY(y + height)
+}
+
+// Start a section of a recipe, such as its ingredients.
+func (book *pdfBook) heading(title string) {
+	// Keep a heading with at least a couple of lines after it
+	book.ensureSpace(10 + 2*pdfLineHeight)
+	book.Ln(3)
+	book.SetFont("Helvetica", "B", 14)
+	book.SetTextColor(0, 0, 0)
+	book.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+	book.Ln(1)
+}
+
+// Start a new page unless there is room for the given height on this one.
+func (book *pdfBook) ensureSpace(height float64) {
+	_, pageHeight := book.GetPageSize()
+	if book.GetY()+height > pageHeight-pdfMargin-pdfFooter {
+		book.AddPage()
+	}
+}
+
+// Width of the page between its margins.
+func (book *pdfBook) contentWidth() float64 {
+	pageWidth, _ := book.GetPageSize()
+	return pageWidth - 2*pdfMargin
+}
+
+// Translate text for the current font, shortening it with an ellipsis to fit on one line.
+//
+// Parameters:
+//   - text: Text to fit
+//   - width: Room available
+//
+// Returns:
+//   - Text ready to write
+func (book *pdfBook) fit(text string, width float64) string {
+	text = book.text(text)
+	// Cells pad their text by a millimetre on each side
+	if book.GetStringWidth(text) <= width-2 {
+		return text
+	}
+	ellipsis := book.text("…")
+	runes := []rune(text)
+	for len(runes) > 0 && book.GetStringWidth(string(runes)+ellipsis) > width-2 {
+		runes = runes[:len(runes)-1]
+	}
+	return strings.TrimSpace(string(runes)) + ellipsis
+}
+
+// Describe a recipe's servings and times on one line, e.g. "Serves 4 · Prep 10 min · Total 40 min".
+func recipeSummary(recipe *model.Recipe) string {
+	parts := []string{}
+	if recipe.Servings != nil {
+		parts = append(parts, fmt.Sprintf("Serves %d", *recipe.Servings))
+	}
+	for _, field := range []struct {
+		label    string
+		duration *time.Duration
+	}{
+		{"Prep", recipe.PrepTime},
+		{"Cook", recipe.CookTime},
+		{"Rest", recipe.RestTime},
+		{"Total", recipe.TotalTime},
+	} {
+		if field.duration != nil {
+			parts = append(parts, field.label+" "+readableDuration(*field.duration))
+		}
+	}
+	return strings.Join(parts, "  ·  ")
+}
+
+// Write a duration for people to read, e.g. "1 hr 30 min".
+func readableDuration(d time.Duration) string {
+	if d < time.Minute {
+		return strconv.Itoa(int(d.Seconds())) + " sec"
+	}
+	d = d.Round(time.Minute)
+	hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	switch {
+	case hours == 0:
+		return strconv.Itoa(minutes) + " min"
+	case minutes == 0:
+		return strconv.Itoa(hours) + " hr"
+	default:
+		return fmt.Sprintf("%d hr %d min", hours, minutes)
+	}
+}
+
+// Write an amount of a nutrient, with a decimal place for small amounts, e.g. "2.5 g".
+func nutrientAmount(value float64, unit string) string {
+	if value < 10 {
+		return strconv.FormatFloat(value, 'f', 1, 64) + " " + unit
+	}
+	return strconv.FormatFloat(value, 'f', 0, 64) + " " + unit
+}
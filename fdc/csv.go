@@ -0,0 +1,131 @@
+package fdc
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Read a CSV file, calling fn with each record keyed by column name.
+//
+// Parameters:
+//   - path: CSV file with a header row
+//   - fn: Called with each record
+//
+// Returns:
+//   - Error if the file could not be read
+func readCSVFile(path string, fn func(record map[string]string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("could not read header of %s: %v", path, err)
+	}
+	header = append([]string(nil), header...)
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("could not read %s: %v", path, err)
+		}
+		record := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				record[column] = row[i]
+			}
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// Read every food from a directory of FoodData Central CSV files.
+// Expects food.csv, nutrient.csv and food_nutrient.csv; food_category.csv is optional.
+//
+// Parameters:
+//   - dir: Directory of extracted CSV files
+//   - fn: Called with each food that is read
+//
+// Returns:
+//   - Error if the files could not be read
+func ReadCSV(dir string, fn func(*model.Food) error) error {
+	// Nutrient IDs to their numbers and units
+	nutrientNumbers := map[string]string{}
+	nutrientUnits := map[string]string{}
+	err := readCSVFile(filepath.Join(dir, "nutrient.csv"), func(record map[string]string) error {
+		nutrientNumbers[record["id"]] = record["nutrient_nbr"]
+		nutrientUnits[record["id"]] = record["unit_name"]
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	categories := map[string]string{}
+	categoryPath := filepath.Join(dir, "food_category.csv")
+	if _, err := os.Stat(categoryPath); err == nil {
+		err = readCSVFile(categoryPath, func(record map[string]string) error {
+			categories[record["id"]] = record["description"]
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	foods := map[string]*foodNutrients{}
+	err = readCSVFile(filepath.Join(dir, "food.csv"), func(record map[string]string) error {
+		id := record["fdc_id"]
+		foods[id] = newFoodNutrients(id, record["description"], record["data_type"], categories[record["food_category_id"]])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = readCSVFile(filepath.Join(dir, "food_nutrient.csv"), func(record map[string]string) error {
+		food, ok := foods[record["fdc_id"]]
+		if !ok {
+			return nil
+		}
+		amount, err := strconv.ParseFloat(record["amount"], 64)
+		if err != nil {
+			return nil
+		}
+		id := record["nutrient_id"]
+		food.set(nutrientNumbers[id], nutrientUnits[id], amount)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Emit in a stable order so repeated imports behave the same
+	ids := make([]string, 0, len(foods))
+	for id := range foods {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if err := fn(foods[id].finish()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
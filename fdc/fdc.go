@@ -0,0 +1,133 @@
+// Read foods and their nutrients from USDA FoodData Central downloads.
+//
+// FoodData Central publishes its datasets (Foundation, SR Legacy, Survey, Branded)
+// at https://fdc.nal.usda.gov/download-datasets as both CSV and JSON. Either
+// format can be read: a directory of extracted CSV files, or a single JSON file.
+// All nutrient amounts in the downloads are per 100 g of food.
+package fdc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Map FoodData Central nutrient numbers to the nutrient they provide.
+// Energy is reported under several numbers depending on the dataset;
+// 208 is preferred when a food has more than one.
+var nutrientFields = map[string]func(n *model.Nutrition) *float64{
+	"208": func(n *model.Nutrition) *float64 { return &n.Calories },
+	"203": func(n *model.Nutrition) *float64 { return &n.ProteinG },
+	"204": func(n *model.Nutrition) *float64 { return &n.FatG },
+	"606": func(n *model.Nutrition) *float64 { return &n.SaturatedFatG },
+	"205": func(n *model.Nutrition) *float64 { return &n.CarbohydratesG },
+	"269": func(n *model.Nutrition) *float64 { return &n.SugarG },
+	"291": func(n *model.Nutrition) *float64 { return &n.FiberG },
+	"307": func(n *model.Nutrition) *float64 { return &n.SodiumMg },
+	"601": func(n *model.Nutrition) *float64 { return &n.CholesterolMg },
+	"306": func(n *model.Nutrition) *float64 { return &n.PotassiumMg },
+	"301": func(n *model.Nutrition) *float64 { return &n.CalciumMg },
+	"303": func(n *model.Nutrition) *float64 { return &n.IronMg },
+	"320": func(n *model.Nutrition) *float64 { return &n.VitaminAUg },
+	"401": func(n *model.Nutrition) *float64 { return &n.VitaminCMg },
+	"328": func(n *model.Nutrition) *float64 { return &n.VitaminDUg },
+}
+
+// Atwater energy numbers, used only when a food has no 208 energy value.
+var fallbackEnergyNumbers = map[string]bool{"957": true, "958": true}
+
+// Nutrients of a food as they are being collected.
+type foodNutrients struct {
+	food         *model.Food
+	haveEnergy   bool
+	fallbackKcal *float64
+}
+
+// Record a nutrient amount for a food.
+//
+// Parameters:
+//   - number: FoodData Central nutrient number, e.g. "208"
+//   - unit: Unit the amount is reported in
+//   - amount: Amount per 100 g
+func (f *foodNutrients) set(number string, unit string, amount float64) {
+	// Some releases write nutrient numbers as decimals, e.g. "208.0"
+	number = strings.TrimSuffix(strings.TrimSpace(number), ".0")
+	if fallbackEnergyNumbers[number] {
+		if strings.EqualFold(unit, "kcal") && f.fallbackKcal == nil {
+			f.fallbackKcal = &amount
+		}
+		return
+	}
+	field, ok := nutrientFields[number]
+	if !ok {
+		return
+	}
+	if number == "208" {
+		if unit != "" && !strings.EqualFold(unit, "kcal") {
+			return
+		}
+		f.haveEnergy = true
+	}
+	*field(f.food.Nutrition) = amount
+}
+
+// Finish collecting nutrients for a food.
+//
+// Returns:
+//   - Completed food
+func (f *foodNutrients) finish() *model.Food {
+	if !f.haveEnergy && f.fallbackKcal != nil {
+		f.food.Nutrition.Calories = *f.fallbackKcal
+	}
+	return f.food
+}
+
+// Create a food to collect nutrients for.
+//
+// Parameters:
+//   - fdcID: FoodData Central ID of the food
+//   - description: Name of the food
+//   - dataType: Dataset the food comes from
+//   - category: Food category, may be empty
+//
+// Returns:
+//   - Food with no nutrients yet
+func newFoodNutrients(fdcID string, description string, dataType string, category string) *foodNutrients {
+	food := &model.Food{
+		FdcID:       fdcID,
+		Description: description,
+		Nutrition:   &model.Nutrition{},
+	}
+	if dataType != "" {
+		food.DataType = &dataType
+	}
+	if category != "" {
+		food.Category = &category
+	}
+	return &foodNutrients{food: food}
+}
+
+// Read every food from a FoodData Central download.
+//
+// Parameters:
+//   - path: Directory of extracted CSV files, or a JSON file
+//   - fn: Called with each food that is read; returning an error stops reading
+//
+// Returns:
+//   - Error if the download could not be read
+func Read(path string, fn func(*model.Food) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("could not open FoodData Central download: %v", err)
+	}
+	if info.IsDir() {
+		return ReadCSV(path, fn)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return ReadJSON(path, fn)
+	}
+	return fmt.Errorf("expected a directory of CSV files or a .json file, got %s", path)
+}
@@ -0,0 +1,118 @@
+package fdc
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// A food as it appears in the FoodData Central JSON downloads.
+type jsonFood struct {
+	FdcID        int    `json:"fdcId"`
+	Description  string `json:"description"`
+	DataType     string `json:"dataType"`
+	FoodCategory *struct {
+		Description string `json:"description"`
+	} `json:"foodCategory"`
+	// Survey foods name their category differently
+	WweiaFoodCategory *struct {
+		Description string `json:"wweiaFoodCategoryDescription"`
+	} `json:"wweiaFoodCategory"`
+	// Branded foods give their category as a plain string
+	BrandedFoodCategory string `json:"brandedFoodCategory"`
+	FoodNutrients       []struct {
+		Amount   *float64 `json:"amount"`
+		Nutrient struct {
+			Number   string `json:"number"`
+			UnitName string `json:"unitName"`
+		} `json:"nutrient"`
+	} `json:"foodNutrients"`
+}
+
+// Convert a food from the JSON download into the defined model object.
+//
+// Returns:
+//   - Food encoded as the defined model object
+func (j *jsonFood) toFood() *model.Food {
+	category := j.BrandedFoodCategory
+	if j.FoodCategory != nil {
+		category = j.FoodCategory.Description
+	} else if j.WweiaFoodCategory != nil {
+		category = j.WweiaFoodCategory.Description
+	}
+
+	food := newFoodNutrients(strconv.Itoa(j.FdcID), j.Description, j.DataType, category)
+	for _, nutrient := range j.FoodNutrients {
+		if nutrient.Amount != nil {
+			food.set(nutrient.Nutrient.Number, nutrient.Nutrient.UnitName, *nutrient.Amount)
+		}
+	}
+	return food.finish()
+}
+
+// Read every food from a FoodData Central JSON download.
+// The downloads hold a single object with one array of foods, e.g.
+// {"FoundationFoods": [...]}, which is streamed rather than loaded whole.
+//
+// Parameters:
+//   - path: JSON file to read
+//   - fn: Called with each food that is read
+//
+// Returns:
+//   - Error if the file could not be read
+func ReadJSON(path string, fn func(*model.Food) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return err
+	}
+	for decoder.More() {
+		// Key naming the dataset, e.g. "SRLegacyFoods"
+		if _, err := decoder.Token(); err != nil {
+			return fmt.Errorf("could not read %s: %v", path, err)
+		}
+		if err := expectDelim(decoder, '['); err != nil {
+			return err
+		}
+		for decoder.More() {
+			var food jsonFood
+			if err := decoder.Decode(&food); err != nil {
+				return fmt.Errorf("could not read food from %s: %v", path, err)
+			}
+			if err := fn(food.toFood()); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(decoder, ']'); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read the next JSON token, failing unless it is the expected delimiter.
+//
+// Parameters:
+//   - decoder: JSON stream being read
+//   - delim: Expected delimiter
+//
+// Returns:
+//   - Error if the next token is anything else
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return fmt.Errorf("could not read FoodData Central JSON: %v", err)
+	}
+	if token != delim {
+		return fmt.Errorf("unexpected FoodData Central JSON layout: expected %q, got %v", delim, token)
+	}
+	return nil
+}
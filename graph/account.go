@@ -0,0 +1,272 @@
+package graph
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/images"
+)
+
+// Route a user's data export is downloaded from.
+const accountExportPath = "/account/export.zip"
+
+// A household the user belongs to, with their role in it.
+type accountHousehold struct {
+	Household *model.Household    `json:"household"`
+	Role      model.HouseholdRole `json:"role"`
+}
+
+// Everything a user created, in the shape of the GraphQL types.
+type accountData struct {
+	households    []*accountHousehold
+	recipes       []*exporter.Document
+	ingredients   []*model.Ingredient
+	mealPlan      []*model.MealPlanEntry
+	shoppingLists []*model.ShoppingList
+	pantry        []*model.PantryItem
+	prices        []*model.IngredientPrice
+	images        []*model.Image
+}
+
+// Gather everything a user created, whether personal or for a household.
+//
+// Parameters:
+//   - ctx: Request context
+//   - user: User to gather data for
+//
+// Returns:
+//   - The user's data
+func (r *Resolver) accountData(ctx context.Context, user *model.User) (*accountData, error) {
+	data := accountData{households: []*accountHousehold{}, recipes: []*exporter.Document{}}
+
+	households, err := db.GetHouseholdsForUser(r.DB_POOL, ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := db.GetHouseholdRoles(r.DB_POOL, ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, household := range households {
+		data.households = append(data.households, &accountHousehold{household, roles[household.HouseholdID]})
+	}
+
+	recipes, err := db.GetRecipes(r.DB_POOL, ctx, map[string]interface{}{"r.user_id": user.UserID})
+	if err != nil {
+		return nil, err
+	}
+	for _, recipe := range recipes {
+		doc, err := r.ExportDocument(ctx, recipe)
+		if err != nil {
+			return nil, err
+		}
+		recipe.Steps = doc.Steps
+		data.recipes = append(data.recipes, doc)
+	}
+
+	data.ingredients, err = db.GetIngredients(r.DB_POOL, ctx, map[string]interface{}{"i.user_id": user.UserID})
+	if err != nil {
+		return nil, err
+	}
+	ingredientIDs := []string{}
+	for _, ingredient := range data.ingredients {
+		ingredientIDs = append(ingredientIDs, ingredient.IngredientID)
+	}
+	facts, err := db.GetIngredientNutrition(r.DB_POOL, ctx, ingredientIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, ingredient := range data.ingredients {
+		ingredient.Nutrition = facts[ingredient.IngredientID]
+	}
+
+	if data.mealPlan, err = db.GetMealPlanEntriesByUser(r.DB_POOL, ctx, user.UserID); err != nil {
+		return nil, err
+	}
+	if data.shoppingLists, err = db.GetShoppingListsByUser(r.DB_POOL, ctx, user.UserID); err != nil {
+		return nil, err
+	}
+	if data.pantry, err = db.GetPantryItemsByUser(r.DB_POOL, ctx, user.UserID); err != nil {
+		return nil, err
+	}
+	if data.prices, err = db.GetIngredientPricesByUser(r.DB_POOL, ctx, user.UserID); err != nil {
+		return nil, err
+	}
+	if data.images, err = db.GetImagesByUser(r.DB_POOL, ctx, user.UserID); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// Write everything the current user created as a ZIP: a JSON file for each kind of data, in
+// the shape of the GraphQL types, each recipe as Markdown that import-markdown reads back,
+// and the original image files.
+//
+// Parameters:
+//   - ctx: Request context
+//   - w: Writer to write the ZIP to
+//
+// Returns:
+//   - Error if the export failed; the ZIP may be partly written
+func (r *Resolver) ExportAccount(ctx context.Context, w io.Writer) error {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return err
+	}
+	data, err := r.accountData(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	recipes := []*model.Recipe{}
+	for _, doc := range data.recipes {
+		recipes = append(recipes, doc.Recipe)
+	}
+	archive := zip.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		data interface{}
+	}{
+		{"account.json", map[string]interface{}{"user": user, "households": data.households, "exportedAt": time.Now().UTC()}},
+		{"recipes.json", recipes},
+		{"ingredients.json", data.ingredients},
+		{"meal-plan.json", data.mealPlan},
+		{"shopping-lists.json", data.shoppingLists},
+		{"pantry.json", data.pantry},
+		{"prices.json", data.prices},
+		{"images.json", data.images},
+	} {
+		out, err := archive.Create(file.name)
+		if err != nil {
+			return fmt.Errorf("failed to write export; error: %v", err)
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return fmt.Errorf("failed to write %s; error: %v", file.name, err)
+		}
+	}
+
+	for _, doc := range data.recipes {
+		markdown, err := exporter.Markdown(doc)
+		if err != nil {
+			return err
+		}
+		out, err := archive.Create("recipes/" + doc.Recipe.RecipeID + ".md")
+		if err != nil {
+			return fmt.Errorf("failed to write export; error: %v", err)
+		}
+		if _, err := out.Write(markdown); err != nil {
+			return fmt.Errorf("failed to write export; error: %v", err)
+		}
+	}
+
+	if r.BLOB_STORE != nil {
+		for _, image := range data.images {
+			if err := r.exportImage(ctx, archive, image); err != nil {
+				// The rest of the export is still worth having
+				log.Println("Error exporting image:", err)
+			}
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write export; error: %v", err)
+	}
+	return nil
+}
+
+// Copy an image's original file into an export.
+//
+// Parameters:
+//   - ctx: Request context
+//   - archive: Export being written
+//   - image: Image to copy
+//
+// Returns:
+//   - Error if the image could not be read or written
+func (r *Resolver) exportImage(ctx context.Context, archive *zip.Writer, image *model.Image) error {
+	file, _, err := r.BLOB_STORE.Get(ctx, images.OriginalKey(image.ImageID))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	extension := strings.TrimPrefix(image.ContentType, "image/")
+	if extension == "jpeg" {
+		extension = "jpg"
+	}
+	// Images are already compressed
+	out, err := archive.CreateHeader(&zip.FileHeader{
+		Name:     "images/" + image.ImageID + "." + extension,
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write export; error: %v", err)
+	}
+	_, err = io.Copy(out, file)
+	return err
+}
+
+// Describe the current user's data export and where to download it. Only the counts are
+// gathered here; the archive itself is built when it is downloaded.
+//
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - What the export holds
+func (r *Resolver) dataExport(ctx context.Context) (*model.DataExport, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	export, err := db.CountUserData(r.DB_POOL, ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	export.URL = strings.TrimSuffix(r.PUBLIC_URL, "/") + accountExportPath
+	return export, nil
+}
+
+// Delete the current user's account once they confirm it with their password, handing data
+// other users depend on over to them first, then remove the images no one uses anymore.
+//
+// Parameters:
+//   - ctx: Request context
+//   - password: The user's password
+//
+// Returns:
+//   - What was done with shared data
+func (r *Resolver) deleteAccount(ctx context.Context, password string) (*model.AccountDeletion, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	confirmed, err := db.GetUserByCredentials(r.DB_POOL, ctx, user.Name, password)
+	if err != nil {
+		return nil, err
+	}
+	if confirmed == nil || confirmed.UserID != user.UserID {
+		return nil, apperr.Forbidden("password does not match")
+	}
+
+	result, imageIDs, err := db.DeleteUser(r.DB_POOL, ctx, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, imageID := range imageIDs {
+		r.discardImage(ctx, imageID)
+	}
+	return result, nil
+}
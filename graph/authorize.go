@@ -0,0 +1,348 @@
+package graph
+
+// Authorization checks shared by the resolvers.
+//
+// Resources are owned by the user who created them and may additionally be
+// owned by a household. Household owned resources can be read by any member
+// and modified by editors and owners. Resources without a household remain
+// public to read, but only their creator may modify them.
+
+import (
+	"context"
+
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Rank each household role by the permissions it grants.
+var householdRoleRank = map[model.HouseholdRole]int{
+	model.HouseholdRoleViewer: 1,
+	model.HouseholdRoleEditor: 2,
+	model.HouseholdRoleOwner:  3,
+}
+
+// Check that the current user holds at least the given role in a household.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: ID of the household
+//   - minimum: Least privileged role that is allowed
+//
+// Returns:
+//   - The authenticated user
+func (r *Resolver) requireHouseholdRole(ctx context.Context, householdID string, minimum model.HouseholdRole) (*model.User, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := db.GetHouseholdRole(r.DB_POOL, ctx, householdID, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil || householdRoleRank[*role] < householdRoleRank[minimum] {
+		return nil, apperr.Forbidden("not authorized for this household")
+	}
+	return user, nil
+}
+
+// Check that the current user may act on behalf of the given user,
+// optionally placing the resource in a household.
+//
+// Parameters:
+//   - ctx: Request context
+//   - userID: User the resource will belong to
+//   - householdID: Household the resource will belong to, if any
+//
+// Returns:
+//   - The authenticated user
+func (r *Resolver) authorizeCreate(ctx context.Context, userID string, householdID *string) (*model.User, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if user.UserID != userID {
+		return nil, apperr.Forbidden("not authorized to create resources for another user")
+	}
+	if householdID != nil {
+		return r.requireHouseholdRole(ctx, *householdID, model.HouseholdRoleEditor)
+	}
+	return user, nil
+}
+
+// Check that the current user may modify a resource. Household resources need a current
+// editor or owner, even if they created the resource, so leaving a household ends access.
+//
+// Parameters:
+//   - ctx: Request context
+//   - owner: User that created the resource
+//   - household: Household owning the resource, if any
+//
+// Returns:
+//   - The authenticated user
+func (r *Resolver) authorizeWrite(ctx context.Context, owner *model.User, household *model.Household) (*model.User, error) {
+	if household != nil {
+		return r.requireHouseholdRole(ctx, household.HouseholdID, model.HouseholdRoleEditor)
+	}
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil && owner.UserID == user.UserID {
+		return user, nil
+	}
+	return nil, apperr.Forbidden("not authorized to modify this resource")
+}
+
+// Get the household roles of the current user, empty for anonymous requests.
+//
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - Map of household IDs to the user's role
+func (r *Resolver) currentHouseholdRoles(ctx context.Context) (map[string]model.HouseholdRole, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return map[string]model.HouseholdRole{}, nil
+	}
+	return db.GetHouseholdRoles(r.DB_POOL, ctx, user.UserID)
+}
+
+// Check whether a resource is visible given the current user's household roles. Household
+// resources are only visible to current members, including the user who created them.
+//
+// Parameters:
+//   - roles: Household roles of the current user
+//   - household: Household owning the resource, if any
+//
+// Returns:
+//   - Whether the resource may be read
+func canRead(roles map[string]model.HouseholdRole, household *model.Household) bool {
+	if household == nil {
+		return true
+	}
+	_, member := roles[household.HouseholdID]
+	return member
+}
+
+// Check that the current user may read a single resource.
+//
+// Parameters:
+//   - ctx: Request context
+//   - owner: User that created the resource
+//   - household: Household owning the resource, if any
+//
+// Returns:
+//   - Error if the resource may not be read
+func (r *Resolver) authorizeRead(ctx context.Context, owner *model.User, household *model.Household) error {
+	roles, err := r.currentHouseholdRoles(ctx)
+	if err != nil {
+		return err
+	}
+	if !canRead(roles, household) {
+		return apperr.Forbidden("not authorized to view this resource")
+	}
+	return nil
+}
+
+// Filter a collection of recipes down to those the current user may read.
+//
+// Parameters:
+//   - ctx: Request context
+//   - recipes: Recipes to filter
+//
+// Returns:
+//   - Visible recipes
+func (r *Resolver) visibleRecipes(ctx context.Context, recipes []*model.Recipe) ([]*model.Recipe, error) {
+	roles, err := r.currentHouseholdRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visible := []*model.Recipe{}
+	for _, recipe := range recipes {
+		if canRead(roles, recipe.Household) {
+			visible = append(visible, recipe)
+		}
+	}
+	return visible, nil
+}
+
+// Filter a collection of ingredients down to those the current user may read.
+//
+// Parameters:
+//   - ctx: Request context
+//   - ingredients: Ingredients to filter
+//
+// Returns:
+//   - Visible ingredients
+func (r *Resolver) visibleIngredients(ctx context.Context, ingredients []*model.Ingredient) ([]*model.Ingredient, error) {
+	roles, err := r.currentHouseholdRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visible := []*model.Ingredient{}
+	for _, ingredient := range ingredients {
+		if canRead(roles, ingredient.Household) {
+			visible = append(visible, ingredient)
+		}
+	}
+	return visible, nil
+}
+
+// Get one page of the recipes the current user may read, paged in the database.
+//
+// Parameters:
+//   - ctx: Request context
+//   - limit: Recipes per page
+//   - offset: Recipes to skip
+//
+// Returns:
+//   - Recipes on the page, and how many may be read in all
+func (r *Resolver) ReadableRecipes(ctx context.Context, limit int, offset int) ([]*model.Recipe, int, error) {
+	return db.GetReadableRecipes(r.DB_POOL, ctx, currentUserID(ctx), limit, offset)
+}
+
+// Get one page of the ingredients the current user may read, paged in the database.
+//
+// Parameters:
+//   - ctx: Request context
+//   - limit: Ingredients per page
+//   - offset: Ingredients to skip
+//
+// Returns:
+//   - Ingredients on the page, and how many may be read in all
+func (r *Resolver) ReadableIngredients(ctx context.Context, limit int, offset int) ([]*model.Ingredient, int, error) {
+	return db.GetReadableIngredients(r.DB_POOL, ctx, currentUserID(ctx), limit, offset)
+}
+
+// Get the ID of the current user.
+//
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - ID of the user, nil for anonymous requests
+func currentUserID(ctx context.Context) *string {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil
+	}
+	return &user.UserID
+}
+
+// Get an ingredient, provided the current user may read it.
+//
+// Parameters:
+//   - ctx: Request context
+//   - ingredientID: ID of the ingredient
+//
+// Returns:
+//   - The ingredient
+func (r *Resolver) ReadableIngredient(ctx context.Context, ingredientID string) (*model.Ingredient, error) {
+	ingredient, err := db.GetIngredientById(r.DB_POOL, ingredientID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeRead(ctx, ingredient.User, ingredient.Household); err != nil {
+		return nil, err
+	}
+	return ingredient, nil
+}
+
+// Filter a collection of substitutions down to those whose ingredients the current user may read.
+//
+// Parameters:
+//   - ctx: Request context
+//   - substitutions: Substitutions to filter
+//
+// Returns:
+//   - Visible substitutions
+func (r *Resolver) visibleSubstitutions(ctx context.Context, substitutions []*model.Substitution) ([]*model.Substitution, error) {
+	roles, err := r.currentHouseholdRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	visible := []*model.Substitution{}
+	for _, substitution := range substitutions {
+		readable := substitution.Ingredient != nil && canRead(roles, substitution.Ingredient.Household)
+		for _, part := range substitution.Replacements {
+			readable = readable && part.Ingredient != nil && canRead(roles, part.Ingredient.Household)
+		}
+		if readable {
+			visible = append(visible, substitution)
+		}
+	}
+	return visible, nil
+}
+
+// Check that the current user may read every ingredient being linked to a recipe.
+//
+// Parameters:
+//   - ctx: Request context
+//   - ingredients: IDs of the ingredients being used
+//
+// Returns:
+//   - Error if any ingredient may not be read
+func (r *Resolver) authorizeIngredientUse(ctx context.Context, ingredients []*model.ExistingIngredientID) error {
+	if len(ingredients) == 0 {
+		return nil
+	}
+	roles, err := r.currentHouseholdRoles(ctx)
+	if err != nil {
+		return err
+	}
+	for _, existing := range ingredients {
+		ingredient, err := db.GetIngredientById(r.DB_POOL, existing.IngredientID, ctx)
+		if err != nil {
+			return err
+		}
+		if !canRead(roles, ingredient.Household) {
+			return apperr.Forbidden("not authorized to use ingredient %s", ingredient.IngredientID)
+		}
+	}
+	return nil
+}
+
+// Check that the current user may use a personal or household scoped resource,
+// such as a meal plan. Personal resources belong to the current user.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: Household owning the resource, nil for personal resources
+//   - minimum: Least privileged household role that is allowed
+//
+// Returns:
+//   - The authenticated user
+func (r *Resolver) authorizeScope(ctx context.Context, householdID *string, minimum model.HouseholdRole) (*model.User, error) {
+	if householdID != nil {
+		return r.requireHouseholdRole(ctx, *householdID, minimum)
+	}
+	return auth.RequireUser(ctx)
+}
+
+// Check that the current user may use an existing personal or household scoped resource.
+//
+// Parameters:
+//   - ctx: Request context
+//   - owner: User that created the resource
+//   - household: Household owning the resource, nil for personal resources
+//   - minimum: Least privileged household role that is allowed
+//
+// Returns:
+//   - The authenticated user
+func (r *Resolver) authorizeScoped(ctx context.Context, owner *model.User, household *model.Household, minimum model.HouseholdRole) (*model.User, error) {
+	if household != nil {
+		return r.requireHouseholdRole(ctx, household.HouseholdID, minimum)
+	}
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if owner == nil || owner.UserID != user.UserID {
+		return nil, apperr.Forbidden("not authorized for this resource")
+	}
+	return user, nil
+}
@@ -0,0 +1,638 @@
+package graph
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Columns of the ingredients CSV, in the order they are exported. Allergens and diets
+// are lists of their GraphQL enum values separated by semicolons.
+var ingredientColumns = []string{"name", "description", "category", "grams_per_ml", "grams_each", "allergens", "diets"}
+
+// Columns of the recipe lines CSV, with a row per ingredient of each recipe.
+var recipeLineColumns = []string{"recipe", "ingredient", "quantity", "unit", "preparation", "notes"}
+
+// Longest ingredient or recipe category the database holds.
+const maxCategoryText = 64
+
+// A table that can be imported from and exported to CSV.
+type CSVTable struct {
+	Import func(r *Resolver, ctx context.Context, householdID *string, data io.Reader, dryRun bool) (*CSVImport, error)
+	Export func(r *Resolver, ctx context.Context, householdID *string, w io.Writer) error
+}
+
+// Tables served by the CSV commands and routes, keyed by name.
+var CSVTables = map[string]CSVTable{
+	"ingredients":  {(*Resolver).ImportIngredientsCSV, (*Resolver).ExportIngredientsCSV},
+	"recipe-lines": {(*Resolver).ImportRecipeLinesCSV, (*Resolver).ExportRecipeLinesCSV},
+}
+
+// Outcome of importing a CSV file, reported row by row.
+type CSVImport struct {
+	// Whether nothing was saved, the rows reporting what would have been done
+	DryRun  bool      `json:"dryRun"`
+	Created int       `json:"created"`
+	Updated int       `json:"updated"`
+	Failed  int       `json:"failed"`
+	Rows    []*CSVRow `json:"rows"`
+	// Problems with the file as a whole that did not stop the import
+	Warnings []string `json:"warnings"`
+}
+
+// Outcome of importing a row of a CSV file.
+type CSVRow struct {
+	// Line of the file the row starts on, the header being line 1
+	Line int    `json:"line"`
+	Name string `json:"name"`
+	// "created" or "updated"; empty when the row failed
+	Action string `json:"action,omitempty"`
+	// Other things created for the row, such as a missing ingredient
+	Notes []string `json:"notes,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// Add a row's outcome to the import.
+func (result *CSVImport) add(row *CSVRow) {
+	switch {
+	case row.Error != "":
+		row.Action = ""
+		result.Failed++
+	case row.Action == "created":
+		result.Created++
+	default:
+		result.Updated++
+	}
+	result.Rows = append(result.Rows, row)
+}
+
+// Rows of a CSV file with a header naming its columns.
+type csvRows struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+// Start reading a CSV file, checking its header.
+//
+// Parameters:
+//   - data: CSV file
+//   - required: Columns that must be present
+//   - known: All columns that are read; others are reported as warnings
+//   - result: Import to add warnings to
+//
+// Returns:
+//   - Reader of the rows after the header
+func openCSV(data io.Reader, required []string, known []string, result *CSVImport) (*csvRows, error) {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, apperr.Invalid("file is empty")
+	}
+	if err != nil {
+		return nil, apperr.Invalid("could not read CSV header: %v", err)
+	}
+
+	rows := csvRows{reader: reader, columns: map[string]int{}}
+	for i, name := range header {
+		// Spreadsheets often save a byte order mark before the first column
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\uFEFF")))
+		name = strings.NewReplacer(" ", "_", "-", "_").Replace(name)
+		if !slices.Contains(known, name) {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("ignored unknown column %q", header[i]))
+			continue
+		}
+		rows.columns[name] = i
+	}
+	for _, name := range required {
+		if _, ok := rows.columns[name]; !ok {
+			return nil, apperr.Invalid("missing required column %q", name)
+		}
+	}
+	return &rows, nil
+}
+
+// Read the next row.
+//
+// Returns:
+//   - Line the row starts on, a function giving the trimmed value of a column
+//     (empty when the row is too short), and io.EOF after the last row
+func (rows *csvRows) next() (int, func(column string) string, error) {
+	record, err := rows.reader.Read()
+	if err != nil {
+		if err != io.EOF {
+			err = fmt.Errorf("failed to read CSV; error: %v", err)
+		}
+		return 0, nil, err
+	}
+	line, _ := rows.reader.FieldPos(0)
+	cell := func(column string) string {
+		i, ok := rows.columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	return line, cell, nil
+}
+
+// Import ingredients from CSV, updating the ingredient of the same name in the user's own
+// ingredients, or the household's, and creating one where there is none. Empty cells leave
+// an ingredient's existing value alone. Rows that cannot be read are reported and skipped.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: Household whose ingredients to import into; the user's own if nil
+//   - data: CSV file, with a header row naming the columns
+//   - dryRun: Report what would be done without saving anything
+//
+// Returns:
+//   - What happened to each row
+func (r *Resolver) ImportIngredientsCSV(ctx context.Context, householdID *string, data io.Reader, dryRun bool) (*CSVImport, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	result := CSVImport{DryRun: dryRun, Rows: []*CSVRow{}, Warnings: []string{}}
+	rows, err := openCSV(data, ingredientColumns[:1], ingredientColumns, &result)
+	if err != nil {
+		return nil, err
+	}
+	owned, err := r.scopedIngredients(ctx, user, householdID)
+	if err != nil {
+		return nil, err
+	}
+	byName := map[string]*model.Ingredient{}
+	for _, ingredient := range owned {
+		byName[strings.ToLower(ingredient.Name)] = ingredient
+	}
+
+	seen := map[string]int{}
+	for {
+		line, cell, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		row := CSVRow{Line: line, Name: cell("name")}
+		input, err := ingredientRow(cell)
+		key := strings.ToLower(row.Name)
+		if previous, ok := seen[key]; ok && err == nil {
+			err = apperr.Invalid("repeats the ingredient on line %d", previous)
+		}
+		if err != nil {
+			row.Error = err.Error()
+			result.add(&row)
+			continue
+		}
+		seen[key] = line
+
+		existing := byName[key]
+		switch {
+		case existing != nil:
+			row.Action = "updated"
+			if !dryRun {
+				err = db.UpdateIngredient(r.DB_POOL, ctx, existing.IngredientID, input)
+			}
+		default:
+			row.Action = "created"
+			if !dryRun {
+				description := ""
+				if input.Description != nil {
+					description = *input.Description
+				}
+				_, err = db.CreateIngredient(r.DB_POOL, ctx, model.NewIngredient{
+					Name:        row.Name,
+					Description: description,
+					Category:    input.Category,
+					UserID:      user.UserID,
+					HouseholdID: householdID,
+					GramsPerMl:  input.GramsPerMl,
+					GramsEach:   input.GramsEach,
+					Allergens:   input.Allergens,
+					Diets:       input.Diets,
+				})
+			}
+		}
+		if err != nil {
+			row.Error = err.Error()
+		}
+		result.add(&row)
+	}
+	return &result, nil
+}
+
+// Read the fields of an ingredient from a CSV row, leaving those with empty cells unset.
+//
+// Parameters:
+//   - cell: Value of a column in the row
+//
+// Returns:
+//   - Fields to set on the ingredient
+func ingredientRow(cell func(column string) string) (model.UpdateIngredient, error) {
+	input := model.UpdateIngredient{}
+	name := cell("name")
+	if name == "" {
+		return input, apperr.Invalid("name is required")
+	}
+	if len([]rune(name)) > maxRecipeText {
+		return input, apperr.Invalid("name is longer than %d characters", maxRecipeText)
+	}
+	input.Name = &name
+	if description := cell("description"); description != "" {
+		if len([]rune(description)) > maxRecipeText {
+			return input, apperr.Invalid("description is longer than %d characters", maxRecipeText)
+		}
+		input.Description = &description
+	}
+	if category := cell("category"); category != "" {
+		if len([]rune(category)) > maxCategoryText {
+			return input, apperr.Invalid("category is longer than %d characters", maxCategoryText)
+		}
+		input.Category = &category
+	}
+
+	for _, field := range []struct {
+		column string
+		value  **float64
+	}{
+		{"grams_per_ml", &input.GramsPerMl},
+		{"grams_each", &input.GramsEach},
+	} {
+		text := cell(field.column)
+		if text == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(text, 64)
+		if err != nil || value <= 0 {
+			return input, apperr.Invalid("%s must be a positive number, not %q", field.column, text)
+		}
+		*field.value = &value
+	}
+
+	for _, value := range csvList(cell("allergens")) {
+		allergen := model.Allergen(value)
+		if !allergen.IsValid() {
+			return input, apperr.Invalid("unknown allergen %q", value)
+		}
+		input.Allergens = append(input.Allergens, allergen)
+	}
+	for _, value := range csvList(cell("diets")) {
+		diet := model.Diet(value)
+		if !diet.IsValid() {
+			return input, apperr.Invalid("unknown diet %q", value)
+		}
+		input.Diets = append(input.Diets, diet)
+	}
+	return input, nil
+}
+
+// Split a cell listing enum values, e.g. "gluten; tree nut" into GLUTEN and TREE_NUT.
+func csvList(text string) []string {
+	values := []string{}
+	for _, value := range strings.FieldsFunc(text, func(c rune) bool { return c == ';' || c == ',' }) {
+		if value = strings.Join(strings.Fields(value), "_"); value != "" {
+			values = append(values, strings.ToUpper(value))
+		}
+	}
+	return values
+}
+
+// Import recipe ingredient lines from CSV. Each row names a recipe and an ingredient: the
+// recipe is found by name in the user's own recipes, or the household's, and created
+// with just its name when there is none; the ingredient is matched by name to those the
+// user can see, and created when nothing matches. A recipe's line for that ingredient is
+// then added, or replaced if it has one. Rows that cannot be read are reported and skipped.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: Household whose recipes to import into; the user's own if nil
+//   - data: CSV file, with a header row naming the columns
+//   - dryRun: Report what would be done without saving anything
+//
+// Returns:
+//   - What happened to each row
+func (r *Resolver) ImportRecipeLinesCSV(ctx context.Context, householdID *string, data io.Reader, dryRun bool) (*CSVImport, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	result := CSVImport{DryRun: dryRun, Rows: []*CSVRow{}, Warnings: []string{}}
+	rows, err := openCSV(data, recipeLineColumns[:2], recipeLineColumns, &result)
+	if err != nil {
+		return nil, err
+	}
+	recipes, err := r.scopedRecipes(ctx, user, householdID)
+	if err != nil {
+		return nil, err
+	}
+	known, err := r.knownIngredients(ctx, user, householdID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recipes and ingredients are keyed by ID, or by name when a dry run has not created them
+	key := func(id string, name string) string {
+		if id == "" {
+			return "new:" + strings.ToLower(name)
+		}
+		return id
+	}
+	byName := map[string]*model.Recipe{}
+	lines := map[[2]string]bool{}
+	for _, recipe := range recipes {
+		byName[strings.ToLower(recipe.Name)] = recipe
+		for _, line := range recipe.IngredientLines {
+			lines[[2]string{recipe.RecipeID, line.Ingredient.IngredientID}] = true
+		}
+	}
+
+	for {
+		line, cell, err := rows.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		recipeName, ingredientName := cell("recipe"), cell("ingredient")
+		row := CSVRow{Line: line, Name: recipeName + ": " + ingredientName}
+		amount, err := recipeLineRow(cell)
+		if err != nil {
+			row.Error = err.Error()
+			result.add(&row)
+			continue
+		}
+
+		recipe := byName[strings.ToLower(recipeName)]
+		if recipe == nil {
+			recipe = &model.Recipe{Name: recipeName}
+			if !dryRun {
+				recipeID, err := db.CreateRecipe(r.DB_POOL, ctx, model.NewRecipe{
+					Name:        recipeName,
+					Description: "",
+					Ingredients: []*model.ExistingIngredientID{},
+					UserID:      user.UserID,
+					HouseholdID: householdID,
+				}, nil)
+				if err != nil {
+					row.Error = err.Error()
+					result.add(&row)
+					continue
+				}
+				recipe.RecipeID = recipeID
+			}
+			byName[strings.ToLower(recipeName)] = recipe
+			row.Notes = append(row.Notes, "created recipe "+recipeName)
+		}
+
+		var ingredient *model.Ingredient
+		for _, name := range matchKeys(ingredientName) {
+			if ingredient = known[name]; ingredient != nil {
+				break
+			}
+		}
+		if ingredient == nil {
+			ingredient = &model.Ingredient{Name: ingredientName}
+			if !dryRun {
+				ingredientID, err := db.CreateIngredient(r.DB_POOL, ctx, model.NewIngredient{
+					Name:        ingredientName,
+					Description: "",
+					UserID:      user.UserID,
+					HouseholdID: householdID,
+				})
+				if err != nil {
+					row.Error = err.Error()
+					result.add(&row)
+					continue
+				}
+				ingredient.IngredientID = ingredientID
+			}
+			known[strings.ToLower(baseName(ingredientName))] = ingredient
+			row.Notes = append(row.Notes, "created ingredient "+ingredientName)
+		}
+
+		lineKey := [2]string{key(recipe.RecipeID, recipe.Name), key(ingredient.IngredientID, ingredient.Name)}
+		row.Action = "created"
+		if lines[lineKey] {
+			row.Action = "updated"
+		}
+		lines[lineKey] = true
+		if !dryRun {
+			amount.IngredientID = ingredient.IngredientID
+			if err := db.UpsertRecipeIngredient(r.DB_POOL, ctx, recipe.RecipeID, amount); err != nil {
+				row.Error = err.Error()
+			}
+		}
+		result.add(&row)
+	}
+	return &result, nil
+}
+
+// Read the amount and notes of a recipe ingredient line from a CSV row.
+//
+// Parameters:
+//   - cell: Value of a column in the row
+//
+// Returns:
+//   - The line, without its ingredient ID
+func recipeLineRow(cell func(column string) string) (*model.ExistingIngredientID, error) {
+	for _, column := range recipeLineColumns[:2] {
+		value := cell(column)
+		if value == "" {
+			return nil, apperr.Invalid("%s is required", column)
+		}
+		if len([]rune(value)) > maxRecipeText {
+			return nil, apperr.Invalid("%s is longer than %d characters", column, maxRecipeText)
+		}
+	}
+
+	line := model.ExistingIngredientID{
+		Preparation: optionalText(cell("preparation")),
+		Notes:       optionalText(cell("notes")),
+	}
+	if text := cell("quantity"); text != "" {
+		quantity, ok := ingredientline.ParseQuantity(text)
+		if !ok || quantity < 0 {
+			return nil, apperr.Invalid("quantity %q is not a number", text)
+		}
+		line.Quantity = &quantity
+	}
+	if unit := cell("unit"); unit != "" {
+		if known, ok := units.Lookup(unit); ok {
+			unit = known.Name
+		}
+		line.Unit = &unit
+	}
+	return &line, nil
+}
+
+// Export the user's own ingredients, or the household's, as CSV in the form read by ImportIngredientsCSV.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: Household whose ingredients to export; the user's own if nil
+//   - w: Writer the CSV is written to
+//
+// Returns:
+//   - Error if the export failed
+func (r *Resolver) ExportIngredientsCSV(ctx context.Context, householdID *string, w io.Writer) error {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return err
+	}
+	ingredients, err := r.scopedIngredients(ctx, user, householdID)
+	if err != nil {
+		return err
+	}
+	sort.Slice(ingredients, func(i, j int) bool {
+		return strings.ToLower(ingredients[i].Name) < strings.ToLower(ingredients[j].Name)
+	})
+
+	writer := csv.NewWriter(w)
+	writer.Write(ingredientColumns)
+	for _, ingredient := range ingredients {
+		allergens := []string{}
+		for _, allergen := range ingredient.Allergens {
+			allergens = append(allergens, string(allergen))
+		}
+		diets := []string{}
+		for _, diet := range ingredient.Diets {
+			diets = append(diets, string(diet))
+		}
+		writer.Write([]string{
+			ingredient.Name,
+			ingredient.Description,
+			csvText(ingredient.Category),
+			csvNumber(ingredient.GramsPerMl),
+			csvNumber(ingredient.GramsEach),
+			strings.Join(allergens, ";"),
+			strings.Join(diets, ";"),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Export the ingredient lines of the user's own recipes, or the household's, as CSV in the
+// form read by ImportRecipeLinesCSV.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: Household whose recipes to export; the user's own if nil
+//   - w: Writer the CSV is written to
+//
+// Returns:
+//   - Error if the export failed
+func (r *Resolver) ExportRecipeLinesCSV(ctx context.Context, householdID *string, w io.Writer) error {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return err
+	}
+	recipes, err := r.scopedRecipes(ctx, user, householdID)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(recipes, func(i, j int) bool {
+		return strings.ToLower(recipes[i].Name) < strings.ToLower(recipes[j].Name)
+	})
+
+	writer := csv.NewWriter(w)
+	writer.Write(recipeLineColumns)
+	for _, recipe := range recipes {
+		for _, line := range recipe.IngredientLines {
+			writer.Write([]string{
+				recipe.Name,
+				line.Ingredient.Name,
+				csvNumber(line.Quantity),
+				csvText(line.Unit),
+				csvText(line.Preparation),
+				csvText(line.Notes),
+			})
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Get the ingredients in a scope: the household's, or the user's own outside any household.
+//
+// Parameters:
+//   - ctx: Request context
+//   - user: Current user
+//   - householdID: Household of the scope, nil for the user's own
+//
+// Returns:
+//   - Ingredients in the scope
+func (r *Resolver) scopedIngredients(ctx context.Context, user *model.User, householdID *string) ([]*model.Ingredient, error) {
+	if householdID != nil {
+		return db.GetIngredients(r.DB_POOL, ctx, map[string]interface{}{"i.household_id": *householdID})
+	}
+	ingredients, err := db.GetIngredients(r.DB_POOL, ctx, map[string]interface{}{"i.user_id": user.UserID})
+	if err != nil {
+		return nil, err
+	}
+	personal := []*model.Ingredient{}
+	for _, ingredient := range ingredients {
+		if ingredient.Household == nil {
+			personal = append(personal, ingredient)
+		}
+	}
+	return personal, nil
+}
+
+// Get the recipes in a scope: the household's, or the user's own outside any household.
+//
+// Parameters:
+//   - ctx: Request context
+//   - user: Current user
+//   - householdID: Household of the scope, nil for the user's own
+//
+// Returns:
+//   - Recipes in the scope
+func (r *Resolver) scopedRecipes(ctx context.Context, user *model.User, householdID *string) ([]*model.Recipe, error) {
+	if householdID != nil {
+		return db.GetRecipes(r.DB_POOL, ctx, map[string]interface{}{"r.household_id": *householdID})
+	}
+	recipes, err := db.GetRecipes(r.DB_POOL, ctx, map[string]interface{}{"r.user_id": user.UserID})
+	if err != nil {
+		return nil, err
+	}
+	personal := []*model.Recipe{}
+	for _, recipe := range recipes {
+		if recipe.Household == nil {
+			personal = append(personal, recipe)
+		}
+	}
+	return personal, nil
+}
+
+// Write an optional value as a CSV cell.
+func csvText(text *string) string {
+	if text == nil {
+		return ""
+	}
+	return *text
+}
+
+// Write an optional number as a CSV cell.
+func csvNumber(value *float64) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*value, 'f', -1, 64)
+}
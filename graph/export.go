@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/images"
+	"github.com/zldobbs/ambrosia-server/nutrition"
+)
+
+// Longest side, in pixels, of a photo embedded in an exported recipe; enough to print at full page width.
+const exportPhotoSize = 1600
+
+// Get a recipe the current user may read, for routes outside of GraphQL.
+//
+// Parameters:
+//   - ctx: Request context
+//   - recipeID: ID of the recipe
+//
+// Returns:
+//   - The recipe
+func (r *Resolver) ReadableRecipe(ctx context.Context, recipeID string) (*model.Recipe, error) {
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeRead(ctx, recipe.User, recipe.Household); err != nil {
+		return nil, err
+	}
+	return recipe, nil
+}
+
+// Gather what exporters need to write out a recipe.
+//
+// Parameters:
+//   - ctx: Request context
+//   - recipe: Recipe to export
+//
+// Returns:
+//   - Document holding the recipe with its steps and nutrition
+func (r *Resolver) ExportDocument(ctx context.Context, recipe *model.Recipe) (*exporter.Document, error) {
+	steps, err := db.GetRecipeSteps(r.DB_POOL, ctx, recipe.RecipeID)
+	if err != nil {
+		return nil, err
+	}
+
+	ingredientIDs := []string{}
+	for _, ingredient := range recipe.Ingredients {
+		ingredientIDs = append(ingredientIDs, ingredient.IngredientID)
+	}
+	facts, err := db.GetIngredientNutrition(r.DB_POOL, ctx, ingredientIDs)
+	if err != nil {
+		return nil, err
+	}
+	var total *model.RecipeNutrition
+	if len(facts) > 0 {
+		total = nutrition.ForLines(recipe.IngredientLines, facts)
+	}
+
+	return &exporter.Document{
+		Recipe:    recipe,
+		Steps:     steps,
+		Nutrition: total,
+		BaseURL:   r.PUBLIC_URL,
+	}, nil
+}
+
+// Load a recipe's image into a document, for formats that embed it rather than link to it.
+// Documents of recipes without an image, or written without an image store, are left as they are.
+//
+// Parameters:
+//   - ctx: Request context
+//   - doc: Document to load the image into
+//
+// Returns:
+//   - Error if the image could not be read
+func (r *Resolver) LoadExportPhoto(ctx context.Context, doc *exporter.Document) error {
+	if doc.Recipe.Image == nil || r.BLOB_STORE == nil {
+		return nil
+	}
+	file, _, err := r.BLOB_STORE.Get(ctx, images.OriginalKey(doc.Recipe.Image.ImageID))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return fmt.Errorf("failed to decode recipe image; error: %v", err)
+	}
+	// Every stored format is written as JPEG, which all embedding formats accept
+	doc.Photo, err = images.Thumbnail(img, exportPhotoSize)
+	return err
+}
+
+// List the recipes created by a user that the current user may read, ordered by name, to
+// export as one book.
+//
+// Parameters:
+//   - ctx: Request context
+//   - userID: ID of the user whose recipes to list
+//
+// Returns:
+//   - IDs of the recipes
+func (r *Resolver) UserRecipeIDs(ctx context.Context, userID string) ([]string, error) {
+	recipes, err := db.GetRecipes(r.DB_POOL, ctx, map[string]interface{}{"r.user_id": userID})
+	if err != nil {
+		return nil, err
+	}
+	recipes, err = r.visibleRecipes(ctx, recipes)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(recipes, func(i, j int) bool {
+		return strings.ToLower(recipes[i].Name) < strings.ToLower(recipes[j].Name)
+	})
+	recipeIDs := []string{}
+	for _, recipe := range recipes {
+		recipeIDs = append(recipeIDs, recipe.RecipeID)
+	}
+	return recipeIDs, nil
+}
+
+// Gather documents for several recipes the current user may read, with their images, to be
+// written out together. Images that cannot be loaded are logged and left out.
+//
+// Parameters:
+//   - ctx: Request context
+//   - recipeIDs: IDs of the recipes, in the order to write them
+//
+// Returns:
+//   - Document for each recipe
+func (r *Resolver) ExportDocuments(ctx context.Context, recipeIDs []string) ([]*exporter.Document, error) {
+	docs := []*exporter.Document{}
+	for _, recipeID := range recipeIDs {
+		recipe, err := r.ReadableRecipe(ctx, recipeID)
+		if err != nil {
+			return nil, fmt.Errorf("recipe %s: %w", recipeID, err)
+		}
+		doc, err := r.ExportDocument(ctx, recipe)
+		if err != nil {
+			return nil, err
+		}
+		// A recipe is still worth printing without its image
+		if err := r.LoadExportPhoto(ctx, doc); err != nil {
+			log.Println("Error loading recipe image:", err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
@@ -0,0 +1,85 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/images"
+)
+
+// Validate an uploaded image, store it with its thumbnail and record it.
+//
+// Parameters:
+//   - ctx: Request context
+//   - userID: ID of the user uploading the image
+//   - upload: Uploaded file
+//
+// Returns:
+//   - The stored image
+func (r *Resolver) storeImage(ctx context.Context, userID string, upload graphql.Upload) (*model.Image, error) {
+	if r.BLOB_STORE == nil {
+		return nil, fmt.Errorf("image uploads are not configured")
+	}
+	if upload.Size > images.MaxBytes {
+		return nil, apperr.Invalid("image is larger than %d MB", images.MaxBytes>>20)
+	}
+	processed, err := images.Process(upload.File)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := images.NewID()
+	if err != nil {
+		return nil, err
+	}
+	err = r.BLOB_STORE.Put(ctx, images.OriginalKey(id), processed.ContentType, bytes.NewReader(processed.Original))
+	if err != nil {
+		return nil, err
+	}
+	err = r.BLOB_STORE.Put(ctx, images.ThumbnailKey(id), "image/jpeg", bytes.NewReader(processed.Thumbnail))
+	if err != nil {
+		r.discardImage(ctx, id)
+		return nil, err
+	}
+
+	image := model.Image{
+		ImageID:      id,
+		URL:          images.URL(id),
+		ThumbnailURL: images.ThumbnailURL(id),
+		ContentType:  processed.ContentType,
+		Width:        processed.Width,
+		Height:       processed.Height,
+	}
+	err = db.CreateImage(r.DB_POOL, ctx, userID, &image, len(processed.Original))
+	if err != nil {
+		r.discardImage(ctx, id)
+		return nil, err
+	}
+	return &image, nil
+}
+
+// Remove an image that is no longer referenced, along with its files.
+// Failures are logged rather than returned, since the image is already detached.
+//
+// Parameters:
+//   - ctx: Request context
+//   - imageID: ID of the image
+func (r *Resolver) discardImage(ctx context.Context, imageID string) {
+	if err := db.DeleteImage(r.DB_POOL, ctx, imageID); err != nil {
+		log.Println("Error removing image:", err)
+	}
+	if r.BLOB_STORE == nil {
+		return
+	}
+	for _, key := range []string{images.OriginalKey(imageID), images.ThumbnailKey(imageID)} {
+		if err := r.BLOB_STORE.Delete(ctx, key); err != nil {
+			log.Println("Error removing image file:", err)
+		}
+	}
+}
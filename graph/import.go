@@ -0,0 +1,613 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/importer"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Longest name or description the recipe table holds.
+const maxRecipeText = 255
+
+// Most categories a recipe may be filed under.
+const maxRecipeCategories = 20
+
+// Largest file of several recipes accepted for import, in bytes.
+const maxArchiveBytes = 100 << 20
+
+var parenthesized = regexp.MustCompile(`\([^)]*\)`)
+
+// Reduce an ingredient name to the part worth matching on, dropping
+// parenthesized notes and anything after a comma, e.g. "onion, diced (1 cup)".
+//
+// Parameters:
+//   - name: Ingredient name from a parsed line
+//
+// Returns:
+//   - The base name
+func baseName(name string) string {
+	name = parenthesized.ReplaceAllString(name, " ")
+	name, _, _ = strings.Cut(name, ",")
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Spellings of an ingredient name to try when matching, exact first.
+//
+// Parameters:
+//   - name: Ingredient name
+//
+// Returns:
+//   - Lowercase keys, including singular forms of a plural name
+func matchKeys(name string) []string {
+	key := strings.ToLower(baseName(name))
+	keys := []string{key}
+	if strings.HasSuffix(key, "es") {
+		keys = append(keys, strings.TrimSuffix(key, "es"))
+	}
+	if strings.HasSuffix(key, "s") {
+		keys = append(keys, strings.TrimSuffix(key, "s"))
+	}
+	return keys
+}
+
+// Cut text down to the length a recipe column holds.
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit])
+}
+
+// Tidy the categories given for a recipe, trimming them and dropping blanks and repeats.
+//
+// Parameters:
+//   - categories: Categories as given
+//
+// Returns:
+//   - Categories to store, nil when none were given
+func cleanCategories(categories []string) ([]string, error) {
+	if categories == nil {
+		return nil, nil
+	}
+	cleaned := []string{}
+	seen := map[string]bool{}
+	for _, category := range categories {
+		category = strings.Join(strings.Fields(category), " ")
+		if category == "" || seen[category] {
+			continue
+		}
+		if len([]rune(category)) > maxCategoryText {
+			return nil, apperr.Invalid("category %q is longer than %d characters", category, maxCategoryText)
+		}
+		seen[category] = true
+		cleaned = append(cleaned, category)
+	}
+	if len(cleaned) > maxRecipeCategories {
+		return nil, apperr.Invalid("a recipe can have at most %d categories", maxRecipeCategories)
+	}
+	return cleaned, nil
+}
+
+// Check that a recipe's name and description, where given, fit the recipe table.
+//
+// Parameters:
+//   - name: Name of the recipe, nil if unchanged
+//   - description: Description of the recipe, nil if unchanged
+//
+// Returns:
+//   - Error naming the field that is too long
+func checkRecipeText(name *string, description *string) error {
+	if name != nil && len([]rune(*name)) > maxRecipeText {
+		return apperr.Invalid("name is longer than %d characters", maxRecipeText)
+	}
+	if description != nil && len([]rune(*description)) > maxRecipeText {
+		return apperr.Invalid("description is longer than %d characters", maxRecipeText)
+	}
+	return nil
+}
+
+// Check that a recipe rating, if given, is out of 5.
+func checkRating(rating *int) error {
+	if rating != nil && (*rating < 1 || *rating > 5) {
+		return apperr.Invalid("rating must be from 1 to 5")
+	}
+	return nil
+}
+
+// Save an imported recipe for the current user, who must be able to edit the household if one is given.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: Household to place the recipe and new ingredients in, if any
+//   - imported: Recipe read from the source
+//
+// Returns:
+//   - The saved recipe with what was created along the way
+func (r *Resolver) SaveImport(ctx context.Context, householdID *string, imported *importer.Recipe) (*model.RecipeImport, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	return r.saveImportedRecipe(ctx, user, householdID, imported)
+}
+
+// Save each recipe read from a file of several for the current user, who must be able
+// to edit the household if one is given. Recipes are saved one at a time, so one failing
+// does not stop the rest.
+//
+// Parameters:
+//   - ctx: Request context
+//   - householdID: Household to place the recipes and new ingredients in, if any
+//   - entries: Recipes read from the file
+//
+// Returns:
+//   - Outcome for each recipe
+func (r *Resolver) ImportArchive(ctx context.Context, householdID *string, entries []importer.ArchiveEntry) (*model.RecipeArchiveImport, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	result := model.RecipeArchiveImport{Results: []*model.RecipeArchiveResult{}}
+	for _, entry := range entries {
+		outcome := model.RecipeArchiveResult{Name: entry.Name}
+		err := entry.Err
+		if err == nil {
+			outcome.Saved, err = r.saveImportedRecipe(ctx, user, householdID, entry.Recipe)
+		}
+		if err != nil {
+			message := err.Error()
+			outcome.Error = &message
+			result.Failed++
+		} else {
+			result.Imported++
+		}
+		result.Results = append(result.Results, &outcome)
+	}
+	return &result, nil
+}
+
+// Save an imported recipe for the current user. Lines are matched by name to
+// ingredients the user can see, preferring their own and the household's, and
+// ingredients are created for lines that match nothing.
+//
+// Parameters:
+//   - ctx: Request context
+//   - user: User importing the recipe
+//   - householdID: Household to place the recipe and new ingredients in, if any
+//   - imported: Recipe read from the source
+//
+// Returns:
+//   - The saved recipe with what was created along the way
+func (r *Resolver) saveImportedRecipe(ctx context.Context, user *model.User, householdID *string, imported *importer.Recipe) (*model.RecipeImport, error) {
+	result := model.RecipeImport{
+		CreatedIngredients: []*model.Ingredient{},
+		ImageUrls:          imported.ImageURLs,
+		Warnings:           append([]string{}, imported.Warnings...),
+	}
+	if result.ImageUrls == nil {
+		result.ImageUrls = []string{}
+	}
+
+	matched, err := r.matchIngredientLines(ctx, user, householdID, nil, imported.Ingredients)
+	if err != nil {
+		return nil, err
+	}
+	result.Warnings = append(result.Warnings, matched.warnings...)
+
+	steps := []*model.NewRecipeStep{}
+	for _, step := range imported.Steps {
+		steps = append(steps, &model.NewRecipeStep{Text: step.Text, Duration: step.Duration})
+	}
+
+	categories := []string{}
+	for _, category := range imported.Categories {
+		categories = append(categories, truncate(category, maxCategoryText))
+	}
+	if len(categories) > maxRecipeCategories {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("only the first %d categories were kept", maxRecipeCategories))
+		categories = categories[:maxRecipeCategories]
+	}
+	categories, err = cleanCategories(categories)
+	if err != nil {
+		return nil, err
+	}
+	rating := imported.Rating
+	if checkRating(rating) != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("rating of %d is not out of 5, so it was not kept", *rating))
+		rating = nil
+	}
+	if len([]rune(imported.Description)) > maxRecipeText {
+		result.Warnings = append(result.Warnings, "description was shortened to fit")
+	}
+	recipeID, err := db.CreateRecipe(r.DB_POOL, ctx, model.NewRecipe{
+		Name:        truncate(imported.Name, maxRecipeText),
+		Description: truncate(imported.Description, maxRecipeText),
+		Ingredients: matched.lines,
+		UserID:      user.UserID,
+		HouseholdID: householdID,
+		Servings:    imported.Servings,
+		PrepTime:    imported.PrepTime,
+		CookTime:    imported.CookTime,
+		RestTime:    imported.RestTime,
+		Steps:       steps,
+		SourceURL:   imported.SourceURL,
+		Categories:  categories,
+		Rating:      rating,
+	}, matched.pending)
+	if err != nil {
+		return nil, err
+	}
+	if result.CreatedIngredients, err = r.createdIngredients(ctx, matched); err != nil {
+		return nil, err
+	}
+	if imported.Photo != nil {
+		if err := r.attachImportedPhoto(ctx, user.UserID, recipeID, imported.Photo); err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("photo was not imported: %v", err))
+		}
+	}
+	result.Recipe, err = db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Ingredient lines matched to stored ingredients.
+type matchedLines struct {
+	lines []*model.ExistingIngredientID
+	// Ingredients to create, with the recipe, for lines that matched nothing
+	pending []*db.PendingIngredient
+	// Lines that could not be used
+	warnings []string
+}
+
+// Get the ingredients created for lines that matched nothing, once the recipe is saved.
+//
+// Parameters:
+//   - ctx: Request context
+//   - matched: Lines the recipe was saved with
+//
+// Returns:
+//   - The created ingredients
+func (r *Resolver) createdIngredients(ctx context.Context, matched *matchedLines) ([]*model.Ingredient, error) {
+	created := []*model.Ingredient{}
+	for _, pending := range matched.pending {
+		ingredient, err := db.GetIngredientById(r.DB_POOL, pending.Line.IngredientID, ctx)
+		if err != nil {
+			return nil, err
+		}
+		created = append(created, ingredient)
+	}
+	return created, nil
+}
+
+// Get the ingredients the user can see, keyed by their lowercased base name. Where several
+// share a name, the user's own or the household's is preferred.
+//
+// Parameters:
+//   - ctx: Request context
+//   - user: User looking up ingredients
+//   - householdID: Household whose ingredients are preferred, if any
+//
+// Returns:
+//   - Map of names to ingredients
+func (r *Resolver) knownIngredients(ctx context.Context, user *model.User, householdID *string) (map[string]*model.Ingredient, error) {
+	ingredients, err := db.GetIngredients(r.DB_POOL, ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	ingredients, err = r.visibleIngredients(ctx, ingredients)
+	if err != nil {
+		return nil, err
+	}
+	preferred := func(ingredient *model.Ingredient) bool {
+		if householdID != nil {
+			return ingredient.Household != nil && ingredient.Household.HouseholdID == *householdID
+		}
+		return ingredient.Household == nil && ingredient.User.UserID == user.UserID
+	}
+	known := map[string]*model.Ingredient{}
+	for _, ingredient := range ingredients {
+		key := strings.ToLower(baseName(ingredient.Name))
+		if existing, ok := known[key]; !ok || !preferred(existing) && preferred(ingredient) {
+			known[key] = ingredient
+		}
+	}
+	return known, nil
+}
+
+// Match parsed ingredient lines by name to ingredients the user can see, preferring their
+// own and the household's. Lines that match nothing get an ingredient to create, which
+// db.CreateRecipe and db.UpdateRecipe do in the same transaction as the recipe.
+// A recipe lists each ingredient once, so lines for the same ingredient are folded together.
+//
+// Parameters:
+//   - ctx: Request context
+//   - user: User the lines are for
+//   - householdID: Household to place new ingredients in, if any
+//   - existing: Lines already chosen by ingredient ID, which parsed lines are added to
+//   - parsed: Parsed lines to match
+//
+// Returns:
+//   - The existing and matched lines, with the ingredients to create and any lines that were dropped
+func (r *Resolver) matchIngredientLines(ctx context.Context, user *model.User, householdID *string, existing []*model.ExistingIngredientID, parsed []ingredientline.Line) (*matchedLines, error) {
+	result := matchedLines{lines: append([]*model.ExistingIngredientID{}, existing...)}
+	byIngredient := map[string]*model.ExistingIngredientID{}
+	for _, line := range existing {
+		byIngredient[line.IngredientID] = line
+	}
+	if len(parsed) == 0 {
+		return &result, nil
+	}
+
+	known, err := r.knownIngredients(ctx, user, householdID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Lines for ingredients still to be created, keyed by their lowercased name
+	unmatched := map[string]*model.ExistingIngredientID{}
+	unmatchedNames := []string{}
+	for _, line := range parsed {
+		name := baseName(line.Name)
+		if name == "" {
+			result.warnings = append(result.warnings, fmt.Sprintf("skipped ingredient line %q with no name", line.Text))
+			continue
+		}
+
+		ingredientID := ""
+		var previous *model.ExistingIngredientID
+		for _, key := range matchKeys(name) {
+			if ingredient := known[key]; ingredient != nil {
+				ingredientID = ingredient.IngredientID
+				previous = byIngredient[ingredientID]
+				break
+			}
+			if previous = unmatched[key]; previous != nil {
+				break
+			}
+		}
+		if previous != nil {
+			mergeLine(previous, line)
+			continue
+		}
+
+		matched := lineInput(ingredientID, line)
+		if ingredientID == "" {
+			unmatched[strings.ToLower(name)] = matched
+			unmatchedNames = append(unmatchedNames, name)
+		} else {
+			byIngredient[ingredientID] = matched
+		}
+		result.lines = append(result.lines, matched)
+	}
+
+	for _, name := range unmatchedNames {
+		result.pending = append(result.pending, &db.PendingIngredient{
+			Input: model.NewIngredient{
+				Name:        truncate(name, maxRecipeText),
+				Description: "",
+				UserID:      user.UserID,
+				HouseholdID: householdID,
+			},
+			Line: unmatched[strings.ToLower(name)],
+		})
+	}
+	return &result, nil
+}
+
+// Build the recipe line for a parsed ingredient line.
+//
+// Parameters:
+//   - ingredientID: ID of the ingredient the line was matched to
+//   - line: Parsed line
+//
+// Returns:
+//   - The recipe line
+func lineInput(ingredientID string, line ingredientline.Line) *model.ExistingIngredientID {
+	matched := model.ExistingIngredientID{
+		IngredientID: ingredientID,
+		Quantity:     line.Quantity,
+		Unit:         line.Unit,
+		Preparation:  optionalText(line.Preparation),
+		Notes:        optionalText(line.Notes),
+	}
+	// Lines keep a single quantity, so the lower end of a range is used and the range noted
+	if line.Quantity != nil && line.MaxQuantity != nil {
+		notes := exporter.FormatQuantity(*line.Quantity) + " to " + exporter.FormatQuantity(*line.MaxQuantity)
+		if line.Notes != "" {
+			notes += "; " + line.Notes
+		}
+		matched.Notes = &notes
+	}
+	return &matched
+}
+
+// Parse plain text ingredient lines, such as those given to createRecipe.
+//
+// Parameters:
+//   - texts: Ingredient lines
+//
+// Returns:
+//   - Parsed lines
+func parseIngredientLines(texts []string) []ingredientline.Line {
+	lines := []ingredientline.Line{}
+	for _, text := range texts {
+		lines = append(lines, ingredientline.Parse(text))
+	}
+	return lines
+}
+
+// Describe a parsed ingredient line for the API.
+//
+// Parameters:
+//   - line: Parsed line
+//
+// Returns:
+//   - The line's parts
+func parsedIngredientLine(line ingredientline.Line) *model.ParsedIngredientLine {
+	return &model.ParsedIngredientLine{
+		Text:        line.Text,
+		Quantity:    line.Quantity,
+		MaxQuantity: line.MaxQuantity,
+		Unit:        line.Unit,
+		Name:        line.Name,
+		Preparation: optionalText(line.Preparation),
+		Notes:       optionalText(line.Notes),
+	}
+}
+
+// Turn empty text into nil, for optional fields.
+func optionalText(text string) *string {
+	if text == "" {
+		return nil
+	}
+	return &text
+}
+
+// Largest pasted recipe parseRecipeText reads, in bytes.
+const maxPastedRecipe = 64 << 10
+
+// Build a draft recipe from pasted text, for the user to confirm before passing it to createRecipe.
+// Names and descriptions longer than a recipe can hold are shortened and marked as low confidence.
+//
+// Parameters:
+//   - text: Pasted recipe
+//
+// Returns:
+//   - The draft
+func recipeDraft(text string) (*model.RecipeDraft, error) {
+	if len(text) > maxPastedRecipe {
+		return nil, apperr.Invalid("text is longer than %d KB", maxPastedRecipe>>10)
+	}
+	recipe, markers := importer.FromText(text)
+
+	draft := &model.RecipeDraft{
+		Name:            truncate(recipe.Name, maxRecipeText),
+		Description:     truncate(recipe.Description, maxRecipeText),
+		IngredientLines: []string{},
+		Ingredients:     []*model.ParsedIngredientLine{},
+		Steps:           []*model.RecipeDraftStep{},
+		Servings:        recipe.Servings,
+		PrepTime:        recipe.PrepTime,
+		CookTime:        recipe.CookTime,
+		RestTime:        recipe.RestTime,
+		Confidence:      []*model.DraftConfidence{},
+	}
+	for _, line := range recipe.Ingredients {
+		draft.IngredientLines = append(draft.IngredientLines, line.Text)
+		draft.Ingredients = append(draft.Ingredients, parsedIngredientLine(line))
+	}
+	for _, step := range recipe.Steps {
+		draft.Steps = append(draft.Steps, &model.RecipeDraftStep{Text: step.Text, Duration: step.Duration})
+	}
+
+	for _, marker := range markers {
+		draft.Confidence = append(draft.Confidence, &model.DraftConfidence{
+			Field:      model.RecipeDraftField(marker.Field),
+			Confidence: model.Confidence(marker.Confidence),
+			Note:       marker.Note,
+		})
+	}
+	for _, field := range []struct {
+		name  model.RecipeDraftField
+		value string
+	}{
+		{model.RecipeDraftFieldName, recipe.Name},
+		{model.RecipeDraftFieldDescription, recipe.Description},
+	} {
+		if len([]rune(field.value)) > maxRecipeText {
+			draft.Confidence = append(draft.Confidence, &model.DraftConfidence{
+				Field:      field.name,
+				Confidence: model.ConfidenceLow,
+				Note:       fmt.Sprintf("shortened to %d characters", maxRecipeText),
+			})
+		}
+	}
+	return draft, nil
+}
+
+// Store a photo that came with an imported recipe and make it the recipe's image.
+//
+// Parameters:
+//   - ctx: Request context
+//   - userID: ID of the user importing the recipe
+//   - recipeID: ID of the imported recipe
+//   - photo: Image data
+//
+// Returns:
+//   - Error if the photo could not be stored
+func (r *Resolver) attachImportedPhoto(ctx context.Context, userID string, recipeID string, photo []byte) error {
+	image, err := r.storeImage(ctx, userID, graphql.Upload{File: bytes.NewReader(photo), Size: int64(len(photo))})
+	if err != nil {
+		return err
+	}
+	if _, err := db.SetRecipeImage(r.DB_POOL, ctx, recipeID, &image.ImageID); err != nil {
+		r.discardImage(ctx, image.ImageID)
+		return err
+	}
+	return nil
+}
+
+// Fold a repeated line for an ingredient into the first line for it. Amounts are added when
+// they convert and the preparations agree; otherwise the repeated line is kept in the notes,
+// e.g. "plus 100 g flour". Preparation and notes the first line lacks are taken from the
+// repeated one, so "salt" and "salt, to taste" become "salt (to taste)".
+//
+// Parameters:
+//   - line: Line already in the recipe
+//   - repeated: Repeated line for the same ingredient
+func mergeLine(line *model.ExistingIngredientID, repeated ingredientline.Line) {
+	notes := []string{}
+	if line.Notes != nil {
+		notes = append(notes, *line.Notes)
+	}
+	from, to := "", ""
+	if repeated.Unit != nil {
+		from = *repeated.Unit
+	}
+	if line.Unit != nil {
+		to = *line.Unit
+	}
+	folded := repeated.Preparation == "" || line.Preparation == nil || *line.Preparation == repeated.Preparation
+	switch {
+	case !folded || repeated.Quantity == nil:
+	case line.Quantity == nil && repeated.MaxQuantity == nil:
+		line.Quantity = repeated.Quantity
+		line.Unit = repeated.Unit
+	default:
+		converted, ok := units.ConvertNamed(*repeated.Quantity, from, to)
+		if ok && repeated.MaxQuantity == nil && line.Quantity != nil {
+			total := *line.Quantity + converted
+			line.Quantity = &total
+		} else {
+			folded = false
+		}
+	}
+
+	if !folded {
+		notes = append(notes, "plus "+repeated.Text)
+	} else {
+		if line.Preparation == nil {
+			line.Preparation = optionalText(repeated.Preparation)
+		}
+		if repeated.Notes != "" && !slices.Contains(notes, repeated.Notes) {
+			notes = append(notes, repeated.Notes)
+		}
+	}
+	line.Notes = optionalText(strings.Join(notes, "; "))
+}
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"testing"
+
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+)
+
+func TestMergeLine(t *testing.T) {
+	tests := []struct {
+		first    string
+		repeated string
+		want     string
+	}{
+		{"salt", "salt, to taste", "salt (to taste)"},
+		{"2 tbsp butter", "1/4 cup butter, melted", "6 tbsp butter, melted"},
+		{"butter", "2 tbsp butter", "2 tbsp butter"},
+		{"2 cups flour", "100 g flour", "2 cup flour (plus 100 g flour)"},
+		{"1 onion, diced", "1 onion, diced (for garnish)", "2 onion, diced (for garnish)"},
+		{"1 onion, diced", "1 onion, sliced (for garnish)", "1 onion, diced (plus 1 onion, sliced (for garnish))"},
+		{"2 cups flour", "100 g flour (sifted)", "2 cup flour (plus 100 g flour (sifted))"},
+		{"2 eggs", "2-3 eggs", "2 eggs (plus 2-3 eggs)"},
+	}
+	for _, test := range tests {
+		first := ingredientline.Parse(test.first)
+		line := lineInput("1", first)
+		mergeLine(line, ingredientline.Parse(test.repeated))
+
+		got := ""
+		if line.Quantity != nil {
+			got += exporter.FormatQuantity(*line.Quantity) + " "
+		}
+		if line.Unit != nil {
+			got += *line.Unit + " "
+		}
+		got += first.Name
+		if line.Preparation != nil {
+			got += ", " + *line.Preparation
+		}
+		if line.Notes != nil {
+			got += " (" + *line.Notes + ")"
+		}
+		if got != test.want {
+			t.Errorf("merging %q into %q gave %q; want %q", test.repeated, test.first, got, test.want)
+		}
+	}
+}
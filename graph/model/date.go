@@ -0,0 +1,45 @@
+package model
+
+import (
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/zldobbs/ambrosia-server/apperr"
+)
+
+// Layout used for the Date scalar.
+const DateFormat = "2006-01-02"
+
+// Marshal a Date scalar as an ISO-8601 calendar date.
+//
+// Parameters:
+//   - t: Date to marshal; the time of day is ignored
+//
+// Returns:
+//   - GraphQL marshaler writing the date as a string
+func MarshalDate(t time.Time) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, strconv.Quote(t.Format(DateFormat)))
+	})
+}
+
+// Unmarshal a Date scalar from an ISO-8601 calendar date.
+//
+// Parameters:
+//   - v: Raw input value
+//
+// Returns:
+//   - Parsed date at midnight UTC
+func UnmarshalDate(v interface{}) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, apperr.Invalid("date must be a string in YYYY-MM-DD format")
+	}
+	t, err := time.Parse(DateFormat, s)
+	if err != nil {
+		return time.Time{}, apperr.Invalid("date must be in YYYY-MM-DD format: %v", err)
+	}
+	return t, nil
+}
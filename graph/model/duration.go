@@ -0,0 +1,124 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/zldobbs/ambrosia-server/apperr"
+)
+
+// Format a duration in ISO-8601, e.g. PT1H30M. Days are folded into hours,
+// as schema.org recipes do.
+//
+// Parameters:
+//   - d: Duration to format
+//
+// Returns:
+//   - ISO-8601 duration string
+func FormatDuration(d time.Duration) string {
+	if d <= 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString("PT")
+	if hours := d / time.Hour; hours > 0 {
+		fmt.Fprintf(&b, "%dH", hours)
+		d -= hours * time.Hour
+	}
+	if minutes := d / time.Minute; minutes > 0 {
+		fmt.Fprintf(&b, "%dM", minutes)
+		d -= minutes * time.Minute
+	}
+	if d > 0 {
+		b.WriteString(strconv.FormatFloat(d.Seconds(), 'f', -1, 64) + "S")
+	}
+	return b.String()
+}
+
+// Parse an ISO-8601 duration such as PT45M or P1DT2H.
+// Years and months are rejected since their length varies.
+//
+// Parameters:
+//   - s: ISO-8601 duration string
+//
+// Returns:
+//   - Parsed duration
+func ParseDuration(s string) (time.Duration, error) {
+	value := strings.ToUpper(strings.TrimSpace(s))
+	if !strings.HasPrefix(value, "P") || len(value) < 3 {
+		return 0, apperr.Invalid("invalid ISO-8601 duration %q", s)
+	}
+
+	var total time.Duration
+	inTime := false
+	number := ""
+	for _, c := range value[1:] {
+		switch {
+		case c == 'T':
+			if inTime || number != "" {
+				return 0, apperr.Invalid("invalid ISO-8601 duration %q", s)
+			}
+			inTime = true
+		case c >= '0' && c <= '9' || c == '.' || c == ',':
+			number += string(c)
+		default:
+			amount, err := strconv.ParseFloat(strings.ReplaceAll(number, ",", "."), 64)
+			if err != nil {
+				return 0, apperr.Invalid("invalid ISO-8601 duration %q", s)
+			}
+			var unit time.Duration
+			switch {
+			case c == 'W' && !inTime:
+				unit = 7 * 24 * time.Hour
+			case c == 'D' && !inTime:
+				unit = 24 * time.Hour
+			case c == 'H' && inTime:
+				unit = time.Hour
+			case c == 'M' && inTime:
+				unit = time.Minute
+			case c == 'S' && inTime:
+				unit = time.Second
+			default:
+				return 0, apperr.Invalid("unsupported ISO-8601 duration %q; use weeks, days, hours, minutes or seconds", s)
+			}
+			total += time.Duration(amount * float64(unit))
+			number = ""
+		}
+	}
+	if number != "" {
+		return 0, apperr.Invalid("invalid ISO-8601 duration %q", s)
+	}
+	return total, nil
+}
+
+// Marshal a Duration scalar as an ISO-8601 duration.
+//
+// Parameters:
+//   - d: Duration to marshal
+//
+// Returns:
+//   - GraphQL marshaler writing the duration as a string
+func MarshalDuration(d time.Duration) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, strconv.Quote(FormatDuration(d)))
+	})
+}
+
+// Unmarshal a Duration scalar from an ISO-8601 duration.
+//
+// Parameters:
+//   - v: Raw input value
+//
+// Returns:
+//   - Parsed duration
+func UnmarshalDuration(v interface{}) (time.Duration, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, apperr.Invalid("duration must be an ISO-8601 string, e.g. PT30M")
+	}
+	return ParseDuration(s)
+}
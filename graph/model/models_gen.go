@@ -2,45 +2,983 @@
 
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type AccountDeletion struct {
+	UserID                 string `json:"userId"`
+	Reassigned             int    `json:"reassigned"`
+	HouseholdsTransferred  int    `json:"householdsTransferred"`
+	HouseholdsDeleted      int    `json:"householdsDeleted"`
+	IngredientsCopied      int    `json:"ingredientsCopied"`
+	MealPlanEntriesRemoved int    `json:"mealPlanEntriesRemoved"`
+}
+
+type AppliedSubstitution struct {
+	Original     *RecipeIngredient `json:"original"`
+	Substitution *Substitution     `json:"substitution"`
+}
+
+type DataExport struct {
+	URL             string `json:"url"`
+	Recipes         int    `json:"recipes"`
+	Ingredients     int    `json:"ingredients"`
+	MealPlanEntries int    `json:"mealPlanEntries"`
+	ShoppingLists   int    `json:"shoppingLists"`
+	PantryItems     int    `json:"pantryItems"`
+	Prices          int    `json:"prices"`
+	Images          int    `json:"images"`
+}
+
+type DraftConfidence struct {
+	Field      RecipeDraftField `json:"field"`
+	Confidence Confidence       `json:"confidence"`
+	Note       string           `json:"note"`
+}
+
 type ExistingIngredientID struct {
-	IngredientID string `json:"ingredientId"`
+	IngredientID string   `json:"ingredientId"`
+	Quantity     *float64 `json:"quantity,omitempty"`
+	Unit         *string  `json:"unit,omitempty"`
+	Preparation  *string  `json:"preparation,omitempty"`
+	Notes        *string  `json:"notes,omitempty"`
+}
+
+type Food struct {
+	FdcID       string     `json:"fdcId"`
+	Description string     `json:"description"`
+	DataType    *string    `json:"dataType,omitempty"`
+	Category    *string    `json:"category,omitempty"`
+	Nutrition   *Nutrition `json:"nutrition"`
+}
+
+type GenerateShoppingList struct {
+	Name           string     `json:"name"`
+	RecipeIds      []string   `json:"recipeIds,omitempty"`
+	MealPlanFrom   *time.Time `json:"mealPlanFrom,omitempty"`
+	MealPlanTo     *time.Time `json:"mealPlanTo,omitempty"`
+	HouseholdID    *string    `json:"householdId,omitempty"`
+	SubtractPantry *bool      `json:"subtractPantry,omitempty"`
+}
+
+type Household struct {
+	HouseholdID string             `json:"householdId"`
+	Name        string             `json:"name"`
+	Members     []*HouseholdMember `json:"members"`
+}
+
+type HouseholdInvitation struct {
+	InvitationID string           `json:"invitationId"`
+	Household    *Household       `json:"household"`
+	User         *User            `json:"user"`
+	InvitedBy    *User            `json:"invitedBy"`
+	Role         HouseholdRole    `json:"role"`
+	Status       InvitationStatus `json:"status"`
+}
+
+type HouseholdMember struct {
+	User *User         `json:"user"`
+	Role HouseholdRole `json:"role"`
+}
+
+type Image struct {
+	ImageID      string `json:"imageId"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+	ContentType  string `json:"contentType"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
 }
 
 type Ingredient struct {
-	IngredientID string `json:"ingredientId"`
-	Name         string `json:"name"`
-	Description  string `json:"description"`
-	User         *User  `json:"user"`
+	IngredientID string             `json:"ingredientId"`
+	Name         string             `json:"name"`
+	Description  string             `json:"description"`
+	Category     *string            `json:"category,omitempty"`
+	User         *User              `json:"user"`
+	Household    *Household         `json:"household,omitempty"`
+	GramsPerMl   *float64           `json:"gramsPerMl,omitempty"`
+	GramsEach    *float64           `json:"gramsEach,omitempty"`
+	Allergens    []Allergen         `json:"allergens"`
+	Diets        []Diet             `json:"diets"`
+	Nutrition    *Nutrition         `json:"nutrition,omitempty"`
+	Food         *Food              `json:"food,omitempty"`
+	FoodMatches  []*Food            `json:"foodMatches"`
+	Substitutes  []*Substitution    `json:"substitutes"`
+	PriceHistory []*IngredientPrice `json:"priceHistory"`
+}
+
+type IngredientPrice struct {
+	PriceID    string      `json:"priceId"`
+	Ingredient *Ingredient `json:"ingredient"`
+	Store      *string     `json:"store,omitempty"`
+	Price      float64     `json:"price"`
+	Quantity   float64     `json:"quantity"`
+	Unit       *string     `json:"unit,omitempty"`
+	PricedOn   time.Time   `json:"pricedOn"`
+	User       *User       `json:"user"`
+	Household  *Household  `json:"household,omitempty"`
+}
+
+type MealPlanEntry struct {
+	MealPlanEntryID string     `json:"mealPlanEntryId"`
+	Date            time.Time  `json:"date"`
+	Slot            MealSlot   `json:"slot"`
+	Recipe          *Recipe    `json:"recipe"`
+	Servings        *int       `json:"servings,omitempty"`
+	Notes           string     `json:"notes"`
+	User            *User      `json:"user"`
+	Household       *Household `json:"household,omitempty"`
 }
 
 type Mutation struct {
 }
 
+type NewHousehold struct {
+	Name string `json:"name"`
+}
+
+type NewHouseholdInvitation struct {
+	HouseholdID string        `json:"householdId"`
+	UserName    string        `json:"userName"`
+	Role        HouseholdRole `json:"role"`
+}
+
 type NewIngredient struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	UserID      string `json:"userId"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Category    *string    `json:"category,omitempty"`
+	UserID      string     `json:"userId"`
+	HouseholdID *string    `json:"householdId,omitempty"`
+	GramsPerMl  *float64   `json:"gramsPerMl,omitempty"`
+	GramsEach   *float64   `json:"gramsEach,omitempty"`
+	Allergens   []Allergen `json:"allergens,omitempty"`
+	Diets       []Diet     `json:"diets,omitempty"`
+}
+
+type NewIngredientPrice struct {
+	IngredientID string     `json:"ingredientId"`
+	Store        *string    `json:"store,omitempty"`
+	Price        float64    `json:"price"`
+	Quantity     *float64   `json:"quantity,omitempty"`
+	Unit         *string    `json:"unit,omitempty"`
+	PricedOn     *time.Time `json:"pricedOn,omitempty"`
+	HouseholdID  *string    `json:"householdId,omitempty"`
+}
+
+type NewMealPlanEntry struct {
+	Date        time.Time `json:"date"`
+	Slot        MealSlot  `json:"slot"`
+	RecipeID    string    `json:"recipeId"`
+	Servings    *int      `json:"servings,omitempty"`
+	Notes       *string   `json:"notes,omitempty"`
+	HouseholdID *string   `json:"householdId,omitempty"`
+}
+
+type NewPantryItem struct {
+	IngredientID string     `json:"ingredientId"`
+	Quantity     *float64   `json:"quantity,omitempty"`
+	Unit         *string    `json:"unit,omitempty"`
+	PurchasedOn  *time.Time `json:"purchasedOn,omitempty"`
+	ExpiresOn    *time.Time `json:"expiresOn,omitempty"`
+	HouseholdID  *string    `json:"householdId,omitempty"`
 }
 
 type NewRecipe struct {
-	Name        string                  `json:"name"`
-	Description string                  `json:"description"`
-	Ingredients []*ExistingIngredientID `json:"ingredients"`
-	UserID      string                  `json:"userId"`
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description"`
+	Ingredients     []*ExistingIngredientID `json:"ingredients"`
+	IngredientLines []string                `json:"ingredientLines,omitempty"`
+	UserID          string                  `json:"userId"`
+	HouseholdID     *string                 `json:"householdId,omitempty"`
+	Servings        *int                    `json:"servings,omitempty"`
+	PrepTime        *time.Duration          `json:"prepTime,omitempty"`
+	CookTime        *time.Duration          `json:"cookTime,omitempty"`
+	RestTime        *time.Duration          `json:"restTime,omitempty"`
+	Steps           []*NewRecipeStep        `json:"steps,omitempty"`
+	SourceURL       *string                 `json:"sourceUrl,omitempty"`
+	Categories      []string                `json:"categories,omitempty"`
+	Rating          *int                    `json:"rating,omitempty"`
+}
+
+type NewRecipeStep struct {
+	Text     string         `json:"text"`
+	Duration *time.Duration `json:"duration,omitempty"`
+}
+
+type NewShoppingListItem struct {
+	Name         string   `json:"name"`
+	Quantity     *float64 `json:"quantity,omitempty"`
+	Unit         *string  `json:"unit,omitempty"`
+	Category     *string  `json:"category,omitempty"`
+	IngredientID *string  `json:"ingredientId,omitempty"`
+}
+
+type NewSubstitution struct {
+	IngredientID string                 `json:"ingredientId"`
+	Replacements []*NewSubstitutionPart `json:"replacements"`
+	Notes        *string                `json:"notes,omitempty"`
+}
+
+type NewSubstitutionPart struct {
+	IngredientID string   `json:"ingredientId"`
+	Ratio        *float64 `json:"ratio,omitempty"`
+}
+
+type NewWebhook struct {
+	URL    string         `json:"url"`
+	Events []WebhookEvent `json:"events"`
+}
+
+type Nutrition struct {
+	Calories       float64 `json:"calories"`
+	ProteinG       float64 `json:"proteinG"`
+	FatG           float64 `json:"fatG"`
+	SaturatedFatG  float64 `json:"saturatedFatG"`
+	CarbohydratesG float64 `json:"carbohydratesG"`
+	SugarG         float64 `json:"sugarG"`
+	FiberG         float64 `json:"fiberG"`
+	SodiumMg       float64 `json:"sodiumMg"`
+	CholesterolMg  float64 `json:"cholesterolMg"`
+	PotassiumMg    float64 `json:"potassiumMg"`
+	CalciumMg      float64 `json:"calciumMg"`
+	IronMg         float64 `json:"ironMg"`
+	VitaminAUg     float64 `json:"vitaminAUg"`
+	VitaminCMg     float64 `json:"vitaminCMg"`
+	VitaminDUg     float64 `json:"vitaminDUg"`
+}
+
+type NutritionInput struct {
+	Calories       *float64 `json:"calories,omitempty"`
+	ProteinG       *float64 `json:"proteinG,omitempty"`
+	FatG           *float64 `json:"fatG,omitempty"`
+	SaturatedFatG  *float64 `json:"saturatedFatG,omitempty"`
+	CarbohydratesG *float64 `json:"carbohydratesG,omitempty"`
+	SugarG         *float64 `json:"sugarG,omitempty"`
+	FiberG         *float64 `json:"fiberG,omitempty"`
+	SodiumMg       *float64 `json:"sodiumMg,omitempty"`
+	CholesterolMg  *float64 `json:"cholesterolMg,omitempty"`
+	PotassiumMg    *float64 `json:"potassiumMg,omitempty"`
+	CalciumMg      *float64 `json:"calciumMg,omitempty"`
+	IronMg         *float64 `json:"ironMg,omitempty"`
+	VitaminAUg     *float64 `json:"vitaminAUg,omitempty"`
+	VitaminCMg     *float64 `json:"vitaminCMg,omitempty"`
+	VitaminDUg     *float64 `json:"vitaminDUg,omitempty"`
+}
+
+type PantryItem struct {
+	PantryItemID string      `json:"pantryItemId"`
+	Ingredient   *Ingredient `json:"ingredient"`
+	Quantity     *float64    `json:"quantity,omitempty"`
+	Unit         *string     `json:"unit,omitempty"`
+	PurchasedOn  *time.Time  `json:"purchasedOn,omitempty"`
+	ExpiresOn    *time.Time  `json:"expiresOn,omitempty"`
+	User         *User       `json:"user"`
+	Household    *Household  `json:"household,omitempty"`
+}
+
+type ParsedIngredientLine struct {
+	Text        string   `json:"text"`
+	Quantity    *float64 `json:"quantity,omitempty"`
+	MaxQuantity *float64 `json:"maxQuantity,omitempty"`
+	Unit        *string  `json:"unit,omitempty"`
+	Name        string   `json:"name"`
+	Preparation *string  `json:"preparation,omitempty"`
+	Notes       *string  `json:"notes,omitempty"`
 }
 
 type Query struct {
 }
 
 type Recipe struct {
-	RecipeID    string        `json:"recipeId"`
-	Name        string        `json:"name"`
-	Description string        `json:"description"`
-	Ingredients []*Ingredient `json:"ingredients"`
-	User        *User         `json:"user"`
+	RecipeID            string              `json:"recipeId"`
+	Name                string              `json:"name"`
+	Description         string              `json:"description"`
+	Ingredients         []*Ingredient       `json:"ingredients"`
+	IngredientLines     []*RecipeIngredient `json:"ingredientLines"`
+	User                *User               `json:"user"`
+	Household           *Household          `json:"household,omitempty"`
+	Servings            *int                `json:"servings,omitempty"`
+	PrepTime            *time.Duration      `json:"prepTime,omitempty"`
+	CookTime            *time.Duration      `json:"cookTime,omitempty"`
+	RestTime            *time.Duration      `json:"restTime,omitempty"`
+	TotalTime           *time.Duration      `json:"totalTime,omitempty"`
+	Steps               []*RecipeStep       `json:"steps"`
+	Image               *Image              `json:"image,omitempty"`
+	SourceURL           *string             `json:"sourceUrl,omitempty"`
+	Categories          []string            `json:"categories"`
+	Rating              *int                `json:"rating,omitempty"`
+	JSONLd              string              `json:"jsonLd"`
+	Markdown            string              `json:"markdown"`
+	Cooklang            string              `json:"cooklang"`
+	Allergens           []Allergen          `json:"allergens"`
+	DietaryLabels       []Diet              `json:"dietaryLabels"`
+	Nutrition           *RecipeNutrition    `json:"nutrition"`
+	NutritionPerServing *RecipeNutrition    `json:"nutritionPerServing,omitempty"`
+	SubstitutionsFor    *SubstitutionPlan   `json:"substitutionsFor"`
+	EstimatedCost       *RecipeCost         `json:"estimatedCost"`
+}
+
+type RecipeArchiveImport struct {
+	Results  []*RecipeArchiveResult `json:"results"`
+	Imported int                    `json:"imported"`
+	Failed   int                    `json:"failed"`
+}
+
+type RecipeArchiveResult struct {
+	Name  string        `json:"name"`
+	Saved *RecipeImport `json:"saved,omitempty"`
+	Error *string       `json:"error,omitempty"`
+}
+
+type RecipeCost struct {
+	Total            float64             `json:"total"`
+	PerServing       *float64            `json:"perServing,omitempty"`
+	UnconvertedLines []*RecipeIngredient `json:"unconvertedLines"`
+	MissingPrices    []*Ingredient       `json:"missingPrices"`
+}
+
+type RecipeDraft struct {
+	Name            string                  `json:"name"`
+	Description     string                  `json:"description"`
+	IngredientLines []string                `json:"ingredientLines"`
+	Ingredients     []*ParsedIngredientLine `json:"ingredients"`
+	Steps           []*RecipeDraftStep      `json:"steps"`
+	Servings        *int                    `json:"servings,omitempty"`
+	PrepTime        *time.Duration          `json:"prepTime,omitempty"`
+	CookTime        *time.Duration          `json:"cookTime,omitempty"`
+	RestTime        *time.Duration          `json:"restTime,omitempty"`
+	Confidence      []*DraftConfidence      `json:"confidence"`
+}
+
+type RecipeDraftStep struct {
+	Text     string         `json:"text"`
+	Duration *time.Duration `json:"duration,omitempty"`
+}
+
+type RecipeFilter struct {
+	ExcludeAllergens []Allergen     `json:"excludeAllergens,omitempty"`
+	Diet             *Diet          `json:"diet,omitempty"`
+	MaxTotalTime     *time.Duration `json:"maxTotalTime,omitempty"`
+}
+
+type RecipeImport struct {
+	Recipe             *Recipe       `json:"recipe"`
+	CreatedIngredients []*Ingredient `json:"createdIngredients"`
+	ImageUrls          []string      `json:"imageUrls"`
+	Warnings           []string      `json:"warnings"`
+}
+
+type RecipeIngredient struct {
+	Ingredient  *Ingredient `json:"ingredient"`
+	Quantity    *float64    `json:"quantity,omitempty"`
+	Unit        *string     `json:"unit,omitempty"`
+	Preparation *string     `json:"preparation,omitempty"`
+	Notes       *string     `json:"notes,omitempty"`
+}
+
+type RecipeNutrition struct {
+	Nutrients        *Nutrition          `json:"nutrients"`
+	UnconvertedLines []*RecipeIngredient `json:"unconvertedLines"`
+	MissingNutrition []*Ingredient       `json:"missingNutrition"`
+}
+
+type RecipeStep struct {
+	Position int            `json:"position"`
+	Text     string         `json:"text"`
+	Duration *time.Duration `json:"duration,omitempty"`
+	Image    *Image         `json:"image,omitempty"`
+}
+
+type ShoppingList struct {
+	ShoppingListID string                  `json:"shoppingListId"`
+	Name           string                  `json:"name"`
+	Items          []*ShoppingListItem     `json:"items"`
+	Categories     []*ShoppingListCategory `json:"categories"`
+	User           *User                   `json:"user"`
+	Household      *Household              `json:"household,omitempty"`
+	CreatedAt      time.Time               `json:"createdAt"`
+}
+
+type ShoppingListCategory struct {
+	Category string              `json:"category"`
+	Items    []*ShoppingListItem `json:"items"`
+}
+
+type ShoppingListItem struct {
+	ShoppingListItemID string      `json:"shoppingListItemId"`
+	Ingredient         *Ingredient `json:"ingredient,omitempty"`
+	Name               string      `json:"name"`
+	Quantity           *float64    `json:"quantity,omitempty"`
+	Unit               *string     `json:"unit,omitempty"`
+	Category           string      `json:"category"`
+	Checked            bool        `json:"checked"`
+}
+
+type Subscription struct {
+}
+
+type Substitution struct {
+	SubstitutionID string              `json:"substitutionId"`
+	Ingredient     *Ingredient         `json:"ingredient"`
+	Replacements   []*SubstitutionPart `json:"replacements"`
+	Notes          string              `json:"notes"`
+	User           *User               `json:"user,omitempty"`
+}
+
+type SubstitutionPart struct {
+	Ingredient *Ingredient `json:"ingredient"`
+	Ratio      float64     `json:"ratio"`
+}
+
+type SubstitutionPlan struct {
+	Lines      []*RecipeIngredient    `json:"lines"`
+	Applied    []*AppliedSubstitution `json:"applied"`
+	Unresolved []*RecipeIngredient    `json:"unresolved"`
+}
+
+type UpdateIngredient struct {
+	Name        *string    `json:"name,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Category    *string    `json:"category,omitempty"`
+	GramsPerMl  *float64   `json:"gramsPerMl,omitempty"`
+	GramsEach   *float64   `json:"gramsEach,omitempty"`
+	Allergens   []Allergen `json:"allergens,omitempty"`
+	Diets       []Diet     `json:"diets,omitempty"`
+}
+
+type UpdatePantryItem struct {
+	Quantity    *float64   `json:"quantity,omitempty"`
+	Unit        *string    `json:"unit,omitempty"`
+	PurchasedOn *time.Time `json:"purchasedOn,omitempty"`
+	ExpiresOn   *time.Time `json:"expiresOn,omitempty"`
+}
+
+type UpdateRecipe struct {
+	Name            *string                 `json:"name,omitempty"`
+	Description     *string                 `json:"description,omitempty"`
+	Ingredients     []*ExistingIngredientID `json:"ingredients,omitempty"`
+	IngredientLines []string                `json:"ingredientLines,omitempty"`
+	HouseholdID     *string                 `json:"householdId,omitempty"`
+	Servings        *int                    `json:"servings,omitempty"`
+	PrepTime        *time.Duration          `json:"prepTime,omitempty"`
+	CookTime        *time.Duration          `json:"cookTime,omitempty"`
+	RestTime        *time.Duration          `json:"restTime,omitempty"`
+	Steps           []*NewRecipeStep        `json:"steps,omitempty"`
+	Categories      []string                `json:"categories,omitempty"`
+	Rating          *int                    `json:"rating,omitempty"`
+}
+
+type UpdateShoppingListItem struct {
+	Name     *string  `json:"name,omitempty"`
+	Quantity *float64 `json:"quantity,omitempty"`
+	Unit     *string  `json:"unit,omitempty"`
+	Category *string  `json:"category,omitempty"`
+	Checked  *bool    `json:"checked,omitempty"`
+}
+
+type UpdateWebhook struct {
+	URL    *string        `json:"url,omitempty"`
+	Events []WebhookEvent `json:"events,omitempty"`
+	Active *bool          `json:"active,omitempty"`
 }
 
 type User struct {
 	UserID string `json:"userId"`
 	Name   string `json:"name"`
 }
+
+type Webhook struct {
+	WebhookID  string             `json:"webhookId"`
+	URL        string             `json:"url"`
+	Events     []WebhookEvent     `json:"events"`
+	Active     bool               `json:"active"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	Deliveries []*WebhookDelivery `json:"deliveries"`
+}
+
+type WebhookDelivery struct {
+	DeliveryID     string                `json:"deliveryId"`
+	Event          WebhookEvent          `json:"event"`
+	Payload        string                `json:"payload"`
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt,omitempty"`
+	LastStatusCode *int                  `json:"lastStatusCode,omitempty"`
+	LastError      *string               `json:"lastError,omitempty"`
+	CreatedAt      time.Time             `json:"createdAt"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty"`
+}
+
+type WebhookRegistration struct {
+	Webhook *Webhook `json:"webhook"`
+	Secret  string   `json:"secret"`
+}
+
+type Allergen string
+
+const (
+	AllergenCelery     Allergen = "CELERY"
+	AllergenGluten     Allergen = "GLUTEN"
+	AllergenCrustacean Allergen = "CRUSTACEAN"
+	AllergenEgg        Allergen = "EGG"
+	AllergenFish       Allergen = "FISH"
+	AllergenLupin      Allergen = "LUPIN"
+	AllergenMilk       Allergen = "MILK"
+	AllergenMollusc    Allergen = "MOLLUSC"
+	AllergenMustard    Allergen = "MUSTARD"
+	AllergenTreeNut    Allergen = "TREE_NUT"
+	AllergenPeanut     Allergen = "PEANUT"
+	AllergenSesame     Allergen = "SESAME"
+	AllergenSoy        Allergen = "SOY"
+	AllergenSulphite   Allergen = "SULPHITE"
+)
+
+var AllAllergen = []Allergen{
+	AllergenCelery,
+	AllergenGluten,
+	AllergenCrustacean,
+	AllergenEgg,
+	AllergenFish,
+	AllergenLupin,
+	AllergenMilk,
+	AllergenMollusc,
+	AllergenMustard,
+	AllergenTreeNut,
+	AllergenPeanut,
+	AllergenSesame,
+	AllergenSoy,
+	AllergenSulphite,
+}
+
+func (e Allergen) IsValid() bool {
+	switch e {
+	case AllergenCelery, AllergenGluten, AllergenCrustacean, AllergenEgg, AllergenFish, AllergenLupin, AllergenMilk, AllergenMollusc, AllergenMustard, AllergenTreeNut, AllergenPeanut, AllergenSesame, AllergenSoy, AllergenSulphite:
+		return true
+	}
+	return false
+}
+
+func (e Allergen) String() string {
+	return string(e)
+}
+
+func (e *Allergen) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Allergen(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Allergen", str)
+	}
+	return nil
+}
+
+func (e Allergen) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Confidence string
+
+const (
+	ConfidenceHigh   Confidence = "HIGH"
+	ConfidenceMedium Confidence = "MEDIUM"
+	ConfidenceLow    Confidence = "LOW"
+)
+
+var AllConfidence = []Confidence{
+	ConfidenceHigh,
+	ConfidenceMedium,
+	ConfidenceLow,
+}
+
+func (e Confidence) IsValid() bool {
+	switch e {
+	case ConfidenceHigh, ConfidenceMedium, ConfidenceLow:
+		return true
+	}
+	return false
+}
+
+func (e Confidence) String() string {
+	return string(e)
+}
+
+func (e *Confidence) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Confidence(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Confidence", str)
+	}
+	return nil
+}
+
+func (e Confidence) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Diet string
+
+const (
+	DietVegan      Diet = "VEGAN"
+	DietVegetarian Diet = "VEGETARIAN"
+	DietGlutenFree Diet = "GLUTEN_FREE"
+	DietDairyFree  Diet = "DAIRY_FREE"
+	DietHalal      Diet = "HALAL"
+	DietKosher     Diet = "KOSHER"
+)
+
+var AllDiet = []Diet{
+	DietVegan,
+	DietVegetarian,
+	DietGlutenFree,
+	DietDairyFree,
+	DietHalal,
+	DietKosher,
+}
+
+func (e Diet) IsValid() bool {
+	switch e {
+	case DietVegan, DietVegetarian, DietGlutenFree, DietDairyFree, DietHalal, DietKosher:
+		return true
+	}
+	return false
+}
+
+func (e Diet) String() string {
+	return string(e)
+}
+
+func (e *Diet) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Diet(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid Diet", str)
+	}
+	return nil
+}
+
+func (e Diet) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type HouseholdRole string
+
+const (
+	HouseholdRoleOwner  HouseholdRole = "OWNER"
+	HouseholdRoleEditor HouseholdRole = "EDITOR"
+	HouseholdRoleViewer HouseholdRole = "VIEWER"
+)
+
+var AllHouseholdRole = []HouseholdRole{
+	HouseholdRoleOwner,
+	HouseholdRoleEditor,
+	HouseholdRoleViewer,
+}
+
+func (e HouseholdRole) IsValid() bool {
+	switch e {
+	case HouseholdRoleOwner, HouseholdRoleEditor, HouseholdRoleViewer:
+		return true
+	}
+	return false
+}
+
+func (e HouseholdRole) String() string {
+	return string(e)
+}
+
+func (e *HouseholdRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = HouseholdRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid HouseholdRole", str)
+	}
+	return nil
+}
+
+func (e HouseholdRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type InvitationStatus string
+
+const (
+	InvitationStatusPending  InvitationStatus = "PENDING"
+	InvitationStatusAccepted InvitationStatus = "ACCEPTED"
+	InvitationStatusDeclined InvitationStatus = "DECLINED"
+)
+
+var AllInvitationStatus = []InvitationStatus{
+	InvitationStatusPending,
+	InvitationStatusAccepted,
+	InvitationStatusDeclined,
+}
+
+func (e InvitationStatus) IsValid() bool {
+	switch e {
+	case InvitationStatusPending, InvitationStatusAccepted, InvitationStatusDeclined:
+		return true
+	}
+	return false
+}
+
+func (e InvitationStatus) String() string {
+	return string(e)
+}
+
+func (e *InvitationStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = InvitationStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid InvitationStatus", str)
+	}
+	return nil
+}
+
+func (e InvitationStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type MealSlot string
+
+const (
+	MealSlotBreakfast MealSlot = "BREAKFAST"
+	MealSlotLunch     MealSlot = "LUNCH"
+	MealSlotDinner    MealSlot = "DINNER"
+	MealSlotSnack     MealSlot = "SNACK"
+)
+
+var AllMealSlot = []MealSlot{
+	MealSlotBreakfast,
+	MealSlotLunch,
+	MealSlotDinner,
+	MealSlotSnack,
+}
+
+func (e MealSlot) IsValid() bool {
+	switch e {
+	case MealSlotBreakfast, MealSlotLunch, MealSlotDinner, MealSlotSnack:
+		return true
+	}
+	return false
+}
+
+func (e MealSlot) String() string {
+	return string(e)
+}
+
+func (e *MealSlot) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MealSlot(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MealSlot", str)
+	}
+	return nil
+}
+
+func (e MealSlot) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type RecipeDraftField string
+
+const (
+	RecipeDraftFieldName        RecipeDraftField = "NAME"
+	RecipeDraftFieldDescription RecipeDraftField = "DESCRIPTION"
+	RecipeDraftFieldIngredients RecipeDraftField = "INGREDIENTS"
+	RecipeDraftFieldSteps       RecipeDraftField = "STEPS"
+	RecipeDraftFieldDetails     RecipeDraftField = "DETAILS"
+)
+
+var AllRecipeDraftField = []RecipeDraftField{
+	RecipeDraftFieldName,
+	RecipeDraftFieldDescription,
+	RecipeDraftFieldIngredients,
+	RecipeDraftFieldSteps,
+	RecipeDraftFieldDetails,
+}
+
+func (e RecipeDraftField) IsValid() bool {
+	switch e {
+	case RecipeDraftFieldName, RecipeDraftFieldDescription, RecipeDraftFieldIngredients, RecipeDraftFieldSteps, RecipeDraftFieldDetails:
+		return true
+	}
+	return false
+}
+
+func (e RecipeDraftField) String() string {
+	return string(e)
+}
+
+func (e *RecipeDraftField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RecipeDraftField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RecipeDraftField", str)
+	}
+	return nil
+}
+
+func (e RecipeDraftField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type RecipeSort string
+
+const (
+	RecipeSortName      RecipeSort = "NAME"
+	RecipeSortTotalTime RecipeSort = "TOTAL_TIME"
+)
+
+var AllRecipeSort = []RecipeSort{
+	RecipeSortName,
+	RecipeSortTotalTime,
+}
+
+func (e RecipeSort) IsValid() bool {
+	switch e {
+	case RecipeSortName, RecipeSortTotalTime:
+		return true
+	}
+	return false
+}
+
+func (e RecipeSort) String() string {
+	return string(e)
+}
+
+func (e *RecipeSort) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RecipeSort(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RecipeSort", str)
+	}
+	return nil
+}
+
+func (e RecipeSort) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatus = "PENDING"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatus = "SUCCEEDED"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatus = "FAILED"
+)
+
+var AllWebhookDeliveryStatus = []WebhookDeliveryStatus{
+	WebhookDeliveryStatusPending,
+	WebhookDeliveryStatusSucceeded,
+	WebhookDeliveryStatusFailed,
+}
+
+func (e WebhookDeliveryStatus) IsValid() bool {
+	switch e {
+	case WebhookDeliveryStatusPending, WebhookDeliveryStatusSucceeded, WebhookDeliveryStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e WebhookDeliveryStatus) String() string {
+	return string(e)
+}
+
+func (e *WebhookDeliveryStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookDeliveryStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookDeliveryStatus", str)
+	}
+	return nil
+}
+
+func (e WebhookDeliveryStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type WebhookEvent string
+
+const (
+	WebhookEventRecipeCreated   WebhookEvent = "RECIPE_CREATED"
+	WebhookEventRecipeUpdated   WebhookEvent = "RECIPE_UPDATED"
+	WebhookEventRecipeDeleted   WebhookEvent = "RECIPE_DELETED"
+	WebhookEventMealPlanChanged WebhookEvent = "MEAL_PLAN_CHANGED"
+)
+
+var AllWebhookEvent = []WebhookEvent{
+	WebhookEventRecipeCreated,
+	WebhookEventRecipeUpdated,
+	WebhookEventRecipeDeleted,
+	WebhookEventMealPlanChanged,
+}
+
+func (e WebhookEvent) IsValid() bool {
+	switch e {
+	case WebhookEventRecipeCreated, WebhookEventRecipeUpdated, WebhookEventRecipeDeleted, WebhookEventMealPlanChanged:
+		return true
+	}
+	return false
+}
+
+func (e WebhookEvent) String() string {
+	return string(e)
+}
+
+func (e *WebhookEvent) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = WebhookEvent(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid WebhookEvent", str)
+	}
+	return nil
+}
+
+func (e WebhookEvent) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
@@ -7,20 +7,81 @@ package graph
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 import (
+	"cmp"
+	"slices"
+	"strings"
+
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/blob"
+	"github.com/zldobbs/ambrosia-server/events"
+	"github.com/zldobbs/ambrosia-server/graph/model"
 )
 
 type Resolver struct {
-	DB_POOL *pgxpool.Pool
+	DB_POOL    *pgxpool.Pool
+	BLOB_STORE blob.Store
+	PUBLIC_URL string
+	EVENTS     *events.Broker
+	// Whether webhooks may be registered for loopback, private or link-local addresses
+	WEBHOOKS_ALLOW_PRIVATE bool
 }
 
 // Create a new Resolver with the SQL database connection
 //
 // Parameters:
 // 	- db: Connection to SQL database
+// 	- store: Blob store uploaded images are kept in
+// 	- publicURL: Absolute URL the server is reached at, used in exported links; may be empty
 //
 // Returns:
 // 	A GraphQL Resolver object with a connection to the SQL DB.
-func NewResolver(pool *pgxpool.Pool) *Resolver {
-	return &Resolver{DB_POOL: pool}
+// 	Subscriptions only receive changes while its EVENTS broker is listening.
+func NewResolver(pool *pgxpool.Pool, store blob.Store, publicURL string) *Resolver {
+	return &Resolver{DB_POOL: pool, BLOB_STORE: store, PUBLIC_URL: publicURL, EVENTS: events.NewBroker(pool)}
+}
+
+// Largest number of results a client may ask for from a search.
+const maxSearchLimit = 50
+
+// Bound an optional, client provided result limit.
+//
+// Parameters:
+// 	- limit: Requested limit, defaulting to 10 when nil
+//
+// Returns:
+// 	The limit to use.
+func clampLimit(limit *int) int {
+	if limit == nil {
+		return 10
+	}
+	return max(1, min(*limit, maxSearchLimit))
+}
+
+// Sort recipes in place. Recipes without a total time sort after those with one.
+//
+// Parameters:
+// 	- recipes: Recipes to sort
+// 	- sort: Order to sort them in
+func sortRecipes(recipes []*model.Recipe, sort model.RecipeSort) {
+	byName := func(a, b *model.Recipe) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	}
+	switch sort {
+	case model.RecipeSortName:
+		slices.SortStableFunc(recipes, byName)
+	case model.RecipeSortTotalTime:
+		slices.SortStableFunc(recipes, func(a, b *model.Recipe) int {
+			switch {
+			case a.TotalTime == nil && b.TotalTime == nil:
+				return byName(a, b)
+			case a.TotalTime == nil:
+				return 1
+			case b.TotalTime == nil:
+				return -1
+			case *a.TotalTime != *b.TotalTime:
+				return cmp.Compare(*a.TotalTime, *b.TotalTime)
+			}
+			return byName(a, b)
+		})
+	}
 }
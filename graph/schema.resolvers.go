@@ -7,96 +7,1177 @@ package graph
 import (
 	"context"
 	"fmt"
+	"io"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/cost"
 	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/dietary"
+	"github.com/zldobbs/ambrosia-server/exporter"
 	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/importer"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+	"github.com/zldobbs/ambrosia-server/nutrition"
 )
 
+// Members is the resolver for the members field.
+func (r *householdResolver) Members(ctx context.Context, obj *model.Household) ([]*model.HouseholdMember, error) {
+	if _, err := r.requireHouseholdRole(ctx, obj.HouseholdID, model.HouseholdRoleViewer); err != nil {
+		return nil, err
+	}
+	return db.GetHouseholdMembers(r.DB_POOL, ctx, obj.HouseholdID)
+}
+
+// Nutrition is the resolver for the nutrition field.
+func (r *ingredientResolver) Nutrition(ctx context.Context, obj *model.Ingredient) (*model.Nutrition, error) {
+	facts, err := db.GetIngredientNutrition(r.DB_POOL, ctx, []string{obj.IngredientID})
+	if err != nil {
+		return nil, err
+	}
+	return facts[obj.IngredientID], nil
+}
+
+// Food is the resolver for the food field.
+func (r *ingredientResolver) Food(ctx context.Context, obj *model.Ingredient) (*model.Food, error) {
+	return db.GetFoodForIngredient(r.DB_POOL, ctx, obj.IngredientID)
+}
+
+// FoodMatches is the resolver for the foodMatches field.
+func (r *ingredientResolver) FoodMatches(ctx context.Context, obj *model.Ingredient, limit *int) ([]*model.Food, error) {
+	return db.SearchFoods(r.DB_POOL, ctx, obj.Name, clampLimit(limit))
+}
+
+// Substitutes is the resolver for the substitutes field.
+func (r *ingredientResolver) Substitutes(ctx context.Context, obj *model.Ingredient) ([]*model.Substitution, error) {
+	substitutions, err := db.GetSubstitutions(r.DB_POOL, ctx, []string{obj.IngredientID})
+	if err != nil {
+		return nil, err
+	}
+	return r.visibleSubstitutions(ctx, substitutions[obj.IngredientID])
+}
+
+// PriceHistory is the resolver for the priceHistory field.
+func (r *ingredientResolver) PriceHistory(ctx context.Context, obj *model.Ingredient, householdID *string) ([]*model.IngredientPrice, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetIngredientPrices(r.DB_POOL, ctx, user.UserID, householdID, obj.IngredientID)
+}
+
 // CreateIngredient is the resolver for the createIngredient field.
 func (r *mutationResolver) CreateIngredient(ctx context.Context, input model.NewIngredient) (*model.Ingredient, error) {
-	// Create ingredient
-	row := r.DB_POOL.QueryRow(
-		ctx,
-		`
-		INSERT INTO ingredient (name, description, user_id)
-		VALUES ($1, $2, $3)
-		RETURNING ingredient_id::TEXT
-		`,
-		input.Name,
-		input.Description,
-		input.UserID,
-	)
+	if _, err := r.authorizeCreate(ctx, input.UserID, input.HouseholdID); err != nil {
+		return nil, err
+	}
 
-	var ingredient_id string
-	err := row.Scan(&ingredient_id)
+	ingredient_id, err := db.CreateIngredient(r.DB_POOL, ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ingredient from %v, error: %v", input, err)
+		return nil, err
 	}
-
 	return db.GetIngredientById(r.DB_POOL, ingredient_id, ctx)
 }
 
+// UpdateIngredient is the resolver for the updateIngredient field.
+func (r *mutationResolver) UpdateIngredient(ctx context.Context, ingredientID string, input model.UpdateIngredient) (*model.Ingredient, error) {
+	ingredient, err := db.GetIngredientById(r.DB_POOL, ingredientID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeWrite(ctx, ingredient.User, ingredient.Household); err != nil {
+		return nil, err
+	}
+
+	if err := db.UpdateIngredient(r.DB_POOL, ctx, ingredientID, input); err != nil {
+		return nil, err
+	}
+
+	return db.GetIngredientById(r.DB_POOL, ingredientID, ctx)
+}
+
+// DeleteIngredient is the resolver for the deleteIngredient field.
+func (r *mutationResolver) DeleteIngredient(ctx context.Context, ingredientID string) (string, error) {
+	ingredient, err := db.GetIngredientById(r.DB_POOL, ingredientID, ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.authorizeWrite(ctx, ingredient.User, ingredient.Household); err != nil {
+		return "", err
+	}
+
+	if err := db.DeleteIngredient(r.DB_POOL, ctx, ingredientID); err != nil {
+		return "", err
+	}
+	return ingredientID, nil
+}
+
+// SetIngredientNutrition is the resolver for the setIngredientNutrition field.
+func (r *mutationResolver) SetIngredientNutrition(ctx context.Context, ingredientID string, input model.NutritionInput) (*model.Ingredient, error) {
+	ingredient, err := db.GetIngredientById(r.DB_POOL, ingredientID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeWrite(ctx, ingredient.User, ingredient.Household); err != nil {
+		return nil, err
+	}
+
+	err = db.SetIngredientNutrition(r.DB_POOL, ctx, ingredientID, input)
+	if err != nil {
+		return nil, err
+	}
+	return ingredient, nil
+}
+
+// LinkIngredientToFood is the resolver for the linkIngredientToFood field.
+func (r *mutationResolver) LinkIngredientToFood(ctx context.Context, ingredientID string, fdcID string) (*model.Ingredient, error) {
+	ingredient, err := db.GetIngredientById(r.DB_POOL, ingredientID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeWrite(ctx, ingredient.User, ingredient.Household); err != nil {
+		return nil, err
+	}
+	if _, err := db.GetFoodById(r.DB_POOL, fdcID, ctx); err != nil {
+		return nil, err
+	}
+
+	err = db.LinkIngredientToFood(r.DB_POOL, ctx, ingredientID, fdcID)
+	if err != nil {
+		return nil, err
+	}
+	return ingredient, nil
+}
+
+// CreateSubstitution is the resolver for the createSubstitution field.
+func (r *mutationResolver) CreateSubstitution(ctx context.Context, input model.NewSubstitution) (*model.Substitution, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(input.Replacements) == 0 {
+		return nil, apperr.Invalid("a substitution needs at least one replacement")
+	}
+	used := []*model.ExistingIngredientID{{IngredientID: input.IngredientID}}
+	for _, part := range input.Replacements {
+		if part.IngredientID == input.IngredientID {
+			return nil, apperr.Invalid("an ingredient cannot be substituted with itself")
+		}
+		if part.Ratio != nil && *part.Ratio <= 0 {
+			return nil, apperr.Invalid("substitution ratios must be positive")
+		}
+		used = append(used, &model.ExistingIngredientID{IngredientID: part.IngredientID})
+	}
+	if err := r.authorizeIngredientUse(ctx, used); err != nil {
+		return nil, err
+	}
+
+	substitutionID, err := db.CreateSubstitution(r.DB_POOL, ctx, user.UserID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetSubstitutionById(r.DB_POOL, substitutionID, ctx)
+}
+
+// DeleteSubstitution is the resolver for the deleteSubstitution field.
+func (r *mutationResolver) DeleteSubstitution(ctx context.Context, substitutionID string) (string, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return "", err
+	}
+	substitution, err := db.GetSubstitutionById(r.DB_POOL, substitutionID, ctx)
+	if err != nil {
+		return "", err
+	}
+	if substitution.User == nil || substitution.User.UserID != user.UserID {
+		return "", apperr.Forbidden("not authorized to delete substitution %s", substitutionID)
+	}
+
+	err = db.DeleteSubstitution(r.DB_POOL, ctx, substitutionID)
+	if err != nil {
+		return "", err
+	}
+	return substitutionID, nil
+}
+
 // CreateRecipe is the resolver for the createRecipe field.
 func (r *mutationResolver) CreateRecipe(ctx context.Context, input model.NewRecipe) (*model.Recipe, error) {
-	// First create the recipe
-	row := r.DB_POOL.QueryRow(
-		ctx,
-		`
-		INSERT INTO recipe (name, description, user_id)
-		VALUES ($1, $2, $3)
-		RETURNING recipe_id::TEXT
-		`,
-		input.Name,
-		input.Description,
-		input.UserID,
-	)
-
-	var recipe_id string
-	err := row.Scan(&recipe_id)
-	if err != nil {
-		return nil, fmt.Errorf("could not grab the newly created recipe id: %v", err)
-	}
-
-	// Next add each ingredient as a relationship
-	for _, existing_ingredient_id := range input.Ingredients {
-		_, err = r.DB_POOL.Exec(
-			ctx,
-			`
-			INSERT INTO recipe_ingredient (recipe_id, ingredient_id)
-			VALUES ($1, $2)
-			`,
-			recipe_id,
-			existing_ingredient_id.IngredientID,
-		)
+	user, err := r.authorizeCreate(ctx, input.UserID, input.HouseholdID)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeIngredientUse(ctx, input.Ingredients); err != nil {
+		return nil, err
+	}
+	if err := checkRecipeText(&input.Name, &input.Description); err != nil {
+		return nil, err
+	}
+	if err := checkRating(input.Rating); err != nil {
+		return nil, err
+	}
+	if input.Categories, err = cleanCategories(input.Categories); err != nil {
+		return nil, err
+	}
+	// Ingredients for unmatched lines are created with the recipe
+	var pending []*db.PendingIngredient
+	if len(input.IngredientLines) > 0 {
+		matched, err := r.matchIngredientLines(ctx, user, input.HouseholdID, input.Ingredients, parseIngredientLines(input.IngredientLines))
 		if err != nil {
-			return nil, fmt.Errorf("could not get the returned recipe_ingredient_id: %v", err)
+			return nil, err
 		}
+		addWarnings(ctx, matched.warnings)
+		input.Ingredients = matched.lines
+		pending = matched.pending
+	}
+
+	recipe_id, err := db.CreateRecipe(r.DB_POOL, ctx, input, pending)
+	if err != nil {
+		return nil, err
 	}
 
 	// Collect the entire recipe.
 	return db.GetRecipeById(r.DB_POOL, recipe_id, ctx)
 }
 
+// UpdateRecipe is the resolver for the updateRecipe field.
+func (r *mutationResolver) UpdateRecipe(ctx context.Context, recipeID string, input model.UpdateRecipe) (*model.Recipe, error) {
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.authorizeWrite(ctx, recipe.User, recipe.Household)
+	if err != nil {
+		return nil, err
+	}
+	if input.HouseholdID != nil {
+		// Moving a recipe into a household requires edit rights there as well
+		if _, err := r.requireHouseholdRole(ctx, *input.HouseholdID, model.HouseholdRoleEditor); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.authorizeIngredientUse(ctx, input.Ingredients); err != nil {
+		return nil, err
+	}
+	if err := checkRecipeText(input.Name, input.Description); err != nil {
+		return nil, err
+	}
+	if err := checkRating(input.Rating); err != nil {
+		return nil, err
+	}
+	if input.Categories, err = cleanCategories(input.Categories); err != nil {
+		return nil, err
+	}
+	// Ingredients for unmatched lines are created with the changes
+	var pending []*db.PendingIngredient
+	if input.IngredientLines != nil {
+		householdID := input.HouseholdID
+		if householdID == nil && recipe.Household != nil {
+			householdID = &recipe.Household.HouseholdID
+		}
+		matched, err := r.matchIngredientLines(ctx, user, householdID, input.Ingredients, parseIngredientLines(input.IngredientLines))
+		if err != nil {
+			return nil, err
+		}
+		addWarnings(ctx, matched.warnings)
+		input.Ingredients = matched.lines
+		pending = matched.pending
+	}
+
+	replacedImageIDs, err := db.UpdateRecipe(r.DB_POOL, ctx, recipeID, input, pending)
+	if err != nil {
+		return nil, err
+	}
+	for _, imageID := range replacedImageIDs {
+		r.discardImage(ctx, imageID)
+	}
+
+	return db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+}
+
+// DeleteRecipe is the resolver for the deleteRecipe field.
+func (r *mutationResolver) DeleteRecipe(ctx context.Context, recipeID string) (string, error) {
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.authorizeWrite(ctx, recipe.User, recipe.Household); err != nil {
+		return "", err
+	}
+
+	imageIDs, err := db.GetRecipeImageIds(r.DB_POOL, ctx, recipeID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = r.DB_POOL.Exec(ctx, `DELETE FROM recipe WHERE recipe_id = $1`, recipeID)
+	if err != nil {
+		return "", fmt.Errorf("could not delete recipe: %v", err)
+	}
+	for _, imageID := range imageIDs {
+		r.discardImage(ctx, imageID)
+	}
+	return recipeID, nil
+}
+
+// ImportRecipe is the resolver for the importRecipe field.
+func (r *mutationResolver) ImportRecipe(ctx context.Context, html *string, jsonLd *string, markdown *string, cooklang *string, householdID *string) (*model.RecipeImport, error) {
+	var imported *importer.Recipe
+	var err error
+	switch {
+	case jsonLd != nil:
+		imported, err = importer.FromJSONLD(*jsonLd)
+	case html != nil:
+		imported, err = importer.FromHTML(*html)
+	case markdown != nil:
+		imported, err = importer.FromMarkdown(*markdown)
+	case cooklang != nil:
+		imported, err = importer.FromCooklang(*cooklang)
+	default:
+		return nil, apperr.Invalid("provide one of html, jsonLd, markdown or cooklang to import")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.SaveImport(ctx, householdID, imported)
+}
+
+// ImportRecipeArchive is the resolver for the importRecipeArchive field.
+func (r *mutationResolver) ImportRecipeArchive(ctx context.Context, file graphql.Upload, householdID *string) (*model.RecipeArchiveImport, error) {
+	if file.Size > maxArchiveBytes {
+		return nil, apperr.Invalid("file is larger than %d MB", maxArchiveBytes>>20)
+	}
+	data, err := io.ReadAll(io.LimitReader(file.File, maxArchiveBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxArchiveBytes {
+		return nil, apperr.Invalid("file is larger than %d MB", maxArchiveBytes>>20)
+	}
+	entries, err := importer.FromArchive(data)
+	if err != nil {
+		return nil, err
+	}
+	return r.ImportArchive(ctx, householdID, entries)
+}
+
+// SetRecipeImage is the resolver for the setRecipeImage field.
+func (r *mutationResolver) SetRecipeImage(ctx context.Context, recipeID string, file graphql.Upload) (*model.Recipe, error) {
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.authorizeWrite(ctx, recipe.User, recipe.Household)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := r.storeImage(ctx, user.UserID, file)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := db.SetRecipeImage(r.DB_POOL, ctx, recipeID, &image.ImageID)
+	if err != nil {
+		r.discardImage(ctx, image.ImageID)
+		return nil, err
+	}
+	if previous != nil {
+		r.discardImage(ctx, *previous)
+	}
+	return db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+}
+
+// RemoveRecipeImage is the resolver for the removeRecipeImage field.
+func (r *mutationResolver) RemoveRecipeImage(ctx context.Context, recipeID string) (*model.Recipe, error) {
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeWrite(ctx, recipe.User, recipe.Household); err != nil {
+		return nil, err
+	}
+
+	previous, err := db.SetRecipeImage(r.DB_POOL, ctx, recipeID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		r.discardImage(ctx, *previous)
+	}
+	return db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+}
+
+// SetRecipeStepImage is the resolver for the setRecipeStepImage field.
+func (r *mutationResolver) SetRecipeStepImage(ctx context.Context, recipeID string, position int, file graphql.Upload) (*model.Recipe, error) {
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.authorizeWrite(ctx, recipe.User, recipe.Household)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := r.storeImage(ctx, user.UserID, file)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := db.SetRecipeStepImage(r.DB_POOL, ctx, recipeID, position, &image.ImageID)
+	if err != nil {
+		r.discardImage(ctx, image.ImageID)
+		return nil, err
+	}
+	if previous != nil {
+		r.discardImage(ctx, *previous)
+	}
+	return recipe, nil
+}
+
+// RemoveRecipeStepImage is the resolver for the removeRecipeStepImage field.
+func (r *mutationResolver) RemoveRecipeStepImage(ctx context.Context, recipeID string, position int) (*model.Recipe, error) {
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeWrite(ctx, recipe.User, recipe.Household); err != nil {
+		return nil, err
+	}
+
+	previous, err := db.SetRecipeStepImage(r.DB_POOL, ctx, recipeID, position, nil)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		r.discardImage(ctx, *previous)
+	}
+	return recipe, nil
+}
+
+// CreateHousehold is the resolver for the createHousehold field.
+func (r *mutationResolver) CreateHousehold(ctx context.Context, input model.NewHousehold) (*model.Household, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	household_id, err := db.CreateHousehold(r.DB_POOL, ctx, input.Name, user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetHouseholdById(r.DB_POOL, household_id, ctx)
+}
+
+// InviteToHousehold is the resolver for the inviteToHousehold field.
+func (r *mutationResolver) InviteToHousehold(ctx context.Context, input model.NewHouseholdInvitation) (*model.HouseholdInvitation, error) {
+	user, err := r.requireHouseholdRole(ctx, input.HouseholdID, model.HouseholdRoleOwner)
+	if err != nil {
+		return nil, err
+	}
+
+	invitee, err := db.GetUserByName(r.DB_POOL, input.UserName, ctx)
+	if err != nil {
+		return nil, err
+	}
+	role, err := db.GetHouseholdRole(r.DB_POOL, ctx, input.HouseholdID, invitee.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if role != nil {
+		return nil, apperr.Conflict("user is already a member of this household")
+	}
+
+	invitation_id, err := db.CreateHouseholdInvitation(r.DB_POOL, ctx, input.HouseholdID, invitee.UserID, user.UserID, input.Role)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetHouseholdInvitationById(r.DB_POOL, invitation_id, ctx)
+}
+
+// RespondToHouseholdInvitation is the resolver for the respondToHouseholdInvitation field.
+func (r *mutationResolver) RespondToHouseholdInvitation(ctx context.Context, invitationID string, accept bool) (*model.HouseholdInvitation, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := db.GetHouseholdInvitationById(r.DB_POOL, invitationID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if invitation.User.UserID != user.UserID {
+		return nil, apperr.Forbidden("not authorized to respond to this invitation")
+	}
+
+	err = db.RespondToHouseholdInvitation(r.DB_POOL, ctx, invitationID, accept)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetHouseholdInvitationById(r.DB_POOL, invitationID, ctx)
+}
+
+// SetHouseholdMemberRole is the resolver for the setHouseholdMemberRole field.
+func (r *mutationResolver) SetHouseholdMemberRole(ctx context.Context, householdID string, userID string, role model.HouseholdRole) (*model.Household, error) {
+	if _, err := r.requireHouseholdRole(ctx, householdID, model.HouseholdRoleOwner); err != nil {
+		return nil, err
+	}
+
+	err := db.SetHouseholdMemberRole(r.DB_POOL, ctx, householdID, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetHouseholdById(r.DB_POOL, householdID, ctx)
+}
+
+// RemoveHouseholdMember is the resolver for the removeHouseholdMember field.
+func (r *mutationResolver) RemoveHouseholdMember(ctx context.Context, householdID string, userID string) (*model.Household, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Members may always leave; removing anyone else requires ownership
+	if user.UserID != userID {
+		if _, err := r.requireHouseholdRole(ctx, householdID, model.HouseholdRoleOwner); err != nil {
+			return nil, err
+		}
+	}
+
+	err = db.RemoveHouseholdMember(r.DB_POOL, ctx, householdID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetHouseholdById(r.DB_POOL, householdID, ctx)
+}
+
+// AddMealPlanEntry is the resolver for the addMealPlanEntry field.
+func (r *mutationResolver) AddMealPlanEntry(ctx context.Context, input model.NewMealPlanEntry) (*model.MealPlanEntry, error) {
+	user, err := r.authorizeScope(ctx, input.HouseholdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	recipe, err := db.GetRecipeById(r.DB_POOL, input.RecipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeRead(ctx, recipe.User, recipe.Household); err != nil {
+		return nil, err
+	}
+
+	meal_plan_entry_id, err := db.CreateMealPlanEntry(r.DB_POOL, ctx, user.UserID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetMealPlanEntryById(r.DB_POOL, meal_plan_entry_id, ctx)
+}
+
+// MoveMealPlanEntry is the resolver for the moveMealPlanEntry field.
+func (r *mutationResolver) MoveMealPlanEntry(ctx context.Context, mealPlanEntryID string, date time.Time, slot model.MealSlot) (*model.MealPlanEntry, error) {
+	entry, err := db.GetMealPlanEntryById(r.DB_POOL, mealPlanEntryID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeScoped(ctx, entry.User, entry.Household, model.HouseholdRoleEditor); err != nil {
+		return nil, err
+	}
+
+	err = db.MoveMealPlanEntry(r.DB_POOL, ctx, mealPlanEntryID, date, slot)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetMealPlanEntryById(r.DB_POOL, mealPlanEntryID, ctx)
+}
+
+// RemoveMealPlanEntry is the resolver for the removeMealPlanEntry field.
+func (r *mutationResolver) RemoveMealPlanEntry(ctx context.Context, mealPlanEntryID string) (string, error) {
+	entry, err := db.GetMealPlanEntryById(r.DB_POOL, mealPlanEntryID, ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.authorizeScoped(ctx, entry.User, entry.Household, model.HouseholdRoleEditor); err != nil {
+		return "", err
+	}
+
+	err = db.DeleteMealPlanEntry(r.DB_POOL, ctx, mealPlanEntryID)
+	if err != nil {
+		return "", err
+	}
+	return mealPlanEntryID, nil
+}
+
+// CopyMealPlanWeek is the resolver for the copyMealPlanWeek field.
+func (r *mutationResolver) CopyMealPlanWeek(ctx context.Context, fromWeekStart time.Time, toWeekStart time.Time, householdID *string) ([]*model.MealPlanEntry, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.CopyMealPlanWeek(r.DB_POOL, ctx, user.UserID, householdID, fromWeekStart, toWeekStart)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetMealPlanEntries(r.DB_POOL, ctx, user.UserID, householdID, toWeekStart, toWeekStart.AddDate(0, 0, 6))
+}
+
+// GenerateShoppingList is the resolver for the generateShoppingList field.
+func (r *mutationResolver) GenerateShoppingList(ctx context.Context, input model.GenerateShoppingList) (*model.ShoppingList, error) {
+	user, err := r.authorizeScope(ctx, input.HouseholdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if (input.MealPlanFrom == nil) != (input.MealPlanTo == nil) {
+		return nil, apperr.Invalid("meal plan range needs both a start and an end")
+	}
+	if len(input.RecipeIds) == 0 && input.MealPlanFrom == nil {
+		return nil, apperr.Invalid("provide recipes or a meal plan range to shop for")
+	}
+	for _, recipeID := range input.RecipeIds {
+		recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.authorizeRead(ctx, recipe.User, recipe.Household); err != nil {
+			return nil, err
+		}
+	}
+
+	shopping_list_id, err := db.GenerateShoppingList(r.DB_POOL, ctx, user.UserID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetShoppingListById(r.DB_POOL, shopping_list_id, ctx)
+}
+
+// RenameShoppingList is the resolver for the renameShoppingList field.
+func (r *mutationResolver) RenameShoppingList(ctx context.Context, shoppingListID string, name string) (*model.ShoppingList, error) {
+	list, err := db.GetShoppingListById(r.DB_POOL, shoppingListID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeScoped(ctx, list.User, list.Household, model.HouseholdRoleEditor); err != nil {
+		return nil, err
+	}
+
+	err = db.RenameShoppingList(r.DB_POOL, ctx, shoppingListID, name)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetShoppingListById(r.DB_POOL, shoppingListID, ctx)
+}
+
+// DeleteShoppingList is the resolver for the deleteShoppingList field.
+func (r *mutationResolver) DeleteShoppingList(ctx context.Context, shoppingListID string) (string, error) {
+	list, err := db.GetShoppingListById(r.DB_POOL, shoppingListID, ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.authorizeScoped(ctx, list.User, list.Household, model.HouseholdRoleEditor); err != nil {
+		return "", err
+	}
+
+	err = db.DeleteShoppingList(r.DB_POOL, ctx, shoppingListID)
+	if err != nil {
+		return "", err
+	}
+	return shoppingListID, nil
+}
+
+// AddShoppingListItem is the resolver for the addShoppingListItem field.
+func (r *mutationResolver) AddShoppingListItem(ctx context.Context, shoppingListID string, input model.NewShoppingListItem) (*model.ShoppingList, error) {
+	list, err := db.GetShoppingListById(r.DB_POOL, shoppingListID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeScoped(ctx, list.User, list.Household, model.HouseholdRoleEditor); err != nil {
+		return nil, err
+	}
+
+	err = db.CreateShoppingListItem(r.DB_POOL, ctx, shoppingListID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetShoppingListById(r.DB_POOL, shoppingListID, ctx)
+}
+
+// UpdateShoppingListItem is the resolver for the updateShoppingListItem field.
+func (r *mutationResolver) UpdateShoppingListItem(ctx context.Context, shoppingListItemID string, input model.UpdateShoppingListItem) (*model.ShoppingList, error) {
+	list, err := db.GetShoppingListByItemId(r.DB_POOL, shoppingListItemID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeScoped(ctx, list.User, list.Household, model.HouseholdRoleEditor); err != nil {
+		return nil, err
+	}
+
+	err = db.UpdateShoppingListItem(r.DB_POOL, ctx, shoppingListItemID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetShoppingListById(r.DB_POOL, list.ShoppingListID, ctx)
+}
+
+// CheckShoppingListItem is the resolver for the checkShoppingListItem field.
+func (r *mutationResolver) CheckShoppingListItem(ctx context.Context, shoppingListItemID string, checked bool) (*model.ShoppingList, error) {
+	return r.UpdateShoppingListItem(ctx, shoppingListItemID, model.UpdateShoppingListItem{Checked: &checked})
+}
+
+// RemoveShoppingListItem is the resolver for the removeShoppingListItem field.
+func (r *mutationResolver) RemoveShoppingListItem(ctx context.Context, shoppingListItemID string) (*model.ShoppingList, error) {
+	list, err := db.GetShoppingListByItemId(r.DB_POOL, shoppingListItemID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeScoped(ctx, list.User, list.Household, model.HouseholdRoleEditor); err != nil {
+		return nil, err
+	}
+
+	err = db.DeleteShoppingListItem(r.DB_POOL, ctx, shoppingListItemID)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetShoppingListById(r.DB_POOL, list.ShoppingListID, ctx)
+}
+
+// AddPantryItem is the resolver for the addPantryItem field.
+func (r *mutationResolver) AddPantryItem(ctx context.Context, input model.NewPantryItem) (*model.PantryItem, error) {
+	user, err := r.authorizeScope(ctx, input.HouseholdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeIngredientUse(ctx, []*model.ExistingIngredientID{{IngredientID: input.IngredientID}}); err != nil {
+		return nil, err
+	}
+
+	pantry_item_id, err := db.CreatePantryItem(r.DB_POOL, ctx, user.UserID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPantryItemById(r.DB_POOL, pantry_item_id, ctx)
+}
+
+// UpdatePantryItem is the resolver for the updatePantryItem field.
+func (r *mutationResolver) UpdatePantryItem(ctx context.Context, pantryItemID string, input model.UpdatePantryItem) (*model.PantryItem, error) {
+	item, err := db.GetPantryItemById(r.DB_POOL, pantryItemID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeScoped(ctx, item.User, item.Household, model.HouseholdRoleEditor); err != nil {
+		return nil, err
+	}
+
+	err = db.UpdatePantryItem(r.DB_POOL, ctx, pantryItemID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPantryItemById(r.DB_POOL, pantryItemID, ctx)
+}
+
+// RemovePantryItem is the resolver for the removePantryItem field.
+func (r *mutationResolver) RemovePantryItem(ctx context.Context, pantryItemID string) (string, error) {
+	item, err := db.GetPantryItemById(r.DB_POOL, pantryItemID, ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.authorizeScoped(ctx, item.User, item.Household, model.HouseholdRoleEditor); err != nil {
+		return "", err
+	}
+
+	err = db.DeletePantryItem(r.DB_POOL, ctx, pantryItemID)
+	if err != nil {
+		return "", err
+	}
+	return pantryItemID, nil
+}
+
+// RecordIngredientPrice is the resolver for the recordIngredientPrice field.
+func (r *mutationResolver) RecordIngredientPrice(ctx context.Context, input model.NewIngredientPrice) (*model.IngredientPrice, error) {
+	user, err := r.authorizeScope(ctx, input.HouseholdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeIngredientUse(ctx, []*model.ExistingIngredientID{{IngredientID: input.IngredientID}}); err != nil {
+		return nil, err
+	}
+	if input.Price < 0 {
+		return nil, apperr.Invalid("price cannot be negative")
+	}
+	if input.Quantity != nil && *input.Quantity <= 0 {
+		return nil, apperr.Invalid("priced quantity must be positive")
+	}
+
+	priceID, err := db.CreateIngredientPrice(r.DB_POOL, ctx, user.UserID, input)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetIngredientPriceById(r.DB_POOL, priceID, ctx)
+}
+
+// RemoveIngredientPrice is the resolver for the removeIngredientPrice field.
+func (r *mutationResolver) RemoveIngredientPrice(ctx context.Context, priceID string) (string, error) {
+	price, err := db.GetIngredientPriceById(r.DB_POOL, priceID, ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.authorizeScoped(ctx, price.User, price.Household, model.HouseholdRoleEditor); err != nil {
+		return "", err
+	}
+
+	err = db.DeleteIngredientPrice(r.DB_POOL, ctx, priceID)
+	if err != nil {
+		return "", err
+	}
+	return priceID, nil
+}
+
+// MarkRecipeCooked is the resolver for the markRecipeCooked field.
+func (r *mutationResolver) MarkRecipeCooked(ctx context.Context, recipeID string, servings *int, householdID *string) ([]*model.PantryItem, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleEditor)
+	if err != nil {
+		return nil, err
+	}
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeRead(ctx, recipe.User, recipe.Household); err != nil {
+		return nil, err
+	}
+
+	err = db.DeductRecipeFromPantry(r.DB_POOL, ctx, user.UserID, householdID, recipe, servings)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPantryItems(r.DB_POOL, ctx, user.UserID, householdID)
+}
+
+// DeleteAccount is the resolver for the deleteAccount field.
+func (r *mutationResolver) DeleteAccount(ctx context.Context, password string) (*model.AccountDeletion, error) {
+	return r.deleteAccount(ctx, password)
+}
+
+// RegisterWebhook is the resolver for the registerWebhook field.
+func (r *mutationResolver) RegisterWebhook(ctx context.Context, input model.NewWebhook) (*model.WebhookRegistration, error) {
+	return r.registerWebhook(ctx, input)
+}
+
+// UpdateWebhook is the resolver for the updateWebhook field.
+func (r *mutationResolver) UpdateWebhook(ctx context.Context, webhookID string, input model.UpdateWebhook) (*model.Webhook, error) {
+	return r.updateWebhook(ctx, webhookID, input)
+}
+
+// DeleteWebhook is the resolver for the deleteWebhook field.
+func (r *mutationResolver) DeleteWebhook(ctx context.Context, webhookID string) (string, error) {
+	return r.deleteWebhook(ctx, webhookID)
+}
+
+// RedeliverWebhook is the resolver for the redeliverWebhook field.
+func (r *mutationResolver) RedeliverWebhook(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error) {
+	return r.redeliverWebhook(ctx, deliveryID)
+}
+
 // Recipes is the resolver for the recipes field.
-func (r *queryResolver) Recipes(ctx context.Context) ([]*model.Recipe, error) {
-	return db.GetRecipes(r.DB_POOL, ctx, nil)
+func (r *queryResolver) Recipes(ctx context.Context, filter *model.RecipeFilter, sort *model.RecipeSort) ([]*model.Recipe, error) {
+	recipes, err := db.GetRecipes(r.DB_POOL, ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	recipes, err = r.visibleRecipes(ctx, recipes)
+	if err != nil {
+		return nil, err
+	}
+
+	if filter != nil {
+		filtered := []*model.Recipe{}
+		for _, recipe := range recipes {
+			if !dietary.Matches(recipe, filter.ExcludeAllergens, filter.Diet) {
+				continue
+			}
+			if filter.MaxTotalTime != nil && (recipe.TotalTime == nil || *recipe.TotalTime > *filter.MaxTotalTime) {
+				continue
+			}
+			filtered = append(filtered, recipe)
+		}
+		recipes = filtered
+	}
+	if sort != nil {
+		sortRecipes(recipes, *sort)
+	}
+	return recipes, nil
 }
 
 // RecipeByID is the resolver for the recipeById field.
 func (r *queryResolver) RecipeByID(ctx context.Context, recipeID string) (*model.Recipe, error) {
-	return db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	recipe, err := db.GetRecipeById(r.DB_POOL, recipeID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.authorizeRead(ctx, recipe.User, recipe.Household); err != nil {
+		return nil, err
+	}
+	return recipe, nil
 }
 
 // Ingredients is the resolver for the ingredients field.
 func (r *queryResolver) Ingredients(ctx context.Context) ([]*model.Ingredient, error) {
-	return db.GetIngredients(r.DB_POOL, ctx, nil)
+	ingredients, err := db.GetIngredients(r.DB_POOL, ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.visibleIngredients(ctx, ingredients)
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	return auth.ForContext(ctx), nil
+}
+
+// Households is the resolver for the households field.
+func (r *queryResolver) Households(ctx context.Context) ([]*model.Household, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetHouseholdsForUser(r.DB_POOL, ctx, user.UserID)
+}
+
+// HouseholdInvitations is the resolver for the householdInvitations field.
+func (r *queryResolver) HouseholdInvitations(ctx context.Context) ([]*model.HouseholdInvitation, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where := map[string]interface{}{"hi.user_id": user.UserID}
+	return db.GetHouseholdInvitations(r.DB_POOL, ctx, where)
+}
+
+// MealPlan is the resolver for the mealPlan field.
+func (r *queryResolver) MealPlan(ctx context.Context, from time.Time, to time.Time, householdID *string) ([]*model.MealPlanEntry, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if to.Before(from) {
+		return nil, apperr.Invalid("end of date range must not be before its start")
+	}
+	return db.GetMealPlanEntries(r.DB_POOL, ctx, user.UserID, householdID, from, to)
+}
+
+// ShoppingLists is the resolver for the shoppingLists field.
+func (r *queryResolver) ShoppingLists(ctx context.Context, householdID *string) ([]*model.ShoppingList, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetShoppingLists(r.DB_POOL, ctx, user.UserID, householdID)
+}
+
+// ShoppingList is the resolver for the shoppingList field.
+func (r *queryResolver) ShoppingList(ctx context.Context, shoppingListID string) (*model.ShoppingList, error) {
+	list, err := db.GetShoppingListById(r.DB_POOL, shoppingListID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.authorizeScoped(ctx, list.User, list.Household, model.HouseholdRoleViewer); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Pantry is the resolver for the pantry field.
+func (r *queryResolver) Pantry(ctx context.Context, householdID *string) ([]*model.PantryItem, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetPantryItems(r.DB_POOL, ctx, user.UserID, householdID)
+}
+
+// ExpiringSoon is the resolver for the expiringSoon field.
+func (r *queryResolver) ExpiringSoon(ctx context.Context, days int, householdID *string) ([]*model.PantryItem, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	if days < 0 {
+		return nil, apperr.Invalid("days must not be negative")
+	}
+	return db.GetExpiringPantryItems(r.DB_POOL, ctx, user.UserID, householdID, days)
+}
+
+// SearchFoods is the resolver for the searchFoods field.
+func (r *queryResolver) SearchFoods(ctx context.Context, query string, limit *int) ([]*model.Food, error) {
+	return db.SearchFoods(r.DB_POOL, ctx, query, clampLimit(limit))
+}
+
+// ParseIngredientLine is the resolver for the parseIngredientLine field.
+func (r *queryResolver) ParseIngredientLine(ctx context.Context, text string) (*model.ParsedIngredientLine, error) {
+	return parsedIngredientLine(ingredientline.Parse(text)), nil
+}
+
+// ParseRecipeText is the resolver for the parseRecipeText field.
+func (r *queryResolver) ParseRecipeText(ctx context.Context, text string) (*model.RecipeDraft, error) {
+	return recipeDraft(text)
+}
+
+// ExportMyData is the resolver for the exportMyData field.
+func (r *queryResolver) ExportMyData(ctx context.Context) (*model.DataExport, error) {
+	return r.dataExport(ctx)
 }
 
+// Webhooks is the resolver for the webhooks field.
+func (r *queryResolver) Webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	return r.webhooks(ctx)
+}
+
+// Steps is the resolver for the steps field.
+func (r *recipeResolver) Steps(ctx context.Context, obj *model.Recipe) ([]*model.RecipeStep, error) {
+	return db.GetRecipeSteps(r.DB_POOL, ctx, obj.RecipeID)
+}
+
+// JSONLd is the resolver for the jsonLd field.
+func (r *recipeResolver) JSONLd(ctx context.Context, obj *model.Recipe) (string, error) {
+	doc, err := r.ExportDocument(ctx, obj)
+	if err != nil {
+		return "", err
+	}
+	data, err := exporter.JSONLD(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Markdown is the resolver for the markdown field.
+func (r *recipeResolver) Markdown(ctx context.Context, obj *model.Recipe) (string, error) {
+	doc, err := r.ExportDocument(ctx, obj)
+	if err != nil {
+		return "", err
+	}
+	data, err := exporter.Markdown(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Cooklang is the resolver for the cooklang field.
+func (r *recipeResolver) Cooklang(ctx context.Context, obj *model.Recipe) (string, error) {
+	doc, err := r.ExportDocument(ctx, obj)
+	if err != nil {
+		return "", err
+	}
+	data, err := exporter.Cooklang(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Nutrition is the resolver for the nutrition field.
+func (r *recipeResolver) Nutrition(ctx context.Context, obj *model.Recipe) (*model.RecipeNutrition, error) {
+	ingredientIDs := []string{}
+	for _, ingredient := range obj.Ingredients {
+		ingredientIDs = append(ingredientIDs, ingredient.IngredientID)
+	}
+	facts, err := db.GetIngredientNutrition(r.DB_POOL, ctx, ingredientIDs)
+	if err != nil {
+		return nil, err
+	}
+	return nutrition.ForLines(obj.IngredientLines, facts), nil
+}
+
+// NutritionPerServing is the resolver for the nutritionPerServing field.
+func (r *recipeResolver) NutritionPerServing(ctx context.Context, obj *model.Recipe) (*model.RecipeNutrition, error) {
+	if obj.Servings == nil || *obj.Servings <= 0 {
+		return nil, nil
+	}
+	total, err := r.Nutrition(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	return nutrition.PerServing(total, *obj.Servings), nil
+}
+
+// SubstitutionsFor is the resolver for the substitutionsFor field.
+func (r *recipeResolver) SubstitutionsFor(ctx context.Context, obj *model.Recipe, diet *model.Diet, excludeAllergens []model.Allergen) (*model.SubstitutionPlan, error) {
+	ingredientIDs := []string{}
+	for _, line := range obj.IngredientLines {
+		ingredientIDs = append(ingredientIDs, line.Ingredient.IngredientID)
+	}
+	substitutions, err := db.GetSubstitutions(r.DB_POOL, ctx, ingredientIDs)
+	if err != nil {
+		return nil, err
+	}
+	for ingredientID, candidates := range substitutions {
+		substitutions[ingredientID], err = r.visibleSubstitutions(ctx, candidates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return dietary.Substitute(obj.IngredientLines, substitutions, excludeAllergens, diet), nil
+}
+
+// EstimatedCost is the resolver for the estimatedCost field.
+func (r *recipeResolver) EstimatedCost(ctx context.Context, obj *model.Recipe, householdID *string) (*model.RecipeCost, error) {
+	user, err := r.authorizeScope(ctx, householdID, model.HouseholdRoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	ingredientIDs := []string{}
+	for _, ingredient := range obj.Ingredients {
+		ingredientIDs = append(ingredientIDs, ingredient.IngredientID)
+	}
+	prices, err := db.GetCurrentIngredientPrices(r.DB_POOL, ctx, user.UserID, householdID, ingredientIDs)
+	if err != nil {
+		return nil, err
+	}
+	return cost.ForLines(obj.IngredientLines, prices, obj.Servings), nil
+}
+
+// RecipeUpdated is the resolver for the recipeUpdated field.
+func (r *subscriptionResolver) RecipeUpdated(ctx context.Context, recipeID string) (<-chan *model.Recipe, error) {
+	return r.recipeUpdates(ctx, recipeID)
+}
+
+// ShoppingListChanged is the resolver for the shoppingListChanged field.
+func (r *subscriptionResolver) ShoppingListChanged(ctx context.Context, shoppingListID string) (<-chan *model.ShoppingList, error) {
+	return r.shoppingListUpdates(ctx, shoppingListID)
+}
+
+// NewRecipeFromUser is the resolver for the newRecipeFromUser field.
+func (r *subscriptionResolver) NewRecipeFromUser(ctx context.Context, userID string) (<-chan *model.Recipe, error) {
+	return r.newRecipesFrom(ctx, userID)
+}
+
+// Deliveries is the resolver for the deliveries field.
+func (r *webhookResolver) Deliveries(ctx context.Context, obj *model.Webhook, limit *int) ([]*model.WebhookDelivery, error) {
+	return r.webhookDeliveries(ctx, obj, limit)
+}
+
+// Household returns HouseholdResolver implementation.
+func (r *Resolver) Household() HouseholdResolver { return &householdResolver{r} }
+
+// Ingredient returns IngredientResolver implementation.
+func (r *Resolver) Ingredient() IngredientResolver { return &ingredientResolver{r} }
+
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
+// Recipe returns RecipeResolver implementation.
+func (r *Resolver) Recipe() RecipeResolver { return &recipeResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// Webhook returns WebhookResolver implementation.
+func (r *Resolver) Webhook() WebhookResolver { return &webhookResolver{r} }
+
+type householdResolver struct{ *Resolver }
+type ingredientResolver struct{ *Resolver }
 type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type recipeResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type webhookResolver struct{ *Resolver }
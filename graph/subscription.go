@@ -0,0 +1,115 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/events"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Send the current state of something to a subscriber each time it changes. Events only say
+// what changed, so the state is read again, and authorized again, for every change.
+//
+// Parameters:
+//   - ctx: Context of the subscription
+//   - changes: Changes to follow
+//   - load: Function reading the state after a change; it returns nil to skip the change
+//     and an error to end the subscription, e.g. once the subject is deleted
+//
+// Returns:
+//   - Channel of states, closed when the subscription ends
+func follow[T any](ctx context.Context, changes <-chan events.Event, load func(events.Event) (*T, error)) <-chan *T {
+	out := make(chan *T, 1)
+	go func() {
+		defer close(out)
+		for event := range changes {
+			value, err := load(event)
+			if err != nil {
+				return
+			}
+			if value == nil {
+				continue
+			}
+			select {
+			case out <- value:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// Follow changes to a recipe the current user may read.
+//
+// Parameters:
+//   - ctx: Context of the subscription
+//   - recipeID: ID of the recipe
+//
+// Returns:
+//   - Channel of the recipe after each change
+func (r *Resolver) recipeUpdates(ctx context.Context, recipeID string) (<-chan *model.Recipe, error) {
+	if _, err := r.ReadableRecipe(ctx, recipeID); err != nil {
+		return nil, err
+	}
+	changes := r.EVENTS.Subscribe(ctx, events.RecipeUpdated, recipeID)
+	return follow(ctx, changes, func(events.Event) (*model.Recipe, error) {
+		return r.ReadableRecipe(ctx, recipeID)
+	}), nil
+}
+
+// Follow changes to a shopping list the current user may read.
+//
+// Parameters:
+//   - ctx: Context of the subscription
+//   - shoppingListID: ID of the list
+//
+// Returns:
+//   - Channel of the list after each change
+func (r *Resolver) shoppingListUpdates(ctx context.Context, shoppingListID string) (<-chan *model.ShoppingList, error) {
+	readable := func() (*model.ShoppingList, error) {
+		list, err := db.GetShoppingListById(r.DB_POOL, shoppingListID, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.authorizeScoped(ctx, list.User, list.Household, model.HouseholdRoleViewer); err != nil {
+			return nil, err
+		}
+		return list, nil
+	}
+	if _, err := readable(); err != nil {
+		return nil, err
+	}
+	changes := r.EVENTS.Subscribe(ctx, events.ShoppingListChanged, shoppingListID)
+	return follow(ctx, changes, func(events.Event) (*model.ShoppingList, error) {
+		return readable()
+	}), nil
+}
+
+// Follow recipes a user creates that the current user may read.
+//
+// Parameters:
+//   - ctx: Context of the subscription
+//   - userID: ID of the user creating the recipes
+//
+// Returns:
+//   - Channel of new recipes
+func (r *Resolver) newRecipesFrom(ctx context.Context, userID string) (<-chan *model.Recipe, error) {
+	changes := r.EVENTS.Subscribe(ctx, events.RecipeCreated, "")
+	return follow(ctx, changes, func(event events.Event) (*model.Recipe, error) {
+		// Recipes by other users are skipped before they are loaded
+		if event.UserID != userID {
+			return nil, nil
+		}
+		recipe, err := db.GetRecipeById(r.DB_POOL, event.ID, ctx)
+		// A recipe deleted straight away is skipped, not the end of the subscription
+		if err != nil || recipe.User == nil || recipe.User.UserID != userID {
+			return nil, nil
+		}
+		if err := r.authorizeRead(ctx, recipe.User, recipe.Household); err != nil {
+			return nil, nil
+		}
+		return recipe, nil
+	}), nil
+}
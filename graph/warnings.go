@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// Problems found while resolving a request that did not stop it.
+type Warnings struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+// Get the warnings collected so far.
+//
+// Returns:
+//   - Warning messages in the order they were added
+func (w *Warnings) Messages() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]string{}, w.messages...)
+}
+
+// Write the warnings as a JSON list, as GraphQL response extensions are.
+func (w *Warnings) MarshalJSON() ([]byte, error) {
+	return json.Marshal(w.Messages())
+}
+
+type warningsKey struct{}
+
+// Collect the warnings raised while resolving a request made outside GraphQL, such as
+// a REST request, so they can be returned with its result.
+//
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - Context that collects warnings, and the warnings it collects
+func WithWarnings(ctx context.Context) (context.Context, *Warnings) {
+	warnings := &Warnings{}
+	return context.WithValue(ctx, warningsKey{}, warnings), warnings
+}
+
+// Report problems that did not stop a request, such as ingredient lines that were skipped.
+// GraphQL responses list them under the "warnings" extension; other requests return
+// them when the context was made with WithWarnings.
+//
+// Parameters:
+//   - ctx: Request context
+//   - messages: Warnings to report
+func addWarnings(ctx context.Context, messages []string) {
+	if len(messages) == 0 {
+		return
+	}
+	warnings, ok := ctx.Value(warningsKey{}).(*Warnings)
+	if !ok {
+		if !graphql.HasOperationContext(ctx) {
+			return
+		}
+		if warnings, ok = graphql.GetExtension(ctx, "warnings").(*Warnings); !ok {
+			warnings = &Warnings{}
+			graphql.RegisterExtension(ctx, "warnings", warnings)
+		}
+	}
+	warnings.mu.Lock()
+	defer warnings.mu.Unlock()
+	warnings.messages = append(warnings.messages, messages...)
+}
@@ -0,0 +1,189 @@
+package graph
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/webhooks"
+)
+
+// Most deliveries returned for a webhook at once.
+const maxWebhookDeliveries = 100
+
+// Check that a webhook URL can be POSTed to, and that it is on a public address unless
+// local receivers are allowed.
+//
+// Parameters:
+//   - ctx: Request context
+//   - rawURL: URL given for the webhook
+//
+// Returns:
+//   - Error describing what is wrong with the URL
+func (r *Resolver) validateWebhookURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return apperr.Invalid("webhook url must be an absolute http or https URL")
+	}
+	if r.WEBHOOKS_ALLOW_PRIVATE {
+		return nil
+	}
+	return webhooks.CheckHost(ctx, parsed.Hostname())
+}
+
+// Get a webhook the current user registered.
+//
+// Parameters:
+//   - ctx: Request context
+//   - webhookID: ID of the webhook
+//
+// Returns:
+//   - The webhook
+func (r *Resolver) ownWebhook(ctx context.Context, webhookID string) (*model.Webhook, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	webhook, userID, err := db.GetWebhookById(r.DB_POOL, webhookID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if userID != user.UserID {
+		return nil, apperr.Forbidden("not authorized to access this webhook")
+	}
+	return webhook, nil
+}
+
+// Get the webhooks the current user registered.
+//
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - The user's webhooks
+func (r *Resolver) webhooks(ctx context.Context) ([]*model.Webhook, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetWebhooks(r.DB_POOL, ctx, user.UserID)
+}
+
+// Register a webhook for the current user with a new secret.
+//
+// Parameters:
+//   - ctx: Request context
+//   - input: URL and events of the webhook
+//
+// Returns:
+//   - The webhook and its secret
+func (r *Resolver) registerWebhook(ctx context.Context, input model.NewWebhook) (*model.WebhookRegistration, error) {
+	user, err := auth.RequireUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.validateWebhookURL(ctx, input.URL); err != nil {
+		return nil, err
+	}
+	if len(input.Events) == 0 {
+		return nil, apperr.Invalid("webhook must be subscribed to at least one event")
+	}
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhookID, err := db.CreateWebhook(r.DB_POOL, ctx, user.UserID, input, secret)
+	if err != nil {
+		return nil, err
+	}
+	webhook, _, err := db.GetWebhookById(r.DB_POOL, webhookID, ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.WebhookRegistration{Webhook: webhook, Secret: secret}, nil
+}
+
+// Change a webhook the current user registered.
+//
+// Parameters:
+//   - ctx: Request context
+//   - webhookID: ID of the webhook
+//   - input: Fields to change
+//
+// Returns:
+//   - The updated webhook
+func (r *Resolver) updateWebhook(ctx context.Context, webhookID string, input model.UpdateWebhook) (*model.Webhook, error) {
+	if _, err := r.ownWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	if input.URL != nil {
+		if err := r.validateWebhookURL(ctx, *input.URL); err != nil {
+			return nil, err
+		}
+	}
+	if input.Events != nil && len(input.Events) == 0 {
+		return nil, apperr.Invalid("webhook must be subscribed to at least one event")
+	}
+	if err := db.UpdateWebhook(r.DB_POOL, ctx, webhookID, input); err != nil {
+		return nil, err
+	}
+	webhook, _, err := db.GetWebhookById(r.DB_POOL, webhookID, ctx)
+	return webhook, err
+}
+
+// Delete a webhook the current user registered.
+//
+// Parameters:
+//   - ctx: Request context
+//   - webhookID: ID of the webhook
+//
+// Returns:
+//   - ID of the deleted webhook
+func (r *Resolver) deleteWebhook(ctx context.Context, webhookID string) (string, error) {
+	if _, err := r.ownWebhook(ctx, webhookID); err != nil {
+		return "", err
+	}
+	if err := db.DeleteWebhook(r.DB_POOL, ctx, webhookID); err != nil {
+		return "", err
+	}
+	return webhookID, nil
+}
+
+// Queue a delivery to one of the current user's webhooks to be sent again.
+//
+// Parameters:
+//   - ctx: Request context
+//   - deliveryID: ID of the delivery
+//
+// Returns:
+//   - The delivery, pending again
+func (r *Resolver) redeliverWebhook(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error) {
+	webhookID, err := db.GetWebhookIdForDelivery(r.DB_POOL, ctx, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.ownWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	return db.RetryWebhookDelivery(r.DB_POOL, ctx, deliveryID)
+}
+
+// Get the most recent deliveries to a webhook the current user registered.
+//
+// Parameters:
+//   - ctx: Request context
+//   - webhook: The webhook
+//   - limit: Maximum number of deliveries to return
+//
+// Returns:
+//   - Deliveries, most recent first
+func (r *Resolver) webhookDeliveries(ctx context.Context, webhook *model.Webhook, limit *int) ([]*model.WebhookDelivery, error) {
+	count := 20
+	if limit != nil {
+		count = min(max(*limit, 0), maxWebhookDeliveries)
+	}
+	return db.GetWebhookDeliveries(r.DB_POOL, ctx, webhook.WebhookID, count)
+}
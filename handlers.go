@@ -2,11 +2,55 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/blob"
+	"github.com/zldobbs/ambrosia-server/exporter"
+	"github.com/zldobbs/ambrosia-server/graph"
+	"github.com/zldobbs/ambrosia-server/images"
+	"github.com/zldobbs/ambrosia-server/rest"
 )
 
+// Answer with an error as plain text, choosing the status from its kind as the REST API does.
+// Errors of no known kind are logged and answered with a bare 500.
+//
+// Parameters:
+// 	- w: ResponseWriter object to write to
+// 	- err: Error to report
+// 	- action: What was being done, for the log, e.g. "exporting cookbook"
+func writeHTTPError(w http.ResponseWriter, err error, action string) {
+	status, message := rest.ErrorStatus(err)
+	switch status {
+	case http.StatusUnauthorized:
+		w.Header().Set("WWW-Authenticate", `Basic realm="ambrosia"`)
+	case http.StatusInternalServerError:
+		log.Printf("Error %s: %v", action, err)
+	}
+	http.Error(w, message, status)
+}
+
+// Check that an ID given in a request could name a row, so malformed IDs are refused
+// before they reach the database.
+//
+// Parameters:
+// 	- id: ID to check
+//
+// Returns:
+// 	Whether the ID is a positive integer
+func validID(id string) bool {
+	parsed, err := strconv.ParseInt(id, 10, 32)
+	return err == nil && parsed > 0
+}
+
 // Helper function to write an HTTP response with error handling.
 //
 // Parameters:
@@ -46,3 +90,329 @@ func heartbeatHandler(w http.ResponseWriter, r *http.Request) {
 func graphQLHandler(w http.ResponseWriter, r *http.Request) {
 	safeResponseWrite(w, "GraphQL is not in yet!")
 }
+
+// Serve uploaded images and their thumbnails from the blob store.
+// Image IDs are random and an image never changes once stored, so responses may be cached indefinitely.
+//
+// Parameters:
+// 	- store: Blob store the images are kept in
+// 	- thumbnail: Whether to serve the thumbnail rather than the original
+//
+// Returns:
+// 	Handler for routes with an {id} path value
+func imageHandler(store blob.Store, thumbnail bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		key := images.OriginalKey(id)
+		if thumbnail {
+			key = images.ThumbnailKey(id)
+		}
+		if !blob.ValidKey(key) {
+			http.NotFound(w, r)
+			return
+		}
+
+		etag := strconv.Quote(key)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		file, info, err := store.Get(r.Context(), key)
+		if errors.Is(err, blob.ErrNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		if err != nil {
+			log.Println("Error reading image:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", info.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("ETag", etag)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		if _, err := io.Copy(w, file); err != nil {
+			log.Println("Error writing image:", err)
+		}
+	}
+}
+
+// A format recipes can be downloaded in, keyed by file extension.
+type recipeFormat struct {
+	contentType string
+	write       func(doc *exporter.Document) ([]byte, error)
+	// Whether the format embeds the recipe's image rather than linking to it
+	photo bool
+}
+
+// Formats served by recipeFileHandler.
+var recipeFormats = map[string]recipeFormat{
+	"jsonld": {"application/ld+json", exporter.JSONLD, false},
+	"md":     {"text/markdown; charset=utf-8", exporter.Markdown, false},
+	"cook":   {"text/plain; charset=utf-8", exporter.Cooklang, false},
+	"pdf":    {"application/pdf", exporter.PDF, true},
+}
+
+// Serve a recipe as a file, e.g. /recipes/12.jsonld, in the format named by its extension.
+// Recipes the requester may not read are reported as not found.
+//
+// Parameters:
+// 	- resolver: GraphQL resolver, used to load and authorize recipes
+//
+// Returns:
+// 	Handler for routes with a {file} path value
+func recipeFileHandler(resolver *graph.Resolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, extension, _ := strings.Cut(r.PathValue("file"), ".")
+		format, ok := recipeFormats[extension]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		recipe, err := resolver.ReadableRecipe(r.Context(), id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		doc, err := resolver.ExportDocument(r.Context(), recipe)
+		if err != nil {
+			log.Println("Error exporting recipe:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if doc.BaseURL == "" {
+			doc.BaseURL = requestBaseURL(r)
+		}
+		if format.photo {
+			// A recipe is still worth serving without its image
+			if err := resolver.LoadExportPhoto(r.Context(), doc); err != nil {
+				log.Println("Error loading recipe image:", err)
+			}
+		}
+
+		data, err := format.write(doc)
+		if err != nil {
+			log.Println("Error exporting recipe:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if _, err := w.Write(data); err != nil {
+			log.Println("Error writing response:", err)
+		}
+	}
+}
+
+// Most recipes printed in one cookbook.
+const maxCookbookRecipes = 200
+
+// A way of writing several recipes out as one book.
+type cookbookFormat struct {
+	contentType string
+	write       func(title string, docs []*exporter.Document) ([]byte, error)
+}
+
+// Cookbook formats by file extension.
+var cookbookFormats = map[string]cookbookFormat{
+	"pdf":  {"application/pdf", exporter.Cookbook},
+	"epub": {"application/epub+zip", exporter.EPUB},
+}
+
+// Serve several recipes as one book, e.g. /cookbook.pdf?recipe=3&recipe=7&title=Soups, in the
+// order given. With a user parameter, e.g. /cookbook.epub?user=2, every recipe that user created
+// is included first, by name. Listed recipes that do not exist are answered with a 404, and
+// those the requester may not read with a 403; recipes listed by user are left out instead.
+//
+// Parameters:
+// 	- resolver: GraphQL resolver, used to load and authorize recipes
+// 	- format: Format to write the book in
+//
+// Returns:
+// 	Handler for the cookbook route
+func cookbookHandler(resolver *graph.Resolver, format cookbookFormat) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		recipeIDs := []string{}
+		if userID := r.URL.Query().Get("user"); userID != "" {
+			if !validID(userID) {
+				http.Error(w, "Bad Request: user must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			owned, err := resolver.UserRecipeIDs(r.Context(), userID)
+			if err != nil {
+				log.Println("Error listing recipes:", err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			recipeIDs = append(recipeIDs, owned...)
+		}
+		recipeIDs = append(recipeIDs, r.URL.Query()["recipe"]...)
+		for _, recipeID := range r.URL.Query()["recipe"] {
+			if !validID(recipeID) {
+				http.Error(w, "Bad Request: recipe IDs must be positive integers", http.StatusBadRequest)
+				return
+			}
+		}
+		if len(recipeIDs) == 0 {
+			http.Error(w, "Bad Request: expected at least one recipe parameter, or a user with recipes", http.StatusBadRequest)
+			return
+		}
+		if len(recipeIDs) > maxCookbookRecipes {
+			http.Error(w, fmt.Sprintf("Bad Request: a cookbook holds at most %d recipes", maxCookbookRecipes), http.StatusBadRequest)
+			return
+		}
+		title := r.URL.Query().Get("title")
+		if title == "" {
+			title = "Cookbook"
+		}
+
+		docs, err := resolver.ExportDocuments(r.Context(), recipeIDs)
+		if err != nil {
+			writeHTTPError(w, err, "exporting cookbook")
+			return
+		}
+		data, err := format.write(title, docs)
+		if err != nil {
+			log.Println("Error exporting cookbook:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", format.contentType)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if _, err := w.Write(data); err != nil {
+			log.Println("Error writing response:", err)
+		}
+	}
+}
+
+// Serve a ZIP of everything the requester created, from /account/export.zip.
+// The archive is streamed, since images can make it large, so a failure part way through
+// leaves it truncated and is only logged.
+//
+// Parameters:
+// 	- resolver: GraphQL resolver, used to gather the requester's data
+//
+// Returns:
+// 	Handler for the account export route
+func accountExportHandler(resolver *graph.Resolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := auth.ForContext(r.Context())
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ambrosia"`)
+			http.Error(w, "Unauthorized: Authentication required", http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="ambrosia-export.zip"`)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if err := resolver.ExportAccount(r.Context(), w); err != nil {
+			log.Println("Error exporting account:", err)
+		}
+	}
+}
+
+// Largest CSV file accepted for upload, in bytes.
+const maxCSVBytes = 10 << 20
+
+// Import or export a table as CSV, e.g. POST /csv/ingredients to add and update ingredients
+// by name, or GET /csv/recipe-lines to download the ingredient lines of your recipes.
+// Uploads are the request body, or the "file" field of a multipart form, and are answered
+// with a JSON report of each row. The "household" query parameter works on a household's
+// ingredients or recipes instead of your own, and "dryRun=true" reports what an upload
+// would do without saving it.
+//
+// Parameters:
+// 	- resolver: GraphQL resolver, used to authorize and save the rows
+//
+// Returns:
+// 	Handler for routes with a {table} path value
+func csvHandler(resolver *graph.Resolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(r.PathValue("table"), ".csv")
+		table, ok := graph.CSVTables[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if auth.ForContext(r.Context()) == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ambrosia"`)
+			http.Error(w, "Unauthorized: Authentication required", http.StatusUnauthorized)
+			return
+		}
+		var householdID *string
+		if household := r.URL.Query().Get("household"); household != "" {
+			if !validID(household) {
+				http.Error(w, "Bad Request: household must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			householdID = &household
+		}
+
+		if r.Method == http.MethodGet {
+			var b bytes.Buffer
+			if err := table.Export(resolver, r.Context(), householdID, &b); err != nil {
+				writeHTTPError(w, err, "exporting CSV")
+				return
+			}
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+			w.Header().Set("Cache-Control", "private, no-cache")
+			if _, err := w.Write(b.Bytes()); err != nil {
+				log.Println("Error writing response:", err)
+			}
+			return
+		}
+
+		var upload io.Reader = r.Body
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			r.Body = http.MaxBytesReader(w, r.Body, maxCSVBytes+1<<20)
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, "Bad Request: expected a file field", http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			upload = file
+		}
+		data, err := io.ReadAll(io.LimitReader(upload, maxCSVBytes+1))
+		if err != nil {
+			http.Error(w, "Bad Request: could not read upload", http.StatusBadRequest)
+			return
+		}
+		if len(data) > maxCSVBytes {
+			http.Error(w, fmt.Sprintf("File is larger than %d MB", maxCSVBytes>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+		result, err := table.Import(resolver, r.Context(), householdID, bytes.NewReader(data), dryRun)
+		if err != nil {
+			writeHTTPError(w, err, "importing CSV")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Println("Error writing response:", err)
+		}
+	}
+}
+
+// Work out the absolute URL a request reached the server at.
+//
+// Parameters:
+// 	- r: Incoming request
+//
+// Returns:
+// 	Scheme and host, e.g. "https://ambrosia.example.com"
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,156 @@
+// Validate uploaded images and generate their thumbnails.
+package images
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Largest upload accepted, in bytes.
+const MaxBytes = 10 << 20
+
+// Largest image accepted, in pixels, to guard against decompression bombs.
+const maxPixels = 40_000_000
+
+// Longest side of a thumbnail, in pixels.
+const ThumbnailSize = 320
+
+// Content types accepted for uploads, as sniffed from their contents.
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// An uploaded image ready to be stored.
+type Processed struct {
+	ContentType string
+	Width       int
+	Height      int
+	Original    []byte
+	Thumbnail   []byte
+}
+
+// Generate a new, unguessable image ID.
+//
+// Returns:
+//   - Image ID
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate image id; error: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Blob store key of an image's original upload.
+func OriginalKey(id string) string {
+	return "images/" + id + "/original"
+}
+
+// Blob store key of an image's thumbnail.
+func ThumbnailKey(id string) string {
+	return "images/" + id + "/thumbnail"
+}
+
+// URL the original image is served from.
+func URL(id string) string {
+	return "/images/" + id
+}
+
+// URL the thumbnail is served from.
+func ThumbnailURL(id string) string {
+	return "/images/" + id + "/thumbnail"
+}
+
+// Validate an upload and generate its thumbnail.
+// The content type is sniffed from the data rather than trusted from the client.
+//
+// Parameters:
+//   - r: Uploaded data
+//
+// Returns:
+//   - The processed image
+func Process(r io.Reader) (*Processed, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image; error: %v", err)
+	}
+	if len(data) > MaxBytes {
+		return nil, fmt.Errorf("image is larger than %d MB", MaxBytes>>20)
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return nil, fmt.Errorf("unsupported image type %q; use JPEG, PNG, GIF or WebP", contentType)
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image; error: %v", err)
+	}
+	if config.Width*config.Height > maxPixels {
+		return nil, fmt.Errorf("image is too large at %dx%d pixels", config.Width, config.Height)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image; error: %v", err)
+	}
+
+	thumbnail, err := Thumbnail(img, ThumbnailSize)
+	if err != nil {
+		return nil, err
+	}
+	return &Processed{
+		ContentType: contentType,
+		Width:       config.Width,
+		Height:      config.Height,
+		Original:    data,
+		Thumbnail:   thumbnail,
+	}, nil
+}
+
+// Shrink an image to fit a square and encode it as JPEG.
+// Images already small enough keep their size, and transparency is flattened onto white.
+//
+// Parameters:
+//   - img: Image to shrink
+//   - size: Longest side of the result, in pixels
+//
+// Returns:
+//   - JPEG encoded thumbnail
+func Thumbnail(img image.Image, size int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > size || height > size {
+		if width >= height {
+			width, height = size, max(1, height*size/width)
+		} else {
+			width, height = max(1, width*size/height), size
+		}
+	}
+
+	thumbnail := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(thumbnail, thumbnail.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(thumbnail, thumbnail.Bounds(), img, bounds, draw.Over, nil)
+
+	var out bytes.Buffer
+	err := jpeg.Encode(&out, thumbnail, &jpeg.Options{Quality: 80})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail; error: %v", err)
+	}
+	return out.Bytes(), nil
+}
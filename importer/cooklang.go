@@ -0,0 +1,224 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+var (
+	cooklangBlockComment = regexp.MustCompile(`(?s)\[-.*?-\]`)
+	cooklangLineComment  = regexp.MustCompile(`--.*`)
+	// A component is an ingredient (@), cookware (#) or timer (~), either with
+	// braces, which allow names of several words and a preparation in parentheses
+	// after them, or as a single word
+	cooklangMarkup = regexp.MustCompile(`([@#~])(?:([^@#~{}\n]*)\{([^}]*)\}(?:\(([^)]*)\))?|([\p{L}\p{N}_]+))`)
+	// Separators allowed between ingredients in a step that only lists ingredients
+	cooklangListFiller = regexp.MustCompile(`^[\s,;.]*$`)
+)
+
+// A component found in a Cooklang step.
+type cooklangComponent struct {
+	// "@", "#" or "~"
+	kind   string
+	name   string
+	amount string
+	// Text in parentheses after an ingredient, e.g. "finely chopped"
+	preparation string
+	// Position of the component in the step text
+	start, end int
+}
+
+// Read a recipe written in Cooklang (https://cooklang.org).
+// Each paragraph is a step, with ingredients, cookware and timers marked inline, e.g.
+// "Simmer the @tomatoes{400%g} in a #pot{} for ~{20%minutes}". Metadata may be given as
+// front matter or ">> key: value" lines.
+// Ingredients are read from the steps, and a paragraph made only of ingredients is read
+// as a list of them rather than a step. Timers at the end of a step give how long it takes
+// and are left out of its text.
+//
+// Parameters:
+//   - text: Cooklang document
+//
+// Returns:
+//   - The recipe read from the document
+func FromCooklang(text string) (*Recipe, error) {
+	recipe := Recipe{}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	lines, err := recipe.readFrontMatter(lines)
+	if err != nil {
+		return nil, err
+	}
+	body := cooklangBlockComment.ReplaceAllString(strings.Join(lines, "\n"), "")
+
+	description := []string{}
+	paragraphs := [][]string{{}}
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(cooklangLineComment.ReplaceAllString(line, ""))
+		switch {
+		case strings.HasPrefix(line, ">>"):
+			key, value, ok := strings.Cut(strings.TrimPrefix(line, ">>"), ":")
+			if !ok {
+				recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored metadata line %q", line))
+				continue
+			}
+			recipe.readMetadata(key, value)
+		case strings.HasPrefix(line, ">"):
+			// Notes describe the recipe rather than a step
+			description = append(description, strings.TrimSpace(strings.TrimPrefix(line, ">")))
+		case strings.HasPrefix(line, "="):
+			// Section headings have no equivalent, so the steps are kept in order without them
+			paragraphs = append(paragraphs, []string{})
+		case line == "":
+			paragraphs = append(paragraphs, []string{})
+		default:
+			paragraphs[len(paragraphs)-1] = append(paragraphs[len(paragraphs)-1], line)
+		}
+	}
+	if len(description) > 0 && recipe.Description == "" {
+		recipe.Description = strings.Join(description, "\n")
+	}
+
+	for _, paragraph := range paragraphs {
+		if len(paragraph) == 0 {
+			continue
+		}
+		recipe.readCooklangStep(strings.Join(paragraph, " "))
+	}
+	if recipe.Name == "" {
+		recipe.Warnings = append(recipe.Warnings, "recipe has no title")
+	}
+	return &recipe, nil
+}
+
+// Read a Cooklang step, adding its ingredients to the recipe and the step itself unless
+// it only lists ingredients.
+//
+// Parameters:
+//   - text: Text of the step, its lines joined
+func (recipe *Recipe) readCooklangStep(text string) {
+	components := []cooklangComponent{}
+	for _, match := range cooklangMarkup.FindAllStringSubmatchIndex(text, -1) {
+		component := cooklangComponent{kind: text[match[2]:match[3]], start: match[0], end: match[1]}
+		if match[4] >= 0 {
+			component.name = strings.TrimSpace(text[match[4]:match[5]])
+			component.amount = strings.TrimSpace(text[match[6]:match[7]])
+			if match[8] >= 0 {
+				component.preparation = strings.TrimSpace(text[match[8]:match[9]])
+			}
+		} else {
+			component.name = text[match[10]:match[11]]
+		}
+		components = append(components, component)
+	}
+
+	onlyIngredients := len(components) > 0
+	for i, component := range components {
+		previous := 0
+		if i > 0 {
+			previous = components[i-1].end
+		}
+		if component.kind != "@" || !cooklangListFiller.MatchString(text[previous:component.start]) {
+			onlyIngredients = false
+		}
+	}
+	if onlyIngredients && !cooklangListFiller.MatchString(text[components[len(components)-1].end:]) {
+		onlyIngredients = false
+	}
+
+	// Timers with nothing but space after them give the step's duration
+	trailing := len(components)
+	for trailing > 0 && components[trailing-1].kind == "~" && components[trailing-1].name == "" {
+		end := len(text)
+		if trailing < len(components) {
+			end = components[trailing].start
+		}
+		if strings.TrimSpace(text[components[trailing-1].end:end]) != "" {
+			break
+		}
+		trailing--
+	}
+
+	var b strings.Builder
+	var duration *time.Duration
+	position := 0
+	for i, component := range components {
+		b.WriteString(text[position:component.start])
+		position = component.end
+
+		switch component.kind {
+		case "@":
+			if component.name == "" {
+				recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("skipped ingredient with no name in %q", text))
+				continue
+			}
+			recipe.Ingredients = append(recipe.Ingredients, recipe.cooklangIngredient(component))
+			b.WriteString(component.name)
+		case "#":
+			b.WriteString(component.name)
+		case "~":
+			quantity, unit, _ := strings.Cut(component.amount, "%")
+			d, err := parseTime(quantity + " " + unit)
+			if err != nil {
+				recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored timer %q: %v", text[component.start:component.end], err))
+			} else if i >= trailing {
+				if duration == nil {
+					duration = new(time.Duration)
+				}
+				*duration += d
+				continue
+			}
+			if component.name != "" {
+				b.WriteString(component.name)
+			} else {
+				b.WriteString(strings.TrimSpace(quantity + " " + unit))
+			}
+		}
+	}
+	b.WriteString(text[position:])
+
+	if onlyIngredients {
+		return
+	}
+	recipe.Steps = append(recipe.Steps, Step{Text: strings.TrimSpace(b.String()), Duration: duration})
+}
+
+// Build an ingredient line from a Cooklang ingredient, e.g. "@flour{2%cups}(sifted)".
+// Notes on the ingredient may follow its preparation after a semicolon, as exports write
+// them, e.g. "@salt{}(; to taste)".
+//
+// Parameters:
+//   - component: Ingredient component
+//
+// Returns:
+//   - Ingredient line
+func (recipe *Recipe) cooklangIngredient(component cooklangComponent) ingredientline.Line {
+	line := ingredientline.Line{Name: component.name, Text: strings.TrimSpace(strings.ReplaceAll(component.amount, "%", " ") + " " + component.name)}
+	if component.preparation != "" {
+		preparation, notes, _ := strings.Cut(component.preparation, ";")
+		line.Preparation = strings.TrimSpace(preparation)
+		line.Notes = strings.TrimSpace(notes)
+		line.Text += " (" + component.preparation + ")"
+	}
+	quantity, unit, _ := strings.Cut(component.amount, "%")
+	if quantity = strings.TrimSpace(quantity); quantity != "" {
+		if value, ok := ingredientline.ParseQuantity(quantity); ok {
+			line.Quantity = &value
+		} else {
+			recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored amount %q of %s", quantity, component.name))
+		}
+	}
+	if unit = strings.TrimSpace(unit); unit != "" && line.Quantity != nil {
+		if known, ok := units.Lookup(unit); ok {
+			unit = known.Name
+		}
+		if unit != "" {
+			line.Unit = &unit
+		}
+	}
+	return line
+}
@@ -0,0 +1,106 @@
+// Read recipes from other formats into a common shape ready to be saved.
+package importer
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+)
+
+// A recipe read from another format, not yet matched to stored ingredients.
+type Recipe struct {
+	Name        string
+	Description string
+	Servings    *int
+	PrepTime    *time.Duration
+	CookTime    *time.Duration
+	RestTime    *time.Duration
+	Ingredients []ingredientline.Line
+	Steps       []Step
+	// Images referenced by the source, left for the client to fetch and upload
+	ImageURLs []string
+	SourceURL *string
+	// Image data embedded in the source, e.g. a Paprika photo
+	Photo []byte
+	// Categories the source filed the recipe under
+	Categories []string
+	// Rating out of 5 given in the source
+	Rating *int
+	// Problems found while reading that did not stop the import
+	Warnings []string
+}
+
+// A step of an imported recipe.
+type Step struct {
+	Text     string
+	Duration *time.Duration
+}
+
+// A recipe read from a file holding several, or why it could not be read.
+type ArchiveEntry struct {
+	// Name of the recipe, or of the entry in the file when the recipe could not be read
+	Name   string
+	Recipe *Recipe
+	Err    error
+}
+
+// Read the recipes in a MealMaster file or Paprika export, telling them apart by their contents.
+//
+// Parameters:
+//   - data: Contents of the file
+//
+// Returns:
+//   - Entry for each recipe found
+func FromArchive(data []byte) ([]ArchiveEntry, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("PK")), bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return FromPaprika(data)
+	default:
+		return FromMealMaster(string(data))
+	}
+}
+
+var tags = regexp.MustCompile(`<[^>]*>`)
+
+// Strip markup and entities from text taken from a web page, collapsing whitespace.
+//
+// Parameters:
+//   - text: Text to clean
+//
+// Returns:
+//   - Plain text
+func cleanText(text string) string {
+	text = html.UnescapeString(tags.ReplaceAllString(text, " "))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+var leadingNumber = regexp.MustCompile(`\d+`)
+
+// Read a number of servings from text such as "4 servings" or "Serves 6".
+//
+// Parameters:
+//   - text: Yield text
+//
+// Returns:
+//   - Number of servings, nil if none was found
+func parseServings(text string) *int {
+	match := leadingNumber.FindString(text)
+	if match == "" {
+		return nil
+	}
+	servings := 0
+	for _, digit := range match {
+		servings = servings*10 + int(digit-'0')
+		if servings > 10000 {
+			return nil
+		}
+	}
+	if servings == 0 {
+		return nil
+	}
+	return &servings
+}
@@ -0,0 +1,234 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+)
+
+var jsonLDScripts = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+
+// Read a schema.org Recipe from the JSON-LD embedded in a web page.
+//
+// Parameters:
+//   - page: HTML of the page
+//
+// Returns:
+//   - The first recipe found
+func FromHTML(page string) (*Recipe, error) {
+	matches := jsonLDScripts.FindAllStringSubmatch(page, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("page has no JSON-LD")
+	}
+	for _, match := range matches {
+		recipe, err := FromJSONLD(match[1])
+		if err == nil {
+			return recipe, nil
+		}
+	}
+	return nil, fmt.Errorf("page has no schema.org Recipe")
+}
+
+// Read a schema.org Recipe from a JSON-LD document. The recipe may be the document
+// itself, one of an array of nodes, or a node of an "@graph".
+//
+// Parameters:
+//   - document: JSON-LD text
+//
+// Returns:
+//   - The first recipe found
+func FromJSONLD(document string) (*Recipe, error) {
+	var value interface{}
+	err := json.Unmarshal([]byte(strings.TrimSpace(document)), &value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON-LD; error: %v", err)
+	}
+	node := findRecipeNode(value)
+	if node == nil {
+		return nil, fmt.Errorf("JSON-LD has no schema.org Recipe")
+	}
+	return readRecipeNode(node), nil
+}
+
+// Search a JSON-LD value for a node typed as a Recipe.
+func findRecipeNode(value interface{}) map[string]interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if node := findRecipeNode(item); node != nil {
+				return node
+			}
+		}
+	case map[string]interface{}:
+		for _, t := range texts(v["@type"]) {
+			if t == "Recipe" || strings.HasSuffix(t, "/Recipe") {
+				return v
+			}
+		}
+		if node := findRecipeNode(v["@graph"]); node != nil {
+			return node
+		}
+		return findRecipeNode(v["mainEntity"])
+	}
+	return nil
+}
+
+// Read the fields of a schema.org Recipe node.
+func readRecipeNode(node map[string]interface{}) *Recipe {
+	recipe := Recipe{
+		Name:        cleanText(first(texts(node["name"]))),
+		Description: cleanText(first(texts(node["description"]))),
+	}
+	if recipe.Name == "" {
+		recipe.Name = "Imported recipe"
+		recipe.Warnings = append(recipe.Warnings, "recipe has no name")
+	}
+	if url := first(texts(node["url"])); url != "" {
+		recipe.SourceURL = &url
+	}
+
+	for _, yield := range texts(node["recipeYield"]) {
+		if recipe.Servings = parseServings(yield); recipe.Servings != nil {
+			break
+		}
+	}
+
+	ingredients := texts(node["recipeIngredient"])
+	if len(ingredients) == 0 {
+		ingredients = texts(node["ingredients"])
+	}
+	for _, text := range ingredients {
+		if text = cleanText(text); text != "" {
+			recipe.Ingredients = append(recipe.Ingredients, ingredientline.Parse(text))
+		}
+	}
+
+	recipe.Steps = readInstructions(node["recipeInstructions"])
+	recipe.ImageURLs = readImages(node["image"])
+	recipe.readTimes(node)
+	return &recipe
+}
+
+// Read prepTime, cookTime and totalTime. schema.org has no rest time, so any
+// total beyond prep and cook is kept as rest; a lone total is kept as cook time.
+func (recipe *Recipe) readTimes(node map[string]interface{}) {
+	parse := func(field string) *time.Duration {
+		text := first(texts(node[field]))
+		if text == "" {
+			return nil
+		}
+		d, err := model.ParseDuration(text)
+		if err != nil {
+			recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored %s: %v", field, err))
+			return nil
+		}
+		return &d
+	}
+	recipe.PrepTime = parse("prepTime")
+	recipe.CookTime = parse("cookTime")
+	total := parse("totalTime")
+	if total == nil {
+		return
+	}
+
+	if recipe.PrepTime == nil && recipe.CookTime == nil {
+		recipe.CookTime = total
+		return
+	}
+	rest := *total
+	for _, part := range []*time.Duration{recipe.PrepTime, recipe.CookTime} {
+		if part != nil {
+			rest -= *part
+		}
+	}
+	if rest > 0 {
+		recipe.RestTime = &rest
+	}
+}
+
+// Read recipeInstructions, which may be text, a list of text, HowToSteps, or
+// HowToSections holding steps.
+func readInstructions(value interface{}) []Step {
+	steps := []Step{}
+	switch v := value.(type) {
+	case string:
+		for _, line := range strings.Split(tags.ReplaceAllString(v, "\n"), "\n") {
+			if text := cleanText(line); text != "" {
+				steps = append(steps, Step{Text: text})
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			steps = append(steps, readInstructions(item)...)
+		}
+	case map[string]interface{}:
+		if elements, ok := v["itemListElement"]; ok {
+			return readInstructions(elements)
+		}
+		text := cleanText(first(texts(v["text"])))
+		if text == "" {
+			text = cleanText(first(texts(v["name"])))
+		}
+		if text == "" {
+			return steps
+		}
+		step := Step{Text: text}
+		for _, field := range []string{"timeRequired", "totalTime", "performTime"} {
+			if d, err := model.ParseDuration(first(texts(v[field]))); err == nil {
+				step.Duration = &d
+				break
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
+
+// Read image, which may be a URL, an ImageObject, or a list of either.
+func readImages(value interface{}) []string {
+	urls := []string{}
+	switch v := value.(type) {
+	case string:
+		urls = append(urls, v)
+	case []interface{}:
+		for _, item := range v {
+			urls = append(urls, readImages(item)...)
+		}
+	case map[string]interface{}:
+		urls = append(urls, texts(v["url"])...)
+	}
+	return urls
+}
+
+// Read a JSON-LD value that may be a string, number or list of them as strings.
+func texts(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case float64:
+		return []string{fmt.Sprint(v)}
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			values = append(values, texts(item)...)
+		}
+		return values
+	case map[string]interface{}:
+		// Language tagged values, e.g. {"@value": "Pancakes", "@language": "en"}
+		return texts(v["@value"])
+	}
+	return nil
+}
+
+// First of a list of values, or "" if it is empty.
+func first(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
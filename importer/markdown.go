@@ -0,0 +1,142 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+)
+
+var (
+	markdownHeading  = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	markdownBullet   = regexp.MustCompile(`^\s{0,3}[-*+]\s+(?:\[[ xX]\]\s+)?(.*)$`)
+	markdownNumbered = regexp.MustCompile(`^\s{0,3}\d+[.)]\s+(.*)$`)
+	markdownImage    = regexp.MustCompile(`^!\[[^\]]*\]\(([^)\s]+)[^)]*\)$`)
+	stepDuration     = regexp.MustCompile(`\s*\*\(([^)]*)\)\*$`)
+	// A backslash escaping what would otherwise start a new step, e.g. "2\. Stir"
+	stepEscape = regexp.MustCompile(`^(\d*)\\([[:punct:]])`)
+)
+
+// Headings that start the ingredient and step sections, matched in lower case.
+var (
+	ingredientHeadings = map[string]bool{"ingredients": true}
+	stepHeadings       = map[string]bool{"steps": true, "directions": true, "instructions": true, "method": true, "preparation": true}
+)
+
+// Read a recipe from Ambrosia's Markdown format, as written by exporter.Markdown.
+// Front matter is optional and the title may be given as a top level heading instead,
+// so recipes kept as plain notes can be imported too.
+//
+// Parameters:
+//   - text: Markdown document
+//
+// Returns:
+//   - The recipe read from the document
+func FromMarkdown(text string) (*Recipe, error) {
+	recipe := Recipe{}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	lines, err := recipe.readFrontMatter(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	section := ""
+	description := []string{}
+	var ingredient, step *string
+	ingredients := []string{}
+	steps := []string{}
+	blank := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			if section == "" && len(description) > 0 {
+				description = append(description, "")
+			}
+			continue
+		}
+
+		if match := markdownHeading.FindStringSubmatch(line); match != nil {
+			name := strings.ToLower(strings.TrimSuffix(match[2], ":"))
+			switch {
+			case len(match[1]) == 1 && section == "" && len(description) == 0:
+				if recipe.Name == "" {
+					recipe.Name = match[2]
+				}
+			case ingredientHeadings[name]:
+				section = "ingredients"
+			case stepHeadings[name]:
+				section = "steps"
+			default:
+				section = "skipped"
+				recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("skipped section %q", match[2]))
+			}
+			ingredient, step = nil, nil
+			blank = true
+			continue
+		}
+
+		switch section {
+		case "":
+			description = append(description, strings.TrimSpace(line))
+		case "ingredients":
+			if match := markdownBullet.FindStringSubmatch(line); match != nil || ingredient == nil || blank {
+				text := strings.TrimSpace(line)
+				if match != nil {
+					text = match[1]
+				}
+				ingredients = append(ingredients, text)
+				ingredient = &ingredients[len(ingredients)-1]
+			} else {
+				*ingredient += " " + strings.TrimSpace(line)
+			}
+		case "steps":
+			trimmed := strings.TrimSpace(line)
+			if match := markdownImage.FindStringSubmatch(trimmed); match != nil {
+				recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("step %d image %s was not imported; upload it with setRecipeStepImage", len(steps), match[1]))
+				continue
+			}
+			match := markdownNumbered.FindStringSubmatch(line)
+			if match == nil {
+				match = markdownBullet.FindStringSubmatch(line)
+			}
+			if match != nil || step == nil || blank && !strings.HasPrefix(line, " ") {
+				text := trimmed
+				if match != nil {
+					text = match[1]
+				}
+				steps = append(steps, text)
+				step = &steps[len(steps)-1]
+			} else {
+				if blank {
+					*step += "\n"
+				}
+				*step += "\n" + stepEscape.ReplaceAllString(trimmed, "$1$2")
+			}
+		}
+		blank = false
+	}
+
+	if recipe.Name == "" {
+		recipe.Warnings = append(recipe.Warnings, "recipe has no title")
+	}
+	if len(description) > 0 {
+		recipe.Description = strings.TrimSpace(strings.Join(description, "\n"))
+	}
+	for _, text := range ingredients {
+		recipe.Ingredients = append(recipe.Ingredients, ingredientline.ParseMarked(text))
+	}
+	for _, text := range steps {
+		step := Step{Text: text}
+		if match := stepDuration.FindStringSubmatchIndex(text); match != nil {
+			if d, err := model.ParseDuration(text[match[2]:match[3]]); err == nil {
+				step.Text = text[:match[0]]
+				step.Duration = &d
+			}
+		}
+		recipe.Steps = append(recipe.Steps, step)
+	}
+	return &recipe, nil
+}
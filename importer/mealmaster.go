@@ -0,0 +1,208 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+var (
+	mealMasterStart   = regexp.MustCompile(`(?i)^(MMMMM|-----)-+.*meal-master`)
+	mealMasterEnd     = regexp.MustCompile(`^(MMMMM|-----)\s*$`)
+	mealMasterSection = regexp.MustCompile(`^(MMMMM|-----)-+.*-+\s*$`)
+	mealMasterField   = regexp.MustCompile(`^\s*(Title|Categories|Yield|Servings)\s*:\s*(.*)$`)
+	numberedStep      = regexp.MustCompile(`^\d+[.)]\s+`)
+)
+
+// Names of MealMaster's two letter unit codes. Codes without a matching Ambrosia unit
+// are spelled out, and "x" (per serving) and "ea" are counts.
+var mealMasterUnits = map[string]string{
+	"x": "", "ea": "",
+	"t": "tsp", "ts": "tsp", "T": "tbsp", "tb": "tbsp",
+	"fl": "fl oz", "c": "cup", "pt": "pint", "qt": "quart", "ga": "gallon",
+	"oz": "oz", "lb": "lb", "mg": "mg", "g": "g", "kg": "kg",
+	"ml": "ml", "cb": "ml", "cl": "cl", "dl": "dl", "l": "l",
+	"sm": "small", "md": "medium", "lg": "large", "cn": "can", "pk": "package",
+	"pn": "pinch", "dr": "drop", "ds": "dash", "ct": "carton", "bn": "bunch", "sl": "slice",
+}
+
+// Width of one ingredient column; two column layouts put the second at this offset.
+const mealMasterColumn = 41
+
+// Read the recipes in a MealMaster export. Files usually hold many recipes, each between
+// a "MMMMM----- Recipe via Meal-Master" header and a closing "MMMMM" line, and a recipe
+// that cannot be read is reported without stopping the rest.
+//
+// Parameters:
+//   - text: Contents of the MealMaster file
+//
+// Returns:
+//   - Entry for each recipe found
+func FromMealMaster(text string) ([]ArchiveEntry, error) {
+	lines := strings.Split(strings.ReplaceAll(strings.ToValidUTF8(text, "?"), "\r\n", "\n"), "\n")
+
+	entries := []ArchiveEntry{}
+	var block []string
+	for _, line := range lines {
+		switch {
+		case mealMasterStart.MatchString(line):
+			if block != nil {
+				entries = append(entries, readMealMaster(block))
+			}
+			block = []string{}
+		case block != nil && mealMasterEnd.MatchString(line):
+			entries = append(entries, readMealMaster(block))
+			block = nil
+		case block != nil:
+			block = append(block, strings.TrimRight(line, " \t"))
+		}
+	}
+	if block != nil {
+		entries = append(entries, readMealMaster(block))
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("found no MealMaster recipes")
+	}
+	return entries, nil
+}
+
+// Read a single MealMaster recipe.
+//
+// Parameters:
+//   - lines: Lines between the recipe's header and closing line
+//
+// Returns:
+//   - Entry for the recipe
+func readMealMaster(lines []string) ArchiveEntry {
+	recipe := Recipe{}
+	i := 0
+	for ; i < len(lines); i++ {
+		match := mealMasterField.FindStringSubmatch(lines[i])
+		if match == nil {
+			if strings.TrimSpace(lines[i]) == "" {
+				continue
+			}
+			break
+		}
+		value := strings.TrimSpace(match[2])
+		switch match[1] {
+		case "Title":
+			recipe.Name = value
+		case "Categories":
+			for _, category := range strings.Split(value, ",") {
+				if category = strings.TrimSpace(category); category != "" && !strings.EqualFold(category, "none") {
+					recipe.Categories = append(recipe.Categories, category)
+				}
+			}
+		default:
+			recipe.Servings = parseServings(value)
+		}
+	}
+	if recipe.Name == "" {
+		return ArchiveEntry{Err: fmt.Errorf("recipe has no title")}
+	}
+
+	// Ingredients run until the first line that is not laid out as one
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if strings.TrimSpace(line) == "" || mealMasterSection.MatchString(line) {
+			continue
+		}
+		left, right := line, ""
+		if len(line) > mealMasterColumn {
+			if _, ok := mealMasterIngredient(line[mealMasterColumn:]); ok {
+				left, right = line[:mealMasterColumn], line[mealMasterColumn:]
+			}
+		}
+		parsed, ok := mealMasterIngredient(left)
+		if !ok {
+			break
+		}
+		recipe.addMealMasterIngredient(parsed)
+		if right != "" {
+			parsed, _ := mealMasterIngredient(right)
+			recipe.addMealMasterIngredient(parsed)
+		}
+	}
+
+	// Directions are paragraphs, or numbered lines when they are numbered
+	paragraph := []string{}
+	flush := func() {
+		if len(paragraph) > 0 {
+			recipe.Steps = append(recipe.Steps, Step{Text: strings.Join(paragraph, " ")})
+			paragraph = []string{}
+		}
+	}
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || mealMasterSection.MatchString(lines[i]) {
+			flush()
+			continue
+		}
+		if numberedStep.MatchString(line) {
+			flush()
+			line = numberedStep.ReplaceAllString(line, "")
+		}
+		paragraph = append(paragraph, line)
+	}
+	flush()
+
+	return ArchiveEntry{Name: recipe.Name, Recipe: &recipe}
+}
+
+// Read an ingredient laid out in MealMaster's columns: quantity in the first seven,
+// a unit code in the two after a space, and the name from the twelfth on.
+// Names starting with "-" continue the previous ingredient.
+//
+// Parameters:
+//   - column: Text of one ingredient column
+//
+// Returns:
+//   - Parsed ingredient, and whether the text was laid out as one
+func mealMasterIngredient(column string) (ingredientline.Line, bool) {
+	padded := column + strings.Repeat(" ", max(0, 11-len(column)))
+	quantityText := strings.TrimSpace(padded[:7])
+	code := strings.TrimSpace(padded[8:10])
+	name := strings.TrimSpace(padded[11:])
+	if padded[7] != ' ' || padded[10] != ' ' || name == "" {
+		return ingredientline.Line{}, false
+	}
+	unit, ok := mealMasterUnits[code]
+	if code != "" && !ok {
+		return ingredientline.Line{}, false
+	}
+
+	line := ingredientline.Line{Text: strings.Join(strings.Fields(column), " "), Name: name}
+	if quantityText != "" {
+		quantity, ok := ingredientline.ParseQuantity(quantityText)
+		if !ok {
+			return ingredientline.Line{}, false
+		}
+		line.Quantity = &quantity
+	}
+	if unit != "" {
+		if known, ok := units.Lookup(unit); ok {
+			unit = known.Name
+		}
+		line.Unit = &unit
+	}
+	return line, true
+}
+
+// Add an ingredient read from a MealMaster recipe, joining continuation lines to the
+// ingredient before them.
+//
+// Parameters:
+//   - line: Parsed ingredient
+func (recipe *Recipe) addMealMasterIngredient(line ingredientline.Line) {
+	if strings.HasPrefix(line.Name, "-") && line.Quantity == nil && line.Unit == nil && len(recipe.Ingredients) > 0 {
+		previous := &recipe.Ingredients[len(recipe.Ingredients)-1]
+		previous.Name += " " + strings.TrimSpace(strings.TrimPrefix(line.Name, "-"))
+		previous.Text += " " + strings.TrimSpace(strings.TrimPrefix(line.Name, "-"))
+		return
+	}
+	recipe.Ingredients = append(recipe.Ingredients, line)
+}
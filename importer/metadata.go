@@ -0,0 +1,172 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Read the YAML front matter at the top of a document into the recipe, if there is any.
+//
+// Parameters:
+//   - lines: Lines of the document
+//
+// Returns:
+//   - The lines following the front matter
+func (recipe *Recipe) readFrontMatter(lines []string) ([]string, error) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return lines, nil
+	}
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if trimmed := strings.TrimSpace(lines[i]); trimmed == "---" || trimmed == "..." {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return nil, fmt.Errorf("front matter is not closed with ---")
+	}
+
+	for _, line := range lines[1:end] {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		key, raw, ok := strings.Cut(line, ":")
+		if !ok {
+			recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored front matter line %q", line))
+			continue
+		}
+		recipe.readMetadata(key, yamlValue(raw))
+	}
+	return lines[end+1:], nil
+}
+
+// Read a metadata field, from front matter or a Cooklang ">>" line, into the recipe.
+// Keys are matched case-insensitively, with spaces, dashes and dots read as underscores.
+//
+// Parameters:
+//   - key: Name of the field, e.g. "prep_time" or "prep time"
+//   - value: Value of the field
+func (recipe *Recipe) readMetadata(key string, value string) {
+	key = strings.NewReplacer(" ", "_", "-", "_", ".", "_").Replace(strings.ToLower(strings.TrimSpace(key)))
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	switch key {
+	case "title", "name":
+		recipe.Name = value
+	case "description":
+		recipe.Description = value
+	case "servings", "serves", "yield":
+		if recipe.Servings = parseServings(value); recipe.Servings == nil {
+			recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored %s %q", key, value))
+		}
+	case "prep_time", "time_prep", "cook_time", "time_cook", "rest_time", "time_rest":
+		d, err := parseTime(value)
+		if err != nil {
+			recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored %s: %v", key, err))
+			return
+		}
+		switch key {
+		case "prep_time", "time_prep":
+			recipe.PrepTime = &d
+		case "cook_time", "time_cook":
+			recipe.CookTime = &d
+		default:
+			recipe.RestTime = &d
+		}
+	case "total_time", "time_required", "time", "duration":
+		// Only a total is known, so like a lone schema.org totalTime it is taken as the cook time
+		d, err := parseTime(value)
+		if err != nil {
+			recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored %s: %v", key, err))
+			return
+		}
+		if recipe.CookTime == nil {
+			recipe.CookTime = &d
+		}
+	case "source", "source_url", "url":
+		recipe.SourceURL = &value
+	case "image":
+		recipe.ImageURLs = append(recipe.ImageURLs, value)
+	default:
+		recipe.Warnings = append(recipe.Warnings, fmt.Sprintf("ignored metadata field %q", key))
+	}
+}
+
+// Read a YAML scalar, unquoting it and dropping any trailing comment.
+//
+// Parameters:
+//   - raw: Text after the key's colon
+//
+// Returns:
+//   - The value as text
+func yamlValue(raw string) string {
+	value := strings.TrimSpace(raw)
+	switch {
+	case strings.HasPrefix(value, `"`):
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			return unquoted
+		}
+	case strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'") && len(value) > 1:
+		return strings.ReplaceAll(value[1:len(value)-1], "''", "'")
+	}
+	if comment := strings.Index(value, " #"); comment >= 0 {
+		value = strings.TrimSpace(value[:comment])
+	}
+	return value
+}
+
+var (
+	timePart   = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\s*([a-z]+)`)
+	timeFiller = regexp.MustCompile(`(?i)^[\s,]*(and)?[\s,]*$`)
+)
+
+// Length of each spelling of a time unit.
+var timeUnits = map[string]time.Duration{
+	"s": time.Second, "sec": time.Second, "secs": time.Second, "second": time.Second, "seconds": time.Second,
+	"m": time.Minute, "min": time.Minute, "mins": time.Minute, "minute": time.Minute, "minutes": time.Minute,
+	"h": time.Hour, "hr": time.Hour, "hrs": time.Hour, "hour": time.Hour, "hours": time.Hour,
+	"d": 24 * time.Hour, "day": 24 * time.Hour, "days": 24 * time.Hour,
+}
+
+// Read a time written as an ISO 8601 duration, in words such as "1 hour 30 minutes"
+// or "1h30m", or as a plain number of minutes.
+//
+// Parameters:
+//   - text: Time to read
+//
+// Returns:
+//   - The duration
+func parseTime(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	if d, err := model.ParseDuration(text); err == nil {
+		return d, nil
+	}
+	if minutes, err := strconv.ParseFloat(text, 64); err == nil && minutes >= 0 {
+		return time.Duration(minutes * float64(time.Minute)), nil
+	}
+
+	var total time.Duration
+	end := 0
+	for _, match := range timePart.FindAllStringSubmatchIndex(text, -1) {
+		unit, ok := timeUnits[strings.ToLower(text[match[4]:match[5]])]
+		if !ok || !timeFiller.MatchString(text[end:match[0]]) {
+			return 0, fmt.Errorf("%q is not a duration", text)
+		}
+		amount, _ := strconv.ParseFloat(strings.ReplaceAll(text[match[2]:match[3]], ",", "."), 64)
+		total += time.Duration(amount * float64(unit))
+		end = match[1]
+	}
+	if end == 0 || !timeFiller.MatchString(text[end:]) {
+		return 0, fmt.Errorf("%q is not a duration", text)
+	}
+	return total, nil
+}
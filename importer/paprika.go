@@ -0,0 +1,167 @@
+package importer
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+)
+
+// Largest recipe accepted once decompressed, to guard against decompression bombs.
+const maxPaprikaRecipeBytes = 32 << 20
+
+// Fields of a recipe in a Paprika export.
+type paprikaRecipe struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Ingredients string   `json:"ingredients"`
+	Directions  string   `json:"directions"`
+	Notes       string   `json:"notes"`
+	Servings    string   `json:"servings"`
+	PrepTime    string   `json:"prep_time"`
+	CookTime    string   `json:"cook_time"`
+	TotalTime   string   `json:"total_time"`
+	SourceURL   string   `json:"source_url"`
+	ImageURL    string   `json:"image_url"`
+	PhotoData   string   `json:"photo_data"`
+	Categories  []string `json:"categories"`
+	Rating      int      `json:"rating"`
+}
+
+// Read the recipes in a Paprika export: either a .paprikarecipes archive, which is a zip
+// of recipes, or a single .paprikarecipe, which is gzipped JSON. A recipe that cannot be
+// read is reported without stopping the rest.
+//
+// Parameters:
+//   - data: Contents of the export
+//
+// Returns:
+//   - Entry for each recipe found
+func FromPaprika(data []byte) ([]ArchiveEntry, error) {
+	if !bytes.HasPrefix(data, []byte("PK")) {
+		recipe, err := readPaprika(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		return []ArchiveEntry{{Name: recipe.Name, Recipe: recipe}}, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Paprika archive; error: %v", err)
+	}
+	entries := []ArchiveEntry{}
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		entry := ArchiveEntry{Name: strings.TrimSuffix(path.Base(file.Name), path.Ext(file.Name))}
+		r, err := file.Open()
+		if err != nil {
+			entry.Err = err
+			entries = append(entries, entry)
+			continue
+		}
+		entry.Recipe, entry.Err = readPaprika(r)
+		r.Close()
+		if entry.Recipe != nil && entry.Recipe.Name != "" {
+			entry.Name = entry.Recipe.Name
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("found no recipes in Paprika archive")
+	}
+	return entries, nil
+}
+
+// Read a single gzipped Paprika recipe.
+//
+// Parameters:
+//   - r: Compressed recipe
+//
+// Returns:
+//   - The recipe
+func readPaprika(r io.Reader) (*Recipe, error) {
+	unzipped, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("recipe is not gzipped; error: %v", err)
+	}
+	defer unzipped.Close()
+	data, err := io.ReadAll(io.LimitReader(unzipped, maxPaprikaRecipeBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress recipe; error: %v", err)
+	}
+	if len(data) > maxPaprikaRecipeBytes {
+		return nil, fmt.Errorf("recipe is larger than %d MB", maxPaprikaRecipeBytes>>20)
+	}
+
+	var source paprikaRecipe
+	if err := json.Unmarshal(data, &source); err != nil {
+		return nil, fmt.Errorf("recipe is not valid JSON; error: %v", err)
+	}
+	if strings.TrimSpace(source.Name) == "" {
+		return nil, fmt.Errorf("recipe has no name")
+	}
+
+	recipe := Recipe{
+		Name:        strings.TrimSpace(source.Name),
+		Description: strings.TrimSpace(source.Description),
+		Servings:    parseServings(source.Servings),
+		Categories:  source.Categories,
+	}
+	if notes := strings.TrimSpace(source.Notes); notes != "" {
+		recipe.Description = strings.TrimSpace(recipe.Description + "\n\n" + notes)
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+	}{
+		{"prep_time", source.PrepTime},
+		{"cook_time", source.CookTime},
+		{"total_time", source.TotalTime},
+	} {
+		recipe.readMetadata(field.name, field.value)
+	}
+	if source.Rating > 0 {
+		rating := source.Rating
+		recipe.Rating = &rating
+	}
+	if source.SourceURL != "" {
+		recipe.SourceURL = &source.SourceURL
+	}
+
+	for _, text := range strings.Split(source.Ingredients, "\n") {
+		text = strings.TrimSpace(text)
+		// Headings such as "For the sauce:" group the lines after them
+		if text == "" || strings.HasSuffix(text, ":") {
+			continue
+		}
+		recipe.Ingredients = append(recipe.Ingredients, ingredientline.Parse(text))
+	}
+	for _, text := range strings.Split(source.Directions, "\n") {
+		if text = strings.TrimSpace(numberedStep.ReplaceAllString(strings.TrimSpace(text), "")); text != "" {
+			recipe.Steps = append(recipe.Steps, Step{Text: text})
+		}
+	}
+
+	if source.PhotoData != "" {
+		photo, err := base64.StdEncoding.DecodeString(source.PhotoData)
+		if err != nil {
+			recipe.Warnings = append(recipe.Warnings, "photo could not be decoded")
+		} else {
+			recipe.Photo = photo
+		}
+	}
+	if recipe.Photo == nil && source.ImageURL != "" {
+		recipe.ImageURLs = []string{source.ImageURL}
+	}
+	return &recipe, nil
+}
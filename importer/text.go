@@ -0,0 +1,299 @@
+package importer
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/ingredientline"
+)
+
+// How sure the text reader is about a part of the recipe it found.
+type Confidence string
+
+const (
+	High   Confidence = "HIGH"
+	Medium Confidence = "MEDIUM"
+	Low    Confidence = "LOW"
+)
+
+// Parts of a recipe the text reader reports its confidence in.
+const (
+	FieldName        = "NAME"
+	FieldDescription = "DESCRIPTION"
+	FieldIngredients = "INGREDIENTS"
+	FieldSteps       = "STEPS"
+	FieldDetails     = "DETAILS"
+)
+
+// How confident the text reader is about one part of a recipe, and why.
+type Marker struct {
+	Field      string
+	Confidence Confidence
+	Note       string
+}
+
+var (
+	textBullet            = regexp.MustCompile(`^\s*(?:[-*•·▢□☐]|\[ ?\])\s*`)
+	textHeadingMarks      = regexp.MustCompile(`^#+\s*|[:#*_]+$|^[*_]+`)
+	textIngredientHeading = regexp.MustCompile(`(?i)^(ingredients?|what you('| wi)ll need|you will need|shopping list)$`)
+	textStepHeading       = regexp.MustCompile(`(?i)^(directions|instructions|method|steps|preparation|how to make( it)?)$`)
+	textOtherHeading      = regexp.MustCompile(`(?i)^(notes?|tips?|nutrition|equipment)$`)
+	textDetail            = regexp.MustCompile(`(?i)^(serves|servings|yield|makes|prep(?:aration)? time|cook(?:ing)? time|rest(?:ing)? time|total time)\s*:?\s*(.+)$`)
+)
+
+// Longest line taken as a title.
+const maxTitleLength = 80
+
+// A line that has room for a unit and name but reads as a sentence is a step, not an ingredient.
+const maxIngredientLength = 80
+
+// Read a recipe from unstructured text, such as one pasted from a website or a message.
+// The title, description, ingredients and steps are found using headings where there are
+// any, and otherwise by what the lines look like, so the result is a draft to be confirmed.
+//
+// Parameters:
+//   - text: Pasted recipe
+//
+// Returns:
+//   - Tuple of the recipe and how confident the reader is in each part of it
+func FromText(text string) (*Recipe, []Marker) {
+	recipe := Recipe{}
+	markers := []Marker{}
+	lines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\t", " "), "\n")
+
+	// Sort lines into sections, keeping blank lines as paragraph breaks
+	type section struct {
+		kind  string
+		lines []string
+	}
+	sections := []*section{{kind: ""}}
+	headed := map[string]bool{}
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
+		heading := strings.TrimSpace(textHeadingMarks.ReplaceAllString(line, ""))
+		kind := ""
+		switch {
+		case line == "":
+		case textIngredientHeading.MatchString(heading):
+			kind = "ingredients"
+		case textStepHeading.MatchString(heading):
+			kind = "steps"
+		case textOtherHeading.MatchString(heading):
+			kind = "other"
+		}
+		if kind != "" {
+			headed[kind] = true
+			sections = append(sections, &section{kind: kind})
+			continue
+		}
+		if match := textDetail.FindStringSubmatch(line); match != nil && sections[len(sections)-1].kind != "steps" {
+			recipe.readTextDetail(match[1], match[2])
+			continue
+		}
+		current := sections[len(sections)-1]
+		current.lines = append(current.lines, line)
+	}
+
+	// Without headings, work out where the ingredients and steps are from the lines themselves
+	intro := sections[0].lines
+	if !headed["ingredients"] && !headed["steps"] {
+		intro = nil
+		ingredients := &section{kind: "ingredients"}
+		steps := &section{kind: "steps"}
+		for _, line := range sections[0].lines {
+			switch {
+			case line == "":
+				if len(steps.lines) > 0 {
+					steps.lines = append(steps.lines, line)
+				} else if len(ingredients.lines) == 0 {
+					intro = append(intro, line)
+				}
+			case len(steps.lines) == 0 && looksLikeIngredient(line) && (len(ingredients.lines) > 0 || len(intro) > 0):
+				ingredients.lines = append(ingredients.lines, line)
+			case len(ingredients.lines) > 0 || numberedStep.MatchString(line):
+				steps.lines = append(steps.lines, line)
+			default:
+				intro = append(intro, line)
+			}
+		}
+		sections = []*section{ingredients, steps}
+	}
+
+	// The title is the first line, if it is short enough to be one
+	intro = trimBlank(intro)
+	if len(intro) > 0 && len(intro[0]) <= maxTitleLength && !looksLikeIngredient(intro[0]) {
+		recipe.Name = strings.TrimSpace(textHeadingMarks.ReplaceAllString(intro[0], ""))
+		// A title standing on its own line is more likely to be one
+		confidence := Medium
+		if len(intro) == 1 || intro[1] == "" || strings.HasPrefix(intro[0], "#") {
+			confidence = High
+		}
+		intro = trimBlank(intro[1:])
+		markers = append(markers, Marker{FieldName, confidence, "taken from the first line"})
+	} else {
+		markers = append(markers, Marker{FieldName, Low, "no title was found"})
+	}
+	recipe.Description = strings.Join(paragraphs(intro, " "), "\n\n")
+	if recipe.Description != "" {
+		markers = append(markers, Marker{FieldDescription, Medium, "taken from the text before the ingredients"})
+	}
+
+	var ingredientLines, stepLines []string
+	for _, s := range sections {
+		switch s.kind {
+		case "ingredients":
+			ingredientLines = append(ingredientLines, s.lines...)
+		case "steps":
+			stepLines = append(stepLines, s.lines...)
+		}
+	}
+
+	quantified := 0
+	for _, line := range ingredientLines {
+		line = strings.TrimSpace(textBullet.ReplaceAllString(line, ""))
+		// Lines such as "For the sauce:" group the ingredients after them
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		parsed := ingredientline.Parse(line)
+		if parsed.Quantity != nil {
+			quantified++
+		}
+		recipe.Ingredients = append(recipe.Ingredients, parsed)
+	}
+	switch {
+	case len(recipe.Ingredients) == 0:
+		markers = append(markers, Marker{FieldIngredients, Low, "no ingredients were found"})
+	case headed["ingredients"]:
+		markers = append(markers, Marker{FieldIngredients, High, "listed under an ingredients heading"})
+	case quantified*2 >= len(recipe.Ingredients):
+		markers = append(markers, Marker{FieldIngredients, Medium, "recognized by their quantities"})
+	default:
+		markers = append(markers, Marker{FieldIngredients, Low, "most lines have no quantity"})
+	}
+
+	numbered := false
+	for _, line := range stepLines {
+		if numberedStep.MatchString(textBullet.ReplaceAllString(line, "")) {
+			numbered = true
+			break
+		}
+	}
+	if numbered {
+		// Numbered steps may be wrapped over several lines
+		for _, step := range paragraphs(stepLines, " ") {
+			for _, part := range splitNumbered(step) {
+				recipe.Steps = append(recipe.Steps, Step{Text: part})
+			}
+		}
+	} else {
+		// Otherwise each paragraph, or each line when there are no paragraphs, is a step
+		steps := paragraphs(stepLines, " ")
+		if len(steps) == 1 {
+			steps = paragraphs(stepLines, "\n")
+			steps = strings.Split(steps[0], "\n")
+		}
+		for _, step := range steps {
+			if step = strings.TrimSpace(textBullet.ReplaceAllString(step, "")); step != "" {
+				recipe.Steps = append(recipe.Steps, Step{Text: step})
+			}
+		}
+	}
+	switch {
+	case len(recipe.Steps) == 0:
+		markers = append(markers, Marker{FieldSteps, Low, "no steps were found"})
+	case headed["steps"] || numbered:
+		markers = append(markers, Marker{FieldSteps, High, "listed under a steps heading or numbered"})
+	default:
+		markers = append(markers, Marker{FieldSteps, Medium, "taken from the text after the ingredients"})
+	}
+
+	if recipe.Servings != nil || recipe.PrepTime != nil || recipe.CookTime != nil || recipe.RestTime != nil {
+		markers = append(markers, Marker{FieldDetails, High, "servings and times were labelled"})
+	}
+	for _, warning := range recipe.Warnings {
+		markers = append(markers, Marker{FieldDetails, Low, warning})
+	}
+	recipe.Warnings = nil
+	return &recipe, markers
+}
+
+// Read a labelled detail line such as "Serves 4" or "Prep time: 10 minutes".
+//
+// Parameters:
+//   - label: Label of the detail
+//   - value: Text following the label
+func (recipe *Recipe) readTextDetail(label string, value string) {
+	label = strings.ToLower(label)
+	switch {
+	case label == "serves" || label == "servings" || label == "yield" || label == "makes":
+		recipe.readMetadata("servings", value)
+	case strings.HasPrefix(label, "prep"):
+		recipe.readMetadata("prep_time", value)
+	case strings.HasPrefix(label, "cook"):
+		recipe.readMetadata("cook_time", value)
+	case strings.HasPrefix(label, "rest"):
+		recipe.readMetadata("rest_time", value)
+	default:
+		recipe.readMetadata("total_time", value)
+	}
+}
+
+// Check whether a line reads like an ingredient: a short line starting with a quantity or bullet.
+func looksLikeIngredient(line string) bool {
+	if len(line) > maxIngredientLength || numberedStep.MatchString(line) {
+		return false
+	}
+	if textBullet.MatchString(line) {
+		return true
+	}
+	return ingredientline.Parse(line).Quantity != nil && !strings.HasSuffix(line, ".")
+}
+
+// Join lines into paragraphs, which are separated by blank lines.
+//
+// Parameters:
+//   - lines: Lines to join
+//   - separator: Text to join the lines of a paragraph with
+//
+// Returns:
+//   - Paragraphs
+func paragraphs(lines []string, separator string) []string {
+	result := []string{}
+	current := []string{}
+	for _, line := range append(lines, "") {
+		if strings.TrimSpace(line) != "" {
+			current = append(current, strings.TrimSpace(line))
+			continue
+		}
+		if len(current) > 0 {
+			result = append(result, strings.Join(current, separator))
+			current = []string{}
+		}
+	}
+	return result
+}
+
+var numberedInText = regexp.MustCompile(`(?:^|\s)\d+[.)]\s+`)
+
+// Split a paragraph at step numbers, e.g. "1. Mix. 2. Bake." into "Mix." and "Bake.".
+func splitNumbered(paragraph string) []string {
+	parts := []string{}
+	for _, part := range numberedInText.Split(textBullet.ReplaceAllString(paragraph, ""), -1) {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// Drop blank lines from the start and end.
+func trimBlank(lines []string) []string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,364 @@
+// Parse free-text ingredient lines, e.g. "1 1/2 cups flour", into structured parts.
+package ingredientline
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Structured parts of an ingredient line.
+type Line struct {
+	// Original text of the line
+	Text     string
+	Quantity *float64
+	// Upper end of a range such as "2-3", in which case Quantity is the lower end
+	MaxQuantity *float64
+	// Canonical unit name, nil for counts and unknown units
+	Unit *string
+	Name string
+	// How the ingredient is prepared, e.g. "finely chopped"
+	Preparation string
+	// Anything else said about the ingredient, e.g. "about 2" or "to taste"
+	Notes string
+}
+
+// Values of the unicode vulgar fraction characters.
+var vulgarFractions = map[rune]float64{
+	'¼': 0.25, '½': 0.5, '¾': 0.75,
+	'⅐': 1.0 / 7, '⅑': 1.0 / 9, '⅒': 0.1,
+	'⅓': 1.0 / 3, '⅔': 2.0 / 3,
+	'⅕': 0.2, '⅖': 0.4, '⅗': 0.6, '⅘': 0.8,
+	'⅙': 1.0 / 6, '⅚': 5.0 / 6,
+	'⅛': 0.125, '⅜': 0.375, '⅝': 0.625, '⅞': 0.875,
+}
+
+var (
+	parenthetical = regexp.MustCompile(`\(([^)]*)\)`)
+	// A dash between two numbers, e.g. "2-3" or "½–1"
+	rangeDash = regexp.MustCompile(`([0-9¼-¾⅐-⅞])\s*[-–]\s*([0-9¼-¾⅐-⅞])`)
+	// A number with its unit attached, e.g. "200g"
+	gluedUnit = regexp.MustCompile(`^([0-9]+(?:[.,][0-9]+)?)([A-Za-z]+\.?)$`)
+)
+
+// Words describing how an ingredient is prepared, when they come before its name.
+var preparationWords = map[string]bool{
+	"chopped": true, "diced": true, "minced": true, "sliced": true, "grated": true,
+	"shredded": true, "crushed": true, "peeled": true, "melted": true, "softened": true,
+	"beaten": true, "sifted": true, "cubed": true, "julienned": true, "toasted": true,
+	"drained": true, "rinsed": true, "halved": true, "quartered": true, "mashed": true,
+	"packed": true, "trimmed": true, "zested": true, "juiced": true, "crumbled": true,
+	"thawed": true, "seeded": true, "cored": true, "pitted": true, "deveined": true,
+	"torn": true, "whisked": true, "separated": true, "squeezed": true, "shelled": true,
+	"stemmed": true,
+}
+
+// Words that may qualify a preparation word, e.g. "finely" in "finely chopped".
+var preparationQualifiers = map[string]bool{
+	"finely": true, "coarsely": true, "roughly": true, "thinly": true, "thickly": true,
+	"freshly": true, "lightly": true, "firmly": true, "loosely": true, "very": true,
+	"well": true, "and": true,
+}
+
+// Starts of the comma separated parts of a line that are notes rather than preparation.
+var notePrefixes = []string{
+	"to ", "for ", "or ", "plus ", "if ", "about ", "such as ", "preferably ", "see ",
+	"optional", "divided", "as needed", "at room temperature", "room temperature",
+}
+
+// Parse an ingredient line into its quantity, unit, name, preparation and notes, e.g.
+// "2 1/2 cups finely chopped yellow onions (about 2)". Quantities may be fractions,
+// unicode fractions or ranges such as "2-3", and metric units may be attached to the
+// number as in "200g". Lines without a leading quantity are kept as the name.
+//
+// Parameters:
+//   - text: Ingredient line
+//
+// Returns:
+//   - Parsed line
+func Parse(text string) Line {
+	line := Line{Text: text}
+	notes := []string{}
+	preparation := []string{}
+
+	// Parenthesized asides are notes
+	for _, match := range parenthetical.FindAllStringSubmatch(text, -1) {
+		if note := strings.TrimSpace(match[1]); note != "" {
+			notes = append(notes, note)
+		}
+	}
+	text = parenthetical.ReplaceAllString(text, " ")
+
+	// Parts after a comma say how the ingredient is prepared, or add notes
+	parts := strings.Split(text, ", ")
+	for _, part := range parts[1:] {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if isNote(part) {
+			notes = append(notes, part)
+		} else {
+			preparation = append(preparation, part)
+		}
+	}
+
+	words := splitGluedUnits(strings.Fields(rangeDash.ReplaceAllString(spaceFractions(parts[0]), "$1 - $2")))
+	if quantity, used := parseQuantity(words); used > 0 {
+		line.Quantity = &quantity
+		words = words[used:]
+		if len(words) > 1 && (words[0] == "-" || strings.EqualFold(words[0], "to")) {
+			if max, used := parseQuantity(words[1:]); used > 0 && max > quantity {
+				line.MaxQuantity = &max
+				words = words[1+used:]
+			}
+		}
+
+		if unit, used := parseUnit(words); used > 0 {
+			if unit.Name != "" {
+				line.Unit = &unit.Name
+			}
+			words = words[used:]
+			if len(words) > 1 && strings.EqualFold(words[0], "of") {
+				words = words[1:]
+			}
+		}
+	}
+
+	if leading := leadingPreparation(words); leading > 0 {
+		preparation = append([]string{strings.Join(words[:leading], " ")}, preparation...)
+		words = words[leading:]
+	}
+	line.Name = strings.Join(words, " ")
+	line.Preparation = strings.Join(preparation, ", ")
+	line.Notes = strings.Join(notes, "; ")
+	return line
+}
+
+// Check whether a comma separated part of a line is a note, e.g. "to taste".
+func isNote(part string) bool {
+	lower := strings.ToLower(part)
+	for _, prefix := range notePrefixes {
+		if strings.HasPrefix(lower, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Split numbers from units attached to them, e.g. "200g" into "200" and "g".
+func splitGluedUnits(words []string) []string {
+	split := []string{}
+	for _, word := range words {
+		if match := gluedUnit.FindStringSubmatch(word); match != nil {
+			if _, ok := units.Lookup(match[2]); ok {
+				split = append(split, match[1], match[2])
+				continue
+			}
+		}
+		split = append(split, word)
+	}
+	return split
+}
+
+// Count the words at the start of a name that describe preparation, e.g. "finely chopped"
+// in "finely chopped onions". At least one word is always left for the name.
+//
+// Parameters:
+//   - words: Words of the name
+//
+// Returns:
+//   - Number of preparation words, 0 if there are none
+func leadingPreparation(words []string) int {
+	count := 0
+	for i, word := range words[:max(0, len(words)-1)] {
+		word = strings.ToLower(strings.TrimSuffix(word, ","))
+		if preparationWords[word] {
+			count = i + 1
+			continue
+		}
+		if !preparationQualifiers[word] {
+			break
+		}
+	}
+	return count
+}
+
+// Parse text that is only a quantity, e.g. "1 1/2" or "¾".
+//
+// Parameters:
+//   - text: Quantity as text
+//
+// Returns:
+//   - Tuple of the quantity and whether the whole text was one
+func ParseQuantity(text string) (float64, bool) {
+	words := strings.Fields(spaceFractions(text))
+	quantity, used := parseQuantity(words)
+	return quantity, used > 0 && used == len(words)
+}
+
+// Separate unicode fractions from the digits around them, so "1½" reads as "1 ½".
+func spaceFractions(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if _, ok := vulgarFractions[r]; ok {
+			b.WriteString(" " + string(r) + " ")
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Parse a single number: an integer, decimal, fraction such as 3/4, or unicode fraction.
+//
+// Parameters:
+//   - word: Word to parse
+//
+// Returns:
+//   - Value of the number, and whether the word was one
+func parseNumber(word string) (float64, bool) {
+	runes := []rune(word)
+	if len(runes) == 1 {
+		if value, ok := vulgarFractions[runes[0]]; ok {
+			return value, true
+		}
+	}
+	if numerator, denominator, ok := strings.Cut(word, "/"); ok {
+		n, err := strconv.ParseFloat(numerator, 64)
+		if err != nil {
+			return 0, false
+		}
+		d, err := strconv.ParseFloat(denominator, 64)
+		if err != nil || d == 0 {
+			return 0, false
+		}
+		return n / d, true
+	}
+	if word == "" || !unicode.IsDigit(runes[0]) && runes[0] != '.' {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(word, ",", "."), 64)
+	return value, err == nil
+}
+
+// Parse the quantity at the start of a line, including mixed numbers like "1 1/2".
+//
+// Parameters:
+//   - words: Words of the line
+//
+// Returns:
+//   - Tuple of the quantity and how many words it used, 0 if there is none
+func parseQuantity(words []string) (float64, int) {
+	if len(words) == 0 {
+		return 0, 0
+	}
+	whole, ok := parseNumber(words[0])
+	if !ok {
+		return 0, 0
+	}
+	if len(words) > 1 && whole == float64(int(whole)) && !strings.Contains(words[0], "/") {
+		if fraction, ok := parseNumber(words[1]); ok && fraction < 1 {
+			return whole + fraction, 2
+		}
+	}
+	return whole, 1
+}
+
+// Parse the unit following a quantity, trying two word units such as "fl oz" first.
+//
+// Parameters:
+//   - words: Words of the line after the quantity
+//
+// Returns:
+//   - Tuple of the unit and how many words it used, 0 if there is none
+func parseUnit(words []string) (units.Unit, int) {
+	if len(words) < 2 {
+		// A lone word is the ingredient itself, e.g. "2 eggs"
+		return units.Unit{}, 0
+	}
+	if unit, ok := units.Lookup(words[0] + " " + words[1]); ok && len(words) > 2 {
+		return unit, 2
+	}
+	if words[0] == "" {
+		return units.Unit{}, 0
+	}
+	if unit, ok := units.Lookup(words[0]); ok {
+		return unit, 1
+	}
+	return units.Unit{}, 0
+}
+
+// An ingredient line whose name, preparation and notes are braced, e.g. "1 {whole chicken}"
+// or "2 cup {flour}, {sifted} ({for dusting})".
+var bracedLine = regexp.MustCompile(`^([^{}]*)\{([^{}]*)\}(?:, \{([^{}]*)\})?(?: \(\{([^{}]*)\}\))?$`)
+
+// Parse an ingredient line that may mark some of its parts to be read exactly as written,
+// as the Markdown format does. A bracketed word after the quantity is the unit, e.g.
+// "2 [handful] spinach", and braces around the name, preparation and notes keep them from
+// being split further, e.g. "1 {whole chicken}" or "1 tsp {salt, kosher}, {crushed}".
+//
+// Parameters:
+//   - text: Ingredient line
+//
+// Returns:
+//   - Parsed line
+func ParseMarked(text string) Line {
+	if match := bracedLine.FindStringSubmatch(text); match != nil {
+		if line, ok := parseHead(match[1]); ok {
+			line.Text = text
+			line.Name = match[2]
+			line.Preparation = match[3]
+			line.Notes = match[4]
+			return line
+		}
+	}
+
+	line := Parse(text)
+	if line.Unit != nil || !strings.HasPrefix(line.Name, "[") {
+		return line
+	}
+	unit, name, ok := strings.Cut(line.Name[1:], "]")
+	if !ok || strings.TrimSpace(unit) == "" {
+		return line
+	}
+	unit = strings.TrimSpace(unit)
+	line.Unit = &unit
+	line.Name = strings.TrimSpace(name)
+	return line
+}
+
+// Parse the quantity and unit written before a braced name, e.g. "1 1/2 [handful]".
+//
+// Parameters:
+//   - head: Text before the name
+//
+// Returns:
+//   - Line holding the quantity and unit, and whether the text was only those
+func parseHead(head string) (Line, bool) {
+	line := Line{}
+	words := strings.Fields(spaceFractions(head))
+	if quantity, used := parseQuantity(words); used > 0 {
+		line.Quantity = &quantity
+		words = words[used:]
+	}
+	rest := strings.Join(words, " ")
+	switch {
+	case rest == "":
+	case strings.HasPrefix(rest, "[") && strings.HasSuffix(rest, "]") && len(rest) > 2:
+		unit := strings.TrimSpace(rest[1 : len(rest)-1])
+		line.Unit = &unit
+	default:
+		unit, ok := units.Lookup(rest)
+		if !ok || line.Quantity == nil {
+			return Line{}, false
+		}
+		if unit.Name != "" {
+			line.Unit = &unit.Name
+		}
+	}
+	return line, true
+}
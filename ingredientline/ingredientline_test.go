@@ -0,0 +1,110 @@
+package ingredientline
+
+import (
+	"math"
+	"testing"
+)
+
+// Parts of a parsed line to compare, with nil quantities and units as -1 and "".
+type parts struct {
+	quantity    float64
+	maxQuantity float64
+	unit        string
+	name        string
+	preparation string
+	notes       string
+}
+
+func partsOf(line Line) parts {
+	p := parts{quantity: -1, maxQuantity: -1, name: line.Name, preparation: line.Preparation, notes: line.Notes}
+	if line.Quantity != nil {
+		p.quantity = math.Round(*line.Quantity*1000) / 1000
+	}
+	if line.MaxQuantity != nil {
+		p.maxQuantity = *line.MaxQuantity
+	}
+	if line.Unit != nil {
+		p.unit = *line.Unit
+	}
+	return p
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want parts
+	}{
+		{"1 1/2 cups flour, sifted", parts{1.5, -1, "cup", "flour", "sifted", ""}},
+		{"2 1/2 cups finely chopped yellow onions (about 2)", parts{2.5, -1, "cup", "yellow onions", "finely chopped", "about 2"}},
+		{"½ tsp salt", parts{0.5, -1, "tsp", "salt", "", ""}},
+		{"2-3 cloves garlic, minced", parts{2, 3, "", "cloves garlic", "minced", ""}},
+		{"200g butter, softened", parts{200, -1, "g", "butter", "softened", ""}},
+		{"salt, to taste", parts{-1, -1, "", "salt", "", "to taste"}},
+		{"3 eggs", parts{3, -1, "", "eggs", "", ""}},
+		{"1 whole chicken", parts{1, -1, "", "chicken", "", ""}},
+		{"400 g crushed tomatoes", parts{400, -1, "g", "tomatoes", "crushed", ""}},
+		{"1 (14 oz) can tomatoes", parts{1, -1, "", "can tomatoes", "", "14 oz"}},
+		{"2 large eggs, beaten", parts{2, -1, "", "large eggs", "beaten", ""}},
+		{"pinch of salt", parts{-1, -1, "", "pinch of salt", "", ""}},
+		{"1.5 kg potatoes, peeled and diced", parts{1.5, -1, "kg", "potatoes", "peeled and diced", ""}},
+		{"2 tbsp. olive oil", parts{2, -1, "tbsp", "olive oil", "", ""}},
+		{"1 cup packed brown sugar", parts{1, -1, "cup", "brown sugar", "packed", ""}},
+	}
+	for _, test := range tests {
+		line := Parse(test.text)
+		if got := partsOf(line); got != test.want {
+			t.Errorf("Parse(%q) = %+v; want %+v", test.text, got, test.want)
+		}
+		if line.Text != test.text {
+			t.Errorf("Parse(%q).Text = %q", test.text, line.Text)
+		}
+	}
+}
+
+func TestParseMarked(t *testing.T) {
+	tests := []struct {
+		text string
+		want parts
+	}{
+		{"1 1/2 [handful] spinach", parts{1.5, -1, "handful", "spinach", "", ""}},
+		{"[pinch] salt", parts{-1, -1, "pinch", "salt", "", ""}},
+		{"1 {whole chicken}", parts{1, -1, "", "whole chicken", "", ""}},
+		{"400 g {crushed tomatoes}", parts{400, -1, "g", "crushed tomatoes", "", ""}},
+		{"1 tsp {salt, kosher}, {crushed} ({to taste})", parts{1, -1, "tsp", "salt, kosher", "crushed", "to taste"}},
+		{"0.3 [cups] {butter (salted)}", parts{0.3, -1, "cups", "butter (salted)", "", ""}},
+		{"{7 up}", parts{-1, -1, "", "7 up", "", ""}},
+		{"2 cups flour, sifted", parts{2, -1, "cup", "flour", "sifted", ""}},
+		// Text before the braces that is not an amount is read as a plain line
+		{"fresh {basil}", parts{-1, -1, "", "fresh {basil}", "", ""}},
+	}
+	for _, test := range tests {
+		if got := partsOf(ParseMarked(test.text)); got != test.want {
+			t.Errorf("ParseMarked(%q) = %+v; want %+v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseQuantity(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+		ok   bool
+	}{
+		{"1", 1, true},
+		{"1/2", 0.5, true},
+		{"1 1/2", 1.5, true},
+		{"½", 0.5, true},
+		{"1½", 1.5, true},
+		{"0.25", 0.25, true},
+		{"1,5", 1.5, true},
+		{"1/0", 0, false},
+		{"abc", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseQuantity(test.text)
+		if ok != test.ok || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("ParseQuantity(%q) = %v, %v; want %v, %v", test.text, got, ok, test.want, test.ok)
+		}
+	}
+}
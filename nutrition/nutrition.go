@@ -0,0 +1,132 @@
+// Compute the nutrition of recipes from per ingredient nutrient data.
+package nutrition
+
+import (
+	"github.com/zldobbs/ambrosia-server/graph/model"
+	"github.com/zldobbs/ambrosia-server/units"
+)
+
+// Get pointers to every nutrient of a Nutrition, in a fixed order.
+//
+// Parameters:
+//   - n: Nutrition to address
+//
+// Returns:
+//   - Array of pointers to each nutrient field
+func Fields(n *model.Nutrition) []*float64 {
+	return []*float64{
+		&n.Calories,
+		&n.ProteinG,
+		&n.FatG,
+		&n.SaturatedFatG,
+		&n.CarbohydratesG,
+		&n.SugarG,
+		&n.FiberG,
+		&n.SodiumMg,
+		&n.CholesterolMg,
+		&n.PotassiumMg,
+		&n.CalciumMg,
+		&n.IronMg,
+		&n.VitaminAUg,
+		&n.VitaminCMg,
+		&n.VitaminDUg,
+	}
+}
+
+// Convert an amount of an ingredient to grams.
+//
+// Parameters:
+//   - quantity: Amount of the ingredient
+//   - unit: Unit the amount is written in
+//   - gramsPerMl: Density of the ingredient, needed for volumes
+//   - gramsEach: Weight of one item, needed for counts
+//
+// Returns:
+//   - Weight in grams, and whether the conversion was possible
+func Grams(quantity float64, unit string, gramsPerMl *float64, gramsEach *float64) (float64, bool) {
+	u, ok := units.Lookup(unit)
+	if !ok {
+		return 0, false
+	}
+	switch u.Dimension {
+	case units.Mass:
+		return quantity * u.Factor, true
+	case units.Volume:
+		if gramsPerMl == nil {
+			return 0, false
+		}
+		return quantity * u.Factor * *gramsPerMl, true
+	default:
+		if gramsEach == nil {
+			return 0, false
+		}
+		return quantity * u.Factor * *gramsEach, true
+	}
+}
+
+// Total the nutrition of a recipe's ingredient lines.
+//
+// Parameters:
+//   - lines: Ingredient lines of the recipe
+//   - per100g: Nutrients per 100 g, keyed by ingredient ID
+//
+// Returns:
+//   - Nutrition of the whole recipe, flagging lines that could not be counted
+func ForLines(lines []*model.RecipeIngredient, per100g map[string]*model.Nutrition) *model.RecipeNutrition {
+	result := &model.RecipeNutrition{
+		Nutrients:        &model.Nutrition{},
+		UnconvertedLines: []*model.RecipeIngredient{},
+		MissingNutrition: []*model.Ingredient{},
+	}
+	totals := Fields(result.Nutrients)
+
+	for _, line := range lines {
+		facts, ok := per100g[line.Ingredient.IngredientID]
+		if !ok {
+			result.MissingNutrition = append(result.MissingNutrition, line.Ingredient)
+			continue
+		}
+		if line.Quantity == nil {
+			result.UnconvertedLines = append(result.UnconvertedLines, line)
+			continue
+		}
+		unit := ""
+		if line.Unit != nil {
+			unit = *line.Unit
+		}
+		grams, ok := Grams(*line.Quantity, unit, line.Ingredient.GramsPerMl, line.Ingredient.GramsEach)
+		if !ok {
+			result.UnconvertedLines = append(result.UnconvertedLines, line)
+			continue
+		}
+		for i, amount := range Fields(facts) {
+			*totals[i] += *amount * grams / 100
+		}
+	}
+
+	for _, total := range totals {
+		*total = units.Round(*total)
+	}
+	return result
+}
+
+// Split the nutrition of a recipe into servings.
+//
+// Parameters:
+//   - total: Nutrition of the whole recipe
+//   - servings: Number of servings the recipe makes
+//
+// Returns:
+//   - Nutrition of a single serving
+func PerServing(total *model.RecipeNutrition, servings int) *model.RecipeNutrition {
+	perServing := &model.RecipeNutrition{
+		Nutrients:        &model.Nutrition{},
+		UnconvertedLines: total.UnconvertedLines,
+		MissingNutrition: total.MissingNutrition,
+	}
+	fields := Fields(perServing.Nutrients)
+	for i, amount := range Fields(total.Nutrients) {
+		*fields[i] = units.Round(*amount / float64(servings))
+	}
+	return perServing
+}
@@ -0,0 +1,211 @@
+package rest
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Path parameters, e.g. {id}, written the same way by ServeMux patterns and OpenAPI.
+var pathParameter = regexp.MustCompile(`\{(\w+)\}`)
+
+// Values of the enums used in bodies, which reflection cannot find.
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(model.Allergen("")): enumValues(model.AllAllergen),
+	reflect.TypeOf(model.Diet("")):     enumValues(model.AllDiet),
+}
+
+// List the values of an enum as strings.
+//
+// Parameters:
+//   - all: Every value of the enum
+//
+// Returns:
+//   - The values as strings
+func enumValues[T ~string](all []T) []string {
+	values := []string{}
+	for _, value := range all {
+		values = append(values, string(value))
+	}
+	return values
+}
+
+// Builds the component schemas of the document from Go types.
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+// Describe a Go type as an OpenAPI schema, adding named structs to the components.
+//
+// Parameters:
+//   - t: Type to describe
+//
+// Returns:
+//   - The schema, or a reference to it
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	if values, ok := enums[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := g.schema(t.Elem())
+		if _, ok := schema["$ref"]; ok {
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// Claim the name first, in case the struct refers to itself
+			g.schemas[name] = nil
+			g.schemas[name] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// Describe a struct as an object schema. Fields without omitempty are required.
+//
+// Parameters:
+//   - t: Struct type to describe
+//
+// Returns:
+//   - The object schema
+func (g *schemaGenerator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		schema := g.schema(field.Type)
+		if format := field.Tag.Get("format"); format != "" {
+			schema["format"] = format
+		}
+		if doc := field.Tag.Get("doc"); doc != "" {
+			// Siblings of a reference are ignored, so it is wrapped to carry a description
+			if _, ok := schema["$ref"]; ok {
+				schema = map[string]interface{}{"allOf": []interface{}{schema}}
+			}
+			schema["description"] = doc
+		}
+		properties[name] = schema
+		if options != "omitempty" {
+			required = append(required, name)
+		}
+	}
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+	return object
+}
+
+// Describe a JSON body.
+//
+// Parameters:
+//   - schema: Schema of the body
+//
+// Returns:
+//   - Content map for a request body or response
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+}
+
+// Generate the OpenAPI 3 document describing the API, from its routes and body types.
+//
+// Returns:
+//   - The document, ready to encode as JSON
+func OpenAPI() map[string]interface{} {
+	g := &schemaGenerator{schemas: map[string]interface{}{}}
+	errorResponse := map[string]interface{}{
+		"description": "The request failed",
+		"content":     jsonContent(g.schema(reflect.TypeOf(Error{}))),
+	}
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		parameters := []interface{}{}
+		for _, match := range pathParameter.FindAllStringSubmatch(route.path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, param := range route.query {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        param.name,
+				"in":          "query",
+				"description": param.description,
+				"schema":      param.schema,
+			})
+		}
+
+		success := map[string]interface{}{"description": http.StatusText(route.status)}
+		if route.response != nil {
+			success["content"] = jsonContent(g.schema(reflect.TypeOf(route.response)))
+		}
+		operation := map[string]interface{}{
+			"operationId": route.operationID,
+			"tags":        []string{route.tag},
+			"summary":     route.summary,
+			"parameters":  parameters,
+			"responses": map[string]interface{}{
+				strconv.Itoa(route.status): success,
+				"default":                  errorResponse,
+			},
+		}
+		if route.request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(g.schema(reflect.TypeOf(route.request))),
+			}
+		}
+
+		if paths[route.path] == nil {
+			paths[route.path] = map[string]interface{}{}
+		}
+		paths[route.path].(map[string]interface{})[strings.ToLower(route.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Ambrosia",
+			"version":     "1.0.0",
+			"description": "Recipes and ingredients over plain JSON. Durations are ISO-8601, e.g. PT1H30M.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"basicAuth": map[string]interface{}{"type": "http", "scheme": "basic"},
+			},
+		},
+		// Anonymous requests may read public recipes and ingredients
+		"security": []interface{}{
+			map[string]interface{}{"basicAuth": []string{}},
+			map[string]interface{}{},
+		},
+	}
+}
@@ -0,0 +1,423 @@
+// Serve recipes and ingredients as plain JSON for clients that cannot speak GraphQL.
+// Handlers go through the GraphQL resolvers, so both APIs share the same data layer and
+// authorization; requests authenticate with HTTP basic auth as they do for /graphql.
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/graph"
+)
+
+// Route the OpenAPI document is served from.
+const OpenAPIPath = "/api/openapi.json"
+
+// Items per page unless a limit is given, and the most allowed.
+const (
+	defaultLimit = 50
+	maxLimit     = 200
+)
+
+// Largest request body accepted, in bytes.
+const maxBodyBytes = 1 << 20
+
+// A query parameter of a route.
+type parameter struct {
+	name        string
+	description string
+	schema      map[string]interface{}
+}
+
+// Pagination parameters of the list routes.
+var pageParameters = []parameter{
+	{"limit", fmt.Sprintf("Items per page, at most %d", maxLimit), map[string]interface{}{"type": "integer", "minimum": 1, "maximum": maxLimit, "default": defaultLimit}},
+	{"offset", "Items to skip", map[string]interface{}{"type": "integer", "minimum": 0, "default": 0}},
+}
+
+// An endpoint of the API, with what the OpenAPI document says about it.
+type route struct {
+	method      string
+	path        string
+	operationID string
+	tag         string
+	summary     string
+	query       []parameter
+	// Zero values of the request and response bodies, nil for none
+	request  interface{}
+	response interface{}
+	status   int
+	handle   func(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request)
+}
+
+var routes = []route{
+	{http.MethodGet, "/api/recipes", "listRecipes", "recipes", "List the recipes you may read", pageParameters, nil, RecipePage{}, http.StatusOK, listRecipes},
+	{http.MethodPost, "/api/recipes", "createRecipe", "recipes", "Create a recipe", nil, RecipeInput{}, Recipe{}, http.StatusCreated, createRecipe},
+	{http.MethodGet, "/api/recipes/{id}", "getRecipe", "recipes", "Get a recipe with its steps", nil, nil, Recipe{}, http.StatusOK, getRecipe},
+	{http.MethodPatch, "/api/recipes/{id}", "updateRecipe", "recipes", "Change the fields of a recipe that are given", nil, RecipeUpdate{}, Recipe{}, http.StatusOK, updateRecipe},
+	{http.MethodDelete, "/api/recipes/{id}", "deleteRecipe", "recipes", "Delete a recipe", nil, nil, nil, http.StatusNoContent, deleteRecipe},
+	{http.MethodGet, "/api/ingredients", "listIngredients", "ingredients", "List the ingredients you may read", pageParameters, nil, IngredientPage{}, http.StatusOK, listIngredients},
+	{http.MethodPost, "/api/ingredients", "createIngredient", "ingredients", "Create an ingredient", nil, IngredientInput{}, Ingredient{}, http.StatusCreated, createIngredient},
+	{http.MethodGet, "/api/ingredients/{id}", "getIngredient", "ingredients", "Get an ingredient", nil, nil, Ingredient{}, http.StatusOK, getIngredient},
+	{http.MethodPatch, "/api/ingredients/{id}", "updateIngredient", "ingredients", "Change the fields of an ingredient that are given", nil, IngredientUpdate{}, Ingredient{}, http.StatusOK, updateIngredient},
+	{http.MethodDelete, "/api/ingredients/{id}", "deleteIngredient", "ingredients", "Delete an ingredient no recipe uses", nil, nil, nil, http.StatusNoContent, deleteIngredient},
+}
+
+// Mount the API's routes, and its OpenAPI document, on a mux.
+//
+// Parameters:
+//   - mux: Mux to mount the routes on
+//   - resolver: GraphQL resolver the handlers go through
+//   - middleware: Middleware to wrap each handler in, e.g. authentication
+func Register(mux *http.ServeMux, resolver *graph.Resolver, middleware func(http.Handler) http.Handler) {
+	for _, route := range routes {
+		mux.Handle(route.method+" "+route.path, middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route.handle(resolver, w, r)
+		})))
+	}
+
+	document, err := json.MarshalIndent(OpenAPI(), "", "  ")
+	if err != nil {
+		log.Fatal("Error generating OpenAPI document: ", err)
+	}
+	mux.HandleFunc("GET "+OpenAPIPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(document); err != nil {
+			log.Println("Error writing response:", err)
+		}
+	})
+}
+
+// Write a JSON response.
+//
+// Parameters:
+//   - w: Response to write to
+//   - status: HTTP status to answer with
+//   - body: Value to encode
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Println("Error writing response:", err)
+	}
+}
+
+// Choose the HTTP status to answer an error with from its kind, and the message to show.
+// Errors of no known kind are failures of the server, so their message is withheld and the
+// caller should log them. Shared with the other HTTP routes so they answer alike.
+//
+// Parameters:
+//   - err: Error to report
+//
+// Returns:
+//   - HTTP status and message to answer with
+func ErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		return http.StatusUnauthorized, err.Error()
+	case errors.Is(err, apperr.ErrForbidden):
+		return http.StatusForbidden, err.Error()
+	case errors.Is(err, apperr.ErrNotFound):
+		return http.StatusNotFound, err.Error()
+	case errors.Is(err, apperr.ErrConflict):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, apperr.ErrInvalid):
+		return http.StatusBadRequest, err.Error()
+	}
+	return http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)
+}
+
+// Answer with an error, choosing the status from its kind. Errors of no known kind are
+// logged rather than shown.
+//
+// Parameters:
+//   - w: Response to write to
+//   - err: Error to report
+func writeError(w http.ResponseWriter, err error) {
+	status, message := ErrorStatus(err)
+	switch status {
+	case http.StatusUnauthorized:
+		w.Header().Set("WWW-Authenticate", `Basic realm="ambrosia"`)
+	case http.StatusInternalServerError:
+		log.Println("Error serving REST request:", err)
+	}
+	writeJSON(w, status, Error{Error: message})
+}
+
+// Read the ID in a route's path, e.g. the 7 of /api/recipes/7.
+//
+// Parameters:
+//   - r: Request to read
+//
+// Returns:
+//   - The ID, or an error if it is not a positive integer
+func pathID(r *http.Request) (string, error) {
+	id := r.PathValue("id")
+	if parsed, err := strconv.ParseInt(id, 10, 32); err != nil || parsed < 1 {
+		return "", apperr.Invalid("id must be a positive integer, not %q", id)
+	}
+	return id, nil
+}
+
+// Decode a JSON request body, rejecting fields the body does not have.
+//
+// Parameters:
+//   - w: Response, used to limit the body's size
+//   - r: Request to read
+//   - into: Value to decode into
+//
+// Returns:
+//   - Error describing what is wrong with the body
+func decode(w http.ResponseWriter, r *http.Request, into interface{}) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(into); err != nil {
+		return apperr.Invalid("invalid request body: %v", err)
+	}
+	return nil
+}
+
+// Read the limit and offset of a list request.
+//
+// Parameters:
+//   - r: Request to read
+//
+// Returns:
+//   - Items per page and items to skip
+func page(r *http.Request) (int, int, error) {
+	limit, offset := defaultLimit, 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			return 0, 0, apperr.Invalid("limit must be between 1 and %d", maxLimit)
+		}
+		limit = parsed
+	}
+	if value := r.URL.Query().Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, apperr.Invalid("offset must not be negative")
+		}
+		offset = parsed
+	}
+	return limit, offset, nil
+}
+
+// Answer with one page of the recipes the requester may read.
+//
+// Parameters:
+//   - resolver: GraphQL resolver, used to load the page
+//   - w: Response to write to
+//   - r: Request being answered, with the limit and offset
+func listRecipes(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := page(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	recipes, total, err := resolver.ReadableRecipes(r.Context(), limit, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body := RecipePage{Items: []Recipe{}, Total: total, Limit: limit, Offset: offset}
+	for _, recipe := range recipes {
+		body.Items = append(body.Items, recipeBody(recipe, nil))
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// Answer with a recipe and its steps.
+//
+// Parameters:
+//   - resolver: GraphQL resolver, used to load the steps
+//   - w: Response to write to
+//   - r: Request being answered
+//   - status: HTTP status to answer with
+//   - recipeID: ID of the recipe
+//   - warnings: Problems with the request that did not stop it
+func writeRecipe(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request, status int, recipeID string, warnings []string) {
+	recipe, err := resolver.Query().RecipeByID(r.Context(), recipeID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	steps, err := resolver.Recipe().Steps(r.Context(), recipe)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body := recipeBody(recipe, steps)
+	body.Warnings = warnings
+	writeJSON(w, status, body)
+}
+
+func getRecipe(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	recipeID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeRecipe(resolver, w, r, http.StatusOK, recipeID, nil)
+}
+
+func createRecipe(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	user, err := auth.RequireUser(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var body RecipeInput
+	if err := decode(w, r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	input, err := body.model(user.UserID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ctx, warnings := graph.WithWarnings(r.Context())
+	recipe, err := resolver.Mutation().CreateRecipe(ctx, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/recipes/"+recipe.RecipeID)
+	writeRecipe(resolver, w, r, http.StatusCreated, recipe.RecipeID, warnings.Messages())
+}
+
+func updateRecipe(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	recipeID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var body RecipeUpdate
+	if err := decode(w, r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	input, err := body.model()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ctx, warnings := graph.WithWarnings(r.Context())
+	recipe, err := resolver.Mutation().UpdateRecipe(ctx, recipeID, input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeRecipe(resolver, w, r, http.StatusOK, recipe.RecipeID, warnings.Messages())
+}
+
+func deleteRecipe(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	recipeID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := resolver.Mutation().DeleteRecipe(r.Context(), recipeID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Answer with one page of the ingredients the requester may read.
+//
+// Parameters:
+//   - resolver: GraphQL resolver, used to load the page
+//   - w: Response to write to
+//   - r: Request being answered, with the limit and offset
+func listIngredients(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := page(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ingredients, total, err := resolver.ReadableIngredients(r.Context(), limit, offset)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	body := IngredientPage{Items: []Ingredient{}, Total: total, Limit: limit, Offset: offset}
+	for _, ingredient := range ingredients {
+		body.Items = append(body.Items, ingredientBody(ingredient))
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+func getIngredient(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	ingredientID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ingredient, err := resolver.ReadableIngredient(r.Context(), ingredientID)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ingredientBody(ingredient))
+}
+
+func createIngredient(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	user, err := auth.RequireUser(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var body IngredientInput
+	if err := decode(w, r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	ingredient, err := resolver.Mutation().CreateIngredient(r.Context(), body.model(user.UserID))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/api/ingredients/"+ingredient.IngredientID)
+	writeJSON(w, http.StatusCreated, ingredientBody(ingredient))
+}
+
+func updateIngredient(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	ingredientID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var body IngredientUpdate
+	if err := decode(w, r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	ingredient, err := resolver.Mutation().UpdateIngredient(r.Context(), ingredientID, body.model())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ingredientBody(ingredient))
+}
+
+func deleteIngredient(resolver *graph.Resolver, w http.ResponseWriter, r *http.Request) {
+	ingredientID, err := pathID(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if _, err := resolver.Mutation().DeleteIngredient(r.Context(), ingredientID); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
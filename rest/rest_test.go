@@ -0,0 +1,97 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zldobbs/ambrosia-server/apperr"
+	"github.com/zldobbs/ambrosia-server/auth"
+)
+
+func TestPage(t *testing.T) {
+	tests := []struct {
+		query  string
+		limit  int
+		offset int
+		ok     bool
+	}{
+		{"", defaultLimit, 0, true},
+		{"limit=10&offset=20", 10, 20, true},
+		{"limit=1", 1, 0, true},
+		{fmt.Sprintf("limit=%d", maxLimit), maxLimit, 0, true},
+		{"offset=0", defaultLimit, 0, true},
+		{"limit=0", 0, 0, false},
+		{fmt.Sprintf("limit=%d", maxLimit+1), 0, 0, false},
+		{"limit=ten", 0, 0, false},
+		{"offset=-1", 0, 0, false},
+		{"offset=1.5", 0, 0, false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/recipes?"+test.query, nil)
+		limit, offset, err := page(r)
+		if limit != test.limit || offset != test.offset || (err == nil) != test.ok {
+			t.Errorf("page(%q) = %d, %d, %v; want %d, %d, ok %v", test.query, limit, offset, err, test.limit, test.offset, test.ok)
+		}
+		if err != nil && !errors.Is(err, apperr.ErrInvalid) {
+			t.Errorf("page(%q) error %v is not invalid", test.query, err)
+		}
+	}
+}
+
+func TestPathID(t *testing.T) {
+	tests := []struct {
+		id string
+		ok bool
+	}{
+		{"7", true},
+		{"2147483647", true},
+		{"abc", false},
+		{"0", false},
+		{"-3", false},
+		{"2147483648", false},
+		{"7 OR 1=1", false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/recipes/x", nil)
+		r.SetPathValue("id", test.id)
+		id, err := pathID(r)
+		if (err == nil) != test.ok || (test.ok && id != test.id) {
+			t.Errorf("pathID(%q) = %q, %v; want ok %v", test.id, id, err, test.ok)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{auth.ErrUnauthenticated, http.StatusUnauthorized, "authentication required"},
+		{apperr.Forbidden("not authorized to view this resource"), http.StatusForbidden, "not authorized to view this resource"},
+		{apperr.NotFound("found no recipes with provided id"), http.StatusNotFound, "found no recipes with provided id"},
+		{apperr.Conflict("ingredient is used by a recipe"), http.StatusConflict, "ingredient is used by a recipe"},
+		{apperr.Invalid("limit must be between 1 and %d", maxLimit), http.StatusBadRequest, "limit must be between 1 and 200"},
+		{fmt.Errorf("prepTime: %w", apperr.Invalid("invalid ISO-8601 duration %q", "soon")), http.StatusBadRequest, `prepTime: invalid ISO-8601 duration "soon"`},
+		{fmt.Errorf("failed to get recipes; error: invalid input syntax for type integer"), http.StatusInternalServerError, "Internal Server Error"},
+		{fmt.Errorf("something unexpected"), http.StatusInternalServerError, "Internal Server Error"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		writeError(w, test.err)
+		var body Error
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("writeError(%v) wrote invalid JSON: %v", test.err, err)
+		}
+		if w.Code != test.status || body.Error != test.message {
+			t.Errorf("writeError(%v) = %d %q; want %d %q", test.err, w.Code, body.Error, test.status, test.message)
+		}
+		if challenge := w.Header().Get("WWW-Authenticate"); (challenge != "") != (test.status == http.StatusUnauthorized) {
+			t.Errorf("writeError(%v) WWW-Authenticate = %q", test.err, challenge)
+		}
+	}
+}
@@ -0,0 +1,451 @@
+package rest
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Bodies served and accepted by the REST API. Fields tagged omitempty are optional in requests;
+// the OpenAPI document is generated from these types, using the doc and format tags.
+
+// Body of every error response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// An ingredient line of a recipe.
+type RecipeLine struct {
+	IngredientID string   `json:"ingredientId"`
+	Name         string   `json:"name"`
+	Quantity     *float64 `json:"quantity"`
+	Unit         *string  `json:"unit"`
+	Preparation  *string  `json:"preparation"`
+	Notes        *string  `json:"notes"`
+}
+
+// A step of a recipe.
+type Step struct {
+	Position int     `json:"position"`
+	Text     string  `json:"text"`
+	Duration *string `json:"duration" format:"duration"`
+	ImageURL *string `json:"imageUrl"`
+}
+
+// A recipe as served by the API.
+type Recipe struct {
+	ID            string           `json:"id"`
+	Name          string           `json:"name"`
+	Description   string           `json:"description"`
+	UserID        string           `json:"userId"`
+	HouseholdID   *string          `json:"householdId"`
+	Servings      *int             `json:"servings"`
+	PrepTime      *string          `json:"prepTime" format:"duration"`
+	CookTime      *string          `json:"cookTime" format:"duration"`
+	RestTime      *string          `json:"restTime" format:"duration"`
+	TotalTime     *string          `json:"totalTime" format:"duration"`
+	SourceURL     *string          `json:"sourceUrl"`
+	Categories    []string         `json:"categories"`
+	Rating        *int             `json:"rating" doc:"Out of 5"`
+	ImageURL      *string          `json:"imageUrl"`
+	Ingredients   []RecipeLine     `json:"ingredients"`
+	Steps         []Step           `json:"steps,omitempty" doc:"Left out of lists"`
+	Allergens     []model.Allergen `json:"allergens"`
+	DietaryLabels []model.Diet     `json:"dietaryLabels"`
+	Warnings      []string         `json:"warnings,omitempty" doc:"Problems with a create or update that did not stop it, e.g. ingredient lines that were skipped"`
+}
+
+// One page of the recipes you may read, out of the total.
+type RecipePage struct {
+	Items  []Recipe `json:"items"`
+	Total  int      `json:"total"`
+	Limit  int      `json:"limit"`
+	Offset int      `json:"offset"`
+}
+
+// An ingredient line of a recipe being saved, using an existing ingredient.
+type RecipeLineInput struct {
+	IngredientID string   `json:"ingredientId"`
+	Quantity     *float64 `json:"quantity,omitempty"`
+	Unit         *string  `json:"unit,omitempty"`
+	Preparation  *string  `json:"preparation,omitempty"`
+	Notes        *string  `json:"notes,omitempty"`
+}
+
+// A step of a recipe being saved.
+type StepInput struct {
+	Text     string  `json:"text"`
+	Duration *string `json:"duration,omitempty" format:"duration"`
+}
+
+// A recipe to create, for the requesting user.
+type RecipeInput struct {
+	Name            string            `json:"name"`
+	Description     string            `json:"description,omitempty"`
+	HouseholdID     *string           `json:"householdId,omitempty"`
+	Servings        *int              `json:"servings,omitempty"`
+	PrepTime        *string           `json:"prepTime,omitempty" format:"duration"`
+	CookTime        *string           `json:"cookTime,omitempty" format:"duration"`
+	RestTime        *string           `json:"restTime,omitempty" format:"duration"`
+	SourceURL       *string           `json:"sourceUrl,omitempty"`
+	Categories      []string          `json:"categories,omitempty"`
+	Rating          *int              `json:"rating,omitempty" doc:"Out of 5"`
+	Ingredients     []RecipeLineInput `json:"ingredients,omitempty"`
+	IngredientLines []string          `json:"ingredientLines,omitempty" doc:"Lines such as \"2 cups flour\", matched to ingredients by name"`
+	Steps           []StepInput       `json:"steps,omitempty"`
+}
+
+// Changes to a recipe; fields left out stay as they are.
+type RecipeUpdate struct {
+	Name            *string           `json:"name,omitempty"`
+	Description     *string           `json:"description,omitempty"`
+	HouseholdID     *string           `json:"householdId,omitempty" doc:"Moves the recipe into a household"`
+	Servings        *int              `json:"servings,omitempty"`
+	PrepTime        *string           `json:"prepTime,omitempty" format:"duration"`
+	CookTime        *string           `json:"cookTime,omitempty" format:"duration"`
+	RestTime        *string           `json:"restTime,omitempty" format:"duration"`
+	Categories      []string          `json:"categories,omitempty" doc:"Replaces the categories"`
+	Rating          *int              `json:"rating,omitempty" doc:"Out of 5"`
+	Ingredients     []RecipeLineInput `json:"ingredients,omitempty" doc:"Replaces the ingredient lines"`
+	IngredientLines []string          `json:"ingredientLines,omitempty" doc:"Replaces the ingredient lines, matched to ingredients by name"`
+	Steps           []StepInput       `json:"steps,omitempty" doc:"Replaces the steps"`
+}
+
+// An ingredient as served by the API.
+type Ingredient struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Category    *string          `json:"category"`
+	UserID      string           `json:"userId"`
+	HouseholdID *string          `json:"householdId"`
+	GramsPerMl  *float64         `json:"gramsPerMl"`
+	GramsEach   *float64         `json:"gramsEach"`
+	Allergens   []model.Allergen `json:"allergens"`
+	Diets       []model.Diet     `json:"diets"`
+}
+
+// One page of the ingredients you may read, out of the total.
+type IngredientPage struct {
+	Items  []Ingredient `json:"items"`
+	Total  int          `json:"total"`
+	Limit  int          `json:"limit"`
+	Offset int          `json:"offset"`
+}
+
+// An ingredient to create, for the requesting user.
+type IngredientInput struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Category    *string          `json:"category,omitempty"`
+	HouseholdID *string          `json:"householdId,omitempty"`
+	GramsPerMl  *float64         `json:"gramsPerMl,omitempty"`
+	GramsEach   *float64         `json:"gramsEach,omitempty"`
+	Allergens   []model.Allergen `json:"allergens,omitempty"`
+	Diets       []model.Diet     `json:"diets,omitempty"`
+}
+
+// Changes to an ingredient; fields left out stay as they are.
+type IngredientUpdate struct {
+	Name        *string          `json:"name,omitempty"`
+	Description *string          `json:"description,omitempty"`
+	Category    *string          `json:"category,omitempty"`
+	GramsPerMl  *float64         `json:"gramsPerMl,omitempty"`
+	GramsEach   *float64         `json:"gramsEach,omitempty"`
+	Allergens   []model.Allergen `json:"allergens,omitempty" doc:"Replaces the allergens"`
+	Diets       []model.Diet     `json:"diets,omitempty" doc:"Replaces the diets"`
+}
+
+// Format an optional duration in ISO-8601.
+//
+// Parameters:
+//   - d: Duration, if any
+//
+// Returns:
+//   - ISO-8601 duration, if any
+func formatDuration(d *time.Duration) *string {
+	if d == nil {
+		return nil
+	}
+	formatted := model.FormatDuration(*d)
+	return &formatted
+}
+
+// Parse an optional ISO-8601 duration.
+//
+// Parameters:
+//   - field: Name of the field, for the error
+//   - s: ISO-8601 duration, if any
+//
+// Returns:
+//   - Duration, if any
+func parseDuration(field string, s *string) (*time.Duration, error) {
+	if s == nil {
+		return nil, nil
+	}
+	d, err := model.ParseDuration(*s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	return &d, nil
+}
+
+// Convert a recipe to its REST body.
+//
+// Parameters:
+//   - recipe: Recipe to convert
+//   - steps: The recipe's steps, or nil to leave them out
+//
+// Returns:
+//   - REST body of the recipe
+func recipeBody(recipe *model.Recipe, steps []*model.RecipeStep) Recipe {
+	body := Recipe{
+		ID:            recipe.RecipeID,
+		Name:          recipe.Name,
+		Description:   recipe.Description,
+		Servings:      recipe.Servings,
+		PrepTime:      formatDuration(recipe.PrepTime),
+		CookTime:      formatDuration(recipe.CookTime),
+		RestTime:      formatDuration(recipe.RestTime),
+		TotalTime:     formatDuration(recipe.TotalTime),
+		SourceURL:     recipe.SourceURL,
+		Categories:    append([]string{}, recipe.Categories...),
+		Rating:        recipe.Rating,
+		Ingredients:   []RecipeLine{},
+		Allergens:     append([]model.Allergen{}, recipe.Allergens...),
+		DietaryLabels: append([]model.Diet{}, recipe.DietaryLabels...),
+	}
+	if recipe.User != nil {
+		body.UserID = recipe.User.UserID
+	}
+	if recipe.Household != nil {
+		body.HouseholdID = &recipe.Household.HouseholdID
+	}
+	if recipe.Image != nil {
+		body.ImageURL = &recipe.Image.URL
+	}
+	for _, line := range recipe.IngredientLines {
+		body.Ingredients = append(body.Ingredients, RecipeLine{
+			IngredientID: line.Ingredient.IngredientID,
+			Name:         line.Ingredient.Name,
+			Quantity:     line.Quantity,
+			Unit:         line.Unit,
+			Preparation:  line.Preparation,
+			Notes:        line.Notes,
+		})
+	}
+	if steps != nil {
+		body.Steps = []Step{}
+		for _, step := range steps {
+			converted := Step{Position: step.Position, Text: step.Text, Duration: formatDuration(step.Duration)}
+			if step.Image != nil {
+				converted.ImageURL = &step.Image.URL
+			}
+			body.Steps = append(body.Steps, converted)
+		}
+	}
+	return body
+}
+
+// Convert an ingredient to its REST body.
+//
+// Parameters:
+//   - ingredient: Ingredient to convert
+//
+// Returns:
+//   - REST body of the ingredient
+func ingredientBody(ingredient *model.Ingredient) Ingredient {
+	body := Ingredient{
+		ID:          ingredient.IngredientID,
+		Name:        ingredient.Name,
+		Description: ingredient.Description,
+		Category:    ingredient.Category,
+		GramsPerMl:  ingredient.GramsPerMl,
+		GramsEach:   ingredient.GramsEach,
+		Allergens:   append([]model.Allergen{}, ingredient.Allergens...),
+		Diets:       append([]model.Diet{}, ingredient.Diets...),
+	}
+	if ingredient.User != nil {
+		body.UserID = ingredient.User.UserID
+	}
+	if ingredient.Household != nil {
+		body.HouseholdID = &ingredient.Household.HouseholdID
+	}
+	return body
+}
+
+// Convert ingredient lines of a request to their GraphQL input.
+//
+// Parameters:
+//   - lines: Lines of the request
+//
+// Returns:
+//   - GraphQL input of the lines
+func lineInputs(lines []RecipeLineInput) []*model.ExistingIngredientID {
+	if lines == nil {
+		return nil
+	}
+	converted := []*model.ExistingIngredientID{}
+	for _, line := range lines {
+		converted = append(converted, &model.ExistingIngredientID{
+			IngredientID: line.IngredientID,
+			Quantity:     line.Quantity,
+			Unit:         line.Unit,
+			Preparation:  line.Preparation,
+			Notes:        line.Notes,
+		})
+	}
+	return converted
+}
+
+// Convert steps of a request to their GraphQL input.
+//
+// Parameters:
+//   - steps: Steps of the request
+//
+// Returns:
+//   - GraphQL input of the steps
+func stepInputs(steps []StepInput) ([]*model.NewRecipeStep, error) {
+	if steps == nil {
+		return nil, nil
+	}
+	converted := []*model.NewRecipeStep{}
+	for i, step := range steps {
+		duration, err := parseDuration(fmt.Sprintf("steps[%d].duration", i), step.Duration)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, &model.NewRecipeStep{Text: step.Text, Duration: duration})
+	}
+	return converted, nil
+}
+
+// Parse the prep, cook and rest times of a request.
+//
+// Parameters:
+//   - prep: Prep time, if any
+//   - cook: Cook time, if any
+//   - rest: Rest time, if any
+//
+// Returns:
+//   - Parsed prep, cook and rest times
+func parseTimes(prep, cook, rest *string) (*time.Duration, *time.Duration, *time.Duration, error) {
+	prepTime, err := parseDuration("prepTime", prep)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	cookTime, err := parseDuration("cookTime", cook)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	restTime, err := parseDuration("restTime", rest)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return prepTime, cookTime, restTime, nil
+}
+
+// Convert a request creating a recipe to its GraphQL input.
+//
+// Parameters:
+//   - userID: ID of the user creating the recipe
+//
+// Returns:
+//   - GraphQL input of the recipe
+func (input RecipeInput) model(userID string) (model.NewRecipe, error) {
+	prepTime, cookTime, restTime, err := parseTimes(input.PrepTime, input.CookTime, input.RestTime)
+	if err != nil {
+		return model.NewRecipe{}, err
+	}
+	steps, err := stepInputs(input.Steps)
+	if err != nil {
+		return model.NewRecipe{}, err
+	}
+	ingredients := lineInputs(input.Ingredients)
+	if ingredients == nil {
+		ingredients = []*model.ExistingIngredientID{}
+	}
+	return model.NewRecipe{
+		Name:            input.Name,
+		Description:     input.Description,
+		Ingredients:     ingredients,
+		IngredientLines: input.IngredientLines,
+		UserID:          userID,
+		HouseholdID:     input.HouseholdID,
+		Servings:        input.Servings,
+		PrepTime:        prepTime,
+		CookTime:        cookTime,
+		RestTime:        restTime,
+		Steps:           steps,
+		SourceURL:       input.SourceURL,
+		Categories:      input.Categories,
+		Rating:          input.Rating,
+	}, nil
+}
+
+// Convert a request updating a recipe to its GraphQL input.
+//
+// Returns:
+//   - GraphQL input of the changes
+func (input RecipeUpdate) model() (model.UpdateRecipe, error) {
+	prepTime, cookTime, restTime, err := parseTimes(input.PrepTime, input.CookTime, input.RestTime)
+	if err != nil {
+		return model.UpdateRecipe{}, err
+	}
+	steps, err := stepInputs(input.Steps)
+	if err != nil {
+		return model.UpdateRecipe{}, err
+	}
+	return model.UpdateRecipe{
+		Name:            input.Name,
+		Description:     input.Description,
+		Ingredients:     lineInputs(input.Ingredients),
+		IngredientLines: input.IngredientLines,
+		HouseholdID:     input.HouseholdID,
+		Servings:        input.Servings,
+		PrepTime:        prepTime,
+		CookTime:        cookTime,
+		RestTime:        restTime,
+		Steps:           steps,
+		Categories:      input.Categories,
+		Rating:          input.Rating,
+	}, nil
+}
+
+// Convert a request creating an ingredient to its GraphQL input.
+//
+// Parameters:
+//   - userID: ID of the user creating the ingredient
+//
+// Returns:
+//   - GraphQL input of the ingredient
+func (input IngredientInput) model(userID string) model.NewIngredient {
+	return model.NewIngredient{
+		Name:        input.Name,
+		Description: input.Description,
+		Category:    input.Category,
+		UserID:      userID,
+		HouseholdID: input.HouseholdID,
+		GramsPerMl:  input.GramsPerMl,
+		GramsEach:   input.GramsEach,
+		Allergens:   input.Allergens,
+		Diets:       input.Diets,
+	}
+}
+
+// Convert a request updating an ingredient to its GraphQL input.
+//
+// Returns:
+//   - GraphQL input of the changes
+func (input IngredientUpdate) model() model.UpdateIngredient {
+	return model.UpdateIngredient{
+		Name:        input.Name,
+		Description: input.Description,
+		Category:    input.Category,
+		GramsPerMl:  input.GramsPerMl,
+		GramsEach:   input.GramsEach,
+		Allergens:   input.Allergens,
+		Diets:       input.Diets,
+	}
+}
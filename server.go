@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/zldobbs/ambrosia-server/auth"
+	"github.com/zldobbs/ambrosia-server/blob"
 	"github.com/zldobbs/ambrosia-server/db"
 	"github.com/zldobbs/ambrosia-server/graph"
+	"github.com/zldobbs/ambrosia-server/rest"
+	"github.com/zldobbs/ambrosia-server/webhooks"
 )
 
 // Middleware function that logs when a URL is requested.
@@ -28,24 +40,74 @@ func logMiddleware(next http.Handler) http.Handler {
 }
 
 // Middleware function for handling authentication.
+// Requests authenticate with HTTP basic auth using their user name and password,
+// and the matching user is attached to the request context for the resolvers.
+// Requests without credentials proceed anonymously.
 // If authentication fails, reject proceeding to the next handler.
 //
 // Parameters:
+//   - pool: Database connection pool used to check credentials
 //   - next: Next HTTP handler to call after this
 //
 // Returns:
 //   - This handler function as middleware
-func authMiddleware(next http.Handler) http.Handler {
+func authMiddleware(pool *pgxpool.Pool, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// TODO: Implement actual authorization checks
-		if false {
-			http.Error(w, "Forbidden: Access Denied", http.StatusForbidden)
+		name, password, ok := r.BasicAuth()
+		if !ok {
+			next.ServeHTTP(w, r)
 			return
 		}
-		next.ServeHTTP(w, r)
+
+		user, err := db.GetUserByCredentials(pool, r.Context(), name, password)
+		if err != nil {
+			log.Println("Error checking credentials:", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if user == nil {
+			http.Error(w, "Unauthorized: Invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user)))
 	})
 }
 
+// Authenticate a GraphQL websocket connection from its connection_init payload, since browsers
+// cannot set headers on websocket requests. The payload carries the same value as the
+// Authorization header, e.g. {"Authorization": "Basic SmltOnNlY3JldA=="}.
+// Connections without credentials proceed as they are, anonymously unless authMiddleware
+// already authenticated the upgrade request.
+//
+// Parameters:
+//   - pool: Database connection pool used to check credentials
+//
+// Returns:
+//   - Function checking a connection's credentials
+func websocketInit(pool *pgxpool.Pool) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		header := payload.Authorization()
+		if header == "" {
+			return ctx, nil, nil
+		}
+		request := http.Request{Header: http.Header{"Authorization": {header}}}
+		name, password, ok := request.BasicAuth()
+		if !ok {
+			return nil, nil, fmt.Errorf("expected basic auth credentials")
+		}
+
+		user, err := db.GetUserByCredentials(pool, ctx, name, password)
+		if err != nil {
+			log.Println("Error checking credentials:", err)
+			return nil, nil, fmt.Errorf("could not check credentials")
+		}
+		if user == nil {
+			return nil, nil, fmt.Errorf("invalid credentials")
+		}
+		return auth.WithUser(ctx, user), nil, nil
+	}
+}
+
 // Middleware function for enabling CORS on target routes
 //
 // Parameters:
@@ -57,7 +119,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// TODO: CORS should be restricted in production!
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		// Handle preflight requests
@@ -71,9 +133,28 @@ func corsMiddleware(next http.Handler) http.Handler {
 }
 
 const defaultPort = "8080"
+const defaultImageDir = "data/images"
+
+// Open the store uploaded images are kept in, on local disk under AMBROSIA_IMAGE_DIR.
+//
+// Returns:
+//   - The image store
+func openImageStore() (*blob.LocalStore, error) {
+	imageDir := os.Getenv("AMBROSIA_IMAGE_DIR")
+	if imageDir == "" {
+		imageDir = defaultImageDir
+	}
+	return blob.NewLocalStore(imageDir)
+}
 
 // Main entrypoint; will handle launching the HTTP server.
 func main() {
+	// Run a subcommand instead of the server if one was given
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// TODO: Consider using gorilla/mux
 	mux := http.NewServeMux()
 
@@ -86,19 +167,54 @@ func main() {
 	pool := db.InitDB()
 	defer pool.Close()
 
+	store, err := openImageStore()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	// GraphQL Server (using gqlgen)
-	gql_server := handler.NewDefaultServer(
+	resolver := graph.NewResolver(pool, store, os.Getenv("AMBROSIA_PUBLIC_URL"))
+	// Local receivers are only for development; otherwise webhooks could reach into the server's network
+	resolver.WEBHOOKS_ALLOW_PRIVATE, _ = strconv.ParseBool(os.Getenv("AMBROSIA_WEBHOOKS_ALLOW_PRIVATE"))
+	go resolver.EVENTS.Listen(context.Background())
+	go webhooks.NewDispatcher(pool, resolver.WEBHOOKS_ALLOW_PRIVATE).Run(context.Background())
+	// As handler.NewDefaultServer, with websocket connections authenticated for subscriptions
+	gql_server := handler.New(
 		graph.NewExecutableSchema(
-			graph.Config{Resolvers: &graph.Resolver{DB_POOL: pool}},
+			graph.Config{Resolvers: resolver},
 		),
 	)
+	gql_server.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              websocketInit(pool),
+	})
+	gql_server.AddTransport(transport.Options{})
+	gql_server.AddTransport(transport.GET{})
+	gql_server.AddTransport(transport.POST{})
+	gql_server.AddTransport(transport.MultipartForm{})
+	gql_server.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+	gql_server.Use(extension.Introspection{})
+	gql_server.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New[string](100),
+	})
 
 	// Public routes
 	mux.HandleFunc("/", indexHandler)
 	mux.HandleFunc("/heartbeat", heartbeatHandler)
+	mux.HandleFunc("GET /images/{id}", imageHandler(store, false))
+	mux.HandleFunc("GET /images/{id}/thumbnail", imageHandler(store, true))
+	mux.Handle("GET /recipes/{file}", authMiddleware(pool, recipeFileHandler(resolver)))
+	mux.Handle("GET /cookbook.pdf", authMiddleware(pool, cookbookHandler(resolver, cookbookFormats["pdf"])))
+	mux.Handle("GET /cookbook.epub", authMiddleware(pool, cookbookHandler(resolver, cookbookFormats["epub"])))
 
 	// Protected routes
-	mux.Handle("/graphql", authMiddleware(gql_server))
+	mux.Handle("GET /csv/{table}", authMiddleware(pool, csvHandler(resolver)))
+	mux.Handle("POST /csv/{table}", authMiddleware(pool, csvHandler(resolver)))
+	mux.Handle("GET /account/export.zip", authMiddleware(pool, accountExportHandler(resolver)))
+	mux.Handle("/graphql", authMiddleware(pool, gql_server))
+	rest.Register(mux, resolver, func(next http.Handler) http.Handler {
+		return authMiddleware(pool, next)
+	})
 
 	// Wrap all handlers with logging and cors middleware
 	loggedMux := logMiddleware(mux)
@@ -0,0 +1,104 @@
+package units
+
+import (
+	"math"
+	"sort"
+)
+
+// An amount paired with the name of its unit.
+type Amount struct {
+	Amount float64
+	Unit   string
+}
+
+// Running total of quantities of a single ingredient, measured in possibly
+// different units. Amounts of the same dimension are summed together, e.g.
+// "2 tbsp" and "1/4 cup" combine into "6 tbsp". Amounts in units that are not
+// recognized are only summed with amounts written in the same unit.
+type Total struct {
+	base    map[Dimension]float64
+	systems map[Dimension]System
+	other   map[string]float64
+}
+
+// Create an empty total.
+//
+// Returns:
+//   - Total with nothing added to it
+func NewTotal() *Total {
+	return &Total{
+		base:    map[Dimension]float64{},
+		systems: map[Dimension]System{},
+		other:   map[string]float64{},
+	}
+}
+
+// Add an amount to the total.
+//
+// Parameters:
+//   - amount: Amount to add
+//   - unit: Unit the amount is written in
+func (t *Total) Add(amount float64, unit string) {
+	u, ok := Lookup(unit)
+	if !ok {
+		t.other[unit] += amount
+		return
+	}
+	system, seen := t.systems[u.Dimension]
+	if !seen || u.System == USCustomary {
+		// Mixing systems displays in US customary units, since the
+		// metric amounts are then the least likely to round nicely
+		system = u.System
+	}
+	t.systems[u.Dimension] = system
+	t.base[u.Dimension] += amount * u.Factor
+}
+
+// Subtract an amount from the total, never going below zero.
+// Amounts that cannot be converted to anything in the total are ignored.
+//
+// Parameters:
+//   - amount: Amount to subtract
+//   - unit: Unit the amount is written in
+func (t *Total) Subtract(amount float64, unit string) {
+	u, ok := Lookup(unit)
+	if !ok {
+		if remaining, ok := t.other[unit]; ok {
+			t.other[unit] = math.Max(0, remaining-amount)
+		}
+		return
+	}
+	if base, ok := t.base[u.Dimension]; ok {
+		t.base[u.Dimension] = math.Max(0, base-amount*u.Factor)
+	}
+}
+
+// Get the combined amounts, one per dimension or unrecognized unit.
+// Amounts that have been used up entirely are left out.
+//
+// Returns:
+//   - Array of amounts in readable units
+func (t *Total) Amounts() []Amount {
+	amounts := []Amount{}
+	for _, dimension := range []Dimension{Count, Mass, Volume} {
+		base, ok := t.base[dimension]
+		if !ok || base <= 0 {
+			continue
+		}
+		amount, unit := Humanize(base, dimension, t.systems[dimension])
+		amounts = append(amounts, Amount{Amount: amount, Unit: unit.Name})
+	}
+
+	other := make([]string, 0, len(t.other))
+	for unit := range t.other {
+		other = append(other, unit)
+	}
+	sort.Strings(other)
+	for _, unit := range other {
+		if t.other[unit] <= 0 {
+			continue
+		}
+		amounts = append(amounts, Amount{Amount: Round(t.other[unit]), Unit: unit})
+	}
+	return amounts
+}
@@ -0,0 +1,247 @@
+// Recognize cooking units and convert quantities between them.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Kind of physical quantity a unit measures.
+type Dimension int
+
+const (
+	// Counted items, e.g. "2 eggs"
+	Count Dimension = iota
+	// Mass, measured in grams
+	Mass
+	// Volume, measured in milliliters
+	Volume
+)
+
+// Measurement system a unit belongs to, used to pick display units.
+type System int
+
+const (
+	Metric System = iota
+	USCustomary
+)
+
+// A unit of measure.
+type Unit struct {
+	// Canonical name of the unit, e.g. "tbsp"
+	Name      string
+	Dimension Dimension
+	System    System
+	// Number of base units (g, ml or items) in one of this unit
+	Factor float64
+}
+
+var (
+	Each       = Unit{"", Count, Metric, 1}
+	Gram       = Unit{"g", Mass, Metric, 1}
+	Kilogram   = Unit{"kg", Mass, Metric, 1000}
+	Milligram  = Unit{"mg", Mass, Metric, 0.001}
+	Ounce      = Unit{"oz", Mass, USCustomary, 28.349523125}
+	Pound      = Unit{"lb", Mass, USCustomary, 453.59237}
+	Milliliter = Unit{"ml", Volume, Metric, 1}
+	Centiliter = Unit{"cl", Volume, Metric, 10}
+	Deciliter  = Unit{"dl", Volume, Metric, 100}
+	Liter      = Unit{"l", Volume, Metric, 1000}
+	Teaspoon   = Unit{"tsp", Volume, USCustomary, 4.92892159375}
+	Tablespoon = Unit{"tbsp", Volume, USCustomary, 14.78676478125}
+	FluidOunce = Unit{"fl oz", Volume, USCustomary, 29.5735295625}
+	Cup        = Unit{"cup", Volume, USCustomary, 236.5882365}
+	Pint       = Unit{"pint", Volume, USCustomary, 473.176473}
+	Quart      = Unit{"quart", Volume, USCustomary, 946.352946}
+	Gallon     = Unit{"gallon", Volume, USCustomary, 3785.411784}
+)
+
+// Spellings accepted for each unit, matched case-insensitively.
+var aliases = map[string]Unit{
+	"":             Each,
+	"each":         Each,
+	"ea":           Each,
+	"whole":        Each,
+	"piece":        Each,
+	"pieces":       Each,
+	"g":            Gram,
+	"gr":           Gram,
+	"gm":           Gram,
+	"gms":          Gram,
+	"gram":         Gram,
+	"grams":        Gram,
+	"gramme":       Gram,
+	"grammes":      Gram,
+	"kg":           Kilogram,
+	"kgs":          Kilogram,
+	"kilo":         Kilogram,
+	"kilos":        Kilogram,
+	"kilogram":     Kilogram,
+	"kilograms":    Kilogram,
+	"mg":           Milligram,
+	"milligram":    Milligram,
+	"milligrams":   Milligram,
+	"oz":           Ounce,
+	"ounce":        Ounce,
+	"ounces":       Ounce,
+	"lb":           Pound,
+	"lbs":          Pound,
+	"pound":        Pound,
+	"pounds":       Pound,
+	"ml":           Milliliter,
+	"mls":          Milliliter,
+	"milliliter":   Milliliter,
+	"milliliters":  Milliliter,
+	"millilitre":   Milliliter,
+	"millilitres":  Milliliter,
+	"cc":           Milliliter,
+	"cl":           Centiliter,
+	"centiliter":   Centiliter,
+	"centilitre":   Centiliter,
+	"dl":           Deciliter,
+	"deciliter":    Deciliter,
+	"decilitre":    Deciliter,
+	"l":            Liter,
+	"lt":           Liter,
+	"ltr":          Liter,
+	"ltrs":         Liter,
+	"liter":        Liter,
+	"liters":       Liter,
+	"litre":        Liter,
+	"litres":       Liter,
+	"t":            Teaspoon,
+	"tsp":          Teaspoon,
+	"tsps":         Teaspoon,
+	"teaspoon":     Teaspoon,
+	"teaspoons":    Teaspoon,
+	"T":            Tablespoon,
+	"tbs":          Tablespoon,
+	"tbsp":         Tablespoon,
+	"tbsps":        Tablespoon,
+	"tablespoon":   Tablespoon,
+	"tablespoons":  Tablespoon,
+	"fl oz":        FluidOunce,
+	"floz":         FluidOunce,
+	"fluid ounce":  FluidOunce,
+	"fluid ounces": FluidOunce,
+	"c":            Cup,
+	"cup":          Cup,
+	"cups":         Cup,
+	"pt":           Pint,
+	"pint":         Pint,
+	"pints":        Pint,
+	"qt":           Quart,
+	"quart":        Quart,
+	"quarts":       Quart,
+	"gal":          Gallon,
+	"gallon":       Gallon,
+	"gallons":      Gallon,
+}
+
+// Display units for each dimension and system, largest first.
+var displayUnits = map[Dimension]map[System][]Unit{
+	Mass: {
+		Metric:      {Kilogram, Gram},
+		USCustomary: {Pound, Ounce},
+	},
+	Volume: {
+		Metric:      {Liter, Milliliter},
+		USCustomary: {Cup, Tablespoon, Teaspoon},
+	},
+}
+
+// Look up a unit by one of its spellings.
+// "T" and "t" are the conventional abbreviations for tablespoon and teaspoon,
+// so they are matched case-sensitively before falling back to lower case.
+//
+// Parameters:
+//   - name: Unit as written in a recipe, e.g. "Tbsp." or "cups"
+//
+// Returns:
+//   - Matching unit and whether it was recognized
+func Lookup(name string) (Unit, bool) {
+	name = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	if unit, ok := aliases[name]; ok {
+		return unit, true
+	}
+	unit, ok := aliases[strings.ToLower(name)]
+	return unit, ok
+}
+
+// Convert an amount between two units of the same dimension.
+//
+// Parameters:
+//   - amount: Amount in the from unit
+//   - from: Unit the amount is measured in
+//   - to: Unit to convert to
+//
+// Returns:
+//   - Amount measured in the to unit
+func Convert(amount float64, from Unit, to Unit) (float64, error) {
+	if from.Dimension != to.Dimension {
+		return 0, fmt.Errorf("cannot convert %q to %q", from.Name, to.Name)
+	}
+	return amount * from.Factor / to.Factor, nil
+}
+
+// Convert an amount between two units given by name.
+// Units that are not recognized can only be converted to themselves.
+//
+// Parameters:
+//   - amount: Amount in the from unit
+//   - from: Name of the unit the amount is measured in
+//   - to: Name of the unit to convert to
+//
+// Returns:
+//   - Amount measured in the to unit, and whether the conversion was possible
+func ConvertNamed(amount float64, from string, to string) (float64, bool) {
+	if strings.EqualFold(strings.TrimSpace(from), strings.TrimSpace(to)) {
+		return amount, true
+	}
+	fromUnit, ok := Lookup(from)
+	if !ok {
+		return 0, false
+	}
+	toUnit, ok := Lookup(to)
+	if !ok {
+		return 0, false
+	}
+	converted, err := Convert(amount, fromUnit, toUnit)
+	return converted, err == nil
+}
+
+// Pick a readable unit for an amount given in base units (g, ml or items).
+// The largest unit of the system that keeps the amount at or above one is used.
+//
+// Parameters:
+//   - base: Amount in base units
+//   - dimension: Dimension of the amount
+//   - system: Measurement system to display in
+//
+// Returns:
+//   - Tuple of the amount rounded for display and its unit
+func Humanize(base float64, dimension Dimension, system System) (float64, Unit) {
+	candidates := displayUnits[dimension][system]
+	if len(candidates) == 0 {
+		return Round(base), Each
+	}
+	for _, unit := range candidates {
+		if base/unit.Factor >= 1 {
+			return Round(base / unit.Factor), unit
+		}
+	}
+	smallest := candidates[len(candidates)-1]
+	return Round(base / smallest.Factor), smallest
+}
+
+// Round an amount to two decimal places.
+//
+// Parameters:
+//   - amount: Amount to round
+//
+// Returns:
+//   - Rounded amount
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,114 @@
+package units
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		want Unit
+		ok   bool
+	}{
+		{"cups", Cup, true},
+		{"CUPS", Cup, true},
+		{"Tbsp.", Tablespoon, true},
+		{"T", Tablespoon, true},
+		{"t", Teaspoon, true},
+		{" fl oz ", FluidOunce, true},
+		{"", Each, true},
+		{"handful", Unit{}, false},
+	}
+	for _, test := range tests {
+		got, ok := Lookup(test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("Lookup(%q) = %v, %v; want %v, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestConvertNamed(t *testing.T) {
+	tests := []struct {
+		amount float64
+		from   string
+		to     string
+		want   float64
+		ok     bool
+	}{
+		{1, "cup", "tbsp", 16, true},
+		{2, "lb", "oz", 32, true},
+		{1, "kg", "g", 1000, true},
+		{3, "tsp", "tbsp", 1, true},
+		{2, "Cups", "cups", 2, true},
+		{2, "handful", "Handful", 2, true},
+		{1, "handful", "cup", 0, false},
+		{1, "cup", "g", 0, false},
+	}
+	for _, test := range tests {
+		got, ok := ConvertNamed(test.amount, test.from, test.to)
+		if ok != test.ok || math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("ConvertNamed(%v, %q, %q) = %v, %v; want %v, %v", test.amount, test.from, test.to, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	tests := []struct {
+		base      float64
+		dimension Dimension
+		system    System
+		want      float64
+		unit      Unit
+	}{
+		{1500, Mass, Metric, 1.5, Kilogram},
+		{250, Mass, Metric, 250, Gram},
+		{0.5, Mass, Metric, 0.5, Gram},
+		{236.5882365, Volume, USCustomary, 1, Cup},
+		{3 * 14.78676478125, Volume, USCustomary, 3, Tablespoon},
+		{3, Count, Metric, 3, Each},
+	}
+	for _, test := range tests {
+		got, unit := Humanize(test.base, test.dimension, test.system)
+		if got != test.want || unit != test.unit {
+			t.Errorf("Humanize(%v, %v, %v) = %v %v; want %v %v", test.base, test.dimension, test.system, got, unit.Name, test.want, test.unit.Name)
+		}
+	}
+}
+
+func TestTotal(t *testing.T) {
+	type change struct {
+		amount   float64
+		unit     string
+		subtract bool
+	}
+	tests := []struct {
+		name    string
+		changes []change
+		want    []Amount
+	}{
+		{"same dimension", []change{{2, "tbsp", false}, {0.25, "cup", false}}, []Amount{{6, "tbsp"}}},
+		{"metric", []change{{500, "g", false}, {1, "kg", false}}, []Amount{{1.5, "kg"}}},
+		{"mixed systems", []change{{100, "g", false}, {1, "lb", false}}, []Amount{{1.22, "lb"}}},
+		{"counted", []change{{2, "", false}, {1, "whole", false}}, []Amount{{3, ""}}},
+		{"unrecognized", []change{{2, "handful", false}, {1, "handful", false}, {1, "pinch", false}}, []Amount{{3, "handful"}, {1, "pinch"}}},
+		{"dimensions in order", []change{{1, "cup", false}, {2, "", false}, {100, "g", false}}, []Amount{{2, ""}, {100, "g"}, {1, "cup"}}},
+		{"subtract", []change{{1, "cup", false}, {2, "tbsp", true}}, []Amount{{14, "tbsp"}}},
+		{"subtract everything", []change{{1, "tsp", false}, {1, "cup", true}}, []Amount{}},
+		{"subtract unrelated", []change{{1, "cup", false}, {1, "pinch", true}, {5, "g", true}}, []Amount{{1, "cup"}}},
+	}
+	for _, test := range tests {
+		total := NewTotal()
+		for _, c := range test.changes {
+			if c.subtract {
+				total.Subtract(c.amount, c.unit)
+			} else {
+				total.Add(c.amount, c.unit)
+			}
+		}
+		if got := total.Amounts(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: Amounts() = %v; want %v", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"github.com/zldobbs/ambrosia-server/apperr"
+)
+
+// Ranges that are not reachable on the public internet, besides those netip reports as
+// loopback, private, link-local, multicast or unspecified.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+// Check whether an address is on the public internet, so a request to it cannot reach
+// services inside the server's own network such as localhost or cloud metadata endpoints.
+//
+// Parameters:
+//   - addr: Address to check
+//
+// Returns:
+//   - Whether the address is public
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Check that every address a webhook host resolves to is public. Deliveries check the
+// address again when they connect, since the host may resolve differently by then.
+//
+// Parameters:
+//   - ctx: Context of the lookup
+//   - host: Host name or IP address of the webhook URL, without a port
+//
+// Returns:
+//   - Error if the host cannot be resolved or resolves to a non-public address
+func CheckHost(ctx context.Context, host string) error {
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else {
+		addrs, err = net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil || len(addrs) == 0 {
+			return apperr.Invalid("webhook url host could not be resolved")
+		}
+	}
+	for _, addr := range addrs {
+		if !isPublic(addr) {
+			return apperr.Invalid("webhook url must not point to a local or private address")
+		}
+	}
+	return nil
+}
+
+// Refuse connections to non-public addresses; used as a net.Dialer's Control, which sees the
+// address actually being dialed after DNS resolution.
+//
+// Parameters:
+//   - network: Network being dialed
+//   - address: Resolved IP address and port
+//   - conn: Connection not yet connected
+//
+// Returns:
+//   - Error if the address is not public
+func dialPublic(network string, address string, conn syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("failed to parse dialed address %q; error: %v", address, err)
+	}
+	if !isPublic(addrPort.Addr()) {
+		return fmt.Errorf("refusing to deliver to non-public address %s", addrPort.Addr())
+	}
+	return nil
+}
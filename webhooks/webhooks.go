@@ -0,0 +1,282 @@
+// Send events recorded in the webhook outbox to the URLs users registered for them.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/zldobbs/ambrosia-server/db"
+	"github.com/zldobbs/ambrosia-server/graph/model"
+)
+
+// Headers sent with each delivery. The signature is "sha256=" followed by the hex HMAC-SHA256,
+// keyed with the webhook's secret, of the timestamp, a dot and the request body.
+const (
+	EventHeader     = "X-Ambrosia-Event"
+	DeliveryHeader  = "X-Ambrosia-Delivery"
+	TimestampHeader = "X-Ambrosia-Timestamp"
+	SignatureHeader = "X-Ambrosia-Signature"
+)
+
+// How often the outbox and due deliveries are checked.
+const pollInterval = 5 * time.Second
+
+// Events queued, or deliveries sent, per round trip to the database.
+const batchSize = 20
+
+// How long a receiver has to answer.
+const requestTimeout = 10 * time.Second
+
+// How long a claimed delivery is held before another server may send it; longer than a request takes.
+const claimLease = time.Minute
+
+// Attempts made before a delivery is given up on; retries are spread over about four hours.
+const maxAttempts = 10
+
+// Wait before the first retry, doubling after each failure.
+const firstRetryDelay = 30 * time.Second
+
+// How long delivered events are kept in the delivery log.
+const retention = 30 * 24 * time.Hour
+
+// Body POSTed to a webhook.
+type delivery struct {
+	// ID of the event, the same for every webhook it is sent to
+	ID        string             `json:"id"`
+	Event     model.WebhookEvent `json:"event"`
+	CreatedAt time.Time          `json:"createdAt"`
+	Data      json.RawMessage    `json:"data"`
+}
+
+// Dispatcher queues events from the outbox and sends them to webhooks, retrying failures.
+// Any number of servers can run one against the same database.
+type Dispatcher struct {
+	pool   *pgxpool.Pool
+	client *http.Client
+}
+
+// Create a dispatcher; it sends nothing until Run is running.
+//
+// Parameters:
+//   - pool: pgx databse pool connection
+//   - allowPrivate: Whether receivers may be on loopback, private or link-local addresses
+//
+// Returns:
+//   - The dispatcher
+func NewDispatcher(pool *pgxpool.Pool, allowPrivate bool) *Dispatcher {
+	dialer := &net.Dialer{Timeout: requestTimeout}
+	if !allowPrivate {
+		// Checked on the address being dialed, so a host resolving elsewhere after registration is still refused
+		dialer.Control = dialPublic
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would be dialed in place of the receiver, hiding its address from the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Dispatcher{
+		pool: pool,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   requestTimeout,
+			// A redirect is answered like any other non-2xx status
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Create a secret to sign a webhook's requests with.
+//
+// Returns:
+//   - Hex encoded secret
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("failed to create webhook secret; error: %v", err)
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// Sign a request body the way receivers check it.
+//
+// Parameters:
+//   - secret: The webhook's secret
+//   - timestamp: Value of the timestamp header, in Unix seconds
+//   - body: Request body
+//
+// Returns:
+//   - Value of the signature header
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// How long to wait before retrying a delivery that failed.
+//
+// Parameters:
+//   - attempts: Attempts made so far
+//
+// Returns:
+//   - Delay before the next attempt
+func retryDelay(attempts int) time.Duration {
+	return firstRetryDelay << (attempts - 1)
+}
+
+// Queue and send events until the context ends.
+//
+// Parameters:
+//   - ctx: Context to stop with
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var pruned time.Time
+	for {
+		d.queue(ctx)
+		d.send(ctx)
+		if time.Since(pruned) > time.Hour {
+			if _, err := db.PruneWebhookEvents(d.pool, ctx, time.Now().Add(-retention)); err != nil {
+				log.Println("Error pruning webhook events:", err)
+			}
+			pruned = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Queue deliveries for every event waiting in the outbox.
+//
+// Parameters:
+//   - ctx: Context to stop with
+func (d *Dispatcher) queue(ctx context.Context) {
+	for ctx.Err() == nil {
+		queued, err := db.QueueWebhookEvents(d.pool, ctx, batchSize)
+		if err != nil {
+			log.Println("Error queueing webhook events:", err)
+			return
+		}
+		if queued < batchSize {
+			return
+		}
+	}
+}
+
+// Send every delivery that is due.
+//
+// Parameters:
+//   - ctx: Context to stop with
+func (d *Dispatcher) send(ctx context.Context) {
+	for ctx.Err() == nil {
+		attempts, err := db.ClaimWebhookDeliveries(d.pool, ctx, batchSize, claimLease)
+		if err != nil {
+			log.Println("Error claiming webhook deliveries:", err)
+			return
+		}
+		var wg sync.WaitGroup
+		for _, attempt := range attempts {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.attempt(ctx, attempt)
+			}()
+		}
+		wg.Wait()
+		if len(attempts) < batchSize {
+			return
+		}
+	}
+}
+
+// Send a delivery once and record how it went.
+//
+// Parameters:
+//   - ctx: Context to stop with
+//   - attempt: Claimed delivery
+func (d *Dispatcher) attempt(ctx context.Context, attempt *db.WebhookAttempt) {
+	statusCode, err := d.post(ctx, attempt)
+	var statusCodePtr *int
+	if statusCode != 0 {
+		statusCodePtr = &statusCode
+	}
+
+	status := model.WebhookDeliveryStatusSucceeded
+	var attemptError *string
+	var retryIn time.Duration
+	if err != nil {
+		message := err.Error()
+		attemptError = &message
+		status = model.WebhookDeliveryStatusPending
+		retryIn = retryDelay(attempt.Attempts)
+		if attempt.Attempts >= maxAttempts {
+			status = model.WebhookDeliveryStatusFailed
+		}
+	}
+	// Recorded even if the server is stopping, so the attempt is not made again once the lease ends
+	err = db.RecordWebhookAttempt(d.pool, context.WithoutCancel(ctx), attempt.DeliveryID, status, statusCodePtr, attemptError, retryIn)
+	if err != nil {
+		log.Println("Error recording webhook attempt:", err)
+	}
+}
+
+// POST a delivery to its webhook.
+//
+// Parameters:
+//   - ctx: Context to stop with
+//   - attempt: Claimed delivery
+//
+// Returns:
+//   - HTTP status the receiver answered with, or 0 if it did not answer
+//   - Error unless the status was 2xx
+func (d *Dispatcher) post(ctx context.Context, attempt *db.WebhookAttempt) (int, error) {
+	body, err := json.Marshal(delivery{
+		ID:        attempt.EventID,
+		Event:     attempt.Event,
+		CreatedAt: attempt.CreatedAt,
+		Data:      attempt.Payload,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode delivery; error: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, attempt.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request; error: %v", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Ambrosia-Webhooks")
+	req.Header.Set(EventHeader, string(attempt.Event))
+	req.Header.Set(DeliveryHeader, attempt.DeliveryID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(SignatureHeader, Sign(attempt.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Read a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,111 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		secret    string
+		timestamp string
+		body      string
+		want      string
+	}{
+		{"secret", "1767225600", `{"id":"17"}`, "sha256=410aebcf32d1efdde2e09a320438675b9d3e6c44ded960b25018eb6b168d21dc"},
+		{"0123456789abcdef", "1700000000", "hello", "sha256=adc6dd484b44cd99e9825b07b9475d45999b82cf8071c60272358d4a51fe73e9"},
+		{"", "0", "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+	for _, test := range tests {
+		if got := Sign(test.secret, test.timestamp, []byte(test.body)); got != test.want {
+			t.Errorf("Sign(%q, %q, %q) = %q; want %q", test.secret, test.timestamp, test.body, got, test.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{maxAttempts - 1, 30 * time.Second << 8},
+	}
+	for _, test := range tests {
+		if got := retryDelay(test.attempts); got != test.want {
+			t.Errorf("retryDelay(%d) = %v; want %v", test.attempts, got, test.want)
+		}
+	}
+}
+
+func TestIsPublic(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false},
+		{"224.0.0.1", false},
+	}
+	for _, test := range tests {
+		if got := isPublic(netip.MustParseAddr(test.addr)); got != test.want {
+			t.Errorf("isPublic(%s) = %v; want %v", test.addr, got, test.want)
+		}
+	}
+}
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host string
+		ok   bool
+	}{
+		{"93.184.215.14", true},
+		{"127.0.0.1", false},
+		{"169.254.169.254", false},
+		{"::1", false},
+		{"localhost", false},
+	}
+	for _, test := range tests {
+		if err := CheckHost(context.Background(), test.host); (err == nil) != test.ok {
+			t.Errorf("CheckHost(%q) = %v; want ok %v", test.host, err, test.ok)
+		}
+	}
+}
+
+func TestDispatcherRefusesPrivateReceivers(t *testing.T) {
+	received := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer receiver.Close()
+
+	if _, err := NewDispatcher(nil, false).client.Post(receiver.URL, "application/json", nil); err == nil || received {
+		t.Errorf("delivery to %s was sent; want it refused", receiver.URL)
+	}
+	resp, err := NewDispatcher(nil, true).client.Post(receiver.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("delivery to %s with private receivers allowed failed: %v", receiver.URL, err)
+	}
+	resp.Body.Close()
+	if !received {
+		t.Errorf("delivery to %s was not received", receiver.URL)
+	}
+}